@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -22,7 +23,7 @@ import (
 )
 
 func main() {
-	// 清除代理设置（避免数据库连接被代理拦截）
+	// 清除代理设置（避免数据库连接被代理拦截），RestAPI 如需代理请在连接的 proxy 中显式配置
 	os.Unsetenv("http_proxy")
 	os.Unsetenv("https_proxy")
 	os.Unsetenv("HTTP_PROXY")
@@ -35,7 +36,11 @@ func main() {
 	}
 
 	var configPath string
+	var once bool
+	var metricNames string
 	flag.StringVar(&configPath, "config", "configs/config.yml", "配置文件路径")
+	flag.BoolVar(&once, "once", false, "只执行一次采集后退出，配置了 pushgateway.url 时推送结果，有指标失败时以非 0 退出")
+	flag.StringVar(&metricNames, "metrics", "", "配合 -once 使用，只采集逗号分隔的这些指标")
 	flag.Parse()
 
 	cfg, err := config.Load(configPath)
@@ -43,6 +48,10 @@ func main() {
 		log.Fatalf("载入配置失败: %v", err)
 	}
 
+	if once {
+		os.Exit(runOnce(cfg, metricNames))
+	}
+
 	service, err := collectors.NewService(cfg)
 	if err != nil {
 		log.Fatalf("初始化采集服务失败: %v", err)
@@ -145,6 +154,40 @@ func main() {
 	log.Println("采集器已退出。")
 }
 
+// runOnce 执行一次采集，配置了 Pushgateway 时推送结果；有指标失败或推送失败时返回非 0 退出码。
+func runOnce(cfg *config.Config, metricNames string) int {
+	if metricNames != "" {
+		if err := cfg.SelectMetrics(strings.Split(metricNames, ",")); err != nil {
+			log.Printf("选择指标失败: %v", err)
+			return 2
+		}
+	}
+
+	service, err := collectors.NewService(cfg)
+	if err != nil {
+		log.Printf("初始化采集服务失败: %v", err)
+		return 1
+	}
+	defer service.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	exitCode := 0
+	if failed := service.RunOnce(ctx); failed > 0 {
+		log.Printf("单次采集完成，%d 个指标采集失败", failed)
+		exitCode = 1
+	}
+	if cfg.Pushgateway.URL != "" {
+		// 部分指标失败时仍然推送：失败的指标值为 NaN，collector_errors_total 记录了失败次数
+		if err := service.PushToGateway(ctx, cfg.Pushgateway); err != nil {
+			log.Printf("%v", err)
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
 func loadEnv() error {
 	if _, err := os.Stat(".env"); err == nil {
 		if err := godotenv.Load(".env"); err != nil {
@@ -15,10 +15,52 @@ import (
 	"github.com/company/ems-devices/internal/datasource"
 )
 
-// handleGetConfig 获取当前配置。
+// redactedSecret 在 GET /api/config 的响应中替换只能通过配置文件修改的凭据。
+const redactedSecret = "******"
+
+// handleGetConfig 获取当前配置，指标分组的认证凭据以 redactedSecret 代替。
 func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
-	cfg := s.getConfig()
-	s.writeJSON(w, http.StatusOK, cfg)
+	cfg := *s.getConfig()
+	cfg.MetricGroups = redactGroupAuth(cfg.MetricGroups)
+	s.writeJSON(w, http.StatusOK, &cfg)
+}
+
+// redactGroupAuth 返回隐去 bearer_token 与 password 的分组配置副本。
+func redactGroupAuth(groups map[string]config.MetricGroupConfig) map[string]config.MetricGroupConfig {
+	if groups == nil {
+		return nil
+	}
+	redacted := make(map[string]config.MetricGroupConfig, len(groups))
+	for name, group := range groups {
+		if group.BearerToken != "" {
+			group.BearerToken = redactedSecret
+		}
+		if group.Password != "" {
+			group.Password = redactedSecret
+		}
+		redacted[name] = group
+	}
+	return redacted
+}
+
+// preserveGroupAuth 用当前配置中的认证信息覆盖更新请求中的分组：已有分组沿用原来的 bearer_token、username 与 password，
+// 新增分组不能设置认证，带认证的分组被删除时恢复原样，避免经由管理接口取得或放开受保护指标的访问权限。
+func preserveGroupAuth(updated, current map[string]config.MetricGroupConfig) map[string]config.MetricGroupConfig {
+	groups := make(map[string]config.MetricGroupConfig, len(updated))
+	for name, group := range updated {
+		old := current[name]
+		group.BearerToken, group.Username, group.Password = old.BearerToken, old.Username, old.Password
+		groups[name] = group
+	}
+	for name, old := range current {
+		if _, ok := groups[name]; !ok && (old.BearerToken != "" || old.Username != "") {
+			groups[name] = old
+		}
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	return groups
 }
 
 // handleUpdateConfig 更新配置并触发热更新。
@@ -28,6 +70,19 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析配置失败: %v", err))
 		return
 	}
+	// file 白名单与 command 沙箱策略只能通过配置文件修改，避免经由管理接口放开可读取的文件或可执行文件
+	newCfg.File = s.getConfig().File
+	newCfg.Command = s.getConfig().Command
+	// 插件会启动外部进程，同样只能通过配置文件修改
+	newCfg.Plugins = s.getConfig().Plugins
+	// probe 的 DSN 白名单决定服务可以连接哪些主机，同样只能通过配置文件修改
+	newCfg.Probe.AllowedTargets = s.getConfig().Probe.AllowedTargets
+	// 连接发现决定服务会连接哪些主机并使用哪些凭据，同样只能通过配置文件修改
+	newCfg.ConnectionDiscovery = s.getConfig().ConnectionDiscovery
+	// 水位文件路径决定服务会写入哪个文件，同样只能通过配置文件修改
+	newCfg.WatermarkStateFile = s.getConfig().WatermarkStateFile
+	// 指标分组的认证信息决定谁能抓取受保护的指标，同样只能通过配置文件修改
+	newCfg.MetricGroups = preserveGroupAuth(newCfg.MetricGroups, s.getConfig().MetricGroups)
 
 	if err := newCfg.ApplyDefaults(); err != nil {
 		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("应用默认值失败: %v", err))
@@ -88,6 +143,17 @@ func (s *Server) handleGetMetricsURL(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleGroupMetrics 暴露指标分组 /metrics/{group}。
+func (s *Server) handleGroupMetrics(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/metrics/")
+	handler, ok := s.service.GroupHandler(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
 // handleTestMySQL 测试 MySQL 连接。
 func (s *Server) handleTestMySQL(w http.ResponseWriter, r *http.Request) {
 	var mysqlCfg config.MySQLConfig
@@ -228,6 +294,229 @@ func (s *Server) handleTestRestAPI(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleTestModbus 测试 Modbus TCP 连接。
+func (s *Server) handleTestModbus(w http.ResponseWriter, r *http.Request) {
+	var modbusCfg config.ModbusConfig
+	if err := json.NewDecoder(r.Body).Decode(&modbusCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析 Modbus 配置失败: %v", err))
+		return
+	}
+
+	client, err := datasource.NewModbusClient(modbusCfg)
+	if err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer client.Close()
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Modbus 连接测试成功",
+	})
+}
+
+// handleTestMQTT 测试 MQTT Broker 连接。
+func (s *Server) handleTestMQTT(w http.ResponseWriter, r *http.Request) {
+	var mqttCfg config.MQTTConfig
+	if err := json.NewDecoder(r.Body).Decode(&mqttCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析 MQTT 配置失败: %v", err))
+		return
+	}
+
+	client, err := datasource.NewMQTTClient(mqttCfg)
+	if err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer client.Close()
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "MQTT 连接测试成功",
+	})
+}
+
+// handleTestSNMP 测试 SNMP 连接。
+func (s *Server) handleTestSNMP(w http.ResponseWriter, r *http.Request) {
+	var snmpCfg config.SNMPConfig
+	if err := json.NewDecoder(r.Body).Decode(&snmpCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析 SNMP 配置失败: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := datasource.NewSNMPClient(snmpCfg)
+	if err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer client.Close()
+
+	if err := client.Ping(ctx); err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("SNMP 连接测试失败: %v", err),
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "SNMP 连接测试成功",
+	})
+}
+
+// handleTestPrometheus 测试 Prometheus 连接。
+func (s *Server) handleTestPrometheus(w http.ResponseWriter, r *http.Request) {
+	var prometheusCfg config.PrometheusSourceConfig
+	if err := json.NewDecoder(r.Body).Decode(&prometheusCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析 Prometheus 配置失败: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := datasource.NewPrometheusClient(prometheusCfg)
+	if err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer client.Close()
+
+	if err := client.Ping(ctx); err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Prometheus 连接测试失败: %v", err),
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Prometheus 连接测试成功",
+	})
+}
+
+// handleTestMongoDB 测试 MongoDB 连接。
+func (s *Server) handleTestMongoDB(w http.ResponseWriter, r *http.Request) {
+	var mongodbCfg config.MongoDBConfig
+	if err := json.NewDecoder(r.Body).Decode(&mongodbCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析 MongoDB 配置失败: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := datasource.NewMongoDBClient(mongodbCfg)
+	if err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer client.Close()
+
+	if err := client.Ping(ctx); err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("MongoDB 连接测试失败: %v", err),
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "MongoDB 连接测试成功",
+	})
+}
+
+// handleTestElasticsearch 测试 Elasticsearch 连接。
+func (s *Server) handleTestElasticsearch(w http.ResponseWriter, r *http.Request) {
+	var elasticsearchCfg config.ElasticsearchConfig
+	if err := json.NewDecoder(r.Body).Decode(&elasticsearchCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析 Elasticsearch 配置失败: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := datasource.NewElasticsearchClient(elasticsearchCfg)
+	if err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer client.Close()
+
+	if err := client.Ping(ctx); err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Elasticsearch 连接测试失败: %v", err),
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Elasticsearch 连接测试成功",
+	})
+}
+
+// handleTestSQL 测试 SQL 连接。
+func (s *Server) handleTestSQL(w http.ResponseWriter, r *http.Request) {
+	var sqlCfg config.SQLConfig
+	if err := json.NewDecoder(r.Body).Decode(&sqlCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析 SQL 配置失败: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := datasource.NewSQLClient(sqlCfg)
+	if err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer client.Close()
+
+	if err := client.Ping(ctx); err != nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("SQL 连接测试失败: %v", err),
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "SQL 连接测试成功",
+	})
+}
+
 // RestAPIPreviewRequest 用于预览 RestAPI 响应的请求。
 type RestAPIPreviewRequest struct {
 	Config config.RestAPIConfig `json:"config"`
@@ -272,13 +561,28 @@ func (s *Server) handlePreviewRestAPI(w http.ResponseWriter, r *http.Request) {
 
 // QueryPreviewRequest 查询预览请求。
 type QueryPreviewRequest struct {
-	Source      string              `json:"source"`
-	Query       string              `json:"query"`
-	Connection  string              `json:"connection,omitempty"`
-	ResultField string              `json:"result_field,omitempty"`
-	MySQLConfig *config.MySQLConfig `json:"mysql_config,omitempty"`
-	IoTDBConfig *config.IoTDBConfig `json:"iotdb_config,omitempty"`
-	RedisConfig *config.RedisConfig `json:"redis_config,omitempty"`
+	Source              string                         `json:"source"`
+	Query               string                         `json:"query"`
+	Connection          string                         `json:"connection,omitempty"`
+	ResultField         string                         `json:"result_field,omitempty"`
+	MySQLConfig         *config.MySQLConfig            `json:"mysql_config,omitempty"`
+	IoTDBConfig         *config.IoTDBConfig            `json:"iotdb_config,omitempty"`
+	RedisConfig         *config.RedisConfig            `json:"redis_config,omitempty"`
+	ModbusConfig        *config.ModbusConfig           `json:"modbus_config,omitempty"`
+	MQTTConfig          *config.MQTTConfig             `json:"mqtt_config,omitempty"`
+	SNMPConfig          *config.SNMPConfig             `json:"snmp_config,omitempty"`
+	PrometheusConfig    *config.PrometheusSourceConfig `json:"prometheus_config,omitempty"`
+	MongoDBConfig       *config.MongoDBConfig          `json:"mongodb_config,omitempty"`
+	ElasticsearchConfig *config.ElasticsearchConfig    `json:"elasticsearch_config,omitempty"`
+	SQLConfig           *config.SQLConfig              `json:"sql_config,omitempty"`
+	// SeriesSelector 与 SeriesLabels 用于多序列数据源，含义与指标配置相同
+	SeriesSelector map[string]string `json:"series_selector,omitempty"`
+	SeriesLabels   []string          `json:"series_labels,omitempty"`
+	// file 与 command 数据源的解析与执行参数，含义与指标配置相同
+	Parse   *config.OutputParseConfig `json:"parse,omitempty"`
+	MaxAge  string                    `json:"max_age,omitempty"`
+	Args    []string                  `json:"args,omitempty"`
+	Timeout string                    `json:"timeout,omitempty"`
 }
 
 // handlePreviewQuery 预览 SQL 查询结果。
@@ -351,29 +655,237 @@ func (s *Server) handlePreviewQuery(w http.ResponseWriter, r *http.Request) {
 				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("创建 Redis 客户端失败: %v", err))
 				return
 			}
-			defer client.Close()
+			defer client.Close()
+		} else {
+			cfg := s.getConfig()
+			connName := req.Connection
+			if connName == "" {
+				connName = "default"
+			}
+			redisCfg, ok := cfg.RedisConfigFor(connName)
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Redis 连接 %s 未配置", connName))
+				return
+			}
+			client, err = datasource.NewRedisClient(redisCfg)
+			if err != nil {
+				s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("创建 Redis 客户端失败: %v", err))
+				return
+			}
+			defer client.Close()
+		}
+		value, err = client.QueryScalar(ctx, req.Query)
+	case "modbus":
+		modbusCfg := req.ModbusConfig
+		if modbusCfg == nil {
+			cfg := s.getConfig()
+			connName := req.Connection
+			if connName == "" {
+				connName = "default"
+			}
+			connCfg, ok := cfg.ModbusConfigFor(connName)
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Modbus 连接 %s 未配置", connName))
+				return
+			}
+			modbusCfg = &connCfg
+		}
+		var client *datasource.ModbusClient
+		client, err = datasource.NewModbusClient(*modbusCfg)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("创建 Modbus 客户端失败: %v", err))
+			return
+		}
+		defer client.Close()
+		value, err = client.QueryScalar(ctx, req.Query)
+	case "mqtt":
+		mqttCfg := req.MQTTConfig
+		if mqttCfg == nil {
+			cfg := s.getConfig()
+			connName := req.Connection
+			if connName == "" {
+				connName = "default"
+			}
+			connCfg, ok := cfg.MQTTConfigFor(connName)
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("MQTT 连接 %s 未配置", connName))
+				return
+			}
+			mqttCfg = &connCfg
+		}
+		var client *datasource.MQTTClient
+		client, err = datasource.NewMQTTClient(*mqttCfg)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("创建 MQTT 客户端失败: %v", err))
+			return
+		}
+		defer client.Close()
+		// 等待订阅主题的首条消息（保留消息会立即到达），新建的连接没有过期的缓存，无需 max_age
+		value, err = client.QueryScalar(ctx, req.Query, req.ResultField, 0)
+	case "snmp":
+		snmpCfg := req.SNMPConfig
+		if snmpCfg == nil {
+			cfg := s.getConfig()
+			connName := req.Connection
+			if connName == "" {
+				connName = "default"
+			}
+			connCfg, ok := cfg.SNMPConfigFor(connName)
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("SNMP 连接 %s 未配置", connName))
+				return
+			}
+			snmpCfg = &connCfg
+		}
+		var client *datasource.SNMPClient
+		client, err = datasource.NewSNMPClient(*snmpCfg)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("创建 SNMP 客户端失败: %v", err))
+			return
+		}
+		defer client.Close()
+		value, err = client.QueryScalar(ctx, req.Query)
+	case "prometheus":
+		prometheusCfg := req.PrometheusConfig
+		if prometheusCfg == nil {
+			cfg := s.getConfig()
+			connName := req.Connection
+			if connName == "" {
+				connName = "default"
+			}
+			connCfg, ok := cfg.PrometheusSourceConfigFor(connName)
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Prometheus 连接 %s 未配置", connName))
+				return
+			}
+			prometheusCfg = &connCfg
+		}
+		var client *datasource.PrometheusClient
+		client, err = datasource.NewPrometheusClient(*prometheusCfg)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("创建 Prometheus 客户端失败: %v", err))
+			return
+		}
+		defer client.Close()
+		if len(req.SeriesLabels) > 0 {
+			// 标签族指标返回全部匹配序列，便于确认 series_labels 的取值
+			var samples []datasource.Sample
+			samples, err = client.QuerySeries(ctx, req.Query)
+			if err == nil {
+				s.writeJSON(w, http.StatusOK, map[string]interface{}{
+					"success": true,
+					"series":  datasource.SelectSamples(samples, req.SeriesSelector),
+				})
+				return
+			}
+			break
+		}
+		value, err = client.QueryScalar(ctx, req.Query, req.SeriesSelector)
+	case "mongodb":
+		mongodbCfg := req.MongoDBConfig
+		if mongodbCfg == nil {
+			cfg := s.getConfig()
+			connName := req.Connection
+			if connName == "" {
+				connName = "default"
+			}
+			connCfg, ok := cfg.MongoDBConfigFor(connName)
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("MongoDB 连接 %s 未配置", connName))
+				return
+			}
+			mongodbCfg = &connCfg
+		}
+		var client *datasource.MongoDBClient
+		client, err = datasource.NewMongoDBClient(*mongodbCfg)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("创建 MongoDB 客户端失败: %v", err))
+			return
+		}
+		defer client.Close()
+		value, err = client.QueryScalar(ctx, req.Query, req.ResultField)
+	case "elasticsearch":
+		elasticsearchCfg := req.ElasticsearchConfig
+		if elasticsearchCfg == nil {
+			cfg := s.getConfig()
+			connName := req.Connection
+			if connName == "" {
+				connName = "default"
+			}
+			connCfg, ok := cfg.ElasticsearchConfigFor(connName)
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Elasticsearch 连接 %s 未配置", connName))
+				return
+			}
+			elasticsearchCfg = &connCfg
+		}
+		var client *datasource.ElasticsearchClient
+		client, err = datasource.NewElasticsearchClient(*elasticsearchCfg)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("创建 Elasticsearch 客户端失败: %v", err))
+			return
+		}
+		defer client.Close()
+		value, err = client.QueryScalar(ctx, req.Query, req.ResultField, previewWindow(s.getConfig()))
+	case "synthetic":
+		// 预览使用独立的生成器，不影响采集中的随机游走等状态；回放文件同样受 file 白名单约束
+		value, err = datasource.NewSyntheticClient().QueryScalar(ctx, s.getConfig().File, "preview", req.Query)
+	case "file", "command":
+		spec := config.MetricSpec{Source: req.Source, Query: req.Query, Parse: req.Parse, MaxAge: req.MaxAge, Args: req.Args, Timeout: req.Timeout}
+		if spec.Source == "file" {
+			var maxAge time.Duration
+			if spec.MaxAge != "" {
+				if maxAge, err = time.ParseDuration(spec.MaxAge); err != nil {
+					s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析 max_age 失败: %v", err))
+					return
+				}
+			}
+			// 预览同样只能读取配置文件中 file 白名单内的文件
+			value, err = datasource.QueryFile(s.getConfig().File, spec.Query, spec.OutputParse(), req.ResultField, maxAge)
 		} else {
+			// 预览同样受配置文件中的 command 沙箱策略约束
+			commandCfg := s.getConfig().Command
+			runner := datasource.NewCommandRunner(commandCfg)
+			value, err = runner.QueryScalar(ctx, spec.Query, spec.Args, commandCfg.TimeoutFor(spec), spec.OutputParse(), req.ResultField)
+		}
+	case "sql":
+		sqlCfg := req.SQLConfig
+		if sqlCfg == nil {
 			cfg := s.getConfig()
 			connName := req.Connection
 			if connName == "" {
 				connName = "default"
 			}
-			redisCfg, ok := cfg.RedisConfigFor(connName)
+			connCfg, ok := cfg.SQLConfigFor(connName)
 			if !ok {
-				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Redis 连接 %s 未配置", connName))
-				return
-			}
-			client, err = datasource.NewRedisClient(redisCfg)
-			if err != nil {
-				s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("创建 Redis 客户端失败: %v", err))
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("SQL 连接 %s 未配置", connName))
 				return
 			}
-			defer client.Close()
+			sqlCfg = &connCfg
+		}
+		var client *datasource.SQLClient
+		client, err = datasource.NewSQLClient(*sqlCfg)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("创建 SQL 客户端失败: %v", err))
+			return
 		}
+		defer client.Close()
 		value, err = client.QueryScalar(ctx, req.Query)
 	default:
-		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("不支持的数据源: %s", req.Source))
-		return
+		pluginCfg, ok := s.getConfig().Plugins[req.Source]
+		if !ok {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("不支持的数据源: %s", req.Source))
+			return
+		}
+		// 预览启动独立的插件进程，不影响采集中的插件
+		var client *datasource.PluginClient
+		client, err = datasource.NewPluginClient(req.Source, pluginCfg)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("启动插件失败: %v", err))
+			return
+		}
+		defer client.Close()
+		value, err = client.QueryScalar(ctx, req.Query, req.ResultField, req.Connection)
 	}
 
 	if err != nil {
@@ -437,6 +949,11 @@ func (s *Server) handleCreateMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.checkMetricQuery(metric); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("查询成本校验失败: %v", err))
+		return
+	}
+
 	if err := cfg.Save(s.configPath); err != nil {
 		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("保存配置失败: %v", err))
 		return
@@ -487,6 +1004,11 @@ func (s *Server) handleUpdateMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.checkMetricQuery(metric); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("查询成本校验失败: %v", err))
+		return
+	}
+
 	if err := cfg.Save(s.configPath); err != nil {
 		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("保存配置失败: %v", err))
 		return
@@ -502,6 +1024,13 @@ func (s *Server) handleUpdateMetric(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, metric)
 }
 
+// checkMetricQuery 保存指标前通过已建立的数据源连接校验查询成本。
+func (s *Server) checkMetricQuery(metric config.MetricSpec) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.service.CheckMetricQuery(ctx, metric)
+}
+
 // handleDeleteMetric 删除指标。
 func (s *Server) handleDeleteMetric(w http.ResponseWriter, r *http.Request) {
 	metricName := strings.TrimPrefix(r.URL.Path, "/api/metrics/")
@@ -612,6 +1141,11 @@ func (s *Server) handleUpdateMetricByIndex(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if err := s.checkMetricQuery(metric); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("查询成本校验失败: %v", err))
+		return
+	}
+
 	if err := cfg.Save(s.configPath); err != nil {
 		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("保存配置失败: %v", err))
 		return
@@ -837,6 +1371,321 @@ func (s *Server) handleDeleteRestAPIConnection(w http.ResponseWriter, r *http.Re
 	})
 }
 
+// handleUpdateModbusConnection 更新单个 Modbus 连接
+func (s *Server) handleUpdateModbusConnection(w http.ResponseWriter, r *http.Request, name string) {
+	var modbusCfg config.ModbusConfig
+	if err := json.NewDecoder(r.Body).Decode(&modbusCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析配置失败: %v", err))
+		return
+	}
+
+	cfg := s.getConfig().Clone()
+	if cfg.ModbusConnections == nil {
+		cfg.ModbusConnections = make(map[string]config.ModbusConfig)
+	}
+	cfg.ModbusConnections[name] = modbusCfg
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Modbus 连接 %s 已更新", name),
+	})
+}
+
+// handleDeleteModbusConnection 删除单个 Modbus 连接
+func (s *Server) handleDeleteModbusConnection(w http.ResponseWriter, r *http.Request, name string) {
+	cfg := s.getConfig().Clone()
+	if _, ok := cfg.ModbusConnections[name]; !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Modbus 连接 %s 不存在", name))
+		return
+	}
+	delete(cfg.ModbusConnections, name)
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Modbus 连接 %s 已删除", name),
+	})
+}
+
+// handleUpdateMQTTConnection 更新单个 MQTT 连接
+func (s *Server) handleUpdateMQTTConnection(w http.ResponseWriter, r *http.Request, name string) {
+	var mqttCfg config.MQTTConfig
+	if err := json.NewDecoder(r.Body).Decode(&mqttCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析配置失败: %v", err))
+		return
+	}
+
+	cfg := s.getConfig().Clone()
+	if cfg.MQTTConnections == nil {
+		cfg.MQTTConnections = make(map[string]config.MQTTConfig)
+	}
+	cfg.MQTTConnections[name] = mqttCfg
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("MQTT 连接 %s 已更新", name),
+	})
+}
+
+// handleDeleteMQTTConnection 删除单个 MQTT 连接
+func (s *Server) handleDeleteMQTTConnection(w http.ResponseWriter, r *http.Request, name string) {
+	cfg := s.getConfig().Clone()
+	if _, ok := cfg.MQTTConnections[name]; !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("MQTT 连接 %s 不存在", name))
+		return
+	}
+	delete(cfg.MQTTConnections, name)
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("MQTT 连接 %s 已删除", name),
+	})
+}
+
+// handleUpdateSNMPConnection 更新单个 SNMP 连接
+func (s *Server) handleUpdateSNMPConnection(w http.ResponseWriter, r *http.Request, name string) {
+	var snmpCfg config.SNMPConfig
+	if err := json.NewDecoder(r.Body).Decode(&snmpCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析配置失败: %v", err))
+		return
+	}
+
+	cfg := s.getConfig().Clone()
+	if cfg.SNMPConnections == nil {
+		cfg.SNMPConnections = make(map[string]config.SNMPConfig)
+	}
+	cfg.SNMPConnections[name] = snmpCfg
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("SNMP 连接 %s 已更新", name),
+	})
+}
+
+// handleDeleteSNMPConnection 删除单个 SNMP 连接
+func (s *Server) handleDeleteSNMPConnection(w http.ResponseWriter, r *http.Request, name string) {
+	cfg := s.getConfig().Clone()
+	if _, ok := cfg.SNMPConnections[name]; !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("SNMP 连接 %s 不存在", name))
+		return
+	}
+	delete(cfg.SNMPConnections, name)
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("SNMP 连接 %s 已删除", name),
+	})
+}
+
+// handleUpdatePrometheusConnection 更新单个 Prometheus 连接
+func (s *Server) handleUpdatePrometheusConnection(w http.ResponseWriter, r *http.Request, name string) {
+	var prometheusCfg config.PrometheusSourceConfig
+	if err := json.NewDecoder(r.Body).Decode(&prometheusCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析配置失败: %v", err))
+		return
+	}
+
+	cfg := s.getConfig().Clone()
+	if cfg.PrometheusConnections == nil {
+		cfg.PrometheusConnections = make(map[string]config.PrometheusSourceConfig)
+	}
+	cfg.PrometheusConnections[name] = prometheusCfg
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Prometheus 连接 %s 已更新", name),
+	})
+}
+
+// handleDeletePrometheusConnection 删除单个 Prometheus 连接
+func (s *Server) handleDeletePrometheusConnection(w http.ResponseWriter, r *http.Request, name string) {
+	cfg := s.getConfig().Clone()
+	if _, ok := cfg.PrometheusConnections[name]; !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Prometheus 连接 %s 不存在", name))
+		return
+	}
+	delete(cfg.PrometheusConnections, name)
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Prometheus 连接 %s 已删除", name),
+	})
+}
+
+// handleUpdateMongoDBConnection 更新单个 MongoDB 连接
+func (s *Server) handleUpdateMongoDBConnection(w http.ResponseWriter, r *http.Request, name string) {
+	var mongodbCfg config.MongoDBConfig
+	if err := json.NewDecoder(r.Body).Decode(&mongodbCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析配置失败: %v", err))
+		return
+	}
+
+	cfg := s.getConfig().Clone()
+	if cfg.MongoDBConnections == nil {
+		cfg.MongoDBConnections = make(map[string]config.MongoDBConfig)
+	}
+	cfg.MongoDBConnections[name] = mongodbCfg
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("MongoDB 连接 %s 已更新", name),
+	})
+}
+
+// handleDeleteMongoDBConnection 删除单个 MongoDB 连接
+func (s *Server) handleDeleteMongoDBConnection(w http.ResponseWriter, r *http.Request, name string) {
+	cfg := s.getConfig().Clone()
+	if _, ok := cfg.MongoDBConnections[name]; !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("MongoDB 连接 %s 不存在", name))
+		return
+	}
+	delete(cfg.MongoDBConnections, name)
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("MongoDB 连接 %s 已删除", name),
+	})
+}
+
+// handleUpdateElasticsearchConnection 更新单个 Elasticsearch 连接
+func (s *Server) handleUpdateElasticsearchConnection(w http.ResponseWriter, r *http.Request, name string) {
+	var elasticsearchCfg config.ElasticsearchConfig
+	if err := json.NewDecoder(r.Body).Decode(&elasticsearchCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析配置失败: %v", err))
+		return
+	}
+
+	cfg := s.getConfig().Clone()
+	if cfg.ElasticsearchConnections == nil {
+		cfg.ElasticsearchConnections = make(map[string]config.ElasticsearchConfig)
+	}
+	cfg.ElasticsearchConnections[name] = elasticsearchCfg
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Elasticsearch 连接 %s 已更新", name),
+	})
+}
+
+// handleDeleteElasticsearchConnection 删除单个 Elasticsearch 连接
+func (s *Server) handleDeleteElasticsearchConnection(w http.ResponseWriter, r *http.Request, name string) {
+	cfg := s.getConfig().Clone()
+	if _, ok := cfg.ElasticsearchConnections[name]; !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Elasticsearch 连接 %s 不存在", name))
+		return
+	}
+	delete(cfg.ElasticsearchConnections, name)
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Elasticsearch 连接 %s 已删除", name),
+	})
+}
+
+// handleUpdateSQLConnection 更新单个 SQL 连接
+func (s *Server) handleUpdateSQLConnection(w http.ResponseWriter, r *http.Request, name string) {
+	var sqlCfg config.SQLConfig
+	if err := json.NewDecoder(r.Body).Decode(&sqlCfg); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("解析配置失败: %v", err))
+		return
+	}
+
+	cfg := s.getConfig().Clone()
+	if cfg.SQLConnections == nil {
+		cfg.SQLConnections = make(map[string]config.SQLConfig)
+	}
+	cfg.SQLConnections[name] = sqlCfg
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("SQL 连接 %s 已更新", name),
+	})
+}
+
+// handleDeleteSQLConnection 删除单个 SQL 连接
+func (s *Server) handleDeleteSQLConnection(w http.ResponseWriter, r *http.Request, name string) {
+	cfg := s.getConfig().Clone()
+	if _, ok := cfg.SQLConnections[name]; !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("SQL 连接 %s 不存在", name))
+		return
+	}
+	delete(cfg.SQLConnections, name)
+
+	if err := s.saveAndReload(cfg); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("SQL 连接 %s 已删除", name),
+	})
+}
+
 // handleUpdateIoTDB 更新 IoTDB 配置
 func (s *Server) handleUpdateIoTDB(w http.ResponseWriter, r *http.Request) {
 	var iotdbCfg config.IoTDBConfig
@@ -1237,3 +2086,12 @@ func parseTimeToUnix(timeStr string) (int64, error) {
 	}
 	return timestamp, nil
 }
+
+// previewWindow 返回预览查询使用的时间窗口，与采集时一致：[当前时间 - 采集周期, 当前时间)。
+func previewWindow(cfg *config.Config) datasource.TimeWindow {
+	interval, err := cfg.Schedule.IntervalDuration()
+	if err != nil {
+		interval = time.Hour
+	}
+	return datasource.NewTimeWindow(time.Now(), interval)
+}
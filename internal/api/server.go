@@ -88,6 +88,20 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.handleTestRedis(w, r)
 	case path == "/api/datasource/test/restapi" && r.Method == "POST":
 		s.handleTestRestAPI(w, r)
+	case path == "/api/datasource/test/modbus" && r.Method == "POST":
+		s.handleTestModbus(w, r)
+	case path == "/api/datasource/test/mqtt" && r.Method == "POST":
+		s.handleTestMQTT(w, r)
+	case path == "/api/datasource/test/snmp" && r.Method == "POST":
+		s.handleTestSNMP(w, r)
+	case path == "/api/datasource/test/prometheus" && r.Method == "POST":
+		s.handleTestPrometheus(w, r)
+	case path == "/api/datasource/test/mongodb" && r.Method == "POST":
+		s.handleTestMongoDB(w, r)
+	case path == "/api/datasource/test/elasticsearch" && r.Method == "POST":
+		s.handleTestElasticsearch(w, r)
+	case path == "/api/datasource/test/sql" && r.Method == "POST":
+		s.handleTestSQL(w, r)
 	case path == "/api/datasource/restapi/preview" && r.Method == "POST":
 		s.handlePreviewRestAPI(w, r)
 	case path == "/api/datasource/query/preview" && r.Method == "POST":
@@ -184,10 +198,42 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.handleDataSourceRoute(w, r, s.handleUpdateRestAPIConnection)
 	case strings.HasPrefix(path, "/api/datasource/restapi/") && r.Method == "DELETE":
 		s.handleDataSourceRoute(w, r, s.handleDeleteRestAPIConnection)
+	case strings.HasPrefix(path, "/api/datasource/modbus/") && r.Method == "PUT":
+		s.handleDataSourceRoute(w, r, s.handleUpdateModbusConnection)
+	case strings.HasPrefix(path, "/api/datasource/modbus/") && r.Method == "DELETE":
+		s.handleDataSourceRoute(w, r, s.handleDeleteModbusConnection)
+	case strings.HasPrefix(path, "/api/datasource/mqtt/") && r.Method == "PUT":
+		s.handleDataSourceRoute(w, r, s.handleUpdateMQTTConnection)
+	case strings.HasPrefix(path, "/api/datasource/mqtt/") && r.Method == "DELETE":
+		s.handleDataSourceRoute(w, r, s.handleDeleteMQTTConnection)
+	case strings.HasPrefix(path, "/api/datasource/snmp/") && r.Method == "PUT":
+		s.handleDataSourceRoute(w, r, s.handleUpdateSNMPConnection)
+	case strings.HasPrefix(path, "/api/datasource/snmp/") && r.Method == "DELETE":
+		s.handleDataSourceRoute(w, r, s.handleDeleteSNMPConnection)
+	case strings.HasPrefix(path, "/api/datasource/prometheus/") && r.Method == "PUT":
+		s.handleDataSourceRoute(w, r, s.handleUpdatePrometheusConnection)
+	case strings.HasPrefix(path, "/api/datasource/prometheus/") && r.Method == "DELETE":
+		s.handleDataSourceRoute(w, r, s.handleDeletePrometheusConnection)
+	case strings.HasPrefix(path, "/api/datasource/mongodb/") && r.Method == "PUT":
+		s.handleDataSourceRoute(w, r, s.handleUpdateMongoDBConnection)
+	case strings.HasPrefix(path, "/api/datasource/mongodb/") && r.Method == "DELETE":
+		s.handleDataSourceRoute(w, r, s.handleDeleteMongoDBConnection)
+	case strings.HasPrefix(path, "/api/datasource/elasticsearch/") && r.Method == "PUT":
+		s.handleDataSourceRoute(w, r, s.handleUpdateElasticsearchConnection)
+	case strings.HasPrefix(path, "/api/datasource/elasticsearch/") && r.Method == "DELETE":
+		s.handleDataSourceRoute(w, r, s.handleDeleteElasticsearchConnection)
+	case strings.HasPrefix(path, "/api/datasource/sql/") && r.Method == "PUT":
+		s.handleDataSourceRoute(w, r, s.handleUpdateSQLConnection)
+	case strings.HasPrefix(path, "/api/datasource/sql/") && r.Method == "DELETE":
+		s.handleDataSourceRoute(w, r, s.handleDeleteSQLConnection)
 	case path == "/api/datasource/iotdb" && r.Method == "PUT":
 		s.handleUpdateIoTDB(w, r)
 	case path == "/metrics":
 		s.service.GetPrometheusHandler().ServeHTTP(w, r)
+	case strings.HasPrefix(path, "/metrics/") && r.Method == "GET":
+		s.handleGroupMetrics(w, r)
+	case path == "/probe" && r.Method == "GET":
+		s.service.ProbeHandler().ServeHTTP(w, r)
 	default:
 		// 尝试从嵌入的静态文件中服务
 		distFS, err := web.GetDistFS()
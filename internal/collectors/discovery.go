@@ -0,0 +1,225 @@
+package collectors
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+	"github.com/company/ems-devices/internal/discovery"
+)
+
+// connectionDiscovery 定期刷新发现的连接并维护对应的客户端。客户端与配置文件中的连接分开保存，
+// 热更新只重建配置文件中的连接；同名时配置文件中的连接优先。
+type connectionDiscovery struct {
+	discoverer *discovery.Discoverer
+	interval   time.Duration
+	cancel     context.CancelFunc
+	done       chan struct{}
+
+	mu    sync.RWMutex
+	conns discovery.Connections // 已成功创建客户端的连接配置
+	mysql map[string]*datasource.MySQLClient
+	sql   map[string]*datasource.SQLClient
+}
+
+// startConnectionDiscovery 完成首次发现后在后台定期刷新，未配置连接发现时返回 nil。
+func startConnectionDiscovery(cfg config.ConnectionDiscoveryConfig, metrics *discovery.Metrics) *connectionDiscovery {
+	if !cfg.Enabled() {
+		return nil
+	}
+	discoverer, err := discovery.New(cfg, metrics)
+	if err != nil {
+		log.Printf("警告: 连接发现初始化失败，发现的连接将不可用: %v", err)
+		return nil
+	}
+	interval, _ := cfg.RefreshIntervalDuration()
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &connectionDiscovery{
+		discoverer: discoverer,
+		interval:   interval,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		mysql:      make(map[string]*datasource.MySQLClient),
+		sql:        make(map[string]*datasource.SQLClient),
+	}
+	d.refresh(ctx)
+	go d.run(ctx)
+	return d
+}
+
+func (d *connectionDiscovery) run(ctx context.Context) {
+	defer close(d.done)
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.refresh(ctx)
+		}
+	}
+}
+
+// refresh 执行一次发现并增删客户端。只在构造阶段与后台协程中调用，不会并发执行。
+func (d *connectionDiscovery) refresh(ctx context.Context) {
+	conns, err := d.discoverer.Refresh(ctx)
+	if err != nil {
+		log.Printf("连接发现刷新出错: %v", err)
+	}
+
+	d.mu.RLock()
+	old := d.conns
+	d.mu.RUnlock()
+
+	next := discovery.Connections{MySQL: make(map[string]config.MySQLConfig), SQL: make(map[string]config.SQLConfig)}
+	mysqlClients := make(map[string]*datasource.MySQLClient)
+	sqlClients := make(map[string]*datasource.SQLClient)
+	var staleMySQL []*datasource.MySQLClient
+	var staleSQL []*datasource.SQLClient
+
+	// 新增或配置变更的连接并发创建，避免不可达的连接拖慢整次刷新
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for name, conf := range conns.MySQL {
+		if prev, ok := old.MySQL[name]; ok && mysqlConfigEqual(prev, conf) {
+			next.MySQL[name] = conf
+			mysqlClients[name] = d.mysql[name]
+			continue
+		}
+		wg.Add(1)
+		go func(name string, conf config.MySQLConfig) {
+			defer wg.Done()
+			client, err := datasource.NewMySQLClient(conf)
+			if err != nil {
+				log.Printf("警告: 发现的 MySQL 连接 %s 初始化失败，下次刷新时重试: %v", name, err)
+				return
+			}
+			mu.Lock()
+			next.MySQL[name] = conf
+			mysqlClients[name] = client
+			created++
+			mu.Unlock()
+		}(name, conf)
+	}
+	for name, conf := range conns.SQL {
+		if prev, ok := old.SQL[name]; ok && sqlConfigEqual(prev, conf) {
+			next.SQL[name] = conf
+			sqlClients[name] = d.sql[name]
+			continue
+		}
+		wg.Add(1)
+		go func(name string, conf config.SQLConfig) {
+			defer wg.Done()
+			client, err := datasource.NewSQLClient(conf)
+			if err != nil {
+				log.Printf("警告: 发现的 SQL 连接 %s 初始化失败，下次刷新时重试: %v", name, err)
+				return
+			}
+			mu.Lock()
+			next.SQL[name] = conf
+			sqlClients[name] = client
+			created++
+			mu.Unlock()
+		}(name, conf)
+	}
+	wg.Wait()
+
+	d.mu.Lock()
+	for name, client := range d.mysql {
+		if mysqlClients[name] != client {
+			staleMySQL = append(staleMySQL, client)
+		}
+	}
+	for name, client := range d.sql {
+		if sqlClients[name] != client {
+			staleSQL = append(staleSQL, client)
+		}
+	}
+	d.conns, d.mysql, d.sql = next, mysqlClients, sqlClients
+	d.mu.Unlock()
+
+	// 关闭时等待进行中的查询结束
+	for _, client := range staleMySQL {
+		client.Close()
+	}
+	for _, client := range staleSQL {
+		client.Close()
+	}
+	if closed := len(staleMySQL) + len(staleSQL); created > 0 || closed > 0 {
+		log.Printf("连接发现已更新：新建 %d 个连接，关闭 %d 个连接，当前 MySQL %d 个、SQL %d 个", created, closed, len(next.MySQL), len(next.SQL))
+	}
+}
+
+// stop 停止后台刷新并关闭全部发现的连接。
+func (d *connectionDiscovery) stop() {
+	if d == nil {
+		return
+	}
+	d.cancel()
+	<-d.done
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, client := range d.mysql {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭发现的 MySQL 连接 %s 失败: %v", name, err)
+		}
+	}
+	for name, client := range d.sql {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭发现的 SQL 连接 %s 失败: %v", name, err)
+		}
+	}
+	d.mysql, d.sql = nil, nil
+	d.conns = discovery.Connections{}
+}
+
+// names 返回指定数据源已发现的连接名。
+func (d *connectionDiscovery) names(source string) []string {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.conns.Names(source)
+}
+
+func (d *connectionDiscovery) mysqlClient(name string) (*datasource.MySQLClient, bool) {
+	if d == nil {
+		return nil, false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	client, ok := d.mysql[name]
+	return client, ok
+}
+
+func (d *connectionDiscovery) sqlClient(name string) (*datasource.SQLClient, bool) {
+	if d == nil {
+		return nil, false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	client, ok := d.sql[name]
+	return client, ok
+}
+
+// discoveredMySQL 返回发现的 MySQL 连接的客户端。
+func (s *Service) discoveredMySQL(name string) (*datasource.MySQLClient, bool) {
+	s.mu.RLock()
+	d := s.discovery
+	s.mu.RUnlock()
+	return d.mysqlClient(name)
+}
+
+// discoveredSQL 返回发现的 SQL 连接的客户端。
+func (s *Service) discoveredSQL(name string) (*datasource.SQLClient, bool) {
+	s.mu.RLock()
+	d := s.discovery
+	s.mu.RUnlock()
+	return d.sqlClient(name)
+}
@@ -0,0 +1,80 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestConnectionDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	sdFile := filepath.Join(dir, "shards.yml")
+	writeTargets := func(shards map[string]string) {
+		t.Helper()
+		var sb strings.Builder
+		for name, dsn := range shards {
+			fmt.Fprintf(&sb, "- name: %s\n  config:\n    driver: sqlite\n    dsn: %s\n", name, dsn)
+		}
+		if err := os.WriteFile(sdFile, []byte(sb.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shardA := newShard(t, dir, "shard-a.db", 3)
+	shardB := newShard(t, dir, "shard-b.db", 5)
+	writeTargets(map[string]string{"shard-a": shardA})
+
+	cfg := &config.Config{
+		Schedule: config.ScheduleConfig{Interval: "1h"},
+		ConnectionDiscovery: config.ConnectionDiscoveryConfig{
+			FileSD: []config.FileSDConfig{{Source: "sql", Files: []string{sdFile}}},
+		},
+		Metrics: []config.MetricSpec{
+			{Name: "discovered_orders_total", Help: "订单数", Source: "sql", Query: "SELECT COUNT(*) FROM orders", Connection: "shard-*"},
+			{Name: "discovered_shard_a_orders", Help: "订单数", Source: "sql", Query: "SELECT COUNT(*) FROM orders", Connection: "shard-a"},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("glob 或连接名只匹配发现的连接时也应通过校验: %v", err)
+	}
+	if err := cfg.ApplyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	if failed := svc.RunOnce(context.Background()); failed != 0 {
+		t.Fatalf("失败指标数应为 0，实际 %d", failed)
+	}
+	_, body := scrape(t, svc.GetPrometheusHandler(), nil)
+	if !strings.Contains(body, `discovered_orders_total{connection="shard-a"} 3`) || !strings.Contains(body, "discovered_shard_a_orders 3") || !strings.Contains(body, `collector_discovered_connections{source="sql"} 1`) {
+		t.Fatalf("首次发现的连接未生效:\n%s", body)
+	}
+
+	// 引用发现的连接的指标不影响热更新
+	newCfg := *cfg
+	newCfg.Metrics = append([]config.MetricSpec(nil), cfg.Metrics...)
+	newCfg.Metrics[1].Query = "SELECT COUNT(id) FROM orders"
+	if result := svc.ReloadConfig(&newCfg); !result.Success {
+		t.Fatalf("热更新失败: %s", result.Error)
+	}
+
+	// 新增 shard-b、移除 shard-a，刷新后无需重载即可生效
+	writeTargets(map[string]string{"shard-b": shardB})
+	svc.discovery.refresh(context.Background())
+	if _, ok := svc.discoveredSQL("shard-a"); ok {
+		t.Fatal("移除的连接应当被关闭")
+	}
+	svc.RunOnce(context.Background())
+	_, body = scrape(t, svc.GetPrometheusHandler(), nil)
+	if !strings.Contains(body, `discovered_orders_total{connection="shard-b"} 5`) || strings.Contains(body, `connection="shard-a"`) {
+		t.Fatalf("刷新后的连接未生效:\n%s", body)
+	}
+}
@@ -0,0 +1,68 @@
+package collectors
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// updateFanOut 对 fan-out 指标匹配到的每个连接（含连接发现提供的连接）并发查询，每个连接对应 gauge 族中 connection 标签的一个子指标。
+// 单个连接失败只将该连接的子指标置为 NaN，不影响其他连接；全部连接成功时返回 true。
+func (s *Service) updateFanOut(ctx context.Context, holder metricHolder) bool {
+	start := time.Now()
+	conns := s.metricConnections(holder.spec)
+	if len(conns) == 0 {
+		log.Printf("更新指标 %s 失败: 未匹配到任何 %s 连接", holder.spec.Name, holder.spec.Source)
+		holder.vec.Reset()
+		s.errorCount.Inc()
+		return false
+	}
+
+	values := make([]float64, len(conns))
+	errs := make([]error, len(conns))
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+		go func(i int, conn string) {
+			defer wg.Done()
+			spec := holder.spec
+			spec.Connection, spec.Connections = conn, nil
+			values[i], errs[i] = s.queryMetric(ctx, spec)
+		}(i, conn)
+	}
+	wg.Wait()
+
+	// 整体替换子指标，不再匹配的连接不继续暴露
+	holder.vec.Reset()
+	failed := 0
+	for i, conn := range conns {
+		gauge := holder.vec.WithLabelValues(conn)
+		if errs[i] != nil {
+			log.Printf("更新指标 %s 失败（连接=%s）: %v", holder.spec.Name, conn, errs[i])
+			gauge.Set(math.NaN())
+			s.errorCount.Inc()
+			failed++
+			continue
+		}
+		gauge.Set(values[i])
+		s.emitSample(holder.spec, map[string]string{config.FanOutLabel: conn}, values[i])
+	}
+	log.Printf("指标 %s 更新完成，连接数=%d，失败=%d，耗时=%s", holder.spec.Name, len(conns), failed, time.Since(start))
+	return failed == 0
+}
+
+// metricConnections 返回指标查询的连接名：普通指标为其 connection（默认为 default），
+// fan-out 指标为配置文件与连接发现中匹配到的连接，按名称排序去重。
+func (s *Service) metricConnections(spec config.MetricSpec) []string {
+	if !spec.FanOut() {
+		return []string{connectionName(spec.Connection)}
+	}
+	s.mu.RLock()
+	available := append(s.cfg.ConnectionNames(spec.Source), s.discovery.names(spec.Source)...)
+	s.mu.RUnlock()
+	return config.MatchConnections(spec.ConnectionPatterns(), available)
+}
@@ -0,0 +1,87 @@
+package collectors
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestFanOutMetric(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Schedule: config.ScheduleConfig{Interval: "1h"},
+		SQLConnections: map[string]config.SQLConfig{
+			"shard-a": {Driver: "sqlite", DSN: newShard(t, dir, "shard-a.db", 3)},
+			"shard-b": {Driver: "sqlite", DSN: newShard(t, dir, "shard-b.db", 5)},
+			// 没有 orders 表，查询失败
+			"shard-c": {Driver: "sqlite", DSN: filepath.Join(dir, "shard-c.db")},
+			"billing": {Driver: "sqlite", DSN: newShard(t, dir, "billing.db", 7)},
+		},
+		Metrics: []config.MetricSpec{
+			{Name: "shard_orders_total", Help: "订单数", Source: "sql", Query: "SELECT COUNT(*) FROM orders", Connection: "shard-*", Labels: map[string]string{"app": "orders"}},
+			{Name: "listed_orders_total", Help: "订单数", Source: "sql", Query: "SELECT COUNT(*) FROM orders", Connections: []string{"billing", "shard-a"}},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ApplyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	if failed := svc.RunOnce(context.Background()); failed != 1 {
+		t.Fatalf("只有 shard-c 失败时失败指标数应为 1，实际 %d", failed)
+	}
+	_, body := scrape(t, svc.GetPrometheusHandler(), nil)
+	for _, want := range []string{
+		`shard_orders_total{app="orders",connection="shard-a"} 3`,
+		`shard_orders_total{app="orders",connection="shard-b"} 5`,
+		`shard_orders_total{app="orders",connection="shard-c"} NaN`,
+		`listed_orders_total{connection="billing"} 7`,
+		`listed_orders_total{connection="shard-a"} 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("缺少序列 %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `shard_orders_total{app="orders",connection="billing"}`) {
+		t.Errorf("glob 不应匹配 billing:\n%s", body)
+	}
+
+	// 保存指标前的成本检查与采集使用相同的连接解析
+	if got := strings.Join(svc.metricConnections(cfg.Metrics[0]), ","); got != "shard-a,shard-b,shard-c" {
+		t.Errorf("glob 应解析为 shard-a,shard-b,shard-c，实际 %s", got)
+	}
+	if got := strings.Join(svc.metricConnections(config.MetricSpec{Source: "sql"}), ","); got != "default" {
+		t.Errorf("未配置 connection 的指标应使用 default，实际 %s", got)
+	}
+}
+
+func TestCheckMetricQuerySkipsSQLSource(t *testing.T) {
+	cfg := &config.Config{
+		Schedule:       config.ScheduleConfig{Interval: "1h"},
+		SQLConnections: map[string]config.SQLConfig{"shard-a": {Driver: "sqlite", DSN: newShard(t, t.TempDir(), "shard-a.db", 3)}},
+	}
+	if err := cfg.ApplyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	// sql 连接没有 query_guard，全表扫描的查询同样允许保存
+	spec := config.MetricSpec{Name: "orders_total", Source: "sql", Connection: "shard-*", Query: "SELECT COUNT(*) FROM orders"}
+	if err := svc.CheckMetricQuery(context.Background(), spec); err != nil {
+		t.Fatalf("sql 数据源不应检查查询成本: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package collectors
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promcollectors "github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// metricGroup 是一个指标分组的注册表与访问配置。
+type metricGroup struct {
+	cfg      config.MetricGroupConfig
+	registry *prometheus.Registry
+}
+
+// newRuntimeRegistry 创建只包含 Go 运行时与进程指标的注册表，仅在 /metrics 中与业务注册表一同暴露。
+func newRuntimeRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(promcollectors.NewGoCollector(), promcollectors.NewProcessCollector(promcollectors.ProcessCollectorOpts{}))
+	return registry
+}
+
+// selfCollectors 返回 collector_ 自监控指标的采集器。
+func (s *Service) selfCollectors() []prometheus.Collector {
+	return append([]prometheus.Collector{s.errorCount, s.lastRun, s.poolStats, s.pluginStats, s.retries}, append(s.sinkMetrics.Collectors(), s.discoveryMetrics.Collectors()...)...)
+}
+
+// protectedGroup 判断指标是否属于配置了认证的分组。
+func protectedGroup(cfg *config.Config, spec config.MetricSpec) bool {
+	for _, name := range spec.Groups {
+		if group, ok := cfg.MetricGroups[name]; ok && (group.BearerToken != "" || group.Username != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildGroups 按配置为每个指标分组重建独立的注册表，调用方需持有写锁或处于构造阶段。
+// 属于带认证分组的指标只能从分组端点读取：它们从 /metrics 中隐藏，也不注册到默认注册表。
+func (s *Service) rebuildGroups(cfg *config.Config) {
+	hidden := make(map[string]struct{})
+	for _, spec := range cfg.Metrics {
+		if protectedGroup(cfg, spec) {
+			hidden[spec.Name] = struct{}{}
+		}
+	}
+	for _, holder := range s.metrics {
+		if _, ok := hidden[holder.spec.Name]; ok {
+			prometheus.DefaultRegisterer.Unregister(holder.collector())
+			continue
+		}
+		// 从带认证的分组中移出的指标重新注册到默认注册表
+		if err := prometheus.DefaultRegisterer.Register(holder.collector()); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				log.Printf("注册指标 %s 到默认注册表失败: %v", holder.spec.Name, err)
+			}
+		}
+	}
+	s.hidden = hidden
+
+	groups := make(map[string]*metricGroup, len(cfg.MetricGroups))
+	for name, groupCfg := range cfg.MetricGroups {
+		group := &metricGroup{cfg: groupCfg, registry: prometheus.NewRegistry()}
+		if groupCfg.IncludeSelfMetrics {
+			group.registry.MustRegister(s.selfCollectors()...)
+		}
+		groups[name] = group
+	}
+	for _, holder := range s.metrics {
+		for _, name := range holder.spec.Groups {
+			group, ok := groups[name]
+			if !ok {
+				continue
+			}
+			if err := group.registry.Register(holder.collector()); err != nil {
+				log.Printf("注册指标 %s 到分组 %s 失败: %v", holder.spec.Name, name, err)
+			}
+		}
+	}
+	s.groups = groups
+}
+
+// publicGatherer 返回 /metrics 使用的业务指标，去掉属于带认证分组的指标。
+func (s *Service) publicGatherer() prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := s.registry.Gather()
+		s.mu.RLock()
+		hidden := s.hidden
+		s.mu.RUnlock()
+		public := families[:0]
+		for _, family := range families {
+			if _, ok := hidden[family.GetName()]; !ok {
+				public = append(public, family)
+			}
+		}
+		return public, err
+	})
+}
+
+// GroupHandler 返回指标分组 /metrics/{group} 的处理器，分组不存在时返回 false。
+// 分组配置了 bearer_token 或 username 时，未通过认证的请求返回 401。
+func (s *Service) GroupHandler(name string) (http.Handler, bool) {
+	s.mu.RLock()
+	group, ok := s.groups[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	handler := s.withScrapeRefresh(promhttp.HandlerFor(group.registry, promhttp.HandlerOpts{}), name)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !scrapeAuthorized(group.cfg, r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="sql2metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}), true
+}
+
+// scrapeAuthorized 校验抓取请求的认证信息，未配置认证时放行。
+func scrapeAuthorized(cfg config.MetricGroupConfig, r *http.Request) bool {
+	switch {
+	case cfg.BearerToken != "":
+		return secureEqual(r.Header.Get("Authorization"), "Bearer "+cfg.BearerToken)
+	case cfg.Username != "":
+		user, pass, ok := r.BasicAuth()
+		return ok && secureEqual(user, cfg.Username) && secureEqual(pass, cfg.Password)
+	default:
+		return true
+	}
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
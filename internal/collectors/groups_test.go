@@ -0,0 +1,121 @@
+package collectors
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func scrape(t *testing.T, handler http.Handler, setup func(r *http.Request)) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if setup != nil {
+		setup(req)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestMetricGroups(t *testing.T) {
+	cfg := &config.Config{
+		Schedule: config.ScheduleConfig{Interval: "1h"},
+		MetricGroups: map[string]config.MetricGroupConfig{
+			"finance": {BearerToken: "s3cret"},
+			"ops":     {IncludeSelfMetrics: true},
+		},
+		Metrics: []config.MetricSpec{
+			{Name: "group_revenue_total", Help: "营收", Source: "synthetic", Query: "constant?value=42", Groups: []string{"finance"}},
+			{Name: "group_queue_depth", Help: "队列深度", Source: "synthetic", Query: "constant?value=3", Groups: []string{"ops", "finance"}},
+			{Name: "group_ungrouped", Help: "未分组", Source: "synthetic", Query: "constant?value=1"},
+		},
+	}
+	if err := cfg.ApplyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+	svc.RunOnce(context.Background())
+
+	_, all := scrape(t, svc.GetPrometheusHandler(), nil)
+	for _, want := range []string{"group_ungrouped", "collector_errors_total", "go_goroutines"} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("/metrics 应当包含 %s", want)
+		}
+	}
+	// 属于带认证分组的指标只能从分组端点读取
+	for _, unwanted := range []string{"group_revenue_total", "group_queue_depth"} {
+		if strings.Contains(all, unwanted) {
+			t.Fatalf("/metrics 不应包含带认证分组的指标 %s: %s", unwanted, all)
+		}
+		if families, _ := prometheus.DefaultGatherer.Gather(); hasFamily(families, unwanted) {
+			t.Fatalf("默认注册表不应包含带认证分组的指标 %s", unwanted)
+		}
+	}
+
+	finance, ok := svc.GroupHandler("finance")
+	if !ok {
+		t.Fatal("分组 finance 应当存在")
+	}
+	if code, _ := scrape(t, finance, nil); code != http.StatusUnauthorized {
+		t.Fatalf("未认证的请求应当返回 401，实际 %d", code)
+	}
+	code, body := scrape(t, finance, func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret") })
+	if code != http.StatusOK || !strings.Contains(body, "group_revenue_total 42") || !strings.Contains(body, "group_queue_depth 3") {
+		t.Fatalf("分组 finance 应当暴露其指标，实际 %d: %s", code, body)
+	}
+	for _, unwanted := range []string{"group_ungrouped", "collector_", "go_", "process_"} {
+		if strings.Contains(body, unwanted) {
+			t.Fatalf("分组 finance 不应包含 %s: %s", unwanted, body)
+		}
+	}
+
+	ops, _ := svc.GroupHandler("ops")
+	if _, body := scrape(t, ops, nil); strings.Contains(body, "group_revenue_total") || !strings.Contains(body, "collector_errors_total") {
+		t.Fatalf("分组 ops 应当只包含其指标与自监控指标: %s", body)
+	}
+	if _, ok := svc.GroupHandler("missing"); ok {
+		t.Fatal("未定义的分组不应存在")
+	}
+
+	newCfg := *cfg
+	newCfg.Metrics = append([]config.MetricSpec(nil), cfg.Metrics...)
+	newCfg.Metrics[2].Groups = []string{"ops"}
+	if result := svc.ReloadConfig(&newCfg); !result.Success {
+		t.Fatalf("热更新失败: %s", result.Error)
+	}
+	ops, _ = svc.GroupHandler("ops")
+	if _, body := scrape(t, ops, nil); !strings.Contains(body, "group_ungrouped") {
+		t.Fatalf("热更新后分组 ops 应当包含新加入的指标: %s", body)
+	}
+
+	// 移出带认证的分组后重新在 /metrics 中暴露
+	newCfg.Metrics[0].Groups = nil
+	if result := svc.ReloadConfig(&newCfg); !result.Success {
+		t.Fatalf("热更新失败: %s", result.Error)
+	}
+	if _, all := scrape(t, svc.GetPrometheusHandler(), nil); !strings.Contains(all, "group_revenue_total 42") {
+		t.Fatalf("移出带认证分组的指标应当在 /metrics 中暴露: %s", all)
+	}
+}
+
+func hasFamily(families []*dto.MetricFamily, name string) bool {
+	for _, family := range families {
+		if family.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,10 @@ package collectors
 import (
 	"sort"
 	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/company/ems-devices/internal/config"
 )
 
 // labelMapToString converts a map of labels to a sorted string representation for deduping
@@ -25,3 +29,47 @@ func labelMapToString(labels map[string]string) string {
 	}
 	return sb.String()
 }
+
+// newGaugeMetric 创建 gauge 指标；配置了 series_labels 时创建以这些标签区分序列的 gauge 族，
+// fan-out 指标创建以 connection 标签区分连接的 gauge 族。
+// on_scrape 指标包装为被抓取时才查询的采集器。
+func (s *Service) newGaugeMetric(cfg *config.Config, spec config.MetricSpec) prometheus.Collector {
+	metric := newGauge(spec)
+	if cfg.MetricMode(spec) == config.MetricModeOnScrape {
+		return newScrapeMetric(s, spec, metric)
+	}
+	return metric
+}
+
+func newGauge(spec config.MetricSpec) prometheus.Collector {
+	opts := prometheus.GaugeOpts{
+		Name:        spec.Name,
+		Help:        spec.Help,
+		ConstLabels: spec.Labels,
+	}
+	if len(spec.SeriesLabels) > 0 {
+		return prometheus.NewGaugeVec(opts, spec.SeriesLabels)
+	}
+	if spec.FanOut() {
+		return prometheus.NewGaugeVec(opts, []string{config.FanOutLabel})
+	}
+	return prometheus.NewGauge(opts)
+}
+
+// newMetricHolder 为由采集周期更新的指标（gauge、gauge 族与增量 counter）创建 holder，其他类型返回 false。
+func newMetricHolder(spec config.MetricSpec, metric prometheus.Collector) (metricHolder, bool) {
+	switch m := metric.(type) {
+	case *scrapeMetric:
+		return metricHolder{spec: spec, gauge: m.holder.gauge, vec: m.holder.vec, scrape: m}, true
+	case prometheus.Gauge:
+		return metricHolder{spec: spec, gauge: m}, true
+	case *prometheus.GaugeVec:
+		return metricHolder{spec: spec, vec: m}, true
+	case prometheus.Counter:
+		// 增量指标的 counter 同样由采集周期累加
+		if spec.Watermark != nil {
+			return metricHolder{spec: spec, counter: m}, true
+		}
+	}
+	return metricHolder{}, false
+}
@@ -0,0 +1,68 @@
+package collectors
+
+import (
+	"log"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// syncMQTTSubscriptions 按当前指标配置调整各 MQTT 连接的订阅与消息回调，调用方需持有写锁或处于初始化阶段。
+func (s *Service) syncMQTTSubscriptions(cfg *config.Config) {
+	filters := make(map[string][]string)
+	for _, spec := range cfg.Metrics {
+		if spec.Source != "mqtt" || (spec.Enabled != nil && !*spec.Enabled) {
+			continue
+		}
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		filters[conn] = append(filters[conn], spec.Query)
+	}
+
+	for name, client := range s.mqtt {
+		connName := name
+		client.SetListener(func(topic string, payload []byte) {
+			s.handleMQTTMessage(connName, topic, payload)
+		})
+		if err := client.SetSubscriptions(filters[name]); err != nil {
+			log.Printf("更新 MQTT 连接 %s 的订阅失败: %v", name, err)
+		}
+	}
+}
+
+// handleMQTTMessage 在消息到达时立即更新 mode 为 on_message 的指标。
+func (s *Service) handleMQTTMessage(conn, topic string, payload []byte) {
+	s.mu.RLock()
+	var targets []metricHolder
+	for _, holder := range s.metrics {
+		spec := holder.spec
+		if spec.Source != "mqtt" || spec.Mode != config.MetricModeOnMessage {
+			continue
+		}
+		if spec.Enabled != nil && !*spec.Enabled {
+			continue
+		}
+		name := spec.Connection
+		if name == "" {
+			name = "default"
+		}
+		if name == conn && datasource.TopicMatches(spec.Query, topic) {
+			targets = append(targets, holder)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, holder := range targets {
+		value, err := datasource.ParseMQTTPayload(payload, holder.spec.ResultField)
+		if err != nil {
+			log.Printf("更新指标 %s 失败（主题 %s）: %v", holder.spec.Name, topic, err)
+			s.errorCount.Inc()
+			continue
+		}
+		holder.gauge.Set(value)
+		s.recordValue(holder.spec.Name, value)
+		s.emitSample(holder.spec, nil, value)
+	}
+}
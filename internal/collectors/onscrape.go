@@ -0,0 +1,155 @@
+package collectors
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// scrapeTimeoutHeader 是 Prometheus 抓取请求中携带抓取超时的请求头。
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// scrapeTimeoutOffset 从抓取超时中预留的时间，用于编码与传输响应。
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
+// scrapeMetric 是 on_scrape 指标的采集器：被抓取时才执行查询，结果在 schedule.scrape_min_interval 内复用，
+// 并发的抓取共享同一次查询，避免抓取方增多时成倍增加数据库压力。
+type scrapeMetric struct {
+	svc *Service
+
+	mu      sync.Mutex
+	holder  metricHolder // 内部的 gauge 或 gauge 族，scrape 字段为空
+	last    time.Time
+	running chan struct{} // 查询进行中时非空，查询结束后关闭
+}
+
+func newScrapeMetric(svc *Service, spec config.MetricSpec, metric prometheus.Collector) *scrapeMetric {
+	holder, _ := newMetricHolder(spec, metric)
+	return &scrapeMetric{svc: svc, holder: holder}
+}
+
+// setSpec 在热更新只修改了查询等字段时更新指标配置，下次抓取时立即按新配置查询。
+func (m *scrapeMetric) setSpec(spec config.MetricSpec) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.holder.spec = spec
+	m.last = time.Time{}
+}
+
+// Describe 实现 prometheus.Collector。
+func (m *scrapeMetric) Describe(ch chan<- *prometheus.Desc) {
+	m.holder.collector().Describe(ch)
+}
+
+// Collect 实现 prometheus.Collector，只输出上次查询的结果。查询只由抓取端点的 withScrapeRefresh 触发，
+// remote_write、OTLP 等导出在每个采集周期 Gather 时不会查询，否则 on_scrape 会退化为按周期轮询。
+func (m *scrapeMetric) Collect(ch chan<- prometheus.Metric) {
+	m.holder.collector().Collect(ch)
+}
+
+// refresh 在缓存过期时执行查询；已有查询进行中时等待其完成或 ctx 结束。
+func (m *scrapeMetric) refresh(ctx context.Context) {
+	minInterval := m.svc.scrapeMinInterval()
+	m.mu.Lock()
+	if !m.last.IsZero() && time.Since(m.last) < minInterval {
+		m.mu.Unlock()
+		return
+	}
+	if wait := m.running; wait != nil {
+		m.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+		}
+		return
+	}
+	done := make(chan struct{})
+	m.running = done
+	holder := m.holder
+	m.mu.Unlock()
+
+	m.svc.updateHolder(ctx, holder)
+
+	m.mu.Lock()
+	m.last = time.Now()
+	m.running = nil
+	m.mu.Unlock()
+	close(done)
+}
+
+// refreshOnScrape 并发刷新 on_scrape 指标，group 为空时刷新全部，否则只刷新该分组的指标。
+func (s *Service) refreshOnScrape(ctx context.Context, group string) {
+	s.mu.RLock()
+	var targets []*scrapeMetric
+	for _, holder := range s.metrics {
+		if holder.scrape != nil && (group == "" || containsString(holder.spec.Groups, group)) {
+			targets = append(targets, holder.scrape)
+		}
+	}
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(m *scrapeMetric) {
+			defer wg.Done()
+			m.refresh(ctx)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// withScrapeRefresh 在输出指标前按抓取请求的超时刷新 on_scrape 指标。
+func (s *Service) withScrapeRefresh(next http.Handler, group string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.scrapeTimeout(r))
+		s.refreshOnScrape(ctx, group)
+		cancel()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scrapeTimeout 返回 on_scrape 查询的超时：优先使用抓取请求携带的超时（预留编码响应的时间），否则使用 schedule.scrape_timeout。
+func (s *Service) scrapeTimeout(r *http.Request) time.Duration {
+	if r != nil {
+		if seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64); err == nil && seconds > 0 {
+			timeout := time.Duration(seconds * float64(time.Second))
+			if timeout > 2*scrapeTimeoutOffset {
+				timeout -= scrapeTimeoutOffset
+			}
+			return timeout
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	timeout, err := s.cfg.Schedule.ScrapeTimeoutDuration()
+	if err != nil {
+		return 10 * time.Second
+	}
+	return timeout
+}
+
+func (s *Service) scrapeMinInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	interval, err := s.cfg.Schedule.ScrapeMinIntervalDuration()
+	if err != nil {
+		return 10 * time.Second
+	}
+	return interval
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package collectors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestOnScrapeMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "value.txt")
+	cfg := &config.Config{
+		Schedule: config.ScheduleConfig{Interval: "1h", ScrapeMinInterval: "200ms"},
+		File:     config.FileSourceConfig{AllowedPaths: []string{path}},
+		Metrics: []config.MetricSpec{
+			{Name: "scrape_backlog", Help: "积压量", Source: "file", Query: path, Mode: config.MetricModeOnScrape},
+			{Name: "scrape_polled", Help: "轮询指标", Source: "synthetic", Query: "constant?value=1"},
+		},
+	}
+	if err := cfg.ApplyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	// 文件尚不存在，周期采集不应查询 on_scrape 指标，RunOnce 则查询并计入失败数
+	if failed := svc.execute(context.Background(), false); failed != 0 {
+		t.Fatalf("周期采集不应包含 on_scrape 指标，失败数 %d", failed)
+	}
+	if failed := svc.RunOnce(context.Background()); failed != 1 {
+		t.Fatalf("RunOnce 应当查询 on_scrape 指标并计入失败数，实际失败数 %d", failed)
+	}
+
+	if err := os.WriteFile(path, []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := scrape(t, svc.GetPrometheusHandler(), nil); !strings.Contains(body, "scrape_backlog 1\n") {
+		t.Fatalf("抓取时应当查询 on_scrape 指标: %s", body)
+	}
+
+	if err := os.WriteFile(path, []byte("2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := scrape(t, svc.GetPrometheusHandler(), nil); !strings.Contains(body, "scrape_backlog 1\n") {
+		t.Fatalf("scrape_min_interval 内应当复用上次结果: %s", body)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if _, body := scrape(t, svc.GetPrometheusHandler(), nil); !strings.Contains(body, "scrape_backlog 2\n") {
+		t.Fatalf("缓存过期后应当重新查询: %s", body)
+	}
+
+	// 导出等直接 Gather 只输出上次的结果，不触发查询
+	if err := os.WriteFile(path, []byte("3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	families, err := svc.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "scrape_backlog" && family.GetMetric()[0].GetGauge().GetValue() != 2 {
+			t.Fatalf("Gather 不应查询 on_scrape 指标，实际 %v", family.GetMetric()[0].GetGauge().GetValue())
+		}
+	}
+}
+
+func TestScrapeTimeout(t *testing.T) {
+	svc := &Service{cfg: &config.Config{Schedule: config.ScheduleConfig{ScrapeTimeout: "3s"}}}
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if got := svc.scrapeTimeout(req); got != 3*time.Second {
+		t.Fatalf("未携带抓取超时时应当使用 scrape_timeout，实际 %s", got)
+	}
+	req.Header.Set(scrapeTimeoutHeader, "10")
+	if got := svc.scrapeTimeout(req); got != 9500*time.Millisecond {
+		t.Fatalf("应当使用抓取超时并预留编码时间，实际 %s", got)
+	}
+}
@@ -0,0 +1,32 @@
+package collectors
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// pluginStatsCollector 在抓取时读取各外部插件的监管状态，按插件名打标签导出。
+type pluginStatsCollector struct {
+	svc *Service
+
+	restarts *prometheus.Desc
+}
+
+func newPluginStatsCollector(svc *Service) *pluginStatsCollector {
+	return &pluginStatsCollector{
+		svc:      svc,
+		restarts: prometheus.NewDesc("collector_plugin_restarts_total", "插件进程崩溃或启动失败后被重新启动的次数", []string{"plugin"}, nil),
+	}
+}
+
+// Describe 实现 prometheus.Collector。
+func (c *pluginStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.restarts
+}
+
+// Collect 实现 prometheus.Collector。
+func (c *pluginStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.svc.mu.RLock()
+	defer c.svc.mu.RUnlock()
+
+	for name, client := range c.svc.plugins {
+		ch <- prometheus.MustNewConstMetric(c.restarts, prometheus.CounterValue, float64(client.Restarts()), name)
+	}
+}
@@ -0,0 +1,140 @@
+package collectors
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolStatsCollector 在抓取时读取各数据源连接池状态，按连接名称打标签导出。
+type poolStatsCollector struct {
+	svc *Service
+
+	mysql dbPoolDescs
+	sql   dbPoolDescs
+
+	redisHits       *prometheus.Desc
+	redisMisses     *prometheus.Desc
+	redisTimeouts   *prometheus.Desc
+	redisTotalConns *prometheus.Desc
+	redisIdleConns  *prometheus.Desc
+	redisStaleConns *prometheus.Desc
+}
+
+// dbPoolDescs 是基于 database/sql 的连接池（mysql 与 sql 数据源）的指标描述。
+type dbPoolDescs struct {
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+// newDBPoolDescs 按数据源生成指标描述，指标名为 collector_<source>_pool_*。
+func newDBPoolDescs(source, title string) dbPoolDescs {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("collector_"+source+"_pool_"+name, title+" "+help, []string{"connection"}, nil)
+	}
+	return dbPoolDescs{
+		maxOpen:           desc("max_open_connections", "连接池允许的最大连接数"),
+		open:              desc("open_connections", "连接池当前连接数（使用中与空闲）"),
+		inUse:             desc("in_use_connections", "连接池使用中的连接数"),
+		idle:              desc("idle_connections", "连接池空闲连接数"),
+		waitCount:         desc("wait_count_total", "等待连接的累计次数"),
+		waitDuration:      desc("wait_duration_seconds_total", "等待连接的累计时长"),
+		maxIdleClosed:     desc("max_idle_closed_total", "因超过 max_idle_conns 关闭的连接数"),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "因超过 conn_max_idle_time 关闭的连接数"),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "因超过 conn_max_lifetime 关闭的连接数"),
+	}
+}
+
+func (d dbPoolDescs) list() []*prometheus.Desc {
+	return []*prometheus.Desc{
+		d.maxOpen, d.open, d.inUse, d.idle, d.waitCount,
+		d.waitDuration, d.maxIdleClosed, d.maxIdleTimeClosed, d.maxLifetimeClosed,
+	}
+}
+
+// collect 输出一个连接池的状态。
+func (d dbPoolDescs) collect(ch chan<- prometheus.Metric, name string, stats sql.DBStats) {
+	ch <- prometheus.MustNewConstMetric(d.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections), name)
+	ch <- prometheus.MustNewConstMetric(d.open, prometheus.GaugeValue, float64(stats.OpenConnections), name)
+	ch <- prometheus.MustNewConstMetric(d.inUse, prometheus.GaugeValue, float64(stats.InUse), name)
+	ch <- prometheus.MustNewConstMetric(d.idle, prometheus.GaugeValue, float64(stats.Idle), name)
+	ch <- prometheus.MustNewConstMetric(d.waitCount, prometheus.CounterValue, float64(stats.WaitCount), name)
+	ch <- prometheus.MustNewConstMetric(d.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(d.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed), name)
+	ch <- prometheus.MustNewConstMetric(d.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed), name)
+	ch <- prometheus.MustNewConstMetric(d.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed), name)
+}
+
+func newPoolStatsCollector(svc *Service) *poolStatsCollector {
+	labels := []string{"connection"}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(name, help, labels, nil)
+	}
+	return &poolStatsCollector{
+		svc: svc,
+
+		mysql: newDBPoolDescs("mysql", "MySQL"),
+		sql:   newDBPoolDescs("sql", "SQL"),
+
+		redisHits:       desc("collector_redis_pool_hits_total", "Redis 连接池命中空闲连接的次数"),
+		redisMisses:     desc("collector_redis_pool_misses_total", "Redis 连接池未命中空闲连接的次数"),
+		redisTimeouts:   desc("collector_redis_pool_timeouts_total", "等待 Redis 连接超时的次数"),
+		redisTotalConns: desc("collector_redis_pool_total_connections", "Redis 连接池当前连接数"),
+		redisIdleConns:  desc("collector_redis_pool_idle_connections", "Redis 连接池空闲连接数"),
+		redisStaleConns: desc("collector_redis_pool_stale_connections_total", "Redis 连接池移除的失效连接数"),
+	}
+}
+
+// Describe 实现 prometheus.Collector。
+func (c *poolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	descs := append(c.mysql.list(), c.sql.list()...)
+	descs = append(descs, c.redisHits, c.redisMisses, c.redisTimeouts, c.redisTotalConns, c.redisIdleConns, c.redisStaleConns)
+	for _, d := range descs {
+		ch <- d
+	}
+}
+
+// Collect 实现 prometheus.Collector。
+func (c *poolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.svc.mu.RLock()
+	defer c.svc.mu.RUnlock()
+
+	for name, client := range c.svc.mysql {
+		c.mysql.collect(ch, name, client.Stats())
+	}
+	for name, client := range c.svc.sql {
+		c.sql.collect(ch, name, client.Stats())
+	}
+
+	if d := c.svc.discovery; d != nil {
+		d.mu.RLock()
+		for name, client := range d.mysql {
+			if _, shadowed := c.svc.mysql[name]; !shadowed {
+				c.mysql.collect(ch, name, client.Stats())
+			}
+		}
+		for name, client := range d.sql {
+			if _, shadowed := c.svc.sql[name]; !shadowed {
+				c.sql.collect(ch, name, client.Stats())
+			}
+		}
+		d.mu.RUnlock()
+	}
+
+	for name, client := range c.svc.redis {
+		stats := client.PoolStats()
+		ch <- prometheus.MustNewConstMetric(c.redisHits, prometheus.CounterValue, float64(stats.Hits), name)
+		ch <- prometheus.MustNewConstMetric(c.redisMisses, prometheus.CounterValue, float64(stats.Misses), name)
+		ch <- prometheus.MustNewConstMetric(c.redisTimeouts, prometheus.CounterValue, float64(stats.Timeouts), name)
+		ch <- prometheus.MustNewConstMetric(c.redisTotalConns, prometheus.GaugeValue, float64(stats.TotalConns), name)
+		ch <- prometheus.MustNewConstMetric(c.redisIdleConns, prometheus.GaugeValue, float64(stats.IdleConns), name)
+		ch <- prometheus.MustNewConstMetric(c.redisStaleConns, prometheus.CounterValue, float64(stats.StaleConns), name)
+	}
+}
@@ -0,0 +1,31 @@
+package collectors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestPoolStatsIncludeSQLConnections(t *testing.T) {
+	dsn := newShard(t, t.TempDir(), "orders.db", 1)
+	cfg := &config.Config{
+		Schedule:       config.ScheduleConfig{Interval: "1h"},
+		SQLConnections: map[string]config.SQLConfig{"edge": {Driver: "sqlite", DSN: dsn, MaxOpenConns: 2}},
+		Metrics: []config.MetricSpec{
+			{Name: "orders_total", Help: "订单数", Type: "gauge", Source: "sql", Connection: "edge", Query: "SELECT COUNT(*) FROM orders"},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+	_, body := scrape(t, svc.GetPrometheusHandler(), nil)
+	if !strings.Contains(body, `collector_sql_pool_max_open_connections{connection="edge"} 2`) {
+		t.Fatalf("缺少 SQL 连接池指标:\n%s", body)
+	}
+}
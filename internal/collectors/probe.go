@@ -0,0 +1,161 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// scalarQuerier 是探测模块所需的查询能力，MySQL 与通用 SQL 客户端都满足。
+type scalarQuerier interface {
+	QueryScalar(ctx context.Context, stmt string) (float64, error)
+}
+
+// probeTarget 是解析后的探测目标：已配置的连接或 allowed_targets 中的 DSN。
+type probeTarget struct {
+	name  string // 连接名，DSN 目标为空
+	mysql *config.MySQLConfig
+	sql   *config.SQLConfig
+}
+
+// display 返回可写入日志的目标描述，DSN 可能包含口令，不原样输出。
+func (t probeTarget) display() string {
+	if t.name == "" {
+		return "DSN"
+	}
+	return t.name
+}
+
+// ProbeHandler 返回 /probe?module=<name>&target=<target> 的处理器。模块的查询对 target 执行，
+// 结果与 probe_success、probe_duration_seconds 一起以 exposition 格式返回；查询失败时 probe_success 为 0。
+func (s *Service) ProbeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		moduleName, target := r.URL.Query().Get("module"), r.URL.Query().Get("target")
+		s.mu.RLock()
+		cfg := s.cfg
+		s.mu.RUnlock()
+
+		module, ok := cfg.Probe.Modules[moduleName]
+		if !ok {
+			http.Error(w, fmt.Sprintf("未知的探测模块 %q", moduleName), http.StatusBadRequest)
+			return
+		}
+		resolved, err := resolveProbeTarget(cfg, module, target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		timeout := 10 * time.Second
+		if module.Timeout != "" {
+			timeout, _ = time.ParseDuration(module.Timeout)
+		}
+		if r.Header.Get(scrapeTimeoutHeader) != "" {
+			timeout = min(timeout, s.scrapeTimeout(r))
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		registry := s.probe(ctx, moduleName, module, resolved)
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// resolveProbeTarget 先按模块数据源的连接名查找 target，否则要求其为 allowed_targets 中的 DSN。
+func resolveProbeTarget(cfg *config.Config, module config.ProbeModule, target string) (probeTarget, error) {
+	if target == "" {
+		return probeTarget{}, errors.New("缺少 target 参数")
+	}
+	switch module.Source {
+	case "mysql":
+		if conn, ok := cfg.MySQLConnections[target]; ok {
+			return probeTarget{name: target, mysql: &conn}, nil
+		}
+	case "sql":
+		if conn, ok := cfg.SQLConnections[target]; ok {
+			return probeTarget{name: target, sql: &conn}, nil
+		}
+	}
+	if !cfg.Probe.TargetAllowed(target) {
+		return probeTarget{}, fmt.Errorf("target 既不是已配置的 %s 连接，也不在 allowed_targets 中", module.Source)
+	}
+	if module.Driver == "" {
+		return probeTarget{}, errors.New("探测模块未配置 driver，不能直接探测 DSN")
+	}
+	return probeTarget{sql: &config.SQLConfig{Driver: module.Driver, DSN: target, MaxOpenConns: 1}}, nil
+}
+
+// probeClient 返回目标的查询客户端：已为指标建立的连接直接复用，否则临时创建，release 负责关闭临时连接。
+func (s *Service) probeClient(target probeTarget) (scalarQuerier, func(), error) {
+	if target.name != "" {
+		s.mu.RLock()
+		var client scalarQuerier
+		if target.mysql != nil {
+			if c, ok := s.mysql[target.name]; ok {
+				client = c
+			}
+		} else if c, ok := s.sql[target.name]; ok {
+			client = c
+		}
+		s.mu.RUnlock()
+		if client != nil {
+			return client, func() {}, nil
+		}
+	}
+	if target.mysql != nil {
+		client, err := datasource.NewMySQLClient(*target.mysql)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func() { client.Close() }, nil
+	}
+	client, err := datasource.NewSQLClient(*target.sql)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, func() { client.Close() }, nil
+}
+
+// probe 对目标执行模块中的全部查询，返回只包含本次结果的注册表。
+func (s *Service) probe(ctx context.Context, moduleName string, module config.ProbeModule, target probeTarget) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	success := prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_success", Help: "探测是否全部成功"})
+	duration := prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_duration_seconds", Help: "探测耗时（秒）"})
+	registry.MustRegister(success, duration)
+
+	start := time.Now()
+	defer func() { duration.Set(time.Since(start).Seconds()) }()
+
+	client, release, err := s.probeClient(target)
+	if err != nil {
+		log.Printf("探测模块 %s 连接目标 %s 失败: %v", moduleName, target.display(), err)
+		return registry
+	}
+	defer release()
+
+	ok := true
+	for _, spec := range module.Metrics {
+		value, err := client.QueryScalar(ctx, spec.Query)
+		if err != nil {
+			log.Printf("探测模块 %s 在目标 %s 上执行 %s 失败: %v", moduleName, target.display(), spec.Name, err)
+			ok = false
+			continue
+		}
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: spec.Name, Help: spec.Help, ConstLabels: spec.Labels})
+		gauge.Set(value)
+		registry.MustRegister(gauge)
+	}
+	if ok {
+		success.Set(1)
+	}
+	return registry
+}
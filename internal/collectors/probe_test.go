@@ -0,0 +1,90 @@
+package collectors
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func newShard(t *testing.T, dir, name string, orders int) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE orders (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < orders; i++ {
+		if _, err := db.Exec("INSERT INTO orders VALUES (?)", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestProbe(t *testing.T) {
+	dir := t.TempDir()
+	shardA := newShard(t, dir, "shard-a.db", 3)
+	shardB := newShard(t, dir, "shard-b.db", 5)
+	cfg := &config.Config{
+		Schedule:       config.ScheduleConfig{Interval: "1h"},
+		SQLConnections: map[string]config.SQLConfig{"shard-a": {Driver: "sqlite", DSN: shardA}},
+		Probe: config.ProbeConfig{
+			AllowedTargets: []string{filepath.Join(dir, "shard-*.db")},
+			Modules: map[string]config.ProbeModule{
+				"orders": {Source: "sql", Driver: "sqlite", Metrics: []config.ProbeMetric{
+					{Name: "shard_orders_total", Help: "订单数", Query: "SELECT COUNT(*) FROM orders", Labels: map[string]string{"app": "orders"}},
+				}},
+				"broken": {Source: "sql", Metrics: []config.ProbeMetric{
+					{Name: "shard_missing", Help: "不存在的表", Query: "SELECT COUNT(*) FROM missing"},
+				}},
+			},
+		},
+		Metrics: []config.MetricSpec{{Name: "probe_test_constant", Help: "常量", Source: "synthetic", Query: "constant?value=1"}},
+	}
+	if err := cfg.ApplyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	probe := func(query string) (int, string) {
+		rec := httptest.NewRecorder()
+		svc.ProbeHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?"+query, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := probe("module=orders&target=shard-a")
+	if code != http.StatusOK || !strings.Contains(body, `shard_orders_total{app="orders"} 3`) || !strings.Contains(body, "probe_success 1") {
+		t.Fatalf("按连接名探测结果不正确: %d %s", code, body)
+	}
+	if strings.Contains(body, "probe_test_constant") {
+		t.Fatalf("探测结果不应包含常规指标: %s", body)
+	}
+
+	code, body = probe("module=orders&target=" + shardB)
+	if code != http.StatusOK || !strings.Contains(body, `shard_orders_total{app="orders"} 5`) {
+		t.Fatalf("按允许的 DSN 探测结果不正确: %d %s", code, body)
+	}
+
+	if code, _ := probe("module=orders&target=" + filepath.Join(t.TempDir(), "other.db")); code != http.StatusBadRequest {
+		t.Fatalf("不在 allowed_targets 中的 DSN 应当被拒绝，实际 %d", code)
+	}
+	if code, _ := probe("module=unknown&target=shard-a"); code != http.StatusBadRequest {
+		t.Fatalf("未知模块应当返回 400，实际 %d", code)
+	}
+	if _, body := probe("module=broken&target=shard-a"); !strings.Contains(body, "probe_success 0") || strings.Contains(body, "shard_missing") {
+		t.Fatalf("查询失败时 probe_success 应为 0: %s", body)
+	}
+}
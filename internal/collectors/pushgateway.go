@@ -0,0 +1,52 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// RunOnce 执行一次采集周期并返回失败的指标数，供 CronJob 等批处理场景使用。
+// 批处理没有抓取方，on_scrape 指标也在本次周期中查询并计入失败数。
+func (s *Service) RunOnce(ctx context.Context) int {
+	return s.execute(ctx, true)
+}
+
+// PushToGateway 将注册表中的全部指标推送到 Pushgateway。使用 PUT 语义，
+// 同一 job 与分组下上次推送的指标会被整体替换，避免已删除的指标残留。
+func (s *Service) PushToGateway(ctx context.Context, cfg config.PushgatewayConfig) error {
+	tlsConfig, err := datasource.NewClientTLSConfig(cfg.TLS)
+	if err != nil {
+		return fmt.Errorf("Pushgateway TLS 配置无效: %w", err)
+	}
+	timeout := 30 * time.Second
+	if cfg.Timeout != "" {
+		if timeout, err = time.ParseDuration(cfg.Timeout); err != nil {
+			return fmt.Errorf("解析 Pushgateway timeout 失败: %w", err)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	pusher := push.New(cfg.URL, cfg.JobName()).
+		Gatherer(s.registry).
+		Client(&http.Client{Transport: transport, Timeout: timeout})
+	for name, value := range cfg.GroupingKey {
+		pusher = pusher.Grouping(name, value)
+	}
+	if cfg.Username != "" {
+		pusher = pusher.BasicAuth(cfg.Username, cfg.Password)
+	}
+	if err := pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("推送到 Pushgateway %s 失败: %w", cfg.URL, err)
+	}
+	log.Printf("已推送指标到 Pushgateway %s（job=%s）", cfg.URL, cfg.JobName())
+	return nil
+}
@@ -0,0 +1,59 @@
+package collectors
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestRunOncePushesToGateway(t *testing.T) {
+	var method, path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	cfg := &config.Config{
+		Schedule: config.ScheduleConfig{Interval: "24h"},
+		Metrics: []config.MetricSpec{
+			{Name: "batch_orders_total", Help: "每日订单数", Source: "synthetic", Query: "constant?value=42"},
+			{Name: "batch_flaky", Help: "必定失败", Source: "synthetic", Query: "constant?value=1&dropout=1"},
+		},
+	}
+	if err := cfg.ApplyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	if failed := svc.RunOnce(context.Background()); failed != 1 {
+		t.Fatalf("期望 1 个指标失败，实际 %d", failed)
+	}
+	pushCfg := config.PushgatewayConfig{URL: gateway.URL, Job: "daily", GroupingKey: map[string]string{"site": "sh"}}
+	if err := svc.PushToGateway(context.Background(), pushCfg); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/daily/site/sh" {
+		t.Fatalf("期望 PUT /metrics/job/daily/site/sh，实际 %s %s", method, path)
+	}
+	if !strings.Contains(body, "batch_orders_total") || !strings.Contains(body, "collector_errors_total") {
+		t.Fatalf("推送内容应当包含业务指标与自监控指标")
+	}
+
+	gateway.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	if err := svc.PushToGateway(context.Background(), pushCfg); err == nil {
+		t.Fatalf("Pushgateway 返回错误时应当失败")
+	}
+}
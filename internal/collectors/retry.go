@@ -0,0 +1,75 @@
+package collectors
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+func newRetryCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "collector_metric_retries_total",
+		Help: "指标查询失败后重试的次数",
+	}, []string{"metric"})
+}
+
+// queryMetric 按指标生效的重试策略执行查询，仅对可重试的错误重试。
+func (s *Service) queryMetric(ctx context.Context, spec config.MetricSpec) (float64, error) {
+	var value float64
+	err := s.withRetry(ctx, spec, func() (err error) {
+		value, err = s.queryMetricOnce(ctx, spec)
+		return err
+	})
+	return value, err
+}
+
+// withRetry 按指标生效的重试策略反复调用 query，直到成功、错误不可重试或次数用尽。
+func (s *Service) withRetry(ctx context.Context, spec config.MetricSpec, query func() error) error {
+	policy := s.cfg.RetryConfigFor(spec)
+	attempts := policy.Attempts()
+	base, limit := policy.BackoffRange()
+
+	for attempt := 1; ; attempt++ {
+		err := query()
+		if err == nil || attempt >= attempts || !datasource.IsRetryable(err, policy) {
+			return err
+		}
+
+		wait := retryBackoff(base, limit, policy.Jitter, attempt)
+		log.Printf("指标 %s 第 %d 次查询失败，%s 后重试: %v", spec.Name, attempt, wait, err)
+		s.retries.WithLabelValues(spec.Name).Inc()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryBackoff 计算第 attempt 次失败后的等待时间：指数增长、受上限约束并叠加随机抖动。
+func retryBackoff(base, limit time.Duration, jitter float64, attempt int) time.Duration {
+	wait := base
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	if jitter > 0 {
+		delta := float64(wait) * jitter
+		wait += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
@@ -0,0 +1,121 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// updateSeries 查询多序列结果并整体替换 gauge 族的子指标，返回是否成功。
+// 查询失败时清空全部子指标，避免继续暴露过期序列。
+func (s *Service) updateSeries(ctx context.Context, holder metricHolder) bool {
+	start := time.Now()
+	log.Printf("开始更新指标 %s (source=%s)", holder.spec.Name, holder.spec.Source)
+	samples, err := s.queryMetricSeries(ctx, holder.spec)
+	if err != nil {
+		log.Printf("更新指标 %s 失败: %v", holder.spec.Name, err)
+		holder.vec.Reset()
+		s.errorCount.Inc()
+		return false
+	}
+
+	rows, err := seriesRows(holder.spec.SeriesLabels, samples)
+	if err != nil {
+		log.Printf("更新指标 %s 失败: %v", holder.spec.Name, err)
+		holder.vec.Reset()
+		s.errorCount.Inc()
+		return false
+	}
+
+	holder.vec.Reset()
+	for _, row := range rows {
+		holder.vec.WithLabelValues(row.values...).Set(row.value)
+		s.emitSample(holder.spec, row.series, row.value)
+	}
+	log.Printf("指标 %s 更新成功，序列数=%d，耗时=%s", holder.spec.Name, len(samples), time.Since(start))
+	return true
+}
+
+// seriesRow 是按 series_labels 投影后的一个序列。
+type seriesRow struct {
+	values []string
+	series map[string]string
+	value  float64
+}
+
+// seriesRows 将查询结果投影到 series_labels。多个序列投影后取值相同时返回错误并指出冲突的序列，
+// 否则后写入的序列会静默覆盖前一个，应当在查询中聚合掉多余的 label 或将其加入 series_labels。
+func seriesRows(labels []string, samples []datasource.Sample) ([]seriesRow, error) {
+	rows := make([]seriesRow, 0, len(samples))
+	seen := make(map[string]int, len(samples))
+	for i, sample := range samples {
+		row := seriesRow{
+			values: make([]string, len(labels)),
+			series: make(map[string]string, len(labels)),
+			value:  sample.Value,
+		}
+		for j, name := range labels {
+			row.values[j] = sample.Labels[name]
+			row.series[name] = sample.Labels[name]
+		}
+		key := strings.Join(row.values, "\xff")
+		if prev, ok := seen[key]; ok {
+			return nil, fmt.Errorf("序列 %s 与 %s 的 series_labels 取值相同（%s），请在查询中聚合或将区分它们的 label 加入 series_labels",
+				formatLabels(samples[prev].Labels), formatLabels(sample.Labels), formatLabels(row.series))
+		}
+		seen[key] = i
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// formatLabels 按名称排序输出 {name="value",...}。
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%q", name, labels[name])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// queryMetricSeries 按重试策略执行多序列查询，并按 series_selector 过滤。
+func (s *Service) queryMetricSeries(ctx context.Context, spec config.MetricSpec) ([]datasource.Sample, error) {
+	var samples []datasource.Sample
+	err := s.withRetry(ctx, spec, func() (err error) {
+		samples, err = s.queryMetricSeriesOnce(ctx, spec)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return datasource.SelectSamples(samples, spec.SeriesSelector), nil
+}
+
+func (s *Service) queryMetricSeriesOnce(ctx context.Context, spec config.MetricSpec) ([]datasource.Sample, error) {
+	switch spec.Source {
+	case "prometheus":
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		client, ok := s.prometheus[conn]
+		if !ok {
+			return nil, fmt.Errorf("Prometheus 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 PromQL 查询（连接=%s）: %s", conn, spec.Query)
+		return client.QuerySeries(ctx, spec.Query)
+	default:
+		return nil, fmt.Errorf("数据源 %s 不支持多序列查询", spec.Source)
+	}
+}
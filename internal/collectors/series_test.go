@@ -0,0 +1,63 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestSeriesLabelsCollision(t *testing.T) {
+	var site atomic.Value
+	site.Store("s1")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"site":"s1","region":"east"},"value":[0,"3"]},
+			{"metric":{"site":%q,"region":"west"},"value":[0,"4"]}]}}`, site.Load())
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{
+		Schedule:              config.ScheduleConfig{Interval: "1h"},
+		PrometheusConnections: map[string]config.PrometheusSourceConfig{"default": {URL: srv.URL}},
+		Metrics: []config.MetricSpec{
+			{Name: "online_devices", Help: "在线设备数", Source: "prometheus", Query: "sum by (site, region) (up)", SeriesLabels: []string{"site"}},
+		},
+	}
+	if err := cfg.ApplyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	if failed := svc.RunOnce(context.Background()); failed != 1 {
+		t.Fatalf("series_labels 取值冲突时更新应当失败，实际失败指标数 %d", failed)
+	}
+	_, body := scrape(t, svc.GetPrometheusHandler(), nil)
+	if strings.Contains(body, `online_devices{site="s1"}`) {
+		t.Fatalf("冲突的序列不应被发布:\n%s", body)
+	}
+	holder := svc.metrics[0]
+	samples, err := svc.queryMetricSeries(context.Background(), holder.spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = seriesRows(holder.spec.SeriesLabels, samples)
+	if err == nil || !strings.Contains(err.Error(), `{region="east",site="s1"}`) || !strings.Contains(err.Error(), `{region="west",site="s1"}`) || !strings.Contains(err.Error(), `{site="s1"}`) {
+		t.Fatalf("错误应当指出冲突的序列与 series_labels 取值，实际 %v", err)
+	}
+
+	site.Store("s2")
+	if failed := svc.RunOnce(context.Background()); failed != 0 {
+		t.Fatalf("序列不冲突时不应失败，实际失败指标数 %d", failed)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"math"
 	"net/http"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,27 +18,70 @@ import (
 	"github.com/company/ems-devices/internal/alerts"
 	"github.com/company/ems-devices/internal/config"
 	"github.com/company/ems-devices/internal/datasource"
+	"github.com/company/ems-devices/internal/discovery"
+	"github.com/company/ems-devices/internal/otlp"
+	"github.com/company/ems-devices/internal/remotewrite"
+	"github.com/company/ems-devices/internal/sinks"
 )
 
 // Service 负责调度查询并更新 Prometheus 指标。
 type Service struct {
-	cfg             *config.Config
-	mysql           map[string]*datasource.MySQLClient
-	redis           map[string]*datasource.RedisClient
-	iotdb           *datasource.IoTDBClient
-	restapi         map[string]*datasource.RestAPIClient
-	metrics         []metricHolder
-	errorCount      prometheus.Counter
-	lastRun         prometheus.Gauge
-	registry        *prometheus.Registry
-	alertEvaluator  *alerts.Evaluator
-	currentValues   map[string]float64 // Track current metric values for alerts
-	mu              sync.RWMutex
+	cfg              *config.Config
+	mysql            map[string]*datasource.MySQLClient
+	redis            map[string]*datasource.RedisClient
+	iotdb            *datasource.IoTDBClient
+	restapi          map[string]*datasource.RestAPIClient
+	modbus           map[string]*datasource.ModbusClient
+	mqtt             map[string]*datasource.MQTTClient
+	prometheus       map[string]*datasource.PrometheusClient
+	snmp             map[string]*datasource.SNMPClient
+	elasticsearch    map[string]*datasource.ElasticsearchClient
+	mongodb          map[string]*datasource.MongoDBClient
+	sql              map[string]*datasource.SQLClient
+	synthetic        *datasource.SyntheticClient
+	plugins          map[string]*datasource.PluginClient // 按插件名索引
+	remoteWrite      *remotewrite.Writer
+	otlpExporter     *otlp.Exporter
+	sinks            []*sinks.Writer
+	sinkMetrics      *sinks.Metrics
+	discovery        *connectionDiscovery // 连接发现维护的客户端，未配置时为 nil
+	discoveryMetrics *discovery.Metrics
+	watermarks       *watermarkStore // 增量指标的水位，未配置增量指标或读取失败时为 nil
+	metrics          []metricHolder
+	errorCount       prometheus.Counter
+	lastRun          prometheus.Gauge
+	poolStats        *poolStatsCollector
+	pluginStats      *pluginStatsCollector
+	retries          *prometheus.CounterVec
+	registry         *prometheus.Registry
+	runtime          *prometheus.Registry    // Go 运行时与进程指标，只在 /metrics 中暴露
+	groups           map[string]*metricGroup // 按分组名索引
+	hidden           map[string]struct{}     // 属于带认证分组、不在 /metrics 中暴露的指标名
+	alertEvaluator   *alerts.Evaluator
+	currentValues    map[string]float64 // Track current metric values for alerts
+	mu               sync.RWMutex
 }
 
 type metricHolder struct {
-	spec  config.MetricSpec
-	gauge prometheus.Gauge
+	spec    config.MetricSpec
+	gauge   prometheus.Gauge
+	vec     *prometheus.GaugeVec // 配置了 series_labels 的标签族指标
+	scrape  *scrapeMetric        // on_scrape 指标，被抓取时查询
+	counter prometheus.Counter   // 配置了 watermark 的增量指标
+}
+
+// collector 返回需要注册到 Prometheus 的采集器。
+func (h metricHolder) collector() prometheus.Collector {
+	if h.scrape != nil {
+		return h.scrape
+	}
+	if h.counter != nil {
+		return h.counter
+	}
+	if h.vec != nil {
+		return h.vec
+	}
+	return h.gauge
 }
 
 // NewService 构造采集服务，按需初始化数据源。
@@ -48,7 +92,17 @@ func NewService(cfg *config.Config) (*Service, error) {
 		mysql:         make(map[string]*datasource.MySQLClient),
 		redis:         make(map[string]*datasource.RedisClient),
 		restapi:       make(map[string]*datasource.RestAPIClient),
+		modbus:        make(map[string]*datasource.ModbusClient),
+		mqtt:          make(map[string]*datasource.MQTTClient),
+		prometheus:    make(map[string]*datasource.PrometheusClient),
+		snmp:          make(map[string]*datasource.SNMPClient),
+		elasticsearch: make(map[string]*datasource.ElasticsearchClient),
+		mongodb:       make(map[string]*datasource.MongoDBClient),
+		sql:           make(map[string]*datasource.SQLClient),
+		synthetic:     datasource.NewSyntheticClient(),
+		plugins:       make(map[string]*datasource.PluginClient),
 		registry:      prometheus.NewRegistry(),
+		runtime:       newRuntimeRegistry(),
 		currentValues: make(map[string]float64),
 	}
 
@@ -106,6 +160,117 @@ func NewService(cfg *config.Config) (*Service, error) {
 			svc.restapi[connName] = client
 		}
 	}
+
+	// 初始化 Modbus 连接（失败时只记录警告，不阻止服务启动）
+	for connName := range modbusConnectionsNeeded(cfg) {
+		modbusCfg, ok := cfg.ModbusConfigFor(connName)
+		if !ok {
+			log.Printf("警告: 未找到 Modbus 连接配置 %s，相关指标将无法采集", connName)
+			continue
+		}
+		client, err := datasource.NewModbusClient(modbusCfg)
+		if err != nil {
+			log.Printf("警告: Modbus 连接 %s 失败，相关指标将无法采集: %v", connName, err)
+		} else {
+			svc.modbus[connName] = client
+		}
+	}
+
+	// 初始化 MQTT 连接（失败时只记录警告，不阻止服务启动）
+	for connName := range mqttConnectionsNeeded(cfg) {
+		mqttCfg, ok := cfg.MQTTConfigFor(connName)
+		if !ok {
+			log.Printf("警告: 未找到 MQTT 连接配置 %s，相关指标将无法采集", connName)
+			continue
+		}
+		client, err := datasource.NewMQTTClient(mqttCfg)
+		if err != nil {
+			log.Printf("警告: MQTT 连接 %s 失败，相关指标将无法采集: %v", connName, err)
+		} else {
+			svc.mqtt[connName] = client
+		}
+	}
+	// 初始化 SNMP 连接（失败时只记录警告，不阻止服务启动）
+	for connName := range snmpConnectionsNeeded(cfg) {
+		snmpCfg, ok := cfg.SNMPConfigFor(connName)
+		if !ok {
+			log.Printf("警告: 未找到 SNMP 连接配置 %s，相关指标将无法采集", connName)
+			continue
+		}
+		client, err := datasource.NewSNMPClient(snmpCfg)
+		if err != nil {
+			log.Printf("警告: SNMP 连接 %s 失败，相关指标将无法采集: %v", connName, err)
+		} else {
+			svc.snmp[connName] = client
+		}
+	}
+	// 初始化 Prometheus 连接（失败时只记录警告，不阻止服务启动）
+	for connName := range prometheusConnectionsNeeded(cfg) {
+		prometheusCfg, ok := cfg.PrometheusSourceConfigFor(connName)
+		if !ok {
+			log.Printf("警告: 未找到 Prometheus 连接配置 %s，相关指标将无法采集", connName)
+			continue
+		}
+		client, err := datasource.NewPrometheusClient(prometheusCfg)
+		if err != nil {
+			log.Printf("警告: Prometheus 连接 %s 失败，相关指标将无法采集: %v", connName, err)
+		} else {
+			svc.prometheus[connName] = client
+		}
+	}
+	// 初始化 MongoDB 连接（失败时只记录警告，不阻止服务启动）
+	for connName := range mongodbConnectionsNeeded(cfg) {
+		mongodbCfg, ok := cfg.MongoDBConfigFor(connName)
+		if !ok {
+			log.Printf("警告: 未找到 MongoDB 连接配置 %s，相关指标将无法采集", connName)
+			continue
+		}
+		client, err := datasource.NewMongoDBClient(mongodbCfg)
+		if err != nil {
+			log.Printf("警告: MongoDB 连接 %s 失败，相关指标将无法采集: %v", connName, err)
+		} else {
+			svc.mongodb[connName] = client
+		}
+	}
+	// 初始化 Elasticsearch 连接（失败时只记录警告，不阻止服务启动）
+	for connName := range elasticsearchConnectionsNeeded(cfg) {
+		elasticsearchCfg, ok := cfg.ElasticsearchConfigFor(connName)
+		if !ok {
+			log.Printf("警告: 未找到 Elasticsearch 连接配置 %s，相关指标将无法采集", connName)
+			continue
+		}
+		client, err := datasource.NewElasticsearchClient(elasticsearchCfg)
+		if err != nil {
+			log.Printf("警告: Elasticsearch 连接 %s 失败，相关指标将无法采集: %v", connName, err)
+		} else {
+			svc.elasticsearch[connName] = client
+		}
+	}
+	// 初始化 SQL 连接（失败时只记录警告，不阻止服务启动）
+	for connName := range sqlConnectionsNeeded(cfg) {
+		sqlCfg, ok := cfg.SQLConfigFor(connName)
+		if !ok {
+			log.Printf("警告: 未找到 SQL 连接配置 %s，相关指标将无法采集", connName)
+			continue
+		}
+		client, err := datasource.NewSQLClient(sqlCfg)
+		if err != nil {
+			log.Printf("警告: SQL 连接 %s 失败，相关指标将无法采集: %v", connName, err)
+		} else {
+			svc.sql[connName] = client
+		}
+	}
+	// 增量指标的累计值需要在创建 counter 前读取
+	svc.watermarks = loadWatermarks(cfg)
+	// 启动外部插件（启动失败时由监管协程按退避策略重试，不阻止服务启动）
+	for name := range pluginsNeeded(cfg) {
+		client, err := datasource.StartPluginClient(name, cfg.Plugins[name])
+		if err != nil {
+			log.Printf("警告: 插件 %s 配置无效，相关指标将无法采集: %v", name, err)
+			continue
+		}
+		svc.plugins[name] = client
+	}
 	for _, spec := range cfg.Metrics {
 		if spec.Enabled != nil && !*spec.Enabled {
 			continue
@@ -118,17 +283,9 @@ func NewService(cfg *config.Config) (*Service, error) {
 		var metric prometheus.Collector
 		switch metricType {
 		case "gauge":
-			metric = prometheus.NewGauge(prometheus.GaugeOpts{
-				Name:        spec.Name,
-				Help:        spec.Help,
-				ConstLabels: spec.Labels,
-			})
+			metric = svc.newGaugeMetric(cfg, spec)
 		case "counter":
-			metric = prometheus.NewCounter(prometheus.CounterOpts{
-				Name:        spec.Name,
-				Help:        spec.Help,
-				ConstLabels: spec.Labels,
-			})
+			metric = svc.newCounterMetric(spec)
 		case "histogram":
 			buckets := spec.Buckets
 			if len(buckets) == 0 {
@@ -159,11 +316,8 @@ func NewService(cfg *config.Config) (*Service, error) {
 			return nil, fmt.Errorf("注册指标 %s 失败: %w", spec.Name, err)
 		}
 
-		if gauge, ok := metric.(prometheus.Gauge); ok {
-			svc.metrics = append(svc.metrics, metricHolder{
-				spec:  spec,
-				gauge: gauge,
-			})
+		if holder, ok := newMetricHolder(spec, metric); ok {
+			svc.metrics = append(svc.metrics, holder)
 		}
 	}
 
@@ -175,13 +329,47 @@ func NewService(cfg *config.Config) (*Service, error) {
 		Name: "collector_last_success_timestamp_seconds",
 		Help: "最近一次成功采集的 Unix 时间戳",
 	})
-	svc.registry.MustRegister(svc.errorCount, svc.lastRun)
+	svc.poolStats = newPoolStatsCollector(svc)
+	svc.pluginStats = newPluginStatsCollector(svc)
+	svc.retries = newRetryCounter()
+	svc.registry.MustRegister(svc.errorCount, svc.lastRun, svc.poolStats, svc.pluginStats, svc.retries)
+	svc.sinkMetrics = sinks.NewMetrics()
+	svc.registry.MustRegister(svc.sinkMetrics.Collectors()...)
+	svc.discoveryMetrics = discovery.NewMetrics()
+	svc.registry.MustRegister(svc.discoveryMetrics.Collectors()...)
 
 	// 同时注册到默认注册表以保持兼容性
-	prometheus.DefaultRegisterer.MustRegister(svc.errorCount, svc.lastRun)
+	prometheus.DefaultRegisterer.MustRegister(svc.errorCount, svc.lastRun, svc.poolStats, svc.pluginStats, svc.retries)
+	prometheus.DefaultRegisterer.MustRegister(svc.sinkMetrics.Collectors()...)
+	prometheus.DefaultRegisterer.MustRegister(svc.discoveryMetrics.Collectors()...)
 	for _, holder := range svc.metrics {
-		prometheus.DefaultRegisterer.MustRegister(holder.gauge)
+		prometheus.DefaultRegisterer.MustRegister(holder.collector())
+	}
+	svc.rebuildGroups(cfg)
+	svc.syncMQTTSubscriptions(cfg)
+
+	if cfg.RemoteWrite.Enabled {
+		writer, err := remotewrite.New(cfg.RemoteWrite)
+		if err != nil {
+			log.Printf("警告: remote_write 初始化失败，样本将不会推送: %v", err)
+		} else {
+			svc.remoteWrite = writer
+		}
+	}
+	if cfg.OTLP.Enabled {
+		exporter, err := otlp.New(cfg.OTLP)
+		if err != nil {
+			log.Printf("警告: OTLP 导出初始化失败，指标将不会导出: %v", err)
+		} else {
+			svc.otlpExporter = exporter
+		}
+	}
+	if writers, err := newSinks(cfg, svc.sinkMetrics); err != nil {
+		log.Printf("警告: %v，采集结果将不会写回", err)
+	} else {
+		svc.sinks = writers
 	}
+	svc.discovery = startConnectionDiscovery(cfg.ConnectionDiscovery, svc.discoveryMetrics)
 
 	return svc, nil
 }
@@ -196,50 +384,90 @@ func needsSource(metrics []config.MetricSpec, source string) bool {
 }
 
 func mysqlConnectionsNeeded(cfg *config.Config) map[string]struct{} {
-	required := make(map[string]struct{})
-	for _, m := range cfg.Metrics {
-		if m.Source != "mysql" {
-			continue
-		}
-		name := m.Connection
-		if name == "" {
-			name = "default"
-		}
-		required[name] = struct{}{}
-	}
-	return required
+	return connectionsNeeded(cfg, "mysql")
 }
 
 func redisConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "redis")
+}
+
+func restapiConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "restapi")
+}
+
+func modbusConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "modbus")
+}
+
+func mqttConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "mqtt")
+}
+
+func snmpConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "snmp")
+}
+
+func prometheusConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "prometheus")
+}
+
+func mongodbConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "mongodb")
+}
+
+func elasticsearchConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "elasticsearch")
+}
+
+func sqlConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+	return connectionsNeeded(cfg, "sql")
+}
+
+// pluginsNeeded 返回指标引用到的插件名称。
+func pluginsNeeded(cfg *config.Config) map[string]struct{} {
 	required := make(map[string]struct{})
 	for _, m := range cfg.Metrics {
-		if m.Source != "redis" {
-			continue
-		}
-		name := m.Connection
-		if name == "" {
-			name = "default"
+		if _, ok := cfg.Plugins[m.Source]; ok {
+			required[m.Source] = struct{}{}
 		}
-		required[name] = struct{}{}
 	}
 	return required
 }
 
-func restapiConnectionsNeeded(cfg *config.Config) map[string]struct{} {
+// connectionsNeeded 返回指标引用到的指定数据源连接名称，fan-out 指标展开为匹配到的配置文件中的连接，
+// 由连接发现提供的连接不在其中。
+func connectionsNeeded(cfg *config.Config, source string) map[string]struct{} {
 	required := make(map[string]struct{})
 	for _, m := range cfg.Metrics {
-		if m.Source != "restapi" {
+		if m.Source != source {
+			continue
+		}
+		configured := cfg.ConnectionNames(source)
+		if !m.FanOut() {
+			name := connectionName(m.Connection)
+			if cfg.ConnectionDiscovery.Discovers(source) && !containsString(configured, name) {
+				continue
+			}
+			required[name] = struct{}{}
 			continue
 		}
-		name := m.Connection
-		if name == "" {
-			name = "default"
+		for _, name := range config.MatchConnections(m.ConnectionPatterns(), configured) {
+			if containsString(configured, name) {
+				required[name] = struct{}{}
+			}
 		}
-		required[name] = struct{}{}
 	}
 	return required
 }
 
+// connectionName 返回连接名称，未指定时为 default。
+func connectionName(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
 // Run 启动周期性采集流程。
 func (s *Service) Run(ctx context.Context) {
 	interval, err := s.cfg.Schedule.IntervalDuration()
@@ -250,45 +478,38 @@ func (s *Service) Run(ctx context.Context) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	s.execute(ctx)
+	s.execute(ctx, false)
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			s.execute(ctx)
+			s.execute(ctx, false)
 		}
 	}
 }
 
-func (s *Service) execute(ctx context.Context) {
+// execute 执行一个采集周期，返回失败的指标数。includeOnScrape 为 true 时同时查询 on_scrape 指标。
+func (s *Service) execute(ctx context.Context, includeOnScrape bool) int {
 	log.Printf("开始执行采集周期，共 %d 个指标", len(s.metrics))
 	var success bool
+	var failed int
 	for _, holder := range s.metrics {
 		if holder.spec.Enabled != nil && !*holder.spec.Enabled {
 			continue
 		}
-		start := time.Now()
-		log.Printf("开始更新指标 %s (source=%s)", holder.spec.Name, holder.spec.Source)
-		value, err := s.queryMetric(ctx, holder.spec)
-		if err != nil {
-			log.Printf("更新指标 %s 失败: %v", holder.spec.Name, err)
-			holder.gauge.Set(math.NaN())
-			s.errorCount.Inc()
+		if holder.spec.Mode == config.MetricModeOnMessage {
+			// 消息到达时已即时更新
 			continue
 		}
-		holder.gauge.Set(value)
-		success = true
-		log.Printf("指标 %s 更新成功，值=%.3f，耗时=%s", holder.spec.Name, value, time.Since(start))
-
-		// 存储当前指标值供告警使用
-		s.mu.Lock()
-		s.currentValues[holder.spec.Name] = value
-		s.mu.Unlock()
-
-		// 保存指标值到告警存储
-		if s.alertEvaluator != nil {
-			s.alertEvaluator.MetricStore().AddValue(holder.spec.Name, value)
+		if holder.scrape != nil && !includeOnScrape {
+			// 被抓取时查询
+			continue
+		}
+		if s.updateHolder(ctx, holder) {
+			success = true
+		} else {
+			failed++
 		}
 	}
 	if success {
@@ -297,14 +518,86 @@ func (s *Service) execute(ctx context.Context) {
 	} else {
 		log.Printf("采集周期无成功指标，请检查数据源或配置")
 	}
+	s.pushExports()
 
 	// 触发 collection 模式告警评估
 	if s.alertEvaluator != nil {
 		go s.alertEvaluator.EvaluateCollectionModeAlerts(ctx)
 	}
+	return failed
+}
+
+// updateHolder 查询并更新一个指标，返回是否成功。
+func (s *Service) updateHolder(ctx context.Context, holder metricHolder) bool {
+	if holder.spec.Watermark != nil {
+		return s.updateWatermark(ctx, holder)
+	}
+	if holder.spec.FanOut() {
+		return s.updateFanOut(ctx, holder)
+	}
+	if holder.vec != nil {
+		return s.updateSeries(ctx, holder)
+	}
+	start := time.Now()
+	log.Printf("开始更新指标 %s (source=%s)", holder.spec.Name, holder.spec.Source)
+	value, err := s.queryMetric(ctx, holder.spec)
+	if err != nil {
+		log.Printf("更新指标 %s 失败: %v", holder.spec.Name, err)
+		holder.gauge.Set(math.NaN())
+		s.errorCount.Inc()
+		return false
+	}
+	holder.gauge.Set(value)
+	log.Printf("指标 %s 更新成功，值=%.3f，耗时=%s", holder.spec.Name, value, time.Since(start))
+	s.recordValue(holder.spec.Name, value)
+	s.emitSample(holder.spec, nil, value)
+	return true
+}
+
+// pushExports 将本周期注册表中的全部指标交给 remote_write 与 OTLP 异步导出，与 /metrics 暴露的业务与自监控指标一致。
+func (s *Service) pushExports() {
+	s.mu.RLock()
+	writer, exporter := s.remoteWrite, s.otlpExporter
+	s.mu.RUnlock()
+	if writer == nil && exporter == nil {
+		return
+	}
+	families, err := s.registry.Gather()
+	if err != nil {
+		// Gather 出错时仍会返回其余可用的指标
+		log.Printf("收集导出样本时出错: %v", err)
+	}
+	now := time.Now()
+	if writer != nil {
+		writer.Append(remotewrite.FromMetricFamilies(families, now))
+	}
+	if exporter != nil {
+		exporter.Push(families, now)
+	}
+}
+
+// collectionWindow 返回本次采集覆盖的时间窗口：[当前时间 - 采集周期, 当前时间)。
+func (s *Service) collectionWindow() datasource.TimeWindow {
+	interval, err := s.cfg.Schedule.IntervalDuration()
+	if err != nil {
+		interval = time.Hour
+	}
+	return datasource.NewTimeWindow(time.Now(), interval)
+}
+
+// recordValue 存储当前指标值并写入告警存储，供告警评估使用。
+func (s *Service) recordValue(name string, value float64) {
+	s.mu.Lock()
+	s.currentValues[name] = value
+	evaluator := s.alertEvaluator
+	s.mu.Unlock()
+
+	if evaluator != nil {
+		evaluator.MetricStore().AddValue(name, value)
+	}
 }
 
-func (s *Service) queryMetric(ctx context.Context, spec config.MetricSpec) (float64, error) {
+func (s *Service) queryMetricOnce(ctx context.Context, spec config.MetricSpec) (float64, error) {
 	switch spec.Source {
 	case "mysql":
 		conn := spec.Connection
@@ -312,6 +605,9 @@ func (s *Service) queryMetric(ctx context.Context, spec config.MetricSpec) (floa
 			conn = "default"
 		}
 		client, ok := s.mysql[conn]
+		if !ok {
+			client, ok = s.discoveredMySQL(conn)
+		}
 		if !ok {
 			return 0, fmt.Errorf("MySQL 连接 %s 未初始化", conn)
 		}
@@ -345,9 +641,138 @@ func (s *Service) queryMetric(ctx context.Context, spec config.MetricSpec) (floa
 		}
 		log.Printf("执行 RestAPI 查询（连接=%s）: %s", conn, spec.Query)
 		return client.QueryScalar(ctx, spec.Query, spec.ResultField)
+	case "modbus":
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		client, ok := s.modbus[conn]
+		if !ok {
+			return 0, fmt.Errorf("Modbus 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 Modbus 读取（连接=%s）: %s", conn, spec.Query)
+		return client.QueryScalar(ctx, spec.Query)
+	case "mqtt":
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		client, ok := s.mqtt[conn]
+		if !ok {
+			return 0, fmt.Errorf("MQTT 连接 %s 未初始化", conn)
+		}
+		maxAge, _ := time.ParseDuration(spec.MaxAge)
+		log.Printf("读取 MQTT 最新消息（连接=%s）: %s", conn, spec.Query)
+		return client.QueryScalar(ctx, spec.Query, spec.ResultField, maxAge)
+	case "snmp":
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		client, ok := s.snmp[conn]
+		if !ok {
+			return 0, fmt.Errorf("SNMP 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 SNMP 查询（连接=%s）: %s", conn, spec.Query)
+		return client.QueryScalar(ctx, spec.Query)
+	case "prometheus":
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		client, ok := s.prometheus[conn]
+		if !ok {
+			return 0, fmt.Errorf("Prometheus 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 PromQL 查询（连接=%s）: %s", conn, spec.Query)
+		return client.QueryScalar(ctx, spec.Query, spec.SeriesSelector)
+	case "mongodb":
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		client, ok := s.mongodb[conn]
+		if !ok {
+			return 0, fmt.Errorf("MongoDB 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 MongoDB 查询（连接=%s）: %s", conn, spec.Query)
+		return client.QueryScalar(ctx, spec.Query, spec.ResultField)
+	case "elasticsearch":
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		client, ok := s.elasticsearch[conn]
+		if !ok {
+			return 0, fmt.Errorf("Elasticsearch 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 Elasticsearch 查询（连接=%s）: %s", conn, spec.Query)
+		return client.QueryScalar(ctx, spec.Query, spec.ResultField, s.collectionWindow())
+	case "synthetic":
+		log.Printf("生成合成数据: %s", spec.Query)
+		return s.synthetic.QueryScalar(ctx, s.cfg.File, spec.Name, spec.Query)
+	case "file":
+		maxAge, _ := time.ParseDuration(spec.MaxAge)
+		log.Printf("读取文件: %s", spec.Query)
+		return datasource.QueryFile(s.cfg.File, spec.Query, spec.OutputParse(), spec.ResultField, maxAge)
+	case "command":
+		runner := datasource.NewCommandRunner(s.cfg.Command)
+		log.Printf("执行命令: %s %s", spec.Query, strings.Join(spec.Args, " "))
+		return runner.QueryScalar(ctx, spec.Query, spec.Args, s.cfg.Command.TimeoutFor(spec), spec.OutputParse(), spec.ResultField)
+	case "sql":
+		conn := spec.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		client, ok := s.sql[conn]
+		if !ok {
+			client, ok = s.discoveredSQL(conn)
+		}
+		if !ok {
+			return 0, fmt.Errorf("SQL 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 SQL 查询（连接=%s）: %s", conn, spec.Query)
+		return client.QueryScalar(ctx, spec.Query)
 	default:
-		return 0, ErrDataSourceUnavailable(spec.Source)
+		client, ok := s.plugins[spec.Source]
+		if !ok {
+			return 0, ErrDataSourceUnavailable(spec.Source)
+		}
+		log.Printf("执行插件查询（插件=%s）: %s", spec.Source, spec.Query)
+		return client.QueryScalar(ctx, spec.Query, spec.ResultField, spec.Connection)
+	}
+}
+
+// CheckMetricQuery 在保存指标前校验查询成本，连接尚未建立时跳过，采集时仍会再次校验。
+// fan-out 指标对匹配到的每个连接（含连接发现提供的连接）分别校验，任一连接超限即失败。
+func (s *Service) CheckMetricQuery(ctx context.Context, spec config.MetricSpec) error {
+	// 只有 MySQL 连接支持 query_guard；sql 数据源各数据库的 EXPLAIN 输出格式不同，
+	// 其连接没有成本限制，查询只需通过配置校验中的只读检查
+	if spec.Source != "mysql" {
+		return nil
 	}
+	var args []any
+	if spec.Watermark != nil {
+		args = append(args, spec.Watermark.InitialValue())
+	}
+	for _, conn := range s.metricConnections(spec) {
+		s.mu.RLock()
+		client, ok := s.mysql[conn]
+		s.mu.RUnlock()
+		if !ok {
+			client, ok = s.discoveredMySQL(conn)
+		}
+		if !ok {
+			continue
+		}
+		if err := client.CheckQueryCost(ctx, spec.Query, args...); err != nil {
+			if spec.FanOut() {
+				return fmt.Errorf("连接 %s: %w", conn, err)
+			}
+			return err
+		}
+	}
+	return nil
 }
 
 func ErrDataSourceUnavailable(source string) error {
@@ -384,15 +809,76 @@ func (s *Service) Close() {
 			}
 		}
 	}
+	for name, client := range s.modbus {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭 Modbus 连接 %s 失败: %v", name, err)
+		}
+	}
+	for name, client := range s.mqtt {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭 MQTT 连接 %s 失败: %v", name, err)
+		}
+	}
+	for name, client := range s.snmp {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭 SNMP 连接 %s 失败: %v", name, err)
+		}
+	}
+	for name, client := range s.prometheus {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭 Prometheus 连接 %s 失败: %v", name, err)
+		}
+	}
+	for name, client := range s.mongodb {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭 MongoDB 连接 %s 失败: %v", name, err)
+		}
+	}
+	for name, client := range s.elasticsearch {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭 Elasticsearch 连接 %s 失败: %v", name, err)
+		}
+	}
+	for name, client := range s.sql {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭 SQL 连接 %s 失败: %v", name, err)
+		}
+	}
+	for name, client := range s.plugins {
+		if err := client.Close(); err != nil {
+			log.Printf("关闭插件 %s 失败: %v", name, err)
+		}
+	}
+	if s.remoteWrite != nil {
+		// 关闭时会尝试发送队列中剩余的样本
+		s.remoteWrite.Close()
+	}
+	if s.otlpExporter != nil {
+		s.otlpExporter.Close()
+	}
+	closeSinks(s.sinks)
+	s.sinks = nil
+	s.discovery.stop()
+	s.discovery = nil
 	if s.registry != nil {
 		for _, holder := range s.metrics {
-			s.registry.Unregister(holder.gauge)
-			prometheus.DefaultRegisterer.Unregister(holder.gauge)
+			s.registry.Unregister(holder.collector())
+			prometheus.DefaultRegisterer.Unregister(holder.collector())
 		}
 		s.registry.Unregister(s.errorCount)
 		s.registry.Unregister(s.lastRun)
-			prometheus.DefaultRegisterer.Unregister(s.errorCount)
-			prometheus.DefaultRegisterer.Unregister(s.lastRun)
+		s.registry.Unregister(s.poolStats)
+		s.registry.Unregister(s.pluginStats)
+		s.registry.Unregister(s.retries)
+		for _, c := range append(s.sinkMetrics.Collectors(), s.discoveryMetrics.Collectors()...) {
+			s.registry.Unregister(c)
+			prometheus.DefaultRegisterer.Unregister(c)
+		}
+		prometheus.DefaultRegisterer.Unregister(s.errorCount)
+		prometheus.DefaultRegisterer.Unregister(s.lastRun)
+		prometheus.DefaultRegisterer.Unregister(s.poolStats)
+		prometheus.DefaultRegisterer.Unregister(s.pluginStats)
+		prometheus.DefaultRegisterer.Unregister(s.retries)
 	}
 }
 
@@ -401,9 +887,10 @@ func (s *Service) GetRegistry() *prometheus.Registry {
 	return s.registry
 }
 
-// GetPrometheusHandler 返回 Prometheus HTTP handler。
+// GetPrometheusHandler 返回 /metrics 的 HTTP handler，暴露服务注册表中的指标与 Go 运行时指标，
+// 属于带认证分组的指标除外。
 func (s *Service) GetPrometheusHandler() http.Handler {
-	return promhttp.Handler()
+	return s.withScrapeRefresh(promhttp.HandlerFor(prometheus.Gatherers{s.runtime, s.publicGatherer()}, promhttp.HandlerOpts{}), "")
 }
 
 // SetAlertEvaluator sets the alert evaluator
@@ -482,8 +969,8 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 
 	for _, holder := range s.metrics {
 		if !newMetricNames[holder.spec.Name] {
-			s.registry.Unregister(holder.gauge)
-			prometheus.DefaultRegisterer.Unregister(holder.gauge)
+			s.registry.Unregister(holder.collector())
+			prometheus.DefaultRegisterer.Unregister(holder.collector())
 		}
 	}
 
@@ -499,10 +986,46 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 	for name := range s.restapi {
 		oldRestAPIConnections[name] = true
 	}
+	oldModbusConnections := make(map[string]bool)
+	for name := range s.modbus {
+		oldModbusConnections[name] = true
+	}
+	oldMQTTConnections := make(map[string]bool)
+	for name := range s.mqtt {
+		oldMQTTConnections[name] = true
+	}
+	oldSNMPConnections := make(map[string]bool)
+	for name := range s.snmp {
+		oldSNMPConnections[name] = true
+	}
+	oldPrometheusConnections := make(map[string]bool)
+	for name := range s.prometheus {
+		oldPrometheusConnections[name] = true
+	}
+	oldMongoDBConnections := make(map[string]bool)
+	for name := range s.mongodb {
+		oldMongoDBConnections[name] = true
+	}
+	oldElasticsearchConnections := make(map[string]bool)
+	for name := range s.elasticsearch {
+		oldElasticsearchConnections[name] = true
+	}
+	oldSQLConnections := make(map[string]bool)
+	for name := range s.sql {
+		oldSQLConnections[name] = true
+	}
 
 	newMySQLConnections := mysqlConnectionsNeeded(newCfg)
 	newRedisConnections := redisConnectionsNeeded(newCfg)
 	newRestAPIConnections := restapiConnectionsNeeded(newCfg)
+	newModbusConnections := modbusConnectionsNeeded(newCfg)
+	newMQTTConnections := mqttConnectionsNeeded(newCfg)
+	newSNMPConnections := snmpConnectionsNeeded(newCfg)
+	newPrometheusConnections := prometheusConnectionsNeeded(newCfg)
+	newMongoDBConnections := mongodbConnectionsNeeded(newCfg)
+	newElasticsearchConnections := elasticsearchConnectionsNeeded(newCfg)
+	newSQLConnections := sqlConnectionsNeeded(newCfg)
+	newPlugins := pluginsNeeded(newCfg)
 
 	for name := range oldMySQLConnections {
 		if _, needed := newMySQLConnections[name]; !needed {
@@ -528,18 +1051,80 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 			}
 		}
 	}
-
-	needsIoTDB := needsSource(newCfg.Metrics, "iotdb")
-	if !needsIoTDB && s.iotdb != nil {
-		s.iotdb.Close()
-		s.iotdb = nil
-	} else if needsIoTDB && s.iotdb == nil {
-		var err error
-		s.iotdb, err = datasource.NewIoTDBClient(newCfg.IoTDB)
-		if err != nil {
-			return ReloadResult{
-				Success: false,
-				Error:   fmt.Sprintf("初始化 IoTDB 连接失败: %v", err),
+	for name := range oldModbusConnections {
+		if _, needed := newModbusConnections[name]; !needed {
+			if client, ok := s.modbus[name]; ok {
+				client.Close()
+				delete(s.modbus, name)
+			}
+		}
+	}
+	for name := range oldMQTTConnections {
+		if _, needed := newMQTTConnections[name]; !needed {
+			if client, ok := s.mqtt[name]; ok {
+				client.Close()
+				delete(s.mqtt, name)
+			}
+		}
+	}
+	for name := range oldSNMPConnections {
+		if _, needed := newSNMPConnections[name]; !needed {
+			if client, ok := s.snmp[name]; ok {
+				client.Close()
+				delete(s.snmp, name)
+			}
+		}
+	}
+	for name := range oldPrometheusConnections {
+		if _, needed := newPrometheusConnections[name]; !needed {
+			if client, ok := s.prometheus[name]; ok {
+				client.Close()
+				delete(s.prometheus, name)
+			}
+		}
+	}
+	for name := range oldMongoDBConnections {
+		if _, needed := newMongoDBConnections[name]; !needed {
+			if client, ok := s.mongodb[name]; ok {
+				client.Close()
+				delete(s.mongodb, name)
+			}
+		}
+	}
+	for name := range oldElasticsearchConnections {
+		if _, needed := newElasticsearchConnections[name]; !needed {
+			if client, ok := s.elasticsearch[name]; ok {
+				client.Close()
+				delete(s.elasticsearch, name)
+			}
+		}
+	}
+	for name := range oldSQLConnections {
+		if _, needed := newSQLConnections[name]; !needed {
+			if client, ok := s.sql[name]; ok {
+				client.Close()
+				delete(s.sql, name)
+			}
+		}
+	}
+	for name, client := range s.plugins {
+		if _, needed := newPlugins[name]; !needed {
+			client.Close()
+			delete(s.plugins, name)
+		}
+	}
+
+	needsIoTDB := needsSource(newCfg.Metrics, "iotdb")
+	if !needsIoTDB && s.iotdb != nil {
+		s.iotdb.Close()
+		s.iotdb = nil
+	} else if needsIoTDB && s.iotdb == nil {
+		var err error
+		s.iotdb, err = datasource.NewIoTDBClient(newCfg.IoTDB)
+		if err != nil {
+			return ReloadResult{
+				Success: false,
+				Error:   fmt.Sprintf("初始化 IoTDB 连接失败: %v", err),
 				Message: "热更新失败",
 			}
 		}
@@ -656,6 +1241,345 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 		}
 	}
 
+	for connName := range newModbusConnections {
+		modbusCfg, ok := newCfg.ModbusConfigFor(connName)
+		if !ok {
+			return ReloadResult{
+				Success: false,
+				Error:   fmt.Sprintf("未找到 Modbus 连接 %s", connName),
+				Message: "热更新失败",
+			}
+		}
+
+		if client, exists := s.modbus[connName]; exists {
+			var oldModbus config.ModbusConfig
+			var hasOld bool
+			if oldCfg != nil {
+				oldModbus, hasOld = oldCfg.ModbusConfigFor(connName)
+			}
+			if !hasOld || !modbusConfigEqual(oldModbus, modbusCfg) {
+				log.Printf("检测到 Modbus 连接 %s 配置变更，准备重建连接", connName)
+				_ = client.Close()
+				delete(s.modbus, connName)
+				exists = false
+			}
+		}
+
+		if _, exists := s.modbus[connName]; !exists {
+			client, err := datasource.NewModbusClient(modbusCfg)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 Modbus 连接 %s 失败: %v", connName, err),
+					Message: "热更新失败",
+				}
+			}
+			s.modbus[connName] = client
+		}
+	}
+
+	for connName := range newMQTTConnections {
+		mqttCfg, ok := newCfg.MQTTConfigFor(connName)
+		if !ok {
+			return ReloadResult{
+				Success: false,
+				Error:   fmt.Sprintf("未找到 MQTT 连接 %s", connName),
+				Message: "热更新失败",
+			}
+		}
+
+		if client, exists := s.mqtt[connName]; exists {
+			var oldMQTT config.MQTTConfig
+			var hasOld bool
+			if oldCfg != nil {
+				oldMQTT, hasOld = oldCfg.MQTTConfigFor(connName)
+			}
+			if !hasOld || !mqttConfigEqual(oldMQTT, mqttCfg) {
+				log.Printf("检测到 MQTT 连接 %s 配置变更，准备重建连接", connName)
+				_ = client.Close()
+				delete(s.mqtt, connName)
+				exists = false
+			}
+		}
+
+		if _, exists := s.mqtt[connName]; !exists {
+			client, err := datasource.NewMQTTClient(mqttCfg)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 MQTT 连接 %s 失败: %v", connName, err),
+					Message: "热更新失败",
+				}
+			}
+			s.mqtt[connName] = client
+		}
+	}
+
+	for connName := range newSNMPConnections {
+		snmpCfg, ok := newCfg.SNMPConfigFor(connName)
+		if !ok {
+			return ReloadResult{
+				Success: false,
+				Error:   fmt.Sprintf("未找到 SNMP 连接 %s", connName),
+				Message: "热更新失败",
+			}
+		}
+
+		if client, exists := s.snmp[connName]; exists {
+			var oldSNMP config.SNMPConfig
+			var hasOld bool
+			if oldCfg != nil {
+				oldSNMP, hasOld = oldCfg.SNMPConfigFor(connName)
+			}
+			if !hasOld || !snmpConfigEqual(oldSNMP, snmpCfg) {
+				log.Printf("检测到 SNMP 连接 %s 配置变更，准备重建连接", connName)
+				_ = client.Close()
+				delete(s.snmp, connName)
+				exists = false
+			}
+		}
+
+		if _, exists := s.snmp[connName]; !exists {
+			client, err := datasource.NewSNMPClient(snmpCfg)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 SNMP 连接 %s 失败: %v", connName, err),
+					Message: "热更新失败",
+				}
+			}
+			s.snmp[connName] = client
+		}
+	}
+
+	for connName := range newPrometheusConnections {
+		prometheusCfg, ok := newCfg.PrometheusSourceConfigFor(connName)
+		if !ok {
+			return ReloadResult{
+				Success: false,
+				Error:   fmt.Sprintf("未找到 Prometheus 连接 %s", connName),
+				Message: "热更新失败",
+			}
+		}
+
+		if client, exists := s.prometheus[connName]; exists {
+			var oldPrometheus config.PrometheusSourceConfig
+			var hasOld bool
+			if oldCfg != nil {
+				oldPrometheus, hasOld = oldCfg.PrometheusSourceConfigFor(connName)
+			}
+			if !hasOld || !prometheusConfigEqual(oldPrometheus, prometheusCfg) {
+				log.Printf("检测到 Prometheus 连接 %s 配置变更，准备重建连接", connName)
+				_ = client.Close()
+				delete(s.prometheus, connName)
+				exists = false
+			}
+		}
+
+		if _, exists := s.prometheus[connName]; !exists {
+			client, err := datasource.NewPrometheusClient(prometheusCfg)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 Prometheus 连接 %s 失败: %v", connName, err),
+					Message: "热更新失败",
+				}
+			}
+			s.prometheus[connName] = client
+		}
+	}
+
+	for connName := range newMongoDBConnections {
+		mongodbCfg, ok := newCfg.MongoDBConfigFor(connName)
+		if !ok {
+			return ReloadResult{
+				Success: false,
+				Error:   fmt.Sprintf("未找到 MongoDB 连接 %s", connName),
+				Message: "热更新失败",
+			}
+		}
+
+		if client, exists := s.mongodb[connName]; exists {
+			var oldMongoDB config.MongoDBConfig
+			var hasOld bool
+			if oldCfg != nil {
+				oldMongoDB, hasOld = oldCfg.MongoDBConfigFor(connName)
+			}
+			if !hasOld || !mongodbConfigEqual(oldMongoDB, mongodbCfg) {
+				log.Printf("检测到 MongoDB 连接 %s 配置变更，准备重建连接", connName)
+				_ = client.Close()
+				delete(s.mongodb, connName)
+				exists = false
+			}
+		}
+
+		if _, exists := s.mongodb[connName]; !exists {
+			client, err := datasource.NewMongoDBClient(mongodbCfg)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 MongoDB 连接 %s 失败: %v", connName, err),
+					Message: "热更新失败",
+				}
+			}
+			s.mongodb[connName] = client
+		}
+	}
+
+	for connName := range newElasticsearchConnections {
+		elasticsearchCfg, ok := newCfg.ElasticsearchConfigFor(connName)
+		if !ok {
+			return ReloadResult{
+				Success: false,
+				Error:   fmt.Sprintf("未找到 Elasticsearch 连接 %s", connName),
+				Message: "热更新失败",
+			}
+		}
+
+		if client, exists := s.elasticsearch[connName]; exists {
+			var oldElasticsearch config.ElasticsearchConfig
+			var hasOld bool
+			if oldCfg != nil {
+				oldElasticsearch, hasOld = oldCfg.ElasticsearchConfigFor(connName)
+			}
+			if !hasOld || !elasticsearchConfigEqual(oldElasticsearch, elasticsearchCfg) {
+				log.Printf("检测到 Elasticsearch 连接 %s 配置变更，准备重建连接", connName)
+				_ = client.Close()
+				delete(s.elasticsearch, connName)
+				exists = false
+			}
+		}
+
+		if _, exists := s.elasticsearch[connName]; !exists {
+			client, err := datasource.NewElasticsearchClient(elasticsearchCfg)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 Elasticsearch 连接 %s 失败: %v", connName, err),
+					Message: "热更新失败",
+				}
+			}
+			s.elasticsearch[connName] = client
+		}
+	}
+
+	for connName := range newSQLConnections {
+		sqlCfg, ok := newCfg.SQLConfigFor(connName)
+		if !ok {
+			return ReloadResult{
+				Success: false,
+				Error:   fmt.Sprintf("未找到 SQL 连接 %s", connName),
+				Message: "热更新失败",
+			}
+		}
+
+		if client, exists := s.sql[connName]; exists {
+			var oldSQL config.SQLConfig
+			var hasOld bool
+			if oldCfg != nil {
+				oldSQL, hasOld = oldCfg.SQLConfigFor(connName)
+			}
+			if !hasOld || !sqlConfigEqual(oldSQL, sqlCfg) {
+				log.Printf("检测到 SQL 连接 %s 配置变更，准备重建连接", connName)
+				_ = client.Close()
+				delete(s.sql, connName)
+				exists = false
+			}
+		}
+
+		if _, exists := s.sql[connName]; !exists {
+			client, err := datasource.NewSQLClient(sqlCfg)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 SQL 连接 %s 失败: %v", connName, err),
+					Message: "热更新失败",
+				}
+			}
+			s.sql[connName] = client
+		}
+	}
+
+	for name := range newPlugins {
+		pluginCfg := newCfg.Plugins[name]
+		if client, exists := s.plugins[name]; exists {
+			if oldCfg == nil || !reflect.DeepEqual(oldCfg.Plugins[name], pluginCfg) {
+				log.Printf("检测到插件 %s 配置变更，准备重启插件", name)
+				_ = client.Close()
+				delete(s.plugins, name)
+			}
+		}
+
+		if _, exists := s.plugins[name]; !exists {
+			// 与启动时一致，启动失败由监管协程重试，只有配置无效时热更新失败
+			client, err := datasource.StartPluginClient(name, pluginCfg)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   err.Error(),
+					Message: "热更新失败",
+				}
+			}
+			s.plugins[name] = client
+		}
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.RemoteWrite, newCfg.RemoteWrite) {
+		if s.remoteWrite != nil {
+			s.remoteWrite.Close()
+			s.remoteWrite = nil
+		}
+		if newCfg.RemoteWrite.Enabled {
+			writer, err := remotewrite.New(newCfg.RemoteWrite)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 remote_write 失败: %v", err),
+					Message: "热更新失败",
+				}
+			}
+			s.remoteWrite = writer
+		}
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.OTLP, newCfg.OTLP) {
+		if s.otlpExporter != nil {
+			s.otlpExporter.Close()
+			s.otlpExporter = nil
+		}
+		if newCfg.OTLP.Enabled {
+			exporter, err := otlp.New(newCfg.OTLP)
+			if err != nil {
+				return ReloadResult{
+					Success: false,
+					Error:   fmt.Sprintf("初始化 OTLP 导出失败: %v", err),
+					Message: "热更新失败",
+				}
+			}
+			s.otlpExporter = exporter
+		}
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Sinks, newCfg.Sinks) || !reflect.DeepEqual(oldCfg.IoTDB, newCfg.IoTDB) {
+		closeSinks(s.sinks)
+		s.sinks = nil
+		writers, err := newSinks(newCfg, s.sinkMetrics)
+		if err != nil {
+			return ReloadResult{
+				Success: false,
+				Error:   err.Error(),
+				Message: "热更新失败",
+			}
+		}
+		s.sinks = writers
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.ConnectionDiscovery, newCfg.ConnectionDiscovery) {
+		s.discovery.stop()
+		s.discovery = startConnectionDiscovery(newCfg.ConnectionDiscovery, s.discoveryMetrics)
+	}
+	if s.watermarks == nil || oldCfg == nil || oldCfg.WatermarkStatePath() != newCfg.WatermarkStatePath() {
+		// 水位在落盘后才生效，内存中的状态与文件一致，只在路径变化或尚未读取时重新读取
+		s.watermarks = loadWatermarks(newCfg)
+	}
+
 	var newMetrics []string
 	var updatedMetrics []metricHolder
 
@@ -677,8 +1601,8 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 			// 处理 enabled 状态变更
 			if (existingHolder.spec.Enabled == nil || *existingHolder.spec.Enabled) && spec.Enabled != nil && !*spec.Enabled {
 				// 禁用指标: 从 Prometheus 注销
-				s.registry.Unregister(existingHolder.gauge)
-				prometheus.DefaultRegisterer.Unregister(existingHolder.gauge)
+				s.registry.Unregister(existingHolder.collector())
+				prometheus.DefaultRegisterer.Unregister(existingHolder.collector())
 				existingHolder.spec = spec
 				continue
 			}
@@ -687,17 +1611,9 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 				var metric prometheus.Collector
 				switch metricType {
 				case "gauge":
-					metric = prometheus.NewGauge(prometheus.GaugeOpts{
-						Name:        spec.Name,
-						Help:        spec.Help,
-						ConstLabels: spec.Labels,
-					})
+					metric = s.newGaugeMetric(newCfg, spec)
 				case "counter":
-					metric = prometheus.NewCounter(prometheus.CounterOpts{
-						Name:        spec.Name,
-						Help:        spec.Help,
-						ConstLabels: spec.Labels,
-					})
+					metric = s.newCounterMetric(spec)
 				case "histogram":
 					buckets := spec.Buckets
 					if len(buckets) == 0 {
@@ -734,34 +1650,26 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 						continue
 					}
 				}
-				if gauge, ok := metric.(prometheus.Gauge); ok {
-					existingHolder.gauge = gauge
-					existingHolder.spec = spec
-					prometheus.DefaultRegisterer.MustRegister(gauge)
+				if holder, ok := newMetricHolder(spec, metric); ok {
+					*existingHolder = holder
+					prometheus.DefaultRegisterer.MustRegister(holder.collector())
 				}
 				updatedMetrics = append(updatedMetrics, *existingHolder)
 				newMetrics = append(newMetrics, spec.Name)
 				continue
 			}
-			if existingHolder.spec.Type != spec.Type || existingHolder.spec.Help != spec.Help || !labelsEqual(existingHolder.spec.Labels, spec.Labels) {
+			onScrape := newCfg.MetricMode(spec) == config.MetricModeOnScrape && metricType == "gauge"
+			if existingHolder.spec.Type != spec.Type || existingHolder.spec.Help != spec.Help || !labelsEqual(existingHolder.spec.Labels, spec.Labels) || !reflect.DeepEqual(existingHolder.spec.SeriesLabels, spec.SeriesLabels) || existingHolder.spec.FanOut() != spec.FanOut() || (existingHolder.spec.Watermark == nil) != (spec.Watermark == nil) || (existingHolder.scrape != nil) != onScrape {
 				// 从两个注册表清理旧 metric
-				s.registry.Unregister(existingHolder.gauge)
-				prometheus.DefaultRegisterer.Unregister(existingHolder.gauge)
+				s.registry.Unregister(existingHolder.collector())
+				prometheus.DefaultRegisterer.Unregister(existingHolder.collector())
 
 				var metric prometheus.Collector
 				switch metricType {
 				case "gauge":
-					metric = prometheus.NewGauge(prometheus.GaugeOpts{
-						Name:        spec.Name,
-						Help:        spec.Help,
-						ConstLabels: spec.Labels,
-					})
+					metric = s.newGaugeMetric(newCfg, spec)
 				case "counter":
-					metric = prometheus.NewCounter(prometheus.CounterOpts{
-						Name:        spec.Name,
-						Help:        spec.Help,
-						ConstLabels: spec.Labels,
-					})
+					metric = s.newCounterMetric(spec)
 				case "histogram":
 					buckets := spec.Buckets
 					if len(buckets) == 0 {
@@ -807,14 +1715,16 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 					}
 				}
 
-				if gauge, ok := metric.(prometheus.Gauge); ok {
-					existingHolder.gauge = gauge
-					existingHolder.spec = spec
-					prometheus.DefaultRegisterer.Unregister(existingHolder.gauge)
-					prometheus.DefaultRegisterer.MustRegister(gauge)
+				if holder, ok := newMetricHolder(spec, metric); ok {
+					*existingHolder = holder
+					prometheus.DefaultRegisterer.Unregister(existingHolder.collector())
+					prometheus.DefaultRegisterer.MustRegister(holder.collector())
 				}
 			} else {
 				existingHolder.spec = spec
+				if existingHolder.scrape != nil {
+					existingHolder.scrape.setSpec(spec)
+				}
 			}
 			updatedMetrics = append(updatedMetrics, *existingHolder)
 		} else {
@@ -824,17 +1734,9 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 		}
 			switch metricType {
 			case "gauge":
-				metric = prometheus.NewGauge(prometheus.GaugeOpts{
-					Name:        spec.Name,
-					Help:        spec.Help,
-					ConstLabels: spec.Labels,
-				})
+				metric = s.newGaugeMetric(newCfg, spec)
 			case "counter":
-				metric = prometheus.NewCounter(prometheus.CounterOpts{
-					Name:        spec.Name,
-					Help:        spec.Help,
-					ConstLabels: spec.Labels,
-				})
+				metric = s.newCounterMetric(spec)
 			case "histogram":
 				buckets := spec.Buckets
 				if len(buckets) == 0 {
@@ -867,13 +1769,9 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 				}
 			}
 
-			if gauge, ok := metric.(prometheus.Gauge); ok {
-				holder := metricHolder{
-					spec:  spec,
-					gauge: gauge,
-				}
+			if holder, ok := newMetricHolder(spec, metric); ok {
 				updatedMetrics = append(updatedMetrics, holder)
-				prometheus.DefaultRegisterer.MustRegister(gauge)
+				prometheus.DefaultRegisterer.MustRegister(holder.collector())
 				newMetrics = append(newMetrics, spec.Name)
 			}
 		}
@@ -881,6 +1779,17 @@ func (s *Service) ReloadConfig(newCfg *config.Config) ReloadResult {
 
 	s.metrics = updatedMetrics
 	s.cfg = newCfg
+	s.rebuildGroups(newCfg)
+
+	// 清理已删除的合成数据指标的生成器状态
+	syntheticMetrics := make(map[string]bool)
+	for _, spec := range newCfg.Metrics {
+		if spec.Source == "synthetic" {
+			syntheticMetrics[spec.Name] = true
+		}
+	}
+	s.synthetic.Retain(syntheticMetrics)
+	s.syncMQTTSubscriptions(newCfg)
 
 	var metricNames []string
 	for _, m := range newMetrics {
@@ -913,7 +1822,14 @@ func mysqlConfigEqual(a, b config.MySQLConfig) bool {
 		a.User == b.User &&
 		a.Password == b.Password &&
 		a.Database == b.Database &&
-		reflect.DeepEqual(a.Params, b.Params)
+		reflect.DeepEqual(a.Params, b.Params) &&
+		a.TLS == b.TLS &&
+		reflect.DeepEqual(a.SSHTunnel, b.SSHTunnel) &&
+		a.QueryGuard == b.QueryGuard &&
+		a.MaxOpenConns == b.MaxOpenConns &&
+		a.MaxIdleConns == b.MaxIdleConns &&
+		a.ConnMaxLifetime == b.ConnMaxLifetime &&
+		a.ConnMaxIdleTime == b.ConnMaxIdleTime
 }
 
 func redisConfigEqual(a, b config.RedisConfig) bool {
@@ -923,13 +1839,90 @@ func redisConfigEqual(a, b config.RedisConfig) bool {
 		a.Password == b.Password &&
 		a.DB == b.DB &&
 		a.EnableTLS == b.EnableTLS &&
-		a.SkipTLSVerify == b.SkipTLSVerify
+		a.SkipTLSVerify == b.SkipTLSVerify &&
+		a.TLS == b.TLS &&
+		reflect.DeepEqual(a.SSHTunnel, b.SSHTunnel) &&
+		a.PoolSize == b.PoolSize &&
+		a.MinIdleConns == b.MinIdleConns &&
+		a.ConnMaxIdleTime == b.ConnMaxIdleTime
 }
 
 func restapiConfigEqual(a, b config.RestAPIConfig) bool {
 	return a.BaseURL == b.BaseURL &&
 		a.Timeout == b.Timeout &&
-		a.TLS.SkipVerify == b.TLS.SkipVerify &&
-		a.Retry.MaxAttempts == b.Retry.MaxAttempts &&
-		a.Retry.Backoff == b.Retry.Backoff
+		a.TLS == b.TLS &&
+		a.Proxy == b.Proxy
+}
+
+func modbusConfigEqual(a, b config.ModbusConfig) bool {
+	return a.Host == b.Host &&
+		a.Port == b.Port &&
+		a.UnitID == b.UnitID &&
+		a.Timeout == b.Timeout &&
+		a.ByteOrder == b.ByteOrder &&
+		a.WordOrder == b.WordOrder
+}
+
+func mqttConfigEqual(a, b config.MQTTConfig) bool {
+	return a.Broker == b.Broker &&
+		a.ClientID == b.ClientID &&
+		a.Username == b.Username &&
+		a.Password == b.Password &&
+		a.QoS == b.QoS &&
+		a.KeepAlive == b.KeepAlive &&
+		a.ConnectTimeout == b.ConnectTimeout &&
+		a.TLS == b.TLS
+}
+
+func snmpConfigEqual(a, b config.SNMPConfig) bool {
+	return a.Host == b.Host &&
+		a.Port == b.Port &&
+		a.Version == b.Version &&
+		a.Community == b.Community &&
+		a.Timeout == b.Timeout &&
+		a.Retries == b.Retries &&
+		a.V3 == b.V3
+}
+
+func prometheusConfigEqual(a, b config.PrometheusSourceConfig) bool {
+	return a.URL == b.URL &&
+		a.Timeout == b.Timeout &&
+		labelsEqual(a.Headers, b.Headers) &&
+		a.BearerToken == b.BearerToken &&
+		a.Username == b.Username &&
+		a.Password == b.Password &&
+		a.TLS == b.TLS &&
+		a.Proxy == b.Proxy
+}
+
+func mongodbConfigEqual(a, b config.MongoDBConfig) bool {
+	return a.URI == b.URI &&
+		a.Database == b.Database &&
+		a.Username == b.Username &&
+		a.Password == b.Password &&
+		a.AuthSource == b.AuthSource &&
+		a.ReadPreference == b.ReadPreference &&
+		a.MaxTimeMS == b.MaxTimeMS &&
+		a.ConnectTimeout == b.ConnectTimeout &&
+		a.TLS == b.TLS
+}
+
+func elasticsearchConfigEqual(a, b config.ElasticsearchConfig) bool {
+	return a.URL == b.URL &&
+		a.Username == b.Username &&
+		a.Password == b.Password &&
+		a.APIKey == b.APIKey &&
+		a.Timeout == b.Timeout &&
+		labelsEqual(a.Headers, b.Headers) &&
+		a.TLS == b.TLS &&
+		a.Proxy == b.Proxy
+}
+
+func sqlConfigEqual(a, b config.SQLConfig) bool {
+	return a.Driver == b.Driver &&
+		a.DSN == b.DSN &&
+		a.MaxOpenConns == b.MaxOpenConns &&
+		a.MaxIdleConns == b.MaxIdleConns &&
+		a.ConnMaxLifetime == b.ConnMaxLifetime &&
+		a.ConnMaxIdleTime == b.ConnMaxIdleTime
 }
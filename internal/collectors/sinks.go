@@ -0,0 +1,53 @@
+package collectors
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/sinks"
+)
+
+// newSinks 按配置创建全部写回目标，任一目标创建失败时关闭已创建的目标并返回错误。
+func newSinks(cfg *config.Config, metrics *sinks.Metrics) ([]*sinks.Writer, error) {
+	writers := make([]*sinks.Writer, 0, len(cfg.Sinks))
+	for _, sinkCfg := range cfg.Sinks {
+		writer, err := sinks.New(sinkCfg, cfg.IoTDB, metrics)
+		if err != nil {
+			closeSinks(writers)
+			return nil, fmt.Errorf("初始化写回目标 %s 失败: %w", sinkCfg.Name, err)
+		}
+		writers = append(writers, writer)
+	}
+	return writers, nil
+}
+
+// closeSinks 写入剩余样本后关闭写回目标。
+func closeSinks(writers []*sinks.Writer) {
+	for _, writer := range writers {
+		if err := writer.Close(); err != nil {
+			log.Printf("关闭写回目标 %s 失败: %v", writer.Name(), err)
+		}
+	}
+}
+
+// emitSample 将一次成功采集的样本交给全部写回目标，series 为多序列指标的 series label。
+func (s *Service) emitSample(spec config.MetricSpec, series map[string]string, value float64) {
+	s.mu.RLock()
+	writers := s.sinks
+	s.mu.RUnlock()
+	if len(writers) == 0 {
+		return
+	}
+	sample := sinks.Sample{
+		Metric: spec.Name,
+		Labels: spec.Labels,
+		Series: series,
+		Value:  value,
+		Time:   time.Now(),
+	}
+	for _, writer := range writers {
+		writer.Add(sample)
+	}
+}
@@ -0,0 +1,203 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// watermarkState 是一个增量指标持久化的状态。
+type watermarkState struct {
+	Watermark string    `json:"watermark"`
+	Time      bool      `json:"time,omitempty"` // 水位为时间类型，以 RFC3339Nano 保存，查询时按时间绑定
+	Total     float64   `json:"total"`          // counter 的累计值，重启后从这里继续累加
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// watermarkStore 把各增量指标的水位与累计值保存在一个 JSON 文件中，按指标名索引。
+type watermarkStore struct {
+	path  string
+	mu    sync.Mutex
+	state map[string]watermarkState
+}
+
+// loadWatermarkStore 读取水位文件，文件不存在时从空状态开始。
+func loadWatermarkStore(path string) (*watermarkStore, error) {
+	store := &watermarkStore{path: path, state: make(map[string]watermarkState)}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取水位文件失败: %w", err)
+	}
+	if err := json.Unmarshal(raw, &store.state); err != nil {
+		return nil, fmt.Errorf("解析水位文件 %s 失败: %w", path, err)
+	}
+	return store, nil
+}
+
+func (w *watermarkStore) get(name string) (watermarkState, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.state[name]
+	return state, ok
+}
+
+// set 更新一个指标的状态并立即落盘：先写临时文件并同步，再重命名替换，写入失败时内存状态保持不变。
+func (w *watermarkStore) set(name string, state watermarkState) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next := make(map[string]watermarkState, len(w.state)+1)
+	for k, v := range w.state {
+		next[k] = v
+	}
+	next[name] = state
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o750); err != nil {
+		return fmt.Errorf("创建水位文件目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), "."+filepath.Base(w.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("写入水位文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入水位文件失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入水位文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入水位文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return fmt.Errorf("写入水位文件失败: %w", err)
+	}
+	w.state = next
+	return nil
+}
+
+// loadWatermarks 在配置了增量指标时读取水位文件。读取失败时返回 nil，增量指标在修复前一直失败，
+// 而不是从初始水位重新统计造成重复计数。
+func loadWatermarks(cfg *config.Config) *watermarkStore {
+	for _, spec := range cfg.Metrics {
+		if spec.Watermark == nil {
+			continue
+		}
+		store, err := loadWatermarkStore(cfg.WatermarkStatePath())
+		if err != nil {
+			log.Printf("警告: %v，增量指标将无法采集", err)
+			return nil
+		}
+		return store
+	}
+	return nil
+}
+
+// newCounterMetric 创建 counter 指标，增量指标从持久化的累计值继续累加。
+func (s *Service) newCounterMetric(spec config.MetricSpec) prometheus.Collector {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        spec.Name,
+		Help:        spec.Help,
+		ConstLabels: spec.Labels,
+	})
+	if spec.Watermark != nil && s.watermarks != nil {
+		if state, ok := s.watermarks.get(spec.Name); ok {
+			counter.Add(state.Total)
+		}
+	}
+	return counter
+}
+
+// updateWatermark 以上次的水位执行增量查询，先持久化新的水位与累计值，成功后再累加到 counter，
+// 因此查询或落盘失败时下个周期会从原水位重试，既不重复也不遗漏。
+func (s *Service) updateWatermark(ctx context.Context, holder metricHolder) bool {
+	start := time.Now()
+	spec := holder.spec
+	s.mu.RLock()
+	store := s.watermarks
+	s.mu.RUnlock()
+	if store == nil {
+		log.Printf("更新指标 %s 失败: 水位状态不可用", spec.Name)
+		s.errorCount.Inc()
+		return false
+	}
+
+	state, ok := store.get(spec.Name)
+	if !ok {
+		state.Watermark = spec.Watermark.InitialValue()
+	}
+	log.Printf("开始更新指标 %s (source=%s, watermark=%s)", spec.Name, spec.Source, state.Watermark)
+	var increment float64
+	var mark datasource.Watermark
+	err := s.withRetry(ctx, spec, func() (err error) {
+		increment, mark, err = s.queryWatermarkOnce(ctx, spec, datasource.Watermark{Value: state.Watermark, Time: state.Time})
+		return err
+	})
+	if err != nil {
+		log.Printf("更新指标 %s 失败: %v", spec.Name, err)
+		s.errorCount.Inc()
+		return false
+	}
+
+	next := watermarkState{Watermark: state.Watermark, Time: state.Time, Total: state.Total + increment, UpdatedAt: time.Now()}
+	if mark.Value != "" {
+		next.Watermark, next.Time = mark.Value, mark.Time
+	}
+	if err := store.set(spec.Name, next); err != nil {
+		log.Printf("更新指标 %s 失败: %v", spec.Name, err)
+		s.errorCount.Inc()
+		return false
+	}
+	holder.counter.Add(increment)
+	log.Printf("指标 %s 更新成功，增量=%.3f，水位=%s，耗时=%s", spec.Name, increment, next.Watermark, time.Since(start))
+	s.recordValue(spec.Name, next.Total)
+	s.emitSample(spec, nil, next.Total)
+	return true
+}
+
+func (s *Service) queryWatermarkOnce(ctx context.Context, spec config.MetricSpec, watermark datasource.Watermark) (float64, datasource.Watermark, error) {
+	conn := connectionName(spec.Connection)
+	switch spec.Source {
+	case "mysql":
+		client, ok := s.mysql[conn]
+		if !ok {
+			client, ok = s.discoveredMySQL(conn)
+		}
+		if !ok {
+			return 0, datasource.Watermark{}, fmt.Errorf("MySQL 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 MySQL 增量查询（连接=%s）: %s", conn, spec.Query)
+		return client.QueryWatermark(ctx, spec.Query, watermark)
+	case "sql":
+		client, ok := s.sql[conn]
+		if !ok {
+			client, ok = s.discoveredSQL(conn)
+		}
+		if !ok {
+			return 0, datasource.Watermark{}, fmt.Errorf("SQL 连接 %s 未初始化", conn)
+		}
+		log.Printf("执行 SQL 增量查询（连接=%s）: %s", conn, spec.Query)
+		return client.QueryWatermark(ctx, spec.Query, watermark)
+	default:
+		return 0, datasource.Watermark{}, fmt.Errorf("数据源 %s 不支持增量查询", spec.Source)
+	}
+}
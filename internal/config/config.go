@@ -1,15 +1,26 @@
 package config
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/company/ems-devices/internal/sqlguard"
+	"github.com/company/ems-devices/internal/synthetic"
 )
 
 // labelNameRegex 匹配有效的 Prometheus label 名称
@@ -20,24 +31,54 @@ func isValidLabelName(name string) bool {
 	return labelNameRegex.MatchString(name)
 }
 
+// envRefRegex 匹配 ${VAR} 形式的环境变量引用
+var envRefRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv 只展开 ${VAR} 形式的环境变量，保留 $match、$sum 等查询语法中的 $ 字面量。
+func expandEnv(raw string) string {
+	return envRefRegex.ReplaceAllStringFunc(raw, func(ref string) string {
+		return os.Getenv(envRefRegex.FindStringSubmatch(ref)[1])
+	})
+}
+
 // Config 描述采集服务的整体配置。
 type Config struct {
-	Schedule            ScheduleConfig            `yaml:"schedule" json:"schedule"`
-	Prometheus          PrometheusConfig          `yaml:"prometheus" json:"prometheus"`
-	Alertmanager        AlertmanagerConfig        `yaml:"alertmanager" json:"alertmanager"`
-	Notifier            NotifierConfig            `yaml:"notifier" json:"notifier"`
-	MySQL               MySQLConfig               `yaml:"mysql" json:"mysql"`
-	MySQLConnections    map[string]MySQLConfig    `yaml:"mysql_connections" json:"mysql_connections"`
-	Redis               RedisConfig               `yaml:"redis" json:"redis"`
-	RedisConnections    map[string]RedisConfig    `yaml:"redis_connections" json:"redis_connections"`
-	RestAPIConnections  map[string]RestAPIConfig  `yaml:"restapi_connections" json:"restapi_connections"`
-	IoTDB               IoTDBConfig               `yaml:"iotdb" json:"iotdb"`
-	Metrics             []MetricSpec              `yaml:"metrics" json:"metrics"`
+	Schedule                 ScheduleConfig                    `yaml:"schedule" json:"schedule"`
+	Prometheus               PrometheusConfig                  `yaml:"prometheus" json:"prometheus"`
+	Alertmanager             AlertmanagerConfig                `yaml:"alertmanager" json:"alertmanager"`
+	Notifier                 NotifierConfig                    `yaml:"notifier" json:"notifier"`
+	RemoteWrite              RemoteWriteConfig                 `yaml:"remote_write,omitempty" json:"remote_write,omitempty"`
+	OTLP                     OTLPConfig                        `yaml:"otlp,omitempty" json:"otlp,omitempty"`
+	Pushgateway              PushgatewayConfig                 `yaml:"pushgateway,omitempty" json:"pushgateway,omitempty"`
+	Sinks                    []SinkConfig                      `yaml:"sinks,omitempty" json:"sinks,omitempty"`
+	MetricGroups             map[string]MetricGroupConfig      `yaml:"metric_groups,omitempty" json:"metric_groups,omitempty"`
+	Probe                    ProbeConfig                       `yaml:"probe,omitempty" json:"probe,omitempty"`
+	ConnectionDiscovery      ConnectionDiscoveryConfig         `yaml:"connection_discovery,omitempty" json:"connection_discovery,omitempty"`
+	WatermarkStateFile       string                            `yaml:"watermark_state_file,omitempty" json:"watermark_state_file,omitempty"` // 增量查询水位的持久化文件，默认 configs/watermarks.json
+	MySQL                    MySQLConfig                       `yaml:"mysql" json:"mysql"`
+	MySQLConnections         map[string]MySQLConfig            `yaml:"mysql_connections" json:"mysql_connections"`
+	Redis                    RedisConfig                       `yaml:"redis" json:"redis"`
+	RedisConnections         map[string]RedisConfig            `yaml:"redis_connections" json:"redis_connections"`
+	RestAPIConnections       map[string]RestAPIConfig          `yaml:"restapi_connections" json:"restapi_connections"`
+	ModbusConnections        map[string]ModbusConfig           `yaml:"modbus_connections,omitempty" json:"modbus_connections,omitempty"`
+	MQTTConnections          map[string]MQTTConfig             `yaml:"mqtt_connections,omitempty" json:"mqtt_connections,omitempty"`
+	SNMPConnections          map[string]SNMPConfig             `yaml:"snmp_connections,omitempty" json:"snmp_connections,omitempty"`
+	PrometheusConnections    map[string]PrometheusSourceConfig `yaml:"prometheus_connections,omitempty" json:"prometheus_connections,omitempty"`
+	MongoDBConnections       map[string]MongoDBConfig          `yaml:"mongodb_connections,omitempty" json:"mongodb_connections,omitempty"`
+	ElasticsearchConnections map[string]ElasticsearchConfig    `yaml:"elasticsearch_connections,omitempty" json:"elasticsearch_connections,omitempty"`
+	File                     FileSourceConfig                  `yaml:"file,omitempty" json:"file,omitempty"`
+	Command                  CommandSourceConfig               `yaml:"command,omitempty" json:"command,omitempty"`
+	SQLConnections           map[string]SQLConfig              `yaml:"sql_connections,omitempty" json:"sql_connections,omitempty"`
+	Plugins                  map[string]PluginConfig           `yaml:"plugins,omitempty" json:"plugins,omitempty"`
+	IoTDB                    IoTDBConfig                       `yaml:"iotdb" json:"iotdb"`
+	Metrics                  []MetricSpec                      `yaml:"metrics" json:"metrics"`
 }
 
 // ScheduleConfig 控制采集周期。
 type ScheduleConfig struct {
-	Interval string `yaml:"interval" json:"interval"`
+	Interval          string `yaml:"interval" json:"interval"`
+	ScrapeMinInterval string `yaml:"scrape_min_interval,omitempty" json:"scrape_min_interval,omitempty"` // on_scrape 指标的最短查询间隔，间隔内的抓取复用上次结果，默认 10s
+	ScrapeTimeout     string `yaml:"scrape_timeout,omitempty" json:"scrape_timeout,omitempty"`           // on_scrape 指标的查询超时，抓取请求未携带 X-Prometheus-Scrape-Timeout-Seconds 时使用，默认 10s
 }
 
 // PrometheusConfig 定义暴露指标的方式。
@@ -49,6 +90,444 @@ type PrometheusConfig struct {
 	URL string `yaml:"url,omitempty" json:"url,omitempty"`
 }
 
+// RemoteWriteConfig 定义 Prometheus remote_write 推送配置，每个采集周期结束后推送全部样本。
+type RemoteWriteConfig struct {
+	Enabled        bool              `yaml:"enabled" json:"enabled"`
+	URL            string            `yaml:"url" json:"url"` // 接收端地址，如 http://prometheus:9090/api/v1/write
+	Headers        map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	BearerToken    string            `yaml:"bearer_token,omitempty" json:"bearer_token,omitempty"`
+	Username       string            `yaml:"username,omitempty" json:"username,omitempty"`
+	Password       string            `yaml:"password,omitempty" json:"password,omitempty"`
+	ExternalLabels map[string]string `yaml:"external_labels,omitempty" json:"external_labels,omitempty"`   // 追加到每条时间序列，已存在的同名 label 不覆盖
+	Timeout        string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`                   // 单次请求超时，默认 30s
+	BatchSize      int               `yaml:"batch_size,omitempty" json:"batch_size,omitempty"`             // 单次请求最多包含的时间序列数，默认 500
+	MaxRetries     int               `yaml:"max_retries,omitempty" json:"max_retries,omitempty"`           // 可重试错误（网络错误、5xx、429）的重试次数，默认 3
+	MinBackoff     string            `yaml:"min_backoff,omitempty" json:"min_backoff,omitempty"`           // 首次重试前的等待，默认 500ms，之后翻倍
+	MaxBackoff     string            `yaml:"max_backoff,omitempty" json:"max_backoff,omitempty"`           // 重试等待上限，也是重放磁盘缓冲的检查周期，默认 30s
+	BufferDir      string            `yaml:"buffer_dir,omitempty" json:"buffer_dir,omitempty"`             // 重试耗尽的批次写入该目录，恢复后按时间顺序补发；为空时直接丢弃
+	BufferMaxBytes int64             `yaml:"buffer_max_bytes,omitempty" json:"buffer_max_bytes,omitempty"` // 磁盘缓冲上限，超出时删除最旧的批次，默认 64MiB
+	TLS            TLSConfig         `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// validate 检查 remote_write 配置，未启用时不检查。
+func (r RemoteWriteConfig) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url 必须为 http(s) 地址，实际为 %q", r.URL)
+	}
+	if r.BearerToken != "" && r.Username != "" {
+		return errors.New("bearer_token 与 username 不能同时配置")
+	}
+	for name := range r.ExternalLabels {
+		if !isValidLabelName(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("external_labels 中的 label 名称 %s 无效", name)
+		}
+	}
+	if r.BatchSize < 0 || r.MaxRetries < 0 || r.BufferMaxBytes < 0 {
+		return errors.New("batch_size、max_retries 与 buffer_max_bytes 不能为负数")
+	}
+	if err := validateDurations(r.Timeout, r.MinBackoff, r.MaxBackoff); err != nil {
+		return err
+	}
+	return r.TLS.validate()
+}
+
+// OTLPConfig 定义 OpenTelemetry OTLP 指标导出配置，每个采集周期结束后导出全部指标（含 collector_ 自监控指标）。
+type OTLPConfig struct {
+	Enabled            bool              `yaml:"enabled" json:"enabled"`
+	Protocol           string            `yaml:"protocol,omitempty" json:"protocol,omitempty"`                       // grpc（默认）或 http
+	Endpoint           string            `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`                       // grpc 为 host:port，默认 localhost:4317；http 为完整 URL，默认 http://localhost:4318/v1/metrics
+	Insecure           bool              `yaml:"insecure,omitempty" json:"insecure,omitempty"`                       // grpc 不使用 TLS；http 由 URL 的 scheme 决定
+	Headers            map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`                         // grpc metadata 或 HTTP 请求头，如认证令牌
+	Compression        string            `yaml:"compression,omitempty" json:"compression,omitempty"`                 // gzip 或 none（默认）
+	Timeout            string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`                         // 单次导出超时，默认 10s
+	ResourceAttributes map[string]string `yaml:"resource_attributes,omitempty" json:"resource_attributes,omitempty"` // 未配置 service.name 时默认为 sql2metrics
+	TLS                TLSConfig         `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// validate 检查 OTLP 配置，未启用时不检查。
+func (o OTLPConfig) validate() error {
+	if !o.Enabled {
+		return nil
+	}
+	switch o.Protocol {
+	case "", "grpc":
+		if o.Endpoint != "" {
+			if _, _, err := net.SplitHostPort(o.Endpoint); err != nil {
+				return fmt.Errorf("grpc 的 endpoint 必须为 host:port，实际为 %q", o.Endpoint)
+			}
+		}
+	case "http":
+		if o.Endpoint != "" {
+			u, err := url.Parse(o.Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("http 的 endpoint 必须为 http(s) 地址，实际为 %q", o.Endpoint)
+			}
+		}
+	default:
+		return fmt.Errorf("protocol 只能为 grpc 或 http，实际为 %s", o.Protocol)
+	}
+	if o.Compression != "" && o.Compression != "gzip" && o.Compression != "none" {
+		return fmt.Errorf("compression 只能为 gzip 或 none，实际为 %s", o.Compression)
+	}
+	if err := validateDurations(o.Timeout); err != nil {
+		return err
+	}
+	return o.TLS.validate()
+}
+
+// PushgatewayConfig 定义单次运行模式（-once）下推送到 Pushgateway 的方式。
+type PushgatewayConfig struct {
+	URL         string            `yaml:"url" json:"url"`                                       // 为空时单次运行只采集不推送
+	Job         string            `yaml:"job,omitempty" json:"job,omitempty"`                   // 默认 sql2metrics
+	GroupingKey map[string]string `yaml:"grouping_key,omitempty" json:"grouping_key,omitempty"` // 除 job 外的分组 label，如 instance、site
+	Username    string            `yaml:"username,omitempty" json:"username,omitempty"`
+	Password    string            `yaml:"password,omitempty" json:"password,omitempty"`
+	Timeout     string            `yaml:"timeout,omitempty" json:"timeout,omitempty"` // 默认 30s
+	TLS         TLSConfig         `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// validate 检查 Pushgateway 配置，未配置 url 时不检查。
+func (p PushgatewayConfig) validate() error {
+	if p.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url 必须为 http(s) 地址，实际为 %q", p.URL)
+	}
+	for name := range p.GroupingKey {
+		if !isValidLabelName(name) || name == "job" || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("grouping_key 中的 label 名称 %s 无效", name)
+		}
+	}
+	if err := validateDurations(p.Timeout); err != nil {
+		return err
+	}
+	return p.TLS.validate()
+}
+
+// JobName 返回推送使用的 job 名称。
+func (p PushgatewayConfig) JobName() string {
+	if p.Job == "" {
+		return "sql2metrics"
+	}
+	return p.Job
+}
+
+// SinkConfig 声明一个写回目标，采集成功的样本会按批次写入，用于长期保存计算出的业务 KPI。
+type SinkConfig struct {
+	Name          string              `yaml:"name" json:"name"`                                         // 写回目标名称，用于日志与 sink 标签
+	Type          string              `yaml:"type" json:"type"`                                         // iotdb 或 influxdb
+	Metrics       []string            `yaml:"metrics,omitempty" json:"metrics,omitempty"`               // 只写回这些指标，为空时写回全部
+	BatchSize     int                 `yaml:"batch_size,omitempty" json:"batch_size,omitempty"`         // 攒够该数量的样本立即写入，默认 100
+	FlushInterval string              `yaml:"flush_interval,omitempty" json:"flush_interval,omitempty"` // 不足一批时的最长等待，默认 10s
+	MaxPending    int                 `yaml:"max_pending,omitempty" json:"max_pending,omitempty"`       // 写入失败时保留待重试的样本上限，超出时丢弃最旧的样本，默认 10000
+	Timeout       string              `yaml:"timeout,omitempty" json:"timeout,omitempty"`               // 单次写入超时，默认 10s
+	IoTDB         *IoTDBSinkConfig    `yaml:"iotdb,omitempty" json:"iotdb,omitempty"`
+	InfluxDB      *InfluxDBSinkConfig `yaml:"influxdb,omitempty" json:"influxdb,omitempty"`
+}
+
+// IoTDBSinkConfig 定义写回 IoTDB 的位置，连接复用顶层 iotdb 配置。
+// 每个指标写为 DOUBLE 测点，配置了 series_labels 的指标按 label 名排序后将 label 值追加为设备路径的下级节点。
+type IoTDBSinkConfig struct {
+	Device  string                     `yaml:"device" json:"device"`                       // 默认设备路径，如 root.kpi.sql2metrics，测点名默认为指标名
+	Targets map[string]IoTDBSinkTarget `yaml:"targets,omitempty" json:"targets,omitempty"` // 按指标名覆盖设备路径与测点名
+}
+
+// IoTDBSinkTarget 覆盖单个指标写回的设备路径与测点名，留空的字段使用默认值。
+type IoTDBSinkTarget struct {
+	Device      string `yaml:"device,omitempty" json:"device,omitempty"`
+	Measurement string `yaml:"measurement,omitempty" json:"measurement,omitempty"`
+}
+
+// InfluxDBSinkConfig 定义以行协议写入 InfluxDB 的方式，配置 bucket 时使用 v2 接口，否则使用 v1 的 database。
+type InfluxDBSinkConfig struct {
+	URL       string            `yaml:"url" json:"url"`
+	Org       string            `yaml:"org,omitempty" json:"org,omitempty"`             // v2
+	Bucket    string            `yaml:"bucket,omitempty" json:"bucket,omitempty"`       // v2
+	Token     string            `yaml:"token,omitempty" json:"token,omitempty"`         // v2
+	Database  string            `yaml:"database,omitempty" json:"database,omitempty"`   // v1
+	Username  string            `yaml:"username,omitempty" json:"username,omitempty"`   // v1
+	Password  string            `yaml:"password,omitempty" json:"password,omitempty"`   // v1
+	Precision string            `yaml:"precision,omitempty" json:"precision,omitempty"` // s/ms/us/ns，默认 ms
+	Tags      map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`           // 追加到每行的 tag，指标自身的 label 优先
+	TLS       TLSConfig         `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// validate 检查写回配置，metricNames 为已定义的指标。
+func (s SinkConfig) validate(iotdb IoTDBConfig, metricNames map[string]bool) error {
+	for _, name := range s.Metrics {
+		if !metricNames[name] {
+			return fmt.Errorf("metrics 中的指标 %s 不存在", name)
+		}
+	}
+	if s.BatchSize < 0 || s.MaxPending < 0 {
+		return errors.New("batch_size 与 max_pending 不能为负数")
+	}
+	if err := validateDurations(s.FlushInterval, s.Timeout); err != nil {
+		return err
+	}
+	switch s.Type {
+	case "iotdb":
+		if s.IoTDB == nil {
+			return errors.New("iotdb 类型需要配置 iotdb 段")
+		}
+		if iotdb.Host == "" {
+			return errors.New("写回 IoTDB 需要配置顶层 iotdb 连接")
+		}
+		if !strings.HasPrefix(s.IoTDB.Device, "root.") {
+			return fmt.Errorf("device 必须以 root. 开头，实际为 %q", s.IoTDB.Device)
+		}
+		for name, target := range s.IoTDB.Targets {
+			if !metricNames[name] {
+				return fmt.Errorf("targets 中的指标 %s 不存在", name)
+			}
+			if target.Device != "" && !strings.HasPrefix(target.Device, "root.") {
+				return fmt.Errorf("指标 %s 的 device 必须以 root. 开头", name)
+			}
+		}
+	case "influxdb":
+		in := s.InfluxDB
+		if in == nil {
+			return errors.New("influxdb 类型需要配置 influxdb 段")
+		}
+		u, err := url.Parse(in.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("influxdb.url 必须为 http(s) 地址，实际为 %q", in.URL)
+		}
+		if (in.Bucket == "") == (in.Database == "") {
+			return errors.New("influxdb 需要配置 bucket（v2）或 database（v1）之一")
+		}
+		switch in.Precision {
+		case "", "s", "ms", "us", "ns":
+		default:
+			return fmt.Errorf("precision 只能为 s、ms、us 或 ns，实际为 %s", in.Precision)
+		}
+		return in.TLS.validate()
+	default:
+		return fmt.Errorf("不支持的写回类型: %s，可选 iotdb、influxdb", s.Type)
+	}
+	return nil
+}
+
+// metricGroupNameRegex 匹配合法的指标分组名，分组名会出现在 /metrics/{group} 路径中
+var metricGroupNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// MetricGroupConfig 定义一个指标分组的暴露端点 /metrics/{group}。分组拥有独立的注册表，
+// 只包含声明了该分组的指标，不含 Go 运行时指标，便于不同的 Prometheus job 按各自的周期与权限抓取。
+type MetricGroupConfig struct {
+	BearerToken        string `yaml:"bearer_token,omitempty" json:"bearer_token,omitempty"` // 抓取时需携带 Authorization: Bearer
+	Username           string `yaml:"username,omitempty" json:"username,omitempty"`         // 抓取时需携带 Basic 认证
+	Password           string `yaml:"password,omitempty" json:"password,omitempty"`
+	IncludeSelfMetrics bool   `yaml:"include_self_metrics,omitempty" json:"include_self_metrics,omitempty"` // 同时暴露 collector_ 自监控指标
+	Mode               string `yaml:"mode,omitempty" json:"mode,omitempty"`                                 // on_scrape 时分组内未单独配置 mode 的 gauge 指标改为抓取时查询
+}
+
+func (g MetricGroupConfig) validate() error {
+	if g.BearerToken != "" && g.Username != "" {
+		return errors.New("bearer_token 与 username 只能配置一种")
+	}
+	if g.Username != "" && g.Password == "" {
+		return errors.New("配置 username 时需要同时配置 password")
+	}
+	switch g.Mode {
+	case "", MetricModeInterval, MetricModeOnScrape:
+	default:
+		return fmt.Errorf("mode 只能为 interval 或 on_scrape，实际为 %s", g.Mode)
+	}
+	return nil
+}
+
+// ProbeConfig 定义 /probe?module=<name>&target=<target> 端点。模块是一组可复用的查询，
+// 对请求中指定的连接执行，便于由 Prometheus 服务发现驱动对大量同构分片库的采集。
+type ProbeConfig struct {
+	Modules        map[string]ProbeModule `yaml:"modules,omitempty" json:"modules,omitempty"`
+	AllowedTargets []string               `yaml:"allowed_targets,omitempty" json:"allowed_targets,omitempty"` // 允许直接作为 target 的 DSN，* 匹配除 /、@、?、# 外的任意字符；未列出的 DSN 一律拒绝
+}
+
+// ProbeModule 是一组对同一连接执行的查询。
+type ProbeModule struct {
+	Source  string        `yaml:"source" json:"source"`                       // mysql 或 sql，target 为该数据源下的连接名
+	Driver  string        `yaml:"driver,omitempty" json:"driver,omitempty"`   // target 为 DSN 时使用的 sql 驱动，未配置时不接受 DSN
+	Timeout string        `yaml:"timeout,omitempty" json:"timeout,omitempty"` // 整个探测的超时，默认 10s，抓取请求的超时更短时以其为准
+	Metrics []ProbeMetric `yaml:"metrics" json:"metrics"`
+}
+
+// ProbeMetric 是模块中的一个查询，结果以 gauge 输出。
+type ProbeMetric struct {
+	Name   string            `yaml:"name" json:"name"`
+	Help   string            `yaml:"help" json:"help"`
+	Query  string            `yaml:"query" json:"query"`
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// metricNameRegex 匹配有效的 Prometheus 指标名称
+var metricNameRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+func (p ProbeConfig) validate() error {
+	for name, module := range p.Modules {
+		if err := module.validate(); err != nil {
+			return fmt.Errorf("探测模块 %s 配置无效: %w", name, err)
+		}
+	}
+	for _, pattern := range p.AllowedTargets {
+		if strings.TrimSpace(pattern) == "" || strings.Trim(pattern, "*") == "" {
+			return fmt.Errorf("allowed_targets 中的 %q 过于宽泛", pattern)
+		}
+	}
+	return nil
+}
+
+func (m ProbeModule) validate() error {
+	if m.Source != "mysql" && m.Source != "sql" {
+		return fmt.Errorf("source 只能为 mysql 或 sql，实际为 %q", m.Source)
+	}
+	if len(m.Metrics) == 0 {
+		return errors.New("至少需要一个查询")
+	}
+	if err := validateDurations(m.Timeout); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, metric := range m.Metrics {
+		if !metricNameRegex.MatchString(metric.Name) {
+			return fmt.Errorf("指标名称 %q 非法", metric.Name)
+		}
+		if seen[metric.Name] || metric.Name == "probe_success" || metric.Name == "probe_duration_seconds" {
+			return fmt.Errorf("指标名称 %s 重复或与 probe_success、probe_duration_seconds 冲突", metric.Name)
+		}
+		seen[metric.Name] = true
+		if metric.Query == "" {
+			return fmt.Errorf("指标 %s 缺少查询语句", metric.Name)
+		}
+		if err := sqlguard.CheckReadOnly(metric.Query); err != nil {
+			return fmt.Errorf("指标 %s 的查询未通过只读校验: %w", metric.Name, err)
+		}
+		for label := range metric.Labels {
+			if !isValidLabelName(label) {
+				return fmt.Errorf("指标 %s 的 label 名称 %q 非法", metric.Name, label)
+			}
+		}
+	}
+	return nil
+}
+
+// TargetAllowed 判断 DSN 是否在 allowed_targets 中。
+func (p ProbeConfig) TargetAllowed(dsn string) bool {
+	for _, pattern := range p.AllowedTargets {
+		// * 不跨越 /、@、?、#，避免通过用户信息或路径把其他主机拼进 DSN
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `[^/@?#]*`) + "$"
+		if ok, _ := regexp.MatchString(expr, dsn); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ConnectionDiscoveryConfig 定义连接发现：仿照 Prometheus 的 file_sd/http_sd，从文件或 HTTP 接口读取连接定义，
+// 定期刷新并在不重载配置的情况下增删连接。连接定义中不写凭据，而是通过 secret 引用 secrets 中的条目。
+type ConnectionDiscoveryConfig struct {
+	RefreshInterval string                     `yaml:"refresh_interval,omitempty" json:"refresh_interval,omitempty"` // 刷新周期，默认 1m
+	Secrets         map[string]DiscoverySecret `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	FileSD          []FileSDConfig             `yaml:"file_sd,omitempty" json:"file_sd,omitempty"`
+	HTTPSD          []HTTPSDConfig             `yaml:"http_sd,omitempty" json:"http_sd,omitempty"`
+}
+
+// DiscoverySecret 是发现的连接引用的凭据。MySQL 连接使用 username/password，
+// SQL 连接的 dsn 中的 {{username}}、{{password}} 占位符替换为对应的值。
+type DiscoverySecret struct {
+	Username     string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password     string `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty" json:"password_file,omitempty"` // 每次刷新时读取，便于轮换口令
+}
+
+// FileSDConfig 从 JSON/YAML 文件读取连接定义，files 支持 glob。
+type FileSDConfig struct {
+	Source string   `yaml:"source" json:"source"` // mysql 或 sql
+	Files  []string `yaml:"files" json:"files"`
+}
+
+// HTTPSDConfig 从 HTTP 接口读取连接定义，响应体格式与 file_sd 的文件相同。
+type HTTPSDConfig struct {
+	Source      string            `yaml:"source" json:"source"` // mysql 或 sql
+	URL         string            `yaml:"url" json:"url"`
+	Timeout     string            `yaml:"timeout,omitempty" json:"timeout,omitempty"` // 默认 10s
+	Headers     map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	BearerToken string            `yaml:"bearer_token,omitempty" json:"bearer_token,omitempty"`
+	Username    string            `yaml:"username,omitempty" json:"username,omitempty"`
+	Password    string            `yaml:"password,omitempty" json:"password,omitempty"`
+	TLS         TLSConfig         `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// Enabled 返回是否配置了连接发现。
+func (d ConnectionDiscoveryConfig) Enabled() bool {
+	return len(d.FileSD) > 0 || len(d.HTTPSD) > 0
+}
+
+// Discovers 返回是否有发现来源提供指定数据源的连接。
+func (d ConnectionDiscoveryConfig) Discovers(source string) bool {
+	for _, f := range d.FileSD {
+		if f.Source == source {
+			return true
+		}
+	}
+	for _, h := range d.HTTPSD {
+		if h.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshIntervalDuration 返回刷新周期，默认 1m。
+func (d ConnectionDiscoveryConfig) RefreshIntervalDuration() (time.Duration, error) {
+	return ParseDuration(d.RefreshInterval, time.Minute)
+}
+
+func (d ConnectionDiscoveryConfig) validate() error {
+	if _, err := d.RefreshIntervalDuration(); err != nil {
+		return fmt.Errorf("refresh_interval 无效: %w", err)
+	}
+	for name, secret := range d.Secrets {
+		if secret.Password != "" && secret.PasswordFile != "" {
+			return fmt.Errorf("凭据 %s 的 password 与 password_file 不能同时配置", name)
+		}
+	}
+	for i, f := range d.FileSD {
+		if f.Source != "mysql" && f.Source != "sql" {
+			return fmt.Errorf("file_sd[%d] 的 source 只能为 mysql 或 sql，实际为 %q", i, f.Source)
+		}
+		if len(f.Files) == 0 {
+			return fmt.Errorf("file_sd[%d] 缺少 files", i)
+		}
+		for _, pattern := range f.Files {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("file_sd[%d] 的文件 glob %q 无效: %w", i, pattern, err)
+			}
+		}
+	}
+	for i, h := range d.HTTPSD {
+		if h.Source != "mysql" && h.Source != "sql" {
+			return fmt.Errorf("http_sd[%d] 的 source 只能为 mysql 或 sql，实际为 %q", i, h.Source)
+		}
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("http_sd[%d] 的 url 无效: %q", i, h.URL)
+		}
+		if err := validateDurations(h.Timeout); err != nil {
+			return fmt.Errorf("http_sd[%d] 配置无效: %w", i, err)
+		}
+		if err := h.TLS.validate(); err != nil {
+			return fmt.Errorf("http_sd[%d] 的 TLS 配置无效: %w", i, err)
+		}
+	}
+	return nil
+}
+
 // AlertmanagerConfig 定义 Alertmanager 告警推送配置。
 type AlertmanagerConfig struct {
 	URL string `yaml:"url" json:"url"` // Alertmanager API 地址，如 http://localhost:9093
@@ -100,12 +579,32 @@ type FeishuNotifierConfig struct {
 
 // MySQLConfig 填写 MySQL 连接与查询所需信息。
 type MySQLConfig struct {
-	Host     string            `yaml:"host" json:"host"`
-	Port     int               `yaml:"port" json:"port"`
-	User     string            `yaml:"user" json:"user"`
-	Password string            `yaml:"password" json:"password"`
-	Database string            `yaml:"database" json:"database"`
-	Params   map[string]string `yaml:"params" json:"params,omitempty"`
+	Host       string            `yaml:"host" json:"host"`
+	Port       int               `yaml:"port" json:"port"`
+	User       string            `yaml:"user" json:"user"`
+	Password   string            `yaml:"password" json:"password"`
+	Database   string            `yaml:"database" json:"database"`
+	Params     map[string]string `yaml:"params" json:"params,omitempty"`
+	TLS        TLSConfig         `yaml:"tls,omitempty" json:"tls,omitempty"`
+	SSHTunnel  *SSHTunnelConfig  `yaml:"ssh_tunnel,omitempty" json:"ssh_tunnel,omitempty"`
+	QueryGuard QueryGuardConfig  `yaml:"query_guard,omitempty" json:"query_guard,omitempty"`
+	// 连接池设置，未配置时沿用保守默认值（最多 5 个连接、2 个空闲连接、30m 生命周期）
+	MaxOpenConns    int         `yaml:"max_open_conns,omitempty" json:"max_open_conns,omitempty"`
+	MaxIdleConns    int         `yaml:"max_idle_conns,omitempty" json:"max_idle_conns,omitempty"`
+	ConnMaxLifetime string      `yaml:"conn_max_lifetime,omitempty" json:"conn_max_lifetime,omitempty"`
+	ConnMaxIdleTime string      `yaml:"conn_max_idle_time,omitempty" json:"conn_max_idle_time,omitempty"`
+	Retry           RetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// QueryGuardConfig 定义基于 EXPLAIN 的查询成本限制，保存指标与采集时均会检查。
+type QueryGuardConfig struct {
+	MaxEstimatedRows int64 `yaml:"max_estimated_rows,omitempty" json:"max_estimated_rows,omitempty"` // 单表估算扫描行数上限，0 表示不限制
+	DenyFullScan     bool  `yaml:"deny_full_scan,omitempty" json:"deny_full_scan,omitempty"`         // 拒绝 type=ALL 的全表扫描
+}
+
+// Enabled 判断是否配置了成本限制。
+func (g QueryGuardConfig) Enabled() bool {
+	return g.MaxEstimatedRows > 0 || g.DenyFullScan
 }
 
 // RedisConfig 填写 Redis 连接信息。
@@ -117,54 +616,817 @@ type RedisConfig struct {
 	DB            int    `yaml:"db" json:"db,omitempty"`
 	EnableTLS     bool   `yaml:"enable_tls" json:"enable_tls,omitempty"`
 	SkipTLSVerify bool   `yaml:"skip_tls_verify" json:"skip_tls_verify,omitempty"`
+	// TLS 提供 CA、客户端证书等高级选项，enable_tls/skip_tls_verify 保留兼容
+	TLS       TLSConfig        `yaml:"tls,omitempty" json:"tls,omitempty"`
+	SSHTunnel *SSHTunnelConfig `yaml:"ssh_tunnel,omitempty" json:"ssh_tunnel,omitempty"`
+	// 连接池设置，未配置时使用 go-redis 默认值
+	PoolSize        int         `yaml:"pool_size,omitempty" json:"pool_size,omitempty"`
+	MinIdleConns    int         `yaml:"min_idle_conns,omitempty" json:"min_idle_conns,omitempty"`
+	ConnMaxIdleTime string      `yaml:"conn_max_idle_time,omitempty" json:"conn_max_idle_time,omitempty"`
+	Retry           RetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
 }
 
 // IoTDBConfig 填写 IoTDB Session 连接信息。
 type IoTDBConfig struct {
-	Host        string `yaml:"host" json:"host"`
-	Port        int    `yaml:"port" json:"port"`
-	User        string `yaml:"user" json:"user"`
-	Password    string `yaml:"password" json:"password"`
-	FetchSize   int    `yaml:"fetch_size" json:"fetch_size"`
-	ZoneID      string `yaml:"zone_id" json:"zone_id"`
-	EnableTLS   bool   `yaml:"enable_tls" json:"enable_tls"`
-	EnableZstd  bool   `yaml:"enable_zstd" json:"enable_zstd"`
-	SessionPool int    `yaml:"session_pool" json:"session_pool,omitempty"`
+	Host        string           `yaml:"host" json:"host"`
+	Port        int              `yaml:"port" json:"port"`
+	User        string           `yaml:"user" json:"user"`
+	Password    string           `yaml:"password" json:"password"`
+	FetchSize   int              `yaml:"fetch_size" json:"fetch_size"`
+	ZoneID      string           `yaml:"zone_id" json:"zone_id"`
+	EnableTLS   bool             `yaml:"enable_tls" json:"enable_tls"`
+	EnableZstd  bool             `yaml:"enable_zstd" json:"enable_zstd"`
+	SessionPool int              `yaml:"session_pool" json:"session_pool,omitempty"`
+	SSHTunnel   *SSHTunnelConfig `yaml:"ssh_tunnel,omitempty" json:"ssh_tunnel,omitempty"`
+	Retry       RetryConfig      `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// ModbusConfig 定义 Modbus TCP 设备连接。
+type ModbusConfig struct {
+	Host      string      `yaml:"host" json:"host"`
+	Port      int         `yaml:"port" json:"port"`                                 // 默认 502
+	UnitID    int         `yaml:"unit_id" json:"unit_id"`                           // 从站地址（单元标识）
+	Timeout   string      `yaml:"timeout,omitempty" json:"timeout,omitempty"`       // 连接与单次请求超时，默认 5s
+	ByteOrder string      `yaml:"byte_order,omitempty" json:"byte_order,omitempty"` // 寄存器内字节序 big/little，默认 big
+	WordOrder string      `yaml:"word_order,omitempty" json:"word_order,omitempty"` // 32 位数值的字序 big/little，默认 big（高字在前）
+	Retry     RetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// validate 检查 Modbus 连接配置。
+func (m ModbusConfig) validate() error {
+	if m.Host == "" {
+		return errors.New("缺少 host")
+	}
+	if m.UnitID < 0 || m.UnitID > 255 {
+		return fmt.Errorf("unit_id 必须介于 0 与 255 之间，实际为 %d", m.UnitID)
+	}
+	for _, order := range []string{m.ByteOrder, m.WordOrder} {
+		if order != "" && order != "big" && order != "little" {
+			return fmt.Errorf("字节序只能为 big 或 little，实际为 %s", order)
+		}
+	}
+	if err := validateDurations(m.Timeout); err != nil {
+		return err
+	}
+	return m.Retry.validate()
+}
+
+// MQTTConfig 定义 MQTT Broker 连接，指标的 query 为订阅的主题过滤器。
+type MQTTConfig struct {
+	Broker         string      `yaml:"broker" json:"broker"`                           // 例如 tcp://broker:1883、ssl://broker:8883
+	ClientID       string      `yaml:"client_id,omitempty" json:"client_id,omitempty"` // 为空时自动生成
+	Username       string      `yaml:"username,omitempty" json:"username,omitempty"`
+	Password       string      `yaml:"password,omitempty" json:"password,omitempty"`
+	QoS            int         `yaml:"qos,omitempty" json:"qos,omitempty"`                         // 订阅 QoS：0/1/2
+	KeepAlive      string      `yaml:"keep_alive,omitempty" json:"keep_alive,omitempty"`           // 默认 30s
+	ConnectTimeout string      `yaml:"connect_timeout,omitempty" json:"connect_timeout,omitempty"` // 默认 10s
+	TLS            TLSConfig   `yaml:"tls,omitempty" json:"tls,omitempty"`
+	Retry          RetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// validate 检查 MQTT 连接配置。
+func (m MQTTConfig) validate() error {
+	if m.Broker == "" {
+		return errors.New("缺少 broker")
+	}
+	u, err := url.Parse(m.Broker)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("broker 地址无效: %s", m.Broker)
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+	default:
+		return fmt.Errorf("不支持的 broker 协议: %s", u.Scheme)
+	}
+	if m.QoS < 0 || m.QoS > 2 {
+		return fmt.Errorf("qos 只能为 0、1 或 2，实际为 %d", m.QoS)
+	}
+	if err := m.TLS.validate(); err != nil {
+		return err
+	}
+	if err := validateDurations(m.KeepAlive, m.ConnectTimeout); err != nil {
+		return err
+	}
+	return m.Retry.validate()
+}
+
+// SNMPConfig 定义 SNMP 设备连接，支持 v2c 与 v3。
+type SNMPConfig struct {
+	Host      string       `yaml:"host" json:"host"`
+	Port      int          `yaml:"port" json:"port"`                               // 默认 161
+	Version   string       `yaml:"version" json:"version"`                         // 2c（默认）或 3
+	Community string       `yaml:"community,omitempty" json:"community,omitempty"` // v2c 团体名，默认 public
+	Timeout   string       `yaml:"timeout,omitempty" json:"timeout,omitempty"`     // 单次请求超时，默认 5s
+	Retries   int          `yaml:"retries,omitempty" json:"retries,omitempty"`     // UDP 请求重发次数
+	V3        SNMPv3Config `yaml:"v3,omitempty" json:"v3,omitempty"`
+	Retry     RetryConfig  `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// SNMPv3Config 定义 SNMPv3 USM 认证参数。
+type SNMPv3Config struct {
+	Username       string `yaml:"username" json:"username"`
+	SecurityLevel  string `yaml:"security_level" json:"security_level"`                   // noAuthNoPriv/authNoPriv/authPriv
+	AuthProtocol   string `yaml:"auth_protocol,omitempty" json:"auth_protocol,omitempty"` // MD5/SHA/SHA224/SHA256/SHA384/SHA512
+	AuthPassphrase string `yaml:"auth_passphrase,omitempty" json:"auth_passphrase,omitempty"`
+	PrivProtocol   string `yaml:"priv_protocol,omitempty" json:"priv_protocol,omitempty"` // DES/AES/AES192/AES256/AES192C/AES256C
+	PrivPassphrase string `yaml:"priv_passphrase,omitempty" json:"priv_passphrase,omitempty"`
+	ContextName    string `yaml:"context_name,omitempty" json:"context_name,omitempty"`
+}
+
+// validate 检查 SNMP 连接配置。
+func (s SNMPConfig) validate() error {
+	if s.Host == "" {
+		return errors.New("缺少 host")
+	}
+	if s.Retries < 0 {
+		return errors.New("retries 不能为负数")
+	}
+	switch s.Version {
+	case "", "2c":
+	case "3":
+		if s.V3.Username == "" {
+			return errors.New("SNMPv3 需要配置 v3.username")
+		}
+		switch s.V3.SecurityLevel {
+		case "", "noAuthNoPriv":
+		case "authNoPriv", "authPriv":
+			if s.V3.AuthProtocol == "" || s.V3.AuthPassphrase == "" {
+				return fmt.Errorf("安全级别 %s 需要配置 auth_protocol 与 auth_passphrase", s.V3.SecurityLevel)
+			}
+			if s.V3.SecurityLevel == "authPriv" && (s.V3.PrivProtocol == "" || s.V3.PrivPassphrase == "") {
+				return errors.New("安全级别 authPriv 需要配置 priv_protocol 与 priv_passphrase")
+			}
+		default:
+			return fmt.Errorf("不支持的 SNMPv3 安全级别: %s", s.V3.SecurityLevel)
+		}
+	default:
+		return fmt.Errorf("不支持的 SNMP 版本: %s，可选 2c 或 3", s.Version)
+	}
+	if err := validateDurations(s.Timeout); err != nil {
+		return err
+	}
+	return s.Retry.validate()
+}
+
+// PrometheusSourceConfig 定义远端 Prometheus（或兼容 /api/v1/query 的服务，如 Thanos、VictoriaMetrics）连接。
+type PrometheusSourceConfig struct {
+	URL         string            `yaml:"url" json:"url"`
+	Timeout     string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`           // 额外请求头，如 X-Scope-OrgID
+	BearerToken string            `yaml:"bearer_token,omitempty" json:"bearer_token,omitempty"` // 设置后发送 Authorization: Bearer
+	Username    string            `yaml:"username,omitempty" json:"username,omitempty"`         // Basic 认证
+	Password    string            `yaml:"password,omitempty" json:"password,omitempty"`
+	TLS         TLSConfig         `yaml:"tls,omitempty" json:"tls,omitempty"`
+	Proxy       ProxyConfig       `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+	Retry       RetryConfig       `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// validate 检查 Prometheus 连接配置。
+func (p PrometheusSourceConfig) validate() error {
+	if p.URL == "" {
+		return errors.New("缺少 url")
+	}
+	if p.BearerToken != "" && p.Username != "" {
+		return errors.New("bearer_token 与 username/password 不能同时配置")
+	}
+	if err := validateDurations(p.Timeout); err != nil {
+		return err
+	}
+	if err := p.TLS.validate(); err != nil {
+		return err
+	}
+	if err := p.Proxy.validate(); err != nil {
+		return err
+	}
+	return p.Retry.validate()
+}
+
+// RestAPIConfig 将连接转换为 RestAPI 配置，认证信息合并为请求头，以复用 RestAPI 的 HTTP 客户端。
+func (p PrometheusSourceConfig) RestAPIConfig() RestAPIConfig {
+	headers := make(map[string]string, len(p.Headers)+1)
+	for k, v := range p.Headers {
+		headers[k] = v
+	}
+	switch {
+	case p.BearerToken != "":
+		headers["Authorization"] = "Bearer " + p.BearerToken
+	case p.Username != "":
+		credentials := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		headers["Authorization"] = "Basic " + credentials
+	}
+	return RestAPIConfig{
+		BaseURL: p.URL,
+		Timeout: p.Timeout,
+		Headers: headers,
+		TLS:     p.TLS,
+		Proxy:   p.Proxy,
+		Retry:   p.Retry,
+	}
+}
+
+// MongoDBConfig 定义 MongoDB 连接。
+type MongoDBConfig struct {
+	URI            string      `yaml:"uri" json:"uri"`                               // mongodb:// 或 mongodb+srv:// 连接串
+	Database       string      `yaml:"database" json:"database"`                     // 查询未指定 database 时使用
+	Username       string      `yaml:"username,omitempty" json:"username,omitempty"` // 设置后覆盖连接串中的认证信息
+	Password       string      `yaml:"password,omitempty" json:"password,omitempty"`
+	AuthSource     string      `yaml:"auth_source,omitempty" json:"auth_source,omitempty"`         // 默认 admin
+	ReadPreference string      `yaml:"read_preference,omitempty" json:"read_preference,omitempty"` // primary（默认）/primaryPreferred/secondary/secondaryPreferred/nearest
+	MaxTimeMS      int64       `yaml:"max_time_ms,omitempty" json:"max_time_ms,omitempty"`         // 服务端单次查询最长执行时间，默认 10000
+	ConnectTimeout string      `yaml:"connect_timeout,omitempty" json:"connect_timeout,omitempty"` // 默认 10s
+	TLS            TLSConfig   `yaml:"tls,omitempty" json:"tls,omitempty"`
+	Retry          RetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// validate 检查 MongoDB 连接配置。
+func (m MongoDBConfig) validate() error {
+	if m.URI == "" {
+		return errors.New("缺少 uri")
+	}
+	if !strings.HasPrefix(m.URI, "mongodb://") && !strings.HasPrefix(m.URI, "mongodb+srv://") {
+		return errors.New("uri 必须以 mongodb:// 或 mongodb+srv:// 开头")
+	}
+	switch m.ReadPreference {
+	case "", "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest":
+	default:
+		return fmt.Errorf("不支持的 read_preference: %s", m.ReadPreference)
+	}
+	if m.MaxTimeMS < 0 {
+		return errors.New("max_time_ms 不能为负数")
+	}
+	if err := validateDurations(m.ConnectTimeout); err != nil {
+		return err
+	}
+	if err := m.TLS.validate(); err != nil {
+		return err
+	}
+	return m.Retry.validate()
+}
+
+// ElasticsearchConfig 定义 Elasticsearch/OpenSearch 连接。
+type ElasticsearchConfig struct {
+	URL      string            `yaml:"url" json:"url"`
+	Username string            `yaml:"username,omitempty" json:"username,omitempty"` // Basic 认证
+	Password string            `yaml:"password,omitempty" json:"password,omitempty"`
+	APIKey   string            `yaml:"api_key,omitempty" json:"api_key,omitempty"` // Base64 编码的 API Key，发送 Authorization: ApiKey
+	Headers  map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Timeout  string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	TLS      TLSConfig         `yaml:"tls,omitempty" json:"tls,omitempty"`
+	Proxy    ProxyConfig       `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+	Retry    RetryConfig       `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// validate 检查 Elasticsearch 连接配置。
+func (e ElasticsearchConfig) validate() error {
+	if e.URL == "" {
+		return errors.New("缺少 url")
+	}
+	if e.APIKey != "" && e.Username != "" {
+		return errors.New("api_key 与 username/password 不能同时配置")
+	}
+	if err := validateDurations(e.Timeout); err != nil {
+		return err
+	}
+	if err := e.TLS.validate(); err != nil {
+		return err
+	}
+	if err := e.Proxy.validate(); err != nil {
+		return err
+	}
+	return e.Retry.validate()
+}
+
+// RestAPIConfig 将连接转换为 RestAPI 配置，认证信息合并为请求头。
+func (e ElasticsearchConfig) RestAPIConfig() RestAPIConfig {
+	headers := make(map[string]string, len(e.Headers)+1)
+	for k, v := range e.Headers {
+		headers[k] = v
+	}
+	switch {
+	case e.APIKey != "":
+		headers["Authorization"] = "ApiKey " + e.APIKey
+	case e.Username != "":
+		credentials := base64.StdEncoding.EncodeToString([]byte(e.Username + ":" + e.Password))
+		headers["Authorization"] = "Basic " + credentials
+	}
+	return RestAPIConfig{
+		BaseURL: e.URL,
+		Timeout: e.Timeout,
+		Headers: headers,
+		TLS:     e.TLS,
+		Proxy:   e.Proxy,
+		Retry:   e.Retry,
+	}
+}
+
+// SQLConfig 定义通过 database/sql 驱动访问的通用 SQL 连接。
+type SQLConfig struct {
+	Driver          string      `yaml:"driver" json:"driver"` // sqlite/sqlserver/postgres/tidb
+	DSN             string      `yaml:"dsn" json:"dsn"`       // 驱动原生的连接串
+	MaxOpenConns    int         `yaml:"max_open_conns,omitempty" json:"max_open_conns,omitempty"`
+	MaxIdleConns    int         `yaml:"max_idle_conns,omitempty" json:"max_idle_conns,omitempty"`
+	ConnMaxLifetime string      `yaml:"conn_max_lifetime,omitempty" json:"conn_max_lifetime,omitempty"`
+	ConnMaxIdleTime string      `yaml:"conn_max_idle_time,omitempty" json:"conn_max_idle_time,omitempty"`
+	Retry           RetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// Validate 检查通用 SQL 连接配置，也用于校验连接发现得到的连接。驱动是否已注册在创建连接时检查。
+func (s SQLConfig) Validate() error {
+	if s.Driver == "" {
+		return errors.New("缺少 driver")
+	}
+	if s.DSN == "" {
+		return errors.New("缺少 dsn")
+	}
+	if s.MaxOpenConns < 0 || s.MaxIdleConns < 0 {
+		return errors.New("连接池大小不能为负数")
+	}
+	if err := validateDurations(s.ConnMaxLifetime, s.ConnMaxIdleTime); err != nil {
+		return err
+	}
+	return s.Retry.validate()
+}
+
+// FileSourceConfig 定义 file 数据源可以读取的本地文件，对所有 file 指标与预览生效。
+// 该配置只能通过配置文件修改，管理接口更新配置时会保留原值。
+type FileSourceConfig struct {
+	AllowedPaths []string `yaml:"allowed_paths,omitempty" json:"allowed_paths,omitempty"` // 允许读取的文件绝对路径，指标的 query 与其中之一完全一致即可读取
+	AllowedDirs  []string `yaml:"allowed_dirs,omitempty" json:"allowed_dirs,omitempty"`   // 允许读取的目录绝对路径，目录下（含子目录）的文件均可读取
+}
+
+// validate 检查 file 白名单。
+func (c FileSourceConfig) validate() error {
+	for _, path := range append(append([]string(nil), c.AllowedPaths...), c.AllowedDirs...) {
+		if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+			return fmt.Errorf("%q 必须为规范的绝对路径", path)
+		}
+	}
+	return nil
+}
+
+// Allowed 判断文件路径是否在白名单中：与 allowed_paths 之一完全一致，或位于 allowed_dirs 之一下。
+// 这里只做字面比较，读取时还需按解析符号链接后的真实路径再检查一次目录白名单。
+func (c FileSourceConfig) Allowed(path string) bool {
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+		return false
+	}
+	for _, allowed := range c.AllowedPaths {
+		if allowed == path {
+			return true
+		}
+	}
+	for _, dir := range c.AllowedDirs {
+		if WithinDir(dir, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithinDir 判断 path 是否位于目录 dir 下（不含 dir 本身），两者都应为规范的绝对路径。
+func WithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CommandSourceConfig 定义 command 数据源的沙箱策略，对所有 command 指标生效。
+// 该配置只能通过配置文件修改，管理接口更新配置时会保留原值。
+type CommandSourceConfig struct {
+	AllowedCommands []string          `yaml:"allowed_commands,omitempty" json:"allowed_commands,omitempty"` // 允许执行的可执行文件绝对路径，指标的 query 必须与其中之一完全一致
+	WorkDir         string            `yaml:"work_dir,omitempty" json:"work_dir,omitempty"`                 // 工作目录，默认为系统临时目录
+	Env             map[string]string `yaml:"env,omitempty" json:"env,omitempty"`                           // 额外传给命令的环境变量
+	InheritEnv      bool              `yaml:"inherit_env,omitempty" json:"inherit_env,omitempty"`           // 是否继承采集服务的环境变量，默认只提供 PATH
+	Timeout         string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`                   // 默认执行超时，默认 10s
+	MaxTimeout      string            `yaml:"max_timeout,omitempty" json:"max_timeout,omitempty"`           // 指标可配置的最长超时，默认 1m
+	MaxOutputBytes  int               `yaml:"max_output_bytes,omitempty" json:"max_output_bytes,omitempty"` // 标准输出上限，超出视为失败，默认 1MiB
+}
+
+// validate 检查 command 沙箱策略。
+func (c CommandSourceConfig) validate() error {
+	for _, path := range c.AllowedCommands {
+		if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+			return fmt.Errorf("allowed_commands 中的 %q 必须为规范的绝对路径", path)
+		}
+	}
+	if c.WorkDir != "" && !filepath.IsAbs(c.WorkDir) {
+		return errors.New("work_dir 必须为绝对路径")
+	}
+	if c.MaxOutputBytes < 0 {
+		return errors.New("max_output_bytes 不能为负数")
+	}
+	return validateDurations(c.Timeout, c.MaxTimeout)
+}
+
+// Allowed 判断可执行文件是否在白名单中。
+func (c CommandSourceConfig) Allowed(path string) bool {
+	for _, allowed := range c.AllowedCommands {
+		if allowed == path {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeoutFor 返回指标生效的执行超时，受 max_timeout 限制。
+func (c CommandSourceConfig) TimeoutFor(spec MetricSpec) time.Duration {
+	timeout := 10 * time.Second
+	if d, err := time.ParseDuration(c.Timeout); err == nil && c.Timeout != "" {
+		timeout = d
+	}
+	if d, err := time.ParseDuration(spec.Timeout); err == nil && spec.Timeout != "" {
+		timeout = d
+	}
+	limit := time.Minute
+	if d, err := time.ParseDuration(c.MaxTimeout); err == nil && c.MaxTimeout != "" {
+		limit = d
+	}
+	if timeout > limit {
+		timeout = limit
+	}
+	return timeout
+}
+
+// PluginConfig 声明一个外部数据源插件，插件名称即指标可使用的 source。
+// 与 command 一样，该配置只能通过配置文件修改，管理接口更新配置时会保留原值。
+type PluginConfig struct {
+	Command           string                 `yaml:"command" json:"command"`                                             // 插件可执行文件的绝对路径
+	Args              []string               `yaml:"args,omitempty" json:"args,omitempty"`                               // 启动参数
+	Env               map[string]string      `yaml:"env,omitempty" json:"env,omitempty"`                                 // 在服务环境变量之上追加的环境变量
+	Config            map[string]interface{} `yaml:"config,omitempty" json:"config,omitempty"`                           // 通过 Configure 调用原样传给插件
+	Timeout           string                 `yaml:"timeout,omitempty" json:"timeout,omitempty"`                         // 单次调用超时，默认 10s
+	RestartBackoff    string                 `yaml:"restart_backoff,omitempty" json:"restart_backoff,omitempty"`         // 崩溃后首次重启前的等待，默认 1s，连续失败时翻倍
+	MaxRestartBackoff string                 `yaml:"max_restart_backoff,omitempty" json:"max_restart_backoff,omitempty"` // 重启等待上限，默认 1m
+	Retry             RetryConfig            `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// validate 检查插件配置。
+func (p PluginConfig) validate() error {
+	if !filepath.IsAbs(p.Command) {
+		return errors.New("command 必须为插件可执行文件的绝对路径")
+	}
+	if err := validateDurations(p.Timeout, p.RestartBackoff, p.MaxRestartBackoff); err != nil {
+		return err
+	}
+	return p.Retry.validate()
+}
+
+// builtinSources 是内置数据源名称，插件不能与之重名。
+var builtinSources = []string{
+	"mysql", "iotdb", "redis", "restapi", "modbus", "mqtt", "snmp", "prometheus",
+	"mongodb", "elasticsearch", "file", "command", "synthetic", "sql",
+}
+
+// OutputParseConfig 描述如何从文本中提取数值，format 为 text 时取整段文本或 regex 的匹配。
+type OutputParseConfig struct {
+	Format    string            `yaml:"format,omitempty" json:"format,omitempty"`       // text（默认）/json/csv
+	Regex     string            `yaml:"regex,omitempty" json:"regex,omitempty"`         // text：取第一个捕获组，无捕获组时取整个匹配
+	Delimiter string            `yaml:"delimiter,omitempty" json:"delimiter,omitempty"` // csv：分隔符，默认逗号
+	NoHeader  bool              `yaml:"no_header,omitempty" json:"no_header,omitempty"` // csv：首行不是表头，此时列只能用从 0 开始的序号指定
+	Match     map[string]string `yaml:"match,omitempty" json:"match,omitempty"`         // csv：只保留这些列取值完全一致的行
+	Row       string            `yaml:"row,omitempty" json:"row,omitempty"`             // csv：过滤后取 first 或 last（默认）行
+}
+
+// validate 检查解析配置。
+func (p OutputParseConfig) validate() error {
+	switch p.Format {
+	case "", "text":
+		if p.Regex != "" {
+			re, err := regexp.Compile(p.Regex)
+			if err != nil {
+				return fmt.Errorf("regex 无效: %w", err)
+			}
+			if re.NumSubexp() > 1 {
+				return errors.New("regex 最多只能包含一个捕获组")
+			}
+		}
+	case "json", "csv":
+		if p.Regex != "" {
+			return fmt.Errorf("regex 仅适用于 text 格式，当前格式为 %s", p.Format)
+		}
+	default:
+		return fmt.Errorf("不支持的解析格式: %s，可选 text、json 或 csv", p.Format)
+	}
+	if p.Format != "csv" && (p.Delimiter != "" || p.NoHeader || len(p.Match) > 0 || p.Row != "") {
+		return errors.New("delimiter、no_header、match 与 row 仅适用于 csv 格式")
+	}
+	if utf8.RuneCountInString(p.Delimiter) > 1 {
+		return errors.New("delimiter 只能是单个字符")
+	}
+	switch p.Row {
+	case "", "first", "last":
+	default:
+		return fmt.Errorf("row 只能为 first 或 last，当前为 %s", p.Row)
+	}
+	return nil
+}
+
+// OutputParse 返回指标生效的解析配置，未配置时按 text 格式解析。
+func (m MetricSpec) OutputParse() OutputParseConfig {
+	if m.Parse == nil {
+		return OutputParseConfig{}
+	}
+	return *m.Parse
+}
+
+// validateLocal 检查 file 与 command 数据源专用的指标字段，max_age 同时适用于 mqtt。
+func (m MetricSpec) validateLocal(file FileSourceConfig, command CommandSourceConfig) error {
+	if m.Source != "file" && m.Source != "command" {
+		if m.Parse != nil || len(m.Args) > 0 || m.Timeout != "" {
+			return errors.New("parse、args 与 timeout 仅适用于 file 与 command 数据源")
+		}
+		if m.Source == "mqtt" {
+			if m.MaxAge != "" && m.Mode == MetricModeOnMessage {
+				return errors.New("max_age 不适用于 mode 为 on_message 的指标")
+			}
+			return validateDurations(m.MaxAge)
+		}
+		if m.MaxAge != "" {
+			return errors.New("max_age 仅适用于 file 与 mqtt 数据源")
+		}
+		return nil
+	}
+	if m.Parse != nil {
+		if err := m.Parse.validate(); err != nil {
+			return err
+		}
+	}
+	if m.Source == "file" {
+		if len(m.Args) > 0 || m.Timeout != "" {
+			return errors.New("args 与 timeout 仅适用于 command 数据源")
+		}
+		if !file.Allowed(m.Query) {
+			return fmt.Errorf("文件 %s 不在 file.allowed_paths 或 file.allowed_dirs 白名单中", m.Query)
+		}
+		return validateDurations(m.MaxAge)
+	}
+	if m.MaxAge != "" {
+		return errors.New("max_age 仅适用于 file 与 mqtt 数据源")
+	}
+	if !command.Allowed(m.Query) {
+		return fmt.Errorf("可执行文件 %s 不在 command.allowed_commands 白名单中", m.Query)
+	}
+	return validateDurations(m.Timeout)
+}
+
+// validateTopicFilter 检查 MQTT 主题过滤器中通配符的位置是否合法。
+func validateTopicFilter(filter string) error {
+	if filter == "" {
+		return errors.New("主题过滤器不能为空")
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return fmt.Errorf("主题过滤器 %s 中的 # 必须单独位于最后一层", filter)
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return fmt.Errorf("主题过滤器 %s 中的 + 必须独占一层", filter)
+		}
+	}
+	return nil
+}
+
+// SSHTunnelConfig 定义经跳板机访问数据源的 SSH 隧道。
+type SSHTunnelConfig struct {
+	Host                  string `yaml:"host" json:"host"`
+	Port                  int    `yaml:"port,omitempty" json:"port,omitempty"` // 默认 22
+	User                  string `yaml:"user" json:"user"`
+	Password              string `yaml:"password,omitempty" json:"password,omitempty"`
+	PrivateKeyFile        string `yaml:"private_key_file,omitempty" json:"private_key_file,omitempty"`
+	Passphrase            string `yaml:"passphrase,omitempty" json:"passphrase,omitempty"` // 私钥口令
+	KnownHostsFile        string `yaml:"known_hosts_file,omitempty" json:"known_hosts_file,omitempty"`
+	InsecureIgnoreHostKey bool   `yaml:"insecure_ignore_host_key,omitempty" json:"insecure_ignore_host_key,omitempty"` // 跳过主机指纹校验，仅用于测试
+	Timeout               string `yaml:"timeout,omitempty" json:"timeout,omitempty"`                                   // 建立 SSH 连接超时，默认 10s
+}
+
+// Addr 返回跳板机地址。
+func (t SSHTunnelConfig) Addr() string {
+	port := t.Port
+	if port == 0 {
+		port = 22
+	}
+	return fmt.Sprintf("%s:%d", t.Host, port)
+}
+
+// validate 检查 SSH 隧道配置完整性。
+func (t *SSHTunnelConfig) validate() error {
+	if t == nil {
+		return nil
+	}
+	if t.Host == "" || t.User == "" {
+		return errors.New("ssh_tunnel 缺少 host 或 user")
+	}
+	if t.Password == "" && t.PrivateKeyFile == "" {
+		return errors.New("ssh_tunnel 需要配置 password 或 private_key_file")
+	}
+	if t.KnownHostsFile == "" && !t.InsecureIgnoreHostKey {
+		return errors.New("ssh_tunnel 需要配置 known_hosts_file，或显式开启 insecure_ignore_host_key")
+	}
+	if t.Timeout != "" {
+		if _, err := time.ParseDuration(t.Timeout); err != nil {
+			return fmt.Errorf("ssh_tunnel 超时配置无效: %w", err)
+		}
+	}
+	return nil
 }
 
 // RestAPIConfig 填写 RESTful API 连接信息。
 type RestAPIConfig struct {
-	BaseURL string            `yaml:"base_url" json:"base_url"`
-	Timeout string            `yaml:"timeout" json:"timeout,omitempty"`
-	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
-	TLS     RestAPITLSConfig  `yaml:"tls" json:"tls,omitempty"`
+	BaseURL string             `yaml:"base_url" json:"base_url"`
+	Timeout string             `yaml:"timeout" json:"timeout,omitempty"`
+	Headers map[string]string  `yaml:"headers" json:"headers,omitempty"`
+	TLS     RestAPITLSConfig   `yaml:"tls" json:"tls,omitempty"`
 	Retry   RestAPIRetryConfig `yaml:"retry" json:"retry,omitempty"`
+	Proxy   ProxyConfig        `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+}
+
+// TLSConfig 定义客户端 TLS 配置，RestAPI、MySQL、Redis 连接共用。
+type TLSConfig struct {
+	// Enabled 仅对 MySQL/Redis 生效，RestAPI 由 base_url 的 scheme 决定是否使用 TLS
+	Enabled    bool   `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	SkipVerify bool   `yaml:"skip_verify" json:"skip_verify,omitempty"`
+	CAFile     string `yaml:"ca_file,omitempty" json:"ca_file,omitempty"`         // 自定义 CA 证书（PEM）
+	CertFile   string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`     // mTLS 客户端证书（PEM）
+	KeyFile    string `yaml:"key_file,omitempty" json:"key_file,omitempty"`       // mTLS 客户端私钥（PEM）
+	ServerName string `yaml:"server_name,omitempty" json:"server_name,omitempty"` // 覆盖 SNI 与证书校验使用的主机名
+}
+
+// RestAPITLSConfig 定义 RestAPI TLS 配置。
+type RestAPITLSConfig = TLSConfig
+
+// ProxyConfig 定义 RestAPI 出站代理。
+type ProxyConfig struct {
+	URL      string `yaml:"url" json:"url,omitempty"` // 支持 http://、https://、socks5://
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+}
+
+// RetryConfig 定义查询失败时的重试策略，可配置在连接或指标上，指标上的配置优先。
+type RetryConfig struct {
+	MaxAttempts int      `yaml:"max_attempts" json:"max_attempts,omitempty"`         // 总尝试次数，0 或 1 表示不重试
+	Backoff     string   `yaml:"backoff" json:"backoff,omitempty"`                   // 首次重试前等待时间，之后按指数翻倍，默认 1s
+	MaxBackoff  string   `yaml:"max_backoff,omitempty" json:"max_backoff,omitempty"` // 等待时间上限，默认 30s
+	Jitter      float64  `yaml:"jitter,omitempty" json:"jitter,omitempty"`           // 随机抖动比例（0~1）
+	RetryOn     []string `yaml:"retry_on,omitempty" json:"retry_on,omitempty"`       // 可重试的错误类别：connection/timeout/server/all，默认前三者
+}
+
+// RestAPIRetryConfig 定义 RestAPI 重试策略。
+type RestAPIRetryConfig = RetryConfig
+
+// 可重试的错误类别。
+const (
+	RetryOnConnection = "connection" // 连接断开、拒绝、重置等
+	RetryOnTimeout    = "timeout"    // 网络或查询超时
+	RetryOnServer     = "server"     // HTTP 5xx、429 等服务端临时错误
+	RetryOnAll        = "all"        // 任意错误
+)
+
+// Attempts 返回总尝试次数，至少为 1。
+func (r RetryConfig) Attempts() int {
+	if r.MaxAttempts < 1 {
+		return 1
+	}
+	return r.MaxAttempts
+}
+
+// BackoffRange 返回首次等待时间与等待上限。
+func (r RetryConfig) BackoffRange() (time.Duration, time.Duration) {
+	base := time.Second
+	if d, err := time.ParseDuration(r.Backoff); err == nil && r.Backoff != "" {
+		base = d
+	}
+	limit := 30 * time.Second
+	if d, err := time.ParseDuration(r.MaxBackoff); err == nil && r.MaxBackoff != "" {
+		limit = d
+	}
+	if limit < base {
+		limit = base
+	}
+	return base, limit
+}
+
+// RetryClasses 返回生效的可重试错误类别。
+func (r RetryConfig) RetryClasses() []string {
+	if len(r.RetryOn) == 0 {
+		return []string{RetryOnConnection, RetryOnTimeout, RetryOnServer}
+	}
+	return r.RetryOn
+}
+
+// validate 检查重试策略配置。
+func (r RetryConfig) validate() error {
+	if r.MaxAttempts < 0 {
+		return errors.New("max_attempts 不能为负数")
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return errors.New("jitter 必须介于 0 与 1 之间")
+	}
+	if err := validateDurations(r.Backoff, r.MaxBackoff); err != nil {
+		return err
+	}
+	for _, class := range r.RetryOn {
+		switch class {
+		case RetryOnConnection, RetryOnTimeout, RetryOnServer, RetryOnAll:
+		default:
+			return fmt.Errorf("不支持的 retry_on 类别: %s", class)
+		}
+	}
+	return nil
+}
+
+// MetricSpec 定义单个指标查询的元数据。
+type MetricSpec struct {
+	Name        string              `yaml:"name" json:"name"`
+	Help        string              `yaml:"help" json:"help"`
+	Type        string              `yaml:"type" json:"type"` // gauge/counter/histogram/summary，默认为 gauge
+	Source      string              `yaml:"source" json:"source"`
+	Query       string              `yaml:"query" json:"query"`
+	Labels      map[string]string   `yaml:"labels" json:"labels,omitempty"`
+	ResultField string              `yaml:"result_field" json:"result_field,omitempty"`
+	Connection  string              `yaml:"connection" json:"connection,omitempty"`             // 连接名，含 *、?、[ 时为匹配多个连接的 glob
+	Connections []string            `yaml:"connections,omitempty" json:"connections,omitempty"` // 连接名或 glob 列表，指标对每个连接分别查询
+	Buckets     []float64           `yaml:"buckets,omitempty" json:"buckets,omitempty"`         // Histogram 分桶
+	Objectives  map[float64]float64 `yaml:"objectives,omitempty" json:"-"`                      // Summary 分位数目标（JSON 序列化通过 ObjectivesJSON）
+	Enabled     *bool               `yaml:"enabled,omitempty" json:"enabled,omitempty"`         // 是否启用采集，默认为 true，nil 表示启用
+	Retry       *RetryConfig        `yaml:"retry,omitempty" json:"retry,omitempty"`             // 覆盖连接上的重试策略
+	Mode        string              `yaml:"mode,omitempty" json:"mode,omitempty"`               // 更新方式：interval（默认，按采集周期）、on_message（仅 mqtt，消息到达即更新）或 on_scrape（仅 gauge，被抓取时查询）
+	Groups      []string            `yaml:"groups,omitempty" json:"groups,omitempty"`           // 所属指标分组，分组内的指标另外通过 /metrics/{group} 暴露
+
+	// SeriesSelector 按标签值从多序列结果中挑选序列；SeriesLabels 非空时指标为带这些标签的 gauge 族，
+	// 每个序列对应一个子指标，否则结果必须恰好剩下一个序列。目前仅 prometheus 数据源返回多序列。
+	SeriesSelector map[string]string `yaml:"series_selector,omitempty" json:"series_selector,omitempty"`
+	SeriesLabels   []string          `yaml:"series_labels,omitempty" json:"series_labels,omitempty"`
+
+	// Parse 描述 file 与 command 数据源如何从文件内容或命令输出中提取数值，JSON 路径与 CSV 列通过 result_field 指定。
+	Parse   *OutputParseConfig `yaml:"parse,omitempty" json:"parse,omitempty"`
+	MaxAge  string             `yaml:"max_age,omitempty" json:"max_age,omitempty"` // file：文件修改时间早于该时长视为过期，查询失败；mqtt：最新消息早于该时长视为过期
+	Args    []string           `yaml:"args,omitempty" json:"args,omitempty"`       // command：命令参数，直接传给可执行文件，不经过 shell
+	Timeout string             `yaml:"timeout,omitempty" json:"timeout,omitempty"` // command：执行超时，默认取 command.timeout
+
+	// Watermark 非空时指标为增量查询：query 以上次的水位为绑定参数，返回本次的增量与新的水位，增量累加到 counter。
+	Watermark *WatermarkConfig `yaml:"watermark,omitempty" json:"watermark,omitempty"`
+}
+
+// WatermarkConfig 描述增量查询的水位（已统计到的最大 id 或时间戳）。
+type WatermarkConfig struct {
+	Initial string `yaml:"initial,omitempty" json:"initial,omitempty"` // 尚无持久化水位时使用的初始水位，默认 0
+}
+
+// InitialValue 返回初始水位，默认为 0。
+func (w WatermarkConfig) InitialValue() string {
+	if w.Initial == "" {
+		return "0"
+	}
+	return w.Initial
 }
 
-// RestAPITLSConfig 定义 RestAPI TLS 配置。
-type RestAPITLSConfig struct {
-	SkipVerify bool `yaml:"skip_verify" json:"skip_verify,omitempty"`
+// validateWatermark 检查增量查询配置。
+func (m MetricSpec) validateWatermark(metricType string) error {
+	if m.Watermark == nil {
+		return nil
+	}
+	if m.Source != "mysql" && m.Source != "sql" {
+		return fmt.Errorf("watermark 仅支持 mysql 与 sql 数据源，实际为 %s", m.Source)
+	}
+	if metricType != "counter" {
+		return errors.New("watermark 仅支持 counter 类型")
+	}
+	if m.FanOut() {
+		return errors.New("watermark 不能与 connections 或 connection glob 同时使用")
+	}
+	if m.Mode != "" && m.Mode != MetricModeInterval {
+		return fmt.Errorf("watermark 不支持 mode %s", m.Mode)
+	}
+	return nil
 }
 
-// RestAPIRetryConfig 定义 RestAPI 重试策略。
-type RestAPIRetryConfig struct {
-	MaxAttempts int    `yaml:"max_attempts" json:"max_attempts,omitempty"`
-	Backoff     string `yaml:"backoff" json:"backoff,omitempty"`
+// WatermarkStatePath 返回增量查询水位的持久化文件路径。
+func (c *Config) WatermarkStatePath() string {
+	if c.WatermarkStateFile == "" {
+		return "configs/watermarks.json"
+	}
+	return c.WatermarkStateFile
 }
 
-// MetricSpec 定义单个指标查询的元数据。
-type MetricSpec struct {
-	Name        string              `yaml:"name" json:"name"`
-	Help        string              `yaml:"help" json:"help"`
-	Type        string              `yaml:"type" json:"type"` // gauge/counter/histogram/summary，默认为 gauge
-	Source      string              `yaml:"source" json:"source"`
-	Query       string              `yaml:"query" json:"query"`
-	Labels      map[string]string   `yaml:"labels" json:"labels,omitempty"`
-	ResultField string              `yaml:"result_field" json:"result_field,omitempty"`
-	Connection  string              `yaml:"connection" json:"connection,omitempty"`
-	Buckets     []float64           `yaml:"buckets,omitempty" json:"buckets,omitempty"` // Histogram 分桶
-	Objectives  map[float64]float64 `yaml:"objectives,omitempty" json:"-"`              // Summary 分位数目标（JSON 序列化通过 ObjectivesJSON）
-	Enabled     *bool               `yaml:"enabled,omitempty" json:"enabled,omitempty"`  // 是否启用采集，默认为 true，nil 表示启用
+// 指标更新方式。
+const (
+	MetricModeInterval  = "interval"
+	MetricModeOnMessage = "on_message"
+	MetricModeOnScrape  = "on_scrape"
+)
+
+// MetricMode 返回指标实际的更新方式：指标自身配置的 mode 优先，未配置时 gauge 指标继承所属分组的 mode。
+func (c *Config) MetricMode(m MetricSpec) string {
+	if m.Mode != "" {
+		return m.Mode
+	}
+	if m.Type == "" || m.Type == "gauge" {
+		for _, name := range m.Groups {
+			if c.MetricGroups[name].Mode == MetricModeOnScrape {
+				return MetricModeOnScrape
+			}
+		}
+	}
+	return MetricModeInterval
 }
 
 // ObjectivesJSON 用于 JSON 序列化的 objectives（使用字符串 key）。
@@ -226,7 +1488,7 @@ func Load(path string) (*Config, error) {
 	}
 
 	var cfg Config
-	expanded := os.ExpandEnv(string(raw))
+	expanded := expandEnv(string(raw))
 	if err := yaml.Unmarshal([]byte(expanded), &cfg); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
@@ -263,6 +1525,31 @@ func (s ScheduleConfig) IntervalDuration() (time.Duration, error) {
 	return d, nil
 }
 
+// ScrapeMinIntervalDuration 返回 on_scrape 指标的最短查询间隔。
+func (s ScheduleConfig) ScrapeMinIntervalDuration() (time.Duration, error) {
+	return ParseDuration(s.ScrapeMinInterval, 10*time.Second)
+}
+
+// ScrapeTimeoutDuration 返回 on_scrape 指标的默认查询超时。
+func (s ScheduleConfig) ScrapeTimeoutDuration() (time.Duration, error) {
+	return ParseDuration(s.ScrapeTimeout, 10*time.Second)
+}
+
+// ParseDuration 解析配置中的时长，为空时返回 fallback，格式错误或不大于 0 时返回错误。
+func ParseDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("解析时长 %q 失败: %w", value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("时长 %q 必须大于 0", value)
+	}
+	return d, nil
+}
+
 // ListenAddr 拼接监听地址。
 func (p PrometheusConfig) ListenAddr() string {
 	host := p.ListenAddress
@@ -302,6 +1589,29 @@ func (m MySQLConfig) DSN() (string, error) {
 	return dsn, nil
 }
 
+// Validate 检查 MySQL 连接的 TLS、SSH 隧道、查询保护、连接池与重试配置，也用于校验连接发现得到的连接。
+func (m MySQLConfig) Validate() error {
+	if err := m.TLS.validateEnabled(m.TLS.Enabled); err != nil {
+		return fmt.Errorf("TLS 配置无效: %w", err)
+	}
+	if err := m.SSHTunnel.validate(); err != nil {
+		return fmt.Errorf("SSH 隧道配置无效: %w", err)
+	}
+	if m.QueryGuard.MaxEstimatedRows < 0 {
+		return errors.New("max_estimated_rows 不能为负数")
+	}
+	if m.MaxOpenConns < 0 || m.MaxIdleConns < 0 {
+		return errors.New("连接池大小不能为负数")
+	}
+	if err := validateDurations(m.ConnMaxLifetime, m.ConnMaxIdleTime); err != nil {
+		return fmt.Errorf("连接池配置无效: %w", err)
+	}
+	if err := m.Retry.validate(); err != nil {
+		return fmt.Errorf("重试策略无效: %w", err)
+	}
+	return nil
+}
+
 // Validate 检查配置完整性。
 func (c *Config) Validate() error {
 	if len(c.Metrics) == 0 {
@@ -324,6 +1634,121 @@ func (c *Config) Validate() error {
 		if mode != "standalone" {
 			return fmt.Errorf("Redis 连接 %s 使用的模式暂未支持: %s", name, mode)
 		}
+		if err := rc.TLS.validateEnabled(rc.EnableTLS || rc.TLS.Enabled); err != nil {
+			return fmt.Errorf("Redis 连接 %s 的 TLS 配置无效: %w", name, err)
+		}
+		if err := rc.SSHTunnel.validate(); err != nil {
+			return fmt.Errorf("Redis 连接 %s 的 SSH 隧道配置无效: %w", name, err)
+		}
+		if rc.PoolSize < 0 || rc.MinIdleConns < 0 {
+			return fmt.Errorf("Redis 连接 %s 的连接池大小不能为负数", name)
+		}
+		if err := validateDurations(rc.ConnMaxIdleTime); err != nil {
+			return fmt.Errorf("Redis 连接 %s 的连接池配置无效: %w", name, err)
+		}
+		if err := rc.Retry.validate(); err != nil {
+			return fmt.Errorf("Redis 连接 %s 的重试策略无效: %w", name, err)
+		}
+	}
+	for name, mc := range c.MySQLConnections {
+		if err := mc.Validate(); err != nil {
+			return fmt.Errorf("MySQL 连接 %s 配置无效: %w", name, err)
+		}
+	}
+	if err := c.IoTDB.SSHTunnel.validate(); err != nil {
+		return fmt.Errorf("IoTDB 的 SSH 隧道配置无效: %w", err)
+	}
+	if err := c.IoTDB.Retry.validate(); err != nil {
+		return fmt.Errorf("IoTDB 的重试策略无效: %w", err)
+	}
+	for name, rc := range c.RestAPIConnections {
+		if err := rc.TLS.validate(); err != nil {
+			return fmt.Errorf("RestAPI 连接 %s 的 TLS 配置无效: %w", name, err)
+		}
+		if err := rc.Proxy.validate(); err != nil {
+			return fmt.Errorf("RestAPI 连接 %s 的代理配置无效: %w", name, err)
+		}
+		if err := rc.Retry.validate(); err != nil {
+			return fmt.Errorf("RestAPI 连接 %s 的重试策略无效: %w", name, err)
+		}
+	}
+	for name, mc := range c.ModbusConnections {
+		if err := mc.validate(); err != nil {
+			return fmt.Errorf("Modbus 连接 %s 配置无效: %w", name, err)
+		}
+	}
+	for name, mc := range c.MQTTConnections {
+		if err := mc.validate(); err != nil {
+			return fmt.Errorf("MQTT 连接 %s 配置无效: %w", name, err)
+		}
+	}
+	for name, sc := range c.SNMPConnections {
+		if err := sc.validate(); err != nil {
+			return fmt.Errorf("SNMP 连接 %s 配置无效: %w", name, err)
+		}
+	}
+	for name, conn := range c.PrometheusConnections {
+		if err := conn.validate(); err != nil {
+			return fmt.Errorf("Prometheus 连接 %s 配置无效: %w", name, err)
+		}
+	}
+	for name, conn := range c.MongoDBConnections {
+		if err := conn.validate(); err != nil {
+			return fmt.Errorf("MongoDB 连接 %s 配置无效: %w", name, err)
+		}
+	}
+	for name, conn := range c.ElasticsearchConnections {
+		if err := conn.validate(); err != nil {
+			return fmt.Errorf("Elasticsearch 连接 %s 配置无效: %w", name, err)
+		}
+	}
+	for name, plugin := range c.Plugins {
+		if containsString(builtinSources, name) {
+			return fmt.Errorf("插件名称 %s 与内置数据源重名", name)
+		}
+		if err := plugin.validate(); err != nil {
+			return fmt.Errorf("插件 %s 配置无效: %w", name, err)
+		}
+	}
+	if err := c.RemoteWrite.validate(); err != nil {
+		return fmt.Errorf("remote_write 配置无效: %w", err)
+	}
+	if err := c.OTLP.validate(); err != nil {
+		return fmt.Errorf("otlp 配置无效: %w", err)
+	}
+	if err := c.Pushgateway.validate(); err != nil {
+		return fmt.Errorf("pushgateway 配置无效: %w", err)
+	}
+	if err := c.File.validate(); err != nil {
+		return fmt.Errorf("file 白名单配置无效: %w", err)
+	}
+	if err := c.Command.validate(); err != nil {
+		return fmt.Errorf("command 沙箱配置无效: %w", err)
+	}
+	for name, conn := range c.SQLConnections {
+		if err := conn.Validate(); err != nil {
+			return fmt.Errorf("SQL 连接 %s 配置无效: %w", name, err)
+		}
+	}
+	if _, err := c.Schedule.ScrapeMinIntervalDuration(); err != nil {
+		return fmt.Errorf("schedule.scrape_min_interval 无效: %w", err)
+	}
+	if _, err := c.Schedule.ScrapeTimeoutDuration(); err != nil {
+		return fmt.Errorf("schedule.scrape_timeout 无效: %w", err)
+	}
+	if err := c.Probe.validate(); err != nil {
+		return fmt.Errorf("probe 配置无效: %w", err)
+	}
+	if err := c.ConnectionDiscovery.validate(); err != nil {
+		return fmt.Errorf("connection_discovery 配置无效: %w", err)
+	}
+	for name, group := range c.MetricGroups {
+		if !metricGroupNameRegex.MatchString(name) {
+			return fmt.Errorf("指标分组名 %q 非法，只能包含字母、数字、下划线与连字符", name)
+		}
+		if err := group.validate(); err != nil {
+			return fmt.Errorf("指标分组 %s 配置无效: %w", name, err)
+		}
 	}
 	metricNames := make(map[string]bool)
 	for _, m := range c.Metrics {
@@ -334,9 +1759,27 @@ func (c *Config) Validate() error {
 		if m.Name == "" {
 			return errors.New("指标名称不能为空")
 		}
-		if m.Source != "mysql" && m.Source != "iotdb" && m.Source != "redis" && m.Source != "restapi" {
+		if _, isPlugin := c.Plugins[m.Source]; !isPlugin && !containsString(builtinSources, m.Source) {
 			return fmt.Errorf("指标 %s 的 source 非法: %s", m.Name, m.Source)
 		}
+		for _, group := range m.Groups {
+			if _, ok := c.MetricGroups[group]; !ok {
+				return fmt.Errorf("指标 %s 引用的分组 %s 未在 metric_groups 中定义", m.Name, group)
+			}
+		}
+		switch m.Mode {
+		case "", MetricModeInterval:
+		case MetricModeOnMessage:
+			if m.Source != "mqtt" {
+				return fmt.Errorf("指标 %s 的 mode %s 仅适用于 mqtt 数据源", m.Name, m.Mode)
+			}
+		case MetricModeOnScrape:
+			if m.Type != "" && m.Type != "gauge" {
+				return fmt.Errorf("指标 %s 的 mode %s 仅适用于 gauge 类型", m.Name, m.Mode)
+			}
+		default:
+			return fmt.Errorf("指标 %s 的 mode 非法: %s", m.Name, m.Mode)
+		}
 		// RestAPI 类型允许查询为空（直接请求 base_url）
 		if m.Query == "" && m.Source != "restapi" {
 			return fmt.Errorf("指标 %s 缺少查询语句", m.Name)
@@ -354,43 +1797,376 @@ func (c *Config) Validate() error {
 		if metricType == "summary" && len(m.Objectives) == 0 {
 			return fmt.Errorf("指标 %s 类型为 summary，但未配置 objectives", m.Name)
 		}
+		if m.Retry != nil {
+			if err := m.Retry.validate(); err != nil {
+				return fmt.Errorf("指标 %s 的重试策略无效: %w", m.Name, err)
+			}
+		}
 		// 验证 label 名称格式（必须以字母或下划线开头，只能包含字母、数字、下划线）
 		for labelName := range m.Labels {
 			if !isValidLabelName(labelName) {
 				return fmt.Errorf("指标 %s 的 label 名称 %q 无效，必须以字母或下划线开头，只能包含字母、数字和下划线", m.Name, labelName)
 			}
 		}
-		if m.Source == "mysql" {
-			conn := m.Connection
-			if conn == "" {
-				conn = "default"
-			}
-			if _, ok := c.MySQLConnections[conn]; !ok {
-				return fmt.Errorf("指标 %s 引用的 MySQL 连接 %s 未配置", m.Name, conn)
-			}
+		if err := m.validateSeries(metricType); err != nil {
+			return fmt.Errorf("指标 %s 配置无效: %w", m.Name, err)
+		}
+		if err := m.validateWatermark(metricType); err != nil {
+			return fmt.Errorf("指标 %s 配置无效: %w", m.Name, err)
 		}
-		if m.Source == "redis" {
-			conn := m.Connection
-			if conn == "" {
-				conn = "default"
+		if err := m.validateLocal(c.File, c.Command); err != nil {
+			return fmt.Errorf("指标 %s 配置无效: %w", m.Name, err)
+		}
+		if !m.FanOut() {
+			if err := c.validateMetricSource(m); err != nil {
+				return err
 			}
-			if _, ok := c.RedisConnections[conn]; !ok {
-				return fmt.Errorf("指标 %s 引用的 Redis 连接 %s 未配置", m.Name, conn)
+		} else if err := c.validateFanOut(m, metricType); err != nil {
+			return fmt.Errorf("指标 %s 配置无效: %w", m.Name, err)
+		} else {
+			conns, _ := c.MetricConnections(m)
+			for _, name := range conns {
+				single := m
+				single.Connection, single.Connections = name, nil
+				if err := c.validateMetricSource(single); err != nil {
+					return err
+				}
 			}
 		}
-		if m.Source == "restapi" {
-			conn := m.Connection
-			if conn == "" {
-				conn = "default"
+	}
+	sinkNames := make(map[string]bool)
+	for _, sink := range c.Sinks {
+		if sink.Name == "" {
+			return errors.New("写回目标缺少 name")
+		}
+		if sinkNames[sink.Name] {
+			return fmt.Errorf("写回目标名称重复: %s", sink.Name)
+		}
+		sinkNames[sink.Name] = true
+		if err := sink.validate(c.IoTDB, metricNames); err != nil {
+			return fmt.Errorf("写回目标 %s 配置无效: %w", sink.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateMetricSource 检查指标引用的数据源连接与查询，fan-out 指标对每个匹配的连接分别检查。
+func (c *Config) validateMetricSource(m MetricSpec) error {
+	if m.Source == "mysql" {
+		conn := m.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		// 未在配置文件中声明的连接可能由连接发现提供
+		if _, ok := c.MySQLConnections[conn]; !ok && !c.ConnectionDiscovery.Discovers(m.Source) {
+			return fmt.Errorf("指标 %s 引用的 MySQL 连接 %s 未配置", m.Name, conn)
+		}
+		if err := sqlguard.CheckReadOnly(m.Query); err != nil {
+			return fmt.Errorf("指标 %s 的查询未通过只读校验: %w", m.Name, err)
+		}
+	}
+	if m.Source == "redis" {
+		conn := m.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		if _, ok := c.RedisConnections[conn]; !ok {
+			return fmt.Errorf("指标 %s 引用的 Redis 连接 %s 未配置", m.Name, conn)
+		}
+	}
+	if m.Source == "restapi" {
+		conn := m.Connection
+		if conn == "" {
+			conn = "default"
+		}
+		if _, ok := c.RestAPIConnections[conn]; !ok {
+			return fmt.Errorf("指标 %s 引用的 RestAPI 连接 %s 未配置", m.Name, conn)
+		}
+	}
+	if m.Source == "mqtt" {
+		if _, ok := c.MQTTConfigFor(m.Connection); !ok {
+			return fmt.Errorf("指标 %s 引用的 MQTT 连接 %s 未配置", m.Name, connectionName(m.Connection))
+		}
+		if err := validateTopicFilter(m.Query); err != nil {
+			return fmt.Errorf("指标 %s 的订阅主题无效: %w", m.Name, err)
+		}
+	}
+	if m.Source == "snmp" {
+		if _, ok := c.SNMPConfigFor(m.Connection); !ok {
+			return fmt.Errorf("指标 %s 引用的 SNMP 连接 %s 未配置", m.Name, connectionName(m.Connection))
+		}
+	}
+	if m.Source == "prometheus" {
+		if _, ok := c.PrometheusSourceConfigFor(m.Connection); !ok {
+			return fmt.Errorf("指标 %s 引用的 Prometheus 连接 %s 未配置", m.Name, connectionName(m.Connection))
+		}
+	}
+	if m.Source == "mongodb" {
+		if _, ok := c.MongoDBConfigFor(m.Connection); !ok {
+			return fmt.Errorf("指标 %s 引用的 MongoDB 连接 %s 未配置", m.Name, connectionName(m.Connection))
+		}
+	}
+	if m.Source == "elasticsearch" {
+		if _, ok := c.ElasticsearchConfigFor(m.Connection); !ok {
+			return fmt.Errorf("指标 %s 引用的 Elasticsearch 连接 %s 未配置", m.Name, connectionName(m.Connection))
+		}
+	}
+	if m.Source == "synthetic" {
+		if err := synthetic.Validate(m.Query); err != nil {
+			return fmt.Errorf("指标 %s 的合成数据查询无效: %w", m.Name, err)
+		}
+		if file := synthetic.ReplayFile(m.Query); file != "" && !c.File.Allowed(file) {
+			return fmt.Errorf("指标 %s 的回放文件 %s 不在 file.allowed_paths 或 file.allowed_dirs 白名单中", m.Name, file)
+		}
+	}
+	if m.Source == "sql" {
+		if _, ok := c.SQLConfigFor(m.Connection); !ok && !c.ConnectionDiscovery.Discovers(m.Source) {
+			return fmt.Errorf("指标 %s 引用的 SQL 连接 %s 未配置", m.Name, connectionName(m.Connection))
+		}
+		if err := sqlguard.CheckReadOnly(m.Query); err != nil {
+			return fmt.Errorf("指标 %s 的查询未通过只读校验: %w", m.Name, err)
+		}
+	}
+	if m.Source == "modbus" {
+		if _, ok := c.ModbusConfigFor(m.Connection); !ok {
+			return fmt.Errorf("指标 %s 引用的 Modbus 连接 %s 未配置", m.Name, connectionName(m.Connection))
+		}
+	}
+	return nil
+}
+
+// validateSeries 检查多序列相关配置。
+func (m MetricSpec) validateSeries(metricType string) error {
+	if len(m.SeriesSelector) == 0 && len(m.SeriesLabels) == 0 {
+		return nil
+	}
+	if m.Source != "prometheus" {
+		return fmt.Errorf("series_selector/series_labels 不支持 %s 数据源", m.Source)
+	}
+	if len(m.SeriesLabels) > 0 && metricType != "gauge" {
+		return errors.New("series_labels 仅支持 gauge 类型")
+	}
+	seen := make(map[string]bool, len(m.SeriesLabels))
+	for _, name := range m.SeriesLabels {
+		if !isValidLabelName(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("series_labels 中的 label 名称 %q 无效", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("series_labels 中的 label %s 重复", name)
+		}
+		if _, ok := m.Labels[name]; ok {
+			return fmt.Errorf("series_labels 中的 label %s 与 labels 冲突", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// fanOutSources 是支持 fan-out 的数据源，均按连接名索引客户端。
+var fanOutSources = []string{"mysql", "redis", "restapi", "modbus", "snmp", "prometheus", "mongodb", "elasticsearch", "sql"}
+
+// FanOutLabel 是 fan-out 指标区分连接的标签名。
+const FanOutLabel = "connection"
+
+// FanOut 返回指标是否对多个连接分别查询：配置了 connections，或 connection 为 glob。
+func (m MetricSpec) FanOut() bool {
+	return len(m.Connections) > 0 || isConnectionGlob(m.Connection)
+}
+
+func isConnectionGlob(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// validateFanOut 检查 fan-out 指标的配置，连接是否存在由 MetricConnections 检查。
+func (c *Config) validateFanOut(m MetricSpec, metricType string) error {
+	if !containsString(fanOutSources, m.Source) {
+		return fmt.Errorf("%s 数据源不支持 connections 或 connection glob", m.Source)
+	}
+	if len(m.Connections) > 0 && m.Connection != "" {
+		return errors.New("connection 与 connections 不能同时配置")
+	}
+	if metricType != "gauge" {
+		return errors.New("connections 与 connection glob 仅支持 gauge 类型")
+	}
+	if len(m.SeriesLabels) > 0 {
+		return errors.New("connections 与 connection glob 不能与 series_labels 同时使用")
+	}
+	if _, ok := m.Labels[FanOutLabel]; ok {
+		return fmt.Errorf("labels 中不能包含 %s，该 label 用于区分连接", FanOutLabel)
+	}
+	for _, pattern := range m.ConnectionPatterns() {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("连接 glob %q 无效: %w", pattern, err)
+		}
+	}
+	if m.Source == "mysql" || m.Source == "sql" {
+		if err := sqlguard.CheckReadOnly(m.Query); err != nil {
+			return fmt.Errorf("查询未通过只读校验: %w", err)
+		}
+	}
+	if _, err := c.MetricConnections(m); err != nil && !c.ConnectionDiscovery.Discovers(m.Source) {
+		// 由连接发现提供的连接在运行时才能确定，不要求配置文件中已有匹配的连接
+		return err
+	}
+	return nil
+}
+
+// MetricConnections 返回指标查询的连接名：普通指标为其 connection（默认为 default），
+// fan-out 指标为 connections 中的连接名与 glob 匹配到的已配置连接，按名称排序去重。
+func (c *Config) MetricConnections(m MetricSpec) ([]string, error) {
+	if !m.FanOut() {
+		return []string{connectionName(m.Connection)}, nil
+	}
+	available := c.ConnectionNames(m.Source)
+	for _, pattern := range m.ConnectionPatterns() {
+		if !isConnectionGlob(pattern) && !containsString(available, pattern) {
+			return nil, fmt.Errorf("连接 %s 未配置", connectionName(pattern))
+		}
+	}
+	names := MatchConnections(m.ConnectionPatterns(), available)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("connections %v 未匹配到任何 %s 连接", m.ConnectionPatterns(), m.Source)
+	}
+	return names, nil
+}
+
+// ConnectionPatterns 返回 fan-out 指标配置的连接名与 glob。
+func (m MetricSpec) ConnectionPatterns() []string {
+	if len(m.Connections) > 0 {
+		return m.Connections
+	}
+	return []string{m.Connection}
+}
+
+// MatchConnections 返回 patterns 中的连接名与 glob 在 available 中匹配到的连接，按名称排序去重。
+// 连接名原样保留，即使不在 available 中，查询时由对应连接报错。
+func MatchConnections(patterns, available []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, pattern := range patterns {
+		if !isConnectionGlob(pattern) {
+			if !seen[pattern] {
+				seen[pattern] = true
+				names = append(names, pattern)
 			}
-			if _, ok := c.RestAPIConnections[conn]; !ok {
-				return fmt.Errorf("指标 %s 引用的 RestAPI 连接 %s 未配置", m.Name, conn)
+			continue
+		}
+		for _, name := range available {
+			if ok, _ := path.Match(pattern, name); ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
 			}
 		}
 	}
+	sort.Strings(names)
+	return names
+}
+
+// ConnectionNames 返回指定数据源已配置的连接名，按名称排序。
+func (c *Config) ConnectionNames(source string) []string {
+	var names []string
+	switch source {
+	case "mysql":
+		names = mapKeys(c.MySQLConnections)
+	case "redis":
+		names = mapKeys(c.RedisConnections)
+	case "restapi":
+		names = mapKeys(c.RestAPIConnections)
+	case "modbus":
+		names = mapKeys(c.ModbusConnections)
+	case "mqtt":
+		names = mapKeys(c.MQTTConnections)
+	case "snmp":
+		names = mapKeys(c.SNMPConnections)
+	case "prometheus":
+		names = mapKeys(c.PrometheusConnections)
+	case "mongodb":
+		names = mapKeys(c.MongoDBConnections)
+	case "elasticsearch":
+		names = mapKeys(c.ElasticsearchConnections)
+	case "sql":
+		names = mapKeys(c.SQLConnections)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// validateDurations 检查可选的时长配置是否可解析。
+func validateDurations(values ...string) error {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(v); err != nil {
+			return fmt.Errorf("无法解析时长 %q: %w", v, err)
+		}
+	}
+	return nil
+}
+
+// validate 检查 TLS 证书配置是否成对出现。
+func (t TLSConfig) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("cert_file 与 key_file 必须同时配置")
+	}
 	return nil
 }
 
+// validateEnabled 用于由 enabled 决定是否使用 TLS 的连接（MySQL/Redis），
+// 配置了证书等参数却未启用 TLS 时返回错误，避免静默地以明文连接。
+func (t TLSConfig) validateEnabled(enabled bool) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	if !enabled && (t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" || t.SkipVerify || t.ServerName != "") {
+		return errors.New("配置了 ca_file、cert_file、key_file、skip_verify 或 server_name 时必须设置 enabled: true")
+	}
+	return nil
+}
+
+// validate 检查代理地址及协议。
+func (p ProxyConfig) validate() error {
+	if p.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("解析代理地址失败: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("不支持的代理协议: %s，支持 http、https、socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("代理地址缺少主机")
+	}
+	return nil
+}
+
+// ProxyURL 返回带认证信息的代理地址，未配置时返回 nil。
+func (p ProxyConfig) ProxyURL() (*url.URL, error) {
+	if p.URL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("解析代理地址失败: %w", err)
+	}
+	if p.Username != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+	return u, nil
+}
+
 // ApplyDefaults 应用默认值到配置。
 func (c *Config) ApplyDefaults() error {
 	if c.Schedule.Interval == "" {
@@ -486,6 +2262,137 @@ func (c *Config) RestAPIConfigFor(name string) (RestAPIConfig, bool) {
 	return conf, ok
 }
 
+// ModbusConfigFor 返回指定名称的 Modbus 配置，默认为 default。
+func (c *Config) ModbusConfigFor(name string) (ModbusConfig, bool) {
+	conf, ok := c.ModbusConnections[connectionName(name)]
+	return conf, ok
+}
+
+// MQTTConfigFor 返回指定名称的 MQTT 配置，默认为 default。
+func (c *Config) MQTTConfigFor(name string) (MQTTConfig, bool) {
+	conf, ok := c.MQTTConnections[connectionName(name)]
+	return conf, ok
+}
+
+// SNMPConfigFor 返回指定名称的 SNMP 配置，默认为 default。
+func (c *Config) SNMPConfigFor(name string) (SNMPConfig, bool) {
+	conf, ok := c.SNMPConnections[connectionName(name)]
+	return conf, ok
+}
+
+// PrometheusSourceConfigFor 返回指定名称的 Prometheus 配置，默认为 default。
+func (c *Config) PrometheusSourceConfigFor(name string) (PrometheusSourceConfig, bool) {
+	conf, ok := c.PrometheusConnections[connectionName(name)]
+	return conf, ok
+}
+
+// MongoDBConfigFor 返回指定名称的 MongoDB 配置，默认为 default。
+func (c *Config) MongoDBConfigFor(name string) (MongoDBConfig, bool) {
+	conf, ok := c.MongoDBConnections[connectionName(name)]
+	return conf, ok
+}
+
+// ElasticsearchConfigFor 返回指定名称的 Elasticsearch 配置，默认为 default。
+func (c *Config) ElasticsearchConfigFor(name string) (ElasticsearchConfig, bool) {
+	conf, ok := c.ElasticsearchConnections[connectionName(name)]
+	return conf, ok
+}
+
+// SQLConfigFor 返回指定名称的 SQL 配置，默认为 default。
+func (c *Config) SQLConfigFor(name string) (SQLConfig, bool) {
+	conf, ok := c.SQLConnections[connectionName(name)]
+	return conf, ok
+}
+
+// connectionName 返回连接名称，未指定时为 default。
+func connectionName(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
+// RetryConfigFor 返回指标生效的重试策略，指标上的配置优先于所属连接。
+func (c *Config) RetryConfigFor(spec MetricSpec) RetryConfig {
+	if spec.Retry != nil {
+		return *spec.Retry
+	}
+	switch spec.Source {
+	case "mysql":
+		conf, _ := c.MySQLConfigFor(spec.Connection)
+		return conf.Retry
+	case "redis":
+		conf, _ := c.RedisConfigFor(spec.Connection)
+		return conf.Retry
+	case "restapi":
+		conf, _ := c.RestAPIConfigFor(spec.Connection)
+		return conf.Retry
+	case "iotdb":
+		return c.IoTDB.Retry
+	case "modbus":
+		conf, _ := c.ModbusConfigFor(spec.Connection)
+		return conf.Retry
+	case "mqtt":
+		conf, _ := c.MQTTConfigFor(spec.Connection)
+		return conf.Retry
+	case "snmp":
+		conf, _ := c.SNMPConfigFor(spec.Connection)
+		return conf.Retry
+	case "prometheus":
+		conf, _ := c.PrometheusSourceConfigFor(spec.Connection)
+		return conf.Retry
+	case "mongodb":
+		conf, _ := c.MongoDBConfigFor(spec.Connection)
+		return conf.Retry
+	case "elasticsearch":
+		conf, _ := c.ElasticsearchConfigFor(spec.Connection)
+		return conf.Retry
+	case "sql":
+		conf, _ := c.SQLConfigFor(spec.Connection)
+		return conf.Retry
+	}
+	if plugin, ok := c.Plugins[spec.Source]; ok {
+		return plugin.Retry
+	}
+	return RetryConfig{}
+}
+
+// SelectMetrics 只保留指定名称的指标，单次运行模式使用；被点名的指标即使 enabled 为 false 也会采集。
+func (c *Config) SelectMetrics(names []string) error {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			wanted[name] = true
+		}
+	}
+	var selected []MetricSpec
+	for _, m := range c.Metrics {
+		if wanted[m.Name] {
+			m.Enabled = nil
+			selected = append(selected, m)
+			delete(wanted, m.Name)
+		}
+	}
+	for name := range wanted {
+		return fmt.Errorf("指标 %s 不存在", name)
+	}
+	if len(selected) == 0 {
+		return errors.New("至少需要选择一个指标")
+	}
+	c.Metrics = selected
+	return nil
+}
+
+// containsString 判断 items 中是否包含 target。
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
 // Clone 创建配置的深拷贝
 func (c *Config) Clone() *Config {
 	// 使用 JSON 序列化/反序列化来实现深拷贝
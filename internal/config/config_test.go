@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfigDefaults(t *testing.T) {
@@ -143,3 +145,748 @@ metrics:
 		t.Fatalf("期望从环境变量读到用户 env_user，实际 %s", mysqlCfg.User)
 	}
 }
+
+func TestEnvExpansionKeepsDollarLiterals(t *testing.T) {
+	t.Setenv("TEST_MONGO_PASS", "secret")
+	raw := `{"password": "${TEST_MONGO_PASS}", "pipeline": [{"$match": {}}, {"$group": {"total": {"$sum": "$power"}}}]}`
+	want := `{"password": "secret", "pipeline": [{"$match": {}}, {"$group": {"total": {"$sum": "$power"}}}]}`
+	if got := expandEnv(raw); got != want {
+		t.Fatalf("期望 %s，实际 %s", want, got)
+	}
+}
+
+func TestValidateRestAPIProxyAndTLS(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	raw := `
+restapi_connections:
+  default:
+    base_url: https://api.example.com
+    proxy:
+      url: socks5://127.0.0.1:1080
+    tls:
+      cert_file: /tmp/client.pem
+      key_file: /tmp/client-key.pem
+metrics:
+  - name: partner_total
+    help: 合作方接口
+    source: restapi
+    query: GET /count
+`
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatalf("写入配置失败: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("载入配置失败: %v", err)
+	}
+	proxyURL, err := cfg.RestAPIConnections["default"].Proxy.ProxyURL()
+	if err != nil || proxyURL == nil || proxyURL.Scheme != "socks5" {
+		t.Fatalf("应解析出 socks5 代理，实际 %v, err=%v", proxyURL, err)
+	}
+
+	rc := cfg.RestAPIConnections["default"]
+	rc.Proxy.URL = "ftp://127.0.0.1:21"
+	cfg.RestAPIConnections["default"] = rc
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("不支持的代理协议应当返回错误")
+	}
+
+	rc.Proxy.URL = ""
+	rc.TLS.KeyFile = ""
+	cfg.RestAPIConnections["default"] = rc
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("cert_file 未搭配 key_file 时应当返回错误")
+	}
+}
+
+func TestValidateTLSRequiresEnabled(t *testing.T) {
+	cfg := &Config{
+		MySQLConnections: map[string]MySQLConfig{
+			"default": {Host: "10.20.0.15", User: "readonly", Database: "plant_ems", TLS: TLSConfig{CAFile: "/etc/ssl/ca.pem"}},
+		},
+		Metrics: []MetricSpec{{Name: "devices_total", Help: "h", Source: "synthetic", Query: "constant?value=1"}},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("MySQL 配置了 ca_file 但未设置 enabled 时应当返回错误")
+	}
+	mc := cfg.MySQLConnections["default"]
+	mc.TLS.Enabled = true
+	cfg.MySQLConnections["default"] = mc
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("启用 TLS 后应当通过校验: %v", err)
+	}
+
+	cfg.RedisConnections = map[string]RedisConfig{"default": {Addr: "127.0.0.1:6379", TLS: TLSConfig{SkipVerify: true}}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("Redis 配置了 skip_verify 但未启用 TLS 时应当返回错误")
+	}
+	rc := cfg.RedisConnections["default"]
+	rc.EnableTLS = true
+	cfg.RedisConnections["default"] = rc
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("enable_tls 启用 TLS 后应当通过校验: %v", err)
+	}
+}
+
+func TestValidateSSHTunnel(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	raw := `
+mysql_connections:
+  default:
+    host: 10.20.0.15
+    user: readonly
+    database: plant_ems
+    ssh_tunnel:
+      host: bastion.example.com
+      user: ops
+      password: secret
+metrics:
+  - name: plant_devices_total
+    help: 厂站设备数
+    source: mysql
+    query: SELECT COUNT(1) FROM device
+`
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatalf("写入配置失败: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatalf("未配置 known_hosts_file 且未显式忽略主机指纹时应当返回错误")
+	}
+
+	// 补充 known_hosts_file 后应通过校验
+	fixed := `
+mysql_connections:
+  default:
+    host: 10.20.0.15
+    user: readonly
+    database: plant_ems
+    ssh_tunnel:
+      host: bastion.example.com
+      user: ops
+      password: secret
+      known_hosts_file: /etc/ssh/known_hosts
+metrics:
+  - name: plant_devices_total
+    help: 厂站设备数
+    source: mysql
+    query: SELECT COUNT(1) FROM device
+`
+	if err := os.WriteFile(path, []byte(fixed), 0o600); err != nil {
+		t.Fatalf("写入配置失败: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("载入配置失败: %v", err)
+	}
+	if got := cfg.MySQLConnections["default"].SSHTunnel.Addr(); got != "bastion.example.com:22" {
+		t.Fatalf("跳板机默认端口应为 22，实际 %s", got)
+	}
+}
+
+func TestValidateRejectsWriteQueries(t *testing.T) {
+	cfg := &Config{
+		MySQLConnections: map[string]MySQLConfig{
+			"default": {Host: "127.0.0.1", User: "readonly", Database: "nova_energy"},
+		},
+		Metrics: []MetricSpec{{
+			Name:   "cleanup_total",
+			Help:   "误操作",
+			Source: "mysql",
+			Query:  "DELETE FROM station_device",
+		}},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("MySQL 指标使用 DELETE 语句时应当返回错误")
+	}
+
+	cfg.Metrics[0].Query = "SELECT COUNT(1) FROM station_device"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("SELECT 语句应当通过校验: %v", err)
+	}
+}
+
+func TestValidateMySQLPoolSettings(t *testing.T) {
+	cfg := &Config{
+		MySQLConnections: map[string]MySQLConfig{
+			"default": {
+				Host:            "127.0.0.1",
+				User:            "readonly",
+				Database:        "nova_energy",
+				MaxOpenConns:    3,
+				ConnMaxLifetime: "30m",
+				ConnMaxIdleTime: "five minutes",
+			},
+		},
+		Metrics: []MetricSpec{{Name: "sample_total", Help: "样例指标", Source: "mysql", Query: "SELECT 1"}},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("conn_max_idle_time 无法解析时应当返回错误")
+	}
+
+	mc := cfg.MySQLConnections["default"]
+	mc.ConnMaxIdleTime = "5m"
+	cfg.MySQLConnections["default"] = mc
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的连接池配置应当通过校验: %v", err)
+	}
+}
+
+func TestRetryConfigForPrefersMetric(t *testing.T) {
+	cfg := &Config{
+		MySQLConnections: map[string]MySQLConfig{
+			"default": {
+				Host:     "127.0.0.1",
+				User:     "readonly",
+				Database: "nova_energy",
+				Retry:    RetryConfig{MaxAttempts: 3, Backoff: "2s", RetryOn: []string{RetryOnConnection}},
+			},
+		},
+		Metrics: []MetricSpec{
+			{Name: "conn_retry", Help: "使用连接重试", Source: "mysql", Query: "SELECT 1"},
+			{Name: "metric_retry", Help: "使用指标重试", Source: "mysql", Query: "SELECT 1", Retry: &RetryConfig{MaxAttempts: 5}},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("配置校验失败: %v", err)
+	}
+
+	if got := cfg.RetryConfigFor(cfg.Metrics[0]); got.Attempts() != 3 {
+		t.Fatalf("期望使用连接上的重试次数 3，实际 %d", got.Attempts())
+	}
+	got := cfg.RetryConfigFor(cfg.Metrics[1])
+	if got.Attempts() != 5 {
+		t.Fatalf("期望使用指标上的重试次数 5，实际 %d", got.Attempts())
+	}
+	if classes := got.RetryClasses(); len(classes) != 3 {
+		t.Fatalf("未配置 retry_on 时应使用默认类别，实际 %v", classes)
+	}
+
+	cfg.Metrics[1].Retry.RetryOn = []string{"deadlock"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("未知的 retry_on 类别应当返回错误")
+	}
+}
+
+func TestValidateMQTTMetrics(t *testing.T) {
+	cfg := &Config{
+		MQTTConnections: map[string]MQTTConfig{
+			"default": {Broker: "tcp://broker.internal:1883"},
+		},
+		Metrics: []MetricSpec{{
+			Name:        "pcs_power",
+			Help:        "PCS 功率",
+			Source:      "mqtt",
+			Query:       "site/+/pcs/#",
+			ResultField: "power",
+			Mode:        MetricModeOnMessage,
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 MQTT 指标应当通过校验: %v", err)
+	}
+
+	cfg.Metrics[0].Query = "site/#/pcs"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("# 不在最后一层时应当返回错误")
+	}
+
+	cfg.Metrics[0].Query = "site/+/pcs"
+	cfg.Metrics[0].MaxAge = "5m"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("on_message 模式配置 max_age 时应当返回错误")
+	}
+	cfg.Metrics[0].Mode = ""
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("按周期读取的 MQTT 指标应当可以配置 max_age: %v", err)
+	}
+
+	cfg.Metrics[0].MaxAge = ""
+	cfg.Metrics[0].Mode = MetricModeOnMessage
+	cfg.Metrics[0].Source = "redis"
+	cfg.RedisConnections = map[string]RedisConfig{"default": {Addr: "127.0.0.1:6379"}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("on_message 模式用于非 mqtt 数据源时应当返回错误")
+	}
+}
+
+func TestValidateSeriesLabels(t *testing.T) {
+	cfg := &Config{
+		PrometheusConnections: map[string]PrometheusSourceConfig{
+			"remote": {URL: "http://prometheus.remote:9090", BearerToken: "token"},
+		},
+		Metrics: []MetricSpec{{
+			Name:         "remote_up",
+			Help:         "远端集群在线实例数",
+			Source:       "prometheus",
+			Connection:   "remote",
+			Query:        "sum by (cluster) (up)",
+			Labels:       map[string]string{"origin": "remote"},
+			SeriesLabels: []string{"cluster"},
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的标签族指标应当通过校验: %v", err)
+	}
+
+	cfg.Metrics[0].SeriesLabels = []string{"origin"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("series_labels 与 labels 冲突时应当返回错误")
+	}
+
+	cfg.Metrics[0].SeriesLabels = []string{"cluster"}
+	cfg.Metrics[0].Type = "counter"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("非 gauge 类型配置 series_labels 时应当返回错误")
+	}
+
+	cfg.Metrics[0].Type = ""
+	cfg.Metrics[0].Source = "redis"
+	cfg.RedisConnections = map[string]RedisConfig{"remote": {Addr: "127.0.0.1:6379"}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("不支持多序列的数据源配置 series_labels 时应当返回错误")
+	}
+}
+
+func TestValidateCommandAllowlist(t *testing.T) {
+	cfg := &Config{
+		Command: CommandSourceConfig{AllowedCommands: []string{"/opt/vendor/bin/bmsctl"}},
+		Metrics: []MetricSpec{{
+			Name:   "bms_soc_percent",
+			Help:   "电池 SOC",
+			Source: "command",
+			Query:  "/opt/vendor/bin/bmsctl",
+			Args:   []string{"status", "--json"},
+			Parse:  &OutputParseConfig{Format: "json"},
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("白名单内的命令应当通过校验: %v", err)
+	}
+
+	cfg.Metrics[0].Query = "/bin/sh"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("白名单外的命令应当返回错误")
+	}
+
+	cfg.Metrics[0].Query = "/opt/vendor/bin/bmsctl"
+	cfg.Metrics[0].Parse = &OutputParseConfig{Format: "json", Regex: `(\d+)`}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("json 格式配置 regex 时应当返回错误")
+	}
+
+	cfg.Metrics[0].Parse = nil
+	cfg.Command.AllowedCommands = []string{"bin/bmsctl"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("白名单中的相对路径应当返回错误")
+	}
+}
+
+func TestValidateFileAllowlist(t *testing.T) {
+	cfg := &Config{
+		File: FileSourceConfig{AllowedPaths: []string{"/data/exports/daily_energy.csv"}, AllowedDirs: []string{"/var/lib/edge"}},
+		Metrics: []MetricSpec{{
+			Name:   "site_daily_energy_kwh",
+			Help:   "站点日发电量",
+			Source: "file",
+			Query:  "/data/exports/daily_energy.csv",
+		}},
+	}
+	for _, path := range []string{"/data/exports/daily_energy.csv", "/var/lib/edge/cache/value.txt"} {
+		cfg.Metrics[0].Query = path
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("白名单内的文件 %s 应当通过校验: %v", path, err)
+		}
+	}
+	for _, path := range []string{"/etc/shadow", "/var/lib/edge", "/var/lib/edge/../../../etc/shadow", "/var/lib/edge-other/value.txt", "value.txt"} {
+		cfg.Metrics[0].Query = path
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("白名单外的文件 %s 应当返回错误", path)
+		}
+	}
+
+	cfg.Metrics[0] = MetricSpec{Name: "replayed_load", Help: "回放负荷", Source: "synthetic", Query: "replay?file=/var/lib/edge/load.csv&speed=60"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("白名单内的回放文件应当通过校验: %v", err)
+	}
+	cfg.Metrics[0].Query = "replay?file=/etc/shadow"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("白名单外的回放文件应当返回错误")
+	}
+
+	cfg.Metrics[0].Query = "replay?file=/var/lib/edge/load.csv"
+	cfg.File.AllowedDirs = []string{"/var/lib/edge/"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("白名单中不规范的路径应当返回错误")
+	}
+}
+
+func TestValidatePlugins(t *testing.T) {
+	cfg := &Config{
+		Plugins: map[string]PluginConfig{"opcua": {Command: "/opt/plugins/opcua-plugin"}},
+		Metrics: []MetricSpec{{
+			Name:   "plc_line_speed",
+			Help:   "产线速度",
+			Source: "opcua",
+			Query:  "ns=2;s=Line1.Speed",
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("引用已声明插件的指标应当通过校验: %v", err)
+	}
+
+	cfg.Metrics[0].Source = "opcda"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("引用未声明插件的指标应当返回错误")
+	}
+
+	cfg.Metrics[0].Source = "opcua"
+	cfg.Plugins["opcua"] = PluginConfig{Command: "opcua-plugin"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("插件命令为相对路径时应当返回错误")
+	}
+
+	cfg.Plugins = map[string]PluginConfig{"mysql": {Command: "/opt/plugins/mysql-plugin"}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("插件名与内置数据源冲突时应当返回错误")
+	}
+}
+
+func TestValidateRemoteWrite(t *testing.T) {
+	cfg := &Config{
+		RemoteWrite: RemoteWriteConfig{
+			Enabled:        true,
+			URL:            "https://prometheus.example.com/api/v1/write",
+			ExternalLabels: map[string]string{"plant": "p1"},
+		},
+		Metrics: []MetricSpec{{Name: "m", Help: "h", Source: "synthetic", Query: "constant?value=1"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 remote_write 配置应当通过校验: %v", err)
+	}
+
+	cfg.RemoteWrite.ExternalLabels = map[string]string{"__name__": "x"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("external_labels 使用保留 label 名时应当返回错误")
+	}
+
+	cfg.RemoteWrite.ExternalLabels = nil
+	cfg.RemoteWrite.URL = "prometheus:9090/api/v1/write"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("缺少 scheme 的 url 应当返回错误")
+	}
+
+	cfg.RemoteWrite.Enabled = false
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("未启用时不应校验 remote_write: %v", err)
+	}
+}
+
+func TestValidateOTLP(t *testing.T) {
+	cfg := &Config{
+		OTLP:    OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317", Insecure: true},
+		Metrics: []MetricSpec{{Name: "m", Help: "h", Source: "synthetic", Query: "constant?value=1"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 OTLP gRPC 配置应当通过校验: %v", err)
+	}
+
+	cfg.OTLP.Endpoint = "http://otel-collector:4317"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("grpc 的 endpoint 为 URL 时应当返回错误")
+	}
+
+	cfg.OTLP.Protocol = "http"
+	cfg.OTLP.Endpoint = "http://otel-collector:4318/v1/metrics"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 OTLP HTTP 配置应当通过校验: %v", err)
+	}
+
+	cfg.OTLP.Compression = "zstd"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("不支持的压缩方式应当返回错误")
+	}
+}
+
+func TestSelectMetrics(t *testing.T) {
+	disabled := false
+	cfg := &Config{Metrics: []MetricSpec{
+		{Name: "a", Source: "synthetic"},
+		{Name: "b", Source: "synthetic", Enabled: &disabled},
+		{Name: "c", Source: "synthetic"},
+	}}
+	if err := cfg.SelectMetrics([]string{"b", " a"}); err != nil {
+		t.Fatalf("选择已存在的指标应当成功: %v", err)
+	}
+	if len(cfg.Metrics) != 2 || cfg.Metrics[0].Name != "a" || cfg.Metrics[1].Name != "b" || cfg.Metrics[1].Enabled != nil {
+		t.Fatalf("应当按配置顺序保留被点名的指标并启用，实际 %+v", cfg.Metrics)
+	}
+	if err := cfg.SelectMetrics([]string{"missing"}); err == nil {
+		t.Fatalf("选择不存在的指标应当返回错误")
+	}
+}
+
+func TestValidateSinks(t *testing.T) {
+	cfg := &Config{
+		IoTDB:   IoTDBConfig{Host: "127.0.0.1", User: "root"},
+		Metrics: []MetricSpec{{Name: "m", Help: "h", Source: "synthetic", Query: "constant?value=1"}},
+		Sinks: []SinkConfig{
+			{Name: "kpi", Type: "iotdb", Metrics: []string{"m"}, IoTDB: &IoTDBSinkConfig{Device: "root.kpi.sql2metrics"}},
+			{Name: "influx", Type: "influxdb", InfluxDB: &InfluxDBSinkConfig{URL: "http://influx:8086", Org: "ems", Bucket: "kpi"}},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的写回配置应当通过校验: %v", err)
+	}
+
+	cfg.Sinks[0].Metrics = []string{"missing"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("写回不存在的指标应当返回错误")
+	}
+	cfg.Sinks[0].Metrics = nil
+
+	cfg.Sinks[0].IoTDB.Device = "kpi.sql2metrics"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("device 不以 root. 开头时应当返回错误")
+	}
+	cfg.Sinks[0].IoTDB.Device = "root.kpi"
+
+	cfg.Sinks[1].InfluxDB.Database = "kpi"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("同时配置 bucket 与 database 时应当返回错误")
+	}
+	cfg.Sinks[1].InfluxDB.Database = ""
+
+	cfg.Sinks[1].Name = "kpi"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("写回目标名称重复时应当返回错误")
+	}
+}
+
+func TestValidateMetricGroups(t *testing.T) {
+	cfg := &Config{
+		MetricGroups: map[string]MetricGroupConfig{"finance": {BearerToken: "t"}},
+		Metrics:      []MetricSpec{{Name: "m", Help: "h", Source: "synthetic", Query: "constant?value=1", Groups: []string{"finance"}}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的指标分组配置应当通过校验: %v", err)
+	}
+
+	cfg.Metrics[0].Groups = []string{"ops"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("引用未定义的分组应当返回错误")
+	}
+
+	cfg.MetricGroups["ops/internal"] = MetricGroupConfig{}
+	cfg.Metrics[0].Groups = nil
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("分组名包含 / 时应当返回错误")
+	}
+	delete(cfg.MetricGroups, "ops/internal")
+
+	cfg.MetricGroups["finance"] = MetricGroupConfig{Username: "prom"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("配置 username 而缺少 password 时应当返回错误")
+	}
+}
+
+func TestMetricModeOnScrape(t *testing.T) {
+	cfg := &Config{
+		MetricGroups: map[string]MetricGroupConfig{"slow": {Mode: MetricModeOnScrape}},
+		Metrics: []MetricSpec{
+			{Name: "a", Help: "h", Source: "synthetic", Query: "constant?value=1", Groups: []string{"slow"}},
+			{Name: "b", Help: "h", Source: "synthetic", Query: "constant?value=1", Groups: []string{"slow"}, Mode: MetricModeInterval},
+			{Name: "c", Help: "h", Source: "synthetic", Query: "constant?value=1", Mode: MetricModeOnScrape},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 on_scrape 配置应当通过校验: %v", err)
+	}
+	for i, want := range []string{MetricModeOnScrape, MetricModeInterval, MetricModeOnScrape} {
+		if got := cfg.MetricMode(cfg.Metrics[i]); got != want {
+			t.Fatalf("指标 %s 的更新方式应为 %s，实际 %s", cfg.Metrics[i].Name, want, got)
+		}
+	}
+
+	cfg.Metrics[2].Type = "counter"
+	cfg.Metrics[2].Mode = MetricModeOnScrape
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("非 gauge 指标使用 on_scrape 应当返回错误")
+	}
+	cfg.Metrics[2].Type = ""
+
+	cfg.Schedule.ScrapeMinInterval = "0s"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("scrape_min_interval 为 0 时应当返回错误")
+	}
+}
+
+func TestValidateProbe(t *testing.T) {
+	cfg := &Config{
+		Metrics: []MetricSpec{{Name: "m", Help: "h", Source: "synthetic", Query: "constant?value=1"}},
+		Probe: ProbeConfig{
+			AllowedTargets: []string{"postgres://reader@shard-*.db.internal:5432/orders"},
+			Modules: map[string]ProbeModule{
+				"orders": {Source: "sql", Driver: "postgres", Metrics: []ProbeMetric{{Name: "orders_total", Help: "h", Query: "SELECT COUNT(*) FROM orders"}}},
+			},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 probe 配置应当通过校验: %v", err)
+	}
+	if !cfg.Probe.TargetAllowed("postgres://reader@shard-07.db.internal:5432/orders") {
+		t.Fatalf("匹配通配符的 DSN 应当被允许")
+	}
+	if cfg.Probe.TargetAllowed("postgres://reader@shard-1@evil.example.com/x.db.internal:5432/orders") {
+		t.Fatalf("不匹配的 DSN 不应被允许")
+	}
+
+	cfg.Probe.Modules["orders"].Metrics[0].Query = "DELETE FROM orders"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("写操作查询应当返回错误")
+	}
+	cfg.Probe.Modules["orders"].Metrics[0].Query = "SELECT 1"
+
+	cfg.Probe.AllowedTargets = []string{"*"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("过于宽泛的 allowed_targets 应当返回错误")
+	}
+}
+
+func TestValidateFanOut(t *testing.T) {
+	newCfg := func(m MetricSpec) *Config {
+		return &Config{
+			MySQLConnections: map[string]MySQLConfig{
+				"shard-01": {Host: "s1", Port: 3306, User: "u", Database: "orders"},
+				"shard-02": {Host: "s2", Port: 3306, User: "u", Database: "orders"},
+				"billing":  {Host: "b", Port: 3306, User: "u", Database: "billing"},
+			},
+			Metrics: []MetricSpec{m},
+		}
+	}
+	base := MetricSpec{Name: "orders_total", Help: "h", Source: "mysql", Query: "SELECT COUNT(*) FROM orders", Connection: "shard-*"}
+
+	cfg := newCfg(base)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 connection glob 应当通过校验: %v", err)
+	}
+	conns, err := cfg.MetricConnections(base)
+	if err != nil || strings.Join(conns, ",") != "shard-01,shard-02" {
+		t.Fatalf("glob 匹配结果不正确: %v %v", conns, err)
+	}
+
+	listed := base
+	listed.Connection, listed.Connections = "", []string{"billing", "shard-0[1]", "billing"}
+	conns, err = newCfg(listed).MetricConnections(listed)
+	if err != nil || strings.Join(conns, ",") != "billing,shard-01" {
+		t.Fatalf("connections 列表展开结果不正确: %v %v", conns, err)
+	}
+
+	invalid := map[string]func(m *MetricSpec){
+		"未匹配任何连接":         func(m *MetricSpec) { m.Connection = "replica-*" },
+		"列表中的连接未配置":       func(m *MetricSpec) { m.Connection, m.Connections = "", []string{"shard-01", "shard-09"} },
+		"同时配置两种写法":        func(m *MetricSpec) { m.Connections = []string{"billing"} },
+		"非 gauge 类型":      func(m *MetricSpec) { m.Type = "counter" },
+		"connection 标签冲突": func(m *MetricSpec) { m.Labels = map[string]string{"connection": "x"} },
+		"写操作查询":           func(m *MetricSpec) { m.Query = "DELETE FROM orders" },
+		"不支持的数据源":         func(m *MetricSpec) { m.Source, m.Query = "synthetic", "constant?value=1" },
+	}
+	for name, mutate := range invalid {
+		m := base
+		mutate(&m)
+		if err := newCfg(m).Validate(); err == nil {
+			t.Errorf("%s: 应当返回错误", name)
+		}
+	}
+}
+
+func TestValidateConnectionDiscovery(t *testing.T) {
+	cfg := &Config{
+		ConnectionDiscovery: ConnectionDiscoveryConfig{
+			Secrets: map[string]DiscoverySecret{"reader": {Username: "reader", Password: "p"}},
+			FileSD:  []FileSDConfig{{Source: "mysql", Files: []string{"/etc/sql2metrics/shards/*.yml"}}},
+			HTTPSD:  []HTTPSDConfig{{Source: "sql", URL: "https://cmdb.internal/sd"}},
+		},
+		Metrics: []MetricSpec{{Name: "orders_total", Help: "h", Source: "mysql", Query: "SELECT COUNT(*) FROM orders", Connection: "orders-shard-*"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 connection_discovery 配置应当通过校验: %v", err)
+	}
+	single := *cfg
+	single.Metrics = []MetricSpec{{Name: "orders_total", Help: "h", Source: "mysql", Query: "SELECT COUNT(*) FROM orders", Connection: "orders-shard-01"}}
+	if err := single.Validate(); err != nil {
+		t.Fatalf("单连接指标引用发现的连接时应当通过校验: %v", err)
+	}
+
+	invalid := map[string]func(d *ConnectionDiscoveryConfig){
+		"不支持的数据源":  func(d *ConnectionDiscoveryConfig) { d.FileSD[0].Source = "redis" },
+		"缺少 files": func(d *ConnectionDiscoveryConfig) { d.FileSD[0].Files = nil },
+		"url 无效":   func(d *ConnectionDiscoveryConfig) { d.HTTPSD[0].URL = "cmdb.internal/sd" },
+		"刷新周期无效":   func(d *ConnectionDiscoveryConfig) { d.RefreshInterval = "soon" },
+		"口令来源重复": func(d *ConnectionDiscoveryConfig) {
+			d.Secrets["reader"] = DiscoverySecret{Password: "p", PasswordFile: "/run/secrets/p"}
+		},
+	}
+	for name, mutate := range invalid {
+		c := *cfg
+		c.ConnectionDiscovery = ConnectionDiscoveryConfig{
+			Secrets: map[string]DiscoverySecret{"reader": {Username: "reader", Password: "p"}},
+			FileSD:  []FileSDConfig{{Source: "mysql", Files: []string{"/etc/sql2metrics/shards/*.yml"}}},
+			HTTPSD:  []HTTPSDConfig{{Source: "sql", URL: "https://cmdb.internal/sd"}},
+		}
+		mutate(&c.ConnectionDiscovery)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: 应当返回错误", name)
+		}
+	}
+
+	// 没有发现来源时，glob 与连接名必须匹配配置文件中的连接
+	cfg.ConnectionDiscovery = ConnectionDiscoveryConfig{}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("glob 未匹配任何连接且未配置连接发现时应当返回错误")
+	}
+	single.ConnectionDiscovery = ConnectionDiscoveryConfig{}
+	if err := single.Validate(); err == nil {
+		t.Fatal("引用未配置的连接且未配置连接发现时应当返回错误")
+	}
+}
+
+func TestValidateWatermark(t *testing.T) {
+	valid := MetricSpec{Name: "orders_created_total", Help: "h", Type: "counter", Source: "sql", Query: "SELECT COUNT(*), MAX(id) FROM orders WHERE id > ?", Watermark: &WatermarkConfig{}}
+	cfg := &Config{SQLConnections: map[string]SQLConfig{"default": {Driver: "sqlite", DSN: "/tmp/orders.db"}}, Metrics: []MetricSpec{valid}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("合法的 watermark 配置应当通过校验: %v", err)
+	}
+	if got := valid.Watermark.InitialValue(); got != "0" {
+		t.Fatalf("默认初始水位应为 0，实际 %s", got)
+	}
+
+	invalid := map[string]func(m *MetricSpec){
+		"非 counter":    func(m *MetricSpec) { m.Type = "gauge" },
+		"不支持的数据源":      func(m *MetricSpec) { m.Source = "redis" },
+		"on_scrape 模式": func(m *MetricSpec) { m.Mode = MetricModeOnScrape },
+		"扇出":           func(m *MetricSpec) { m.Connection = "*" },
+	}
+	for name, mutate := range invalid {
+		m := valid
+		mutate(&m)
+		if err := m.validateWatermark(m.Type); err == nil {
+			t.Errorf("%s: 应当返回错误", name)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	if d, err := ParseDuration("", time.Minute); err != nil || d != time.Minute {
+		t.Fatalf("空值应当返回默认值，实际 %v, %v", d, err)
+	}
+	if d, err := ParseDuration("250ms", time.Minute); err != nil || d != 250*time.Millisecond {
+		t.Fatalf("期望 250ms，实际 %v, %v", d, err)
+	}
+	for _, value := range []string{"soon", "0s", "-1s"} {
+		if _, err := ParseDuration(value, time.Minute); err == nil {
+			t.Errorf("%q 应当返回错误而不是静默使用默认值", value)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// defaultCommandPath 是不继承环境变量时提供给命令的 PATH。
+const defaultCommandPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// CommandRunner 按沙箱策略执行白名单中的可执行文件，并从标准输出中提取数值。
+type CommandRunner struct {
+	cfg config.CommandSourceConfig
+}
+
+// NewCommandRunner 基于沙箱策略创建命令执行器。
+func NewCommandRunner(cfg config.CommandSourceConfig) *CommandRunner {
+	return &CommandRunner{cfg: cfg}
+}
+
+// QueryScalar 执行命令并按解析配置从标准输出中提取数值。
+// 命令不经过 shell 执行，超时后被强制终止；退出码非 0 或输出超过上限均视为失败。
+func (r *CommandRunner) QueryScalar(ctx context.Context, path string, args []string, timeout time.Duration, parse config.OutputParseConfig, resultField string) (float64, error) {
+	if !r.cfg.Allowed(path) {
+		return 0, fmt.Errorf("可执行文件 %s 不在白名单中", path)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = r.cfg.WorkDir
+	if cmd.Dir == "" {
+		cmd.Dir = os.TempDir()
+	}
+	cmd.Env = r.env()
+	// 终止后不再等待子进程继续持有的输出管道
+	cmd.WaitDelay = time.Second
+
+	limit := r.cfg.MaxOutputBytes
+	if limit == 0 {
+		limit = 1 << 20
+	}
+	stdout := &limitedBuffer{limit: limit}
+	stderr := &limitedBuffer{limit: 4096}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return 0, fmt.Errorf("执行命令 %s 超时（%s）: %w", path, timeout, context.DeadlineExceeded)
+	case err != nil:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return 0, fmt.Errorf("命令 %s 退出码 %d: %s", path, exitErr.ExitCode(), strings.TrimSpace(stderr.String()))
+		}
+		return 0, fmt.Errorf("执行命令 %s 失败: %w", path, err)
+	case stdout.overflow:
+		return 0, fmt.Errorf("命令 %s 的输出超过上限 %d 字节", path, limit)
+	}
+	return ParseOutput(stdout.Bytes(), parse, resultField)
+}
+
+// env 返回命令的环境变量：默认只提供 PATH，配置的 env 覆盖同名变量。
+func (r *CommandRunner) env() []string {
+	values := map[string]string{"PATH": defaultCommandPath}
+	if r.cfg.InheritEnv {
+		for _, kv := range os.Environ() {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				values[k] = v
+			}
+		}
+	}
+	for k, v := range r.cfg.Env {
+		values[k] = v
+	}
+	env := make([]string, 0, len(values))
+	for k, v := range values {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// limitedBuffer 最多保留 limit 字节，超出部分丢弃并记录溢出。
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); len(p) > remaining {
+		b.overflow = true
+		if remaining > 0 {
+			b.Buffer.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,48 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestCommandRunner(t *testing.T) {
+	const shell = "/bin/sh"
+	if _, err := os.Stat(shell); err != nil {
+		t.Skip("缺少 /bin/sh")
+	}
+	t.Setenv("COLLECTOR_SECRET", "leaked")
+	runner := NewCommandRunner(config.CommandSourceConfig{
+		AllowedCommands: []string{shell},
+		Env:             map[string]string{"SITE_VALUE": "7"},
+		MaxOutputBytes:  64,
+	})
+	ctx := context.Background()
+	text := config.OutputParseConfig{}
+
+	value, err := runner.QueryScalar(ctx, shell, []string{"-c", `echo "value=${SITE_VALUE}${COLLECTOR_SECRET}"`}, time.Second,
+		config.OutputParseConfig{Regex: `value=(\d+)$`}, "")
+	if err != nil || value != 7 {
+		t.Fatalf("期望只能看到配置的环境变量并得到 7，实际 %v（错误: %v）", value, err)
+	}
+
+	if _, err := runner.QueryScalar(ctx, "/bin/echo", []string{"1"}, time.Second, text, ""); err == nil {
+		t.Fatalf("白名单外的可执行文件应当被拒绝")
+	}
+	if _, err := runner.QueryScalar(ctx, shell, []string{"-c", "echo 1; exit 3"}, time.Second, text, ""); err == nil {
+		t.Fatalf("退出码非 0 时应当返回错误")
+	}
+	if _, err := runner.QueryScalar(ctx, shell, []string{"-c", "head -c 1000 /dev/zero"}, time.Second, text, ""); err == nil {
+		t.Fatalf("输出超过上限时应当返回错误")
+	}
+
+	start := time.Now()
+	_, err = runner.QueryScalar(ctx, shell, []string{"-c", "sleep 5"}, 100*time.Millisecond, text, "")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 3*time.Second {
+		t.Fatalf("超时后应当终止命令并返回超时错误，实际 %v（耗时 %s）", err, time.Since(start))
+	}
+}
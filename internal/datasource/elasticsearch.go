@@ -0,0 +1,146 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// ElasticsearchClient 对 Elasticsearch/OpenSearch 执行 _count 与 _search 请求，复用 RestAPIClient 的 HTTP 客户端。
+type ElasticsearchClient struct {
+	api *RestAPIClient
+}
+
+// esQuery 描述 MetricSpec.Query 中的一次请求。
+type esQuery struct {
+	operation string // _count/_search
+	index     string
+	body      string
+}
+
+// NewElasticsearchClient 基于配置创建 Elasticsearch 客户端。
+func NewElasticsearchClient(cfg config.ElasticsearchConfig) (*ElasticsearchClient, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("Elasticsearch 配置缺少 url")
+	}
+	api, err := NewRestAPIClient(cfg.RestAPIConfig())
+	if err != nil {
+		return nil, fmt.Errorf("创建 Elasticsearch 客户端失败: %w", err)
+	}
+	return &ElasticsearchClient{api: api}, nil
+}
+
+// QueryScalar 执行查询并返回数值。query 第一行为 "<_count|_search> <索引>"，其余行为 JSON 请求体，例如：
+//
+//	_search <logs-app-{now/d}>,<logs-app-{now/d-1d}>
+//	{"size": 0, "query": {"range": {"@timestamp": {"gte": "{{window_start}}", "lt": "{{window_end}}"}}},
+//	 "aggs": {"errors": {"filter": {"term": {"level": "error"}}}}}
+//
+// 索引支持逗号分隔、通配符与日期数学表达式；请求体中的时间窗口变量见 TimeWindow.Expand。
+// resultField 为空时，_count 取 count，_search 取命中总数 hits.total；
+// 否则按路径提取，如 aggregations.errors.doc_count、aggregations.latency.values[95.0]。
+func (c *ElasticsearchClient) QueryScalar(ctx context.Context, query, resultField string, window TimeWindow) (float64, error) {
+	result, err := c.QueryRaw(ctx, query, resultField, window)
+	if err != nil {
+		return 0, err
+	}
+	if resultField != "" {
+		return extractJSONValue(result, resultField)
+	}
+	if strings.HasPrefix(strings.TrimSpace(query), "_count") {
+		return extractJSONValue(result, "count")
+	}
+	return searchTotalHits(result)
+}
+
+// QueryRaw 执行查询并返回完整的 JSON 响应，用于预览和字段选择。
+func (c *ElasticsearchClient) QueryRaw(ctx context.Context, query, resultField string, window TimeWindow) (interface{}, error) {
+	q, err := parseESQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.api.baseURL + "/" + escapeIndexPattern(q.index) + "/" + q.operation
+	// 命中总数默认最多统计到 10000，取总数时要求精确统计
+	if q.operation == "_search" && (resultField == "" || strings.HasPrefix(resultField, "hits.total")) {
+		endpoint += "?track_total_hits=true"
+	}
+
+	method := "GET"
+	body := window.Expand(q.body)
+	if body != "" {
+		method = "POST"
+	}
+	result, err := c.api.doRequest(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("执行 Elasticsearch %s 请求失败: %w", q.operation, err)
+	}
+	if obj, ok := result.(map[string]interface{}); ok {
+		if shards, ok := obj["_shards"].(map[string]interface{}); ok {
+			if failed, _ := shards["failed"].(float64); failed > 0 {
+				return nil, fmt.Errorf("Elasticsearch 查询有 %v 个分片失败", failed)
+			}
+		}
+		if timedOut, _ := obj["timed_out"].(bool); timedOut {
+			return nil, errors.New("Elasticsearch 查询超时，结果不完整")
+		}
+	}
+	return result, nil
+}
+
+// Ping 请求集群根路径，验证地址与认证信息。
+func (c *ElasticsearchClient) Ping(ctx context.Context) error {
+	if _, err := c.api.doRequest(ctx, "GET", c.api.baseURL+"/", ""); err != nil {
+		return fmt.Errorf("Elasticsearch 连接测试失败: %w", err)
+	}
+	return nil
+}
+
+// Close 释放资源。
+func (c *ElasticsearchClient) Close() error {
+	return c.api.Close()
+}
+
+func parseESQuery(query string) (esQuery, error) {
+	query = strings.TrimSpace(query)
+	head, body, _ := strings.Cut(query, "\n")
+	fields := strings.Fields(head)
+	if len(fields) != 2 {
+		return esQuery{}, errors.New("Elasticsearch 查询第一行格式应为 \"<_count|_search> <索引>\"")
+	}
+	q := esQuery{operation: fields[0], index: fields[1], body: strings.TrimSpace(body)}
+	if q.operation != "_count" && q.operation != "_search" {
+		return esQuery{}, fmt.Errorf("不支持的 Elasticsearch 操作: %s，仅支持 _count 与 _search", q.operation)
+	}
+	// 毫秒时间戳变量可不加引号，替换变量后再检查 JSON 合法性
+	if q.body != "" && !json.Valid([]byte(TimeWindow{}.Expand(q.body))) {
+		return esQuery{}, errors.New("Elasticsearch 请求体必须为合法 JSON")
+	}
+	return q, nil
+}
+
+// escapeIndexPattern 逐个转义逗号分隔的索引，保留逗号与通配符，使 <logs-{now/d}> 等日期数学表达式可用。
+func escapeIndexPattern(index string) string {
+	parts := strings.Split(index, ",")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(url.PathEscape(part), "%2A", "*")
+	}
+	return strings.Join(parts, ",")
+}
+
+// searchTotalHits 读取 _search 响应中的命中总数，兼容 7.x 之前直接返回数字的格式。
+func searchTotalHits(result interface{}) (float64, error) {
+	obj, _ := result.(map[string]interface{})
+	hits, ok := obj["hits"].(map[string]interface{})
+	if !ok {
+		return 0, errors.New("Elasticsearch 响应缺少 hits")
+	}
+	if total, ok := hits["total"].(map[string]interface{}); ok {
+		return toFloat(total["value"])
+	}
+	return toFloat(hits["total"])
+}
@@ -0,0 +1,105 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func newTestElasticsearch(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "ApiKey c2VjcmV0" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.EscapedPath() {
+		case "/":
+			_, _ = w.Write([]byte(`{"cluster_name":"test","version":{"number":"8.13.0"}}`))
+		case "/logs-app-*/_count":
+			_, _ = w.Write([]byte(`{"count":42,"_shards":{"total":1,"successful":1,"failed":0}}`))
+		case "/%3Clogs-app-%7Bnow%2Fd%7D%3E,%3Clogs-app-%7Bnow%2Fd-1d%7D%3E/_search":
+			body, _ := io.ReadAll(r.Body)
+			var req struct {
+				Query struct {
+					Range struct {
+						Timestamp struct {
+							GTE string `json:"gte"`
+							LT  int64  `json:"lt"`
+						} `json:"@timestamp"`
+					} `json:"range"`
+				} `json:"query"`
+			}
+			if err := json.Unmarshal(body, &req); err != nil || r.Method != "POST" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			ts := req.Query.Range.Timestamp
+			if ts.GTE != "2024-05-01T09:00:00.000Z" || ts.LT != 1714557600000 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"unexpected window"}`))
+				return
+			}
+			if r.URL.Query().Get("track_total_hits") == "true" {
+				_, _ = w.Write([]byte(`{"timed_out":false,"hits":{"total":{"value":12000,"relation":"eq"}}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"timed_out":false,"hits":{"total":{"value":10000,"relation":"gte"}},
+				"aggregations":{"errors":{"doc_count":7},"latency":{"values":{"95.0":180.5}}}}`))
+		case "/broken/_search":
+			_, _ = w.Write([]byte(`{"timed_out":false,"_shards":{"total":2,"successful":1,"failed":1},"hits":{"total":{"value":1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestElasticsearchClientQueries(t *testing.T) {
+	server := newTestElasticsearch(t)
+	client, err := NewElasticsearchClient(config.ElasticsearchConfig{URL: server.URL, APIKey: "c2VjcmV0"})
+	if err != nil {
+		t.Fatalf("创建 Elasticsearch 客户端失败: %v", err)
+	}
+	ctx := context.Background()
+	window := NewTimeWindow(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), time.Hour)
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("Ping 失败: %v", err)
+	}
+	if value, err := client.QueryScalar(ctx, "_count logs-app-*", "", window); err != nil || value != 42 {
+		t.Fatalf("_count 期望 42，实际 %v（错误: %v）", value, err)
+	}
+
+	search := "_search <logs-app-{now/d}>,<logs-app-{now/d-1d}>\n" +
+		`{"size": 0, "query": {"range": {"@timestamp": {"gte": "{{window_start}}", "lt": {{window_end_ms}}}}}}`
+	cases := map[string]float64{
+		"":                                  12000,
+		"aggregations.errors.doc_count":     7,
+		"aggregations.latency.values[95.0]": 180.5,
+	}
+	for field, want := range cases {
+		value, err := client.QueryScalar(ctx, search, field, window)
+		if err != nil || value != want {
+			t.Fatalf("result_field=%q 期望 %v，实际 %v（错误: %v）", field, want, value, err)
+		}
+	}
+
+	if _, err := client.QueryScalar(ctx, "_search broken\n{}", "", window); err == nil {
+		t.Fatalf("分片失败时应当返回错误")
+	}
+	if _, err := client.QueryScalar(ctx, "_delete_by_query logs", "", window); err == nil {
+		t.Fatalf("不支持的操作应当返回错误")
+	}
+	if _, err := client.QueryScalar(ctx, "_search logs\n{\"size\": ", "", window); err == nil {
+		t.Fatalf("非法请求体应当返回错误")
+	}
+}
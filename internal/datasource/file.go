@@ -0,0 +1,81 @@
+package datasource
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// maxFileSize 限制 file 数据源单次读取的文件大小。
+const maxFileSize = 16 << 20
+
+// QueryFile 读取 file 白名单中的本地文件并按解析配置提取数值。maxAge 大于 0 时，文件修改时间早于该时长视为过期。
+func QueryFile(policy config.FileSourceConfig, path string, parse config.OutputParseConfig, resultField string, maxAge time.Duration) (float64, error) {
+	data, err := readAllowedFile(policy, path, maxAge)
+	if err != nil {
+		return 0, err
+	}
+	return ParseOutput(data, parse, resultField)
+}
+
+// readAllowedFile 检查 file 白名单后读取文件内容，文件超过 16MiB 时失败。
+func readAllowedFile(policy config.FileSourceConfig, path string, maxAge time.Duration) ([]byte, error) {
+	resolved, err := resolveAllowedFile(policy, path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件信息失败: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s 是目录", path)
+	}
+	if maxAge > 0 {
+		if age := time.Since(info.ModTime()); age > maxAge {
+			return nil, fmt.Errorf("文件 %s 已过期：最后修改于 %s 前，超过 max_age %s", path, age.Truncate(time.Second), maxAge)
+		}
+	}
+	if info.Size() > maxFileSize {
+		return nil, fmt.Errorf("文件 %s 大小 %d 字节，超过上限 %d 字节", path, info.Size(), maxFileSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, maxFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	return data, nil
+}
+
+// resolveAllowedFile 检查路径是否在 file 白名单中，返回实际读取的路径。
+// 通过 allowed_dirs 放行的文件按解析符号链接后的真实路径再检查一次，避免目录中的链接指向白名单以外的文件。
+func resolveAllowedFile(policy config.FileSourceConfig, path string) (string, error) {
+	if !policy.Allowed(path) {
+		return "", fmt.Errorf("文件 %s 不在 file.allowed_paths 或 file.allowed_dirs 白名单中", path)
+	}
+	for _, allowed := range policy.AllowedPaths {
+		if allowed == path {
+			return path, nil
+		}
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	for _, dir := range policy.AllowedDirs {
+		if resolvedDir, err := filepath.EvalSymlinks(dir); err == nil && config.WithinDir(resolvedDir, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("文件 %s 经符号链接指向 file 白名单以外的位置", path)
+}
@@ -0,0 +1,127 @@
+package datasource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestParseOutput(t *testing.T) {
+	csvData := []byte("site, date, energy_kwh\nsh, 2024-05-01, 120.5\nbj, 2024-05-01, 98\nsh, 2024-05-02, 130\n")
+	cases := []struct {
+		name  string
+		data  []byte
+		parse config.OutputParseConfig
+		field string
+		want  float64
+	}{
+		{"纯文本", []byte(" 42\n"), config.OutputParseConfig{}, "", 42},
+		{"正则捕获组", []byte("Battery: 87% (charging)"), config.OutputParseConfig{Regex: `Battery: (\d+)%`}, "", 87},
+		{"JSON 路径", []byte(`{"data":{"items":[{"v":3.5}]}}`), config.OutputParseConfig{Format: "json"}, "data.items[0].v", 3.5},
+		{"CSV 默认最后一行", csvData, config.OutputParseConfig{Format: "csv"}, "energy_kwh", 130},
+		{"CSV 过滤取第一行", csvData, config.OutputParseConfig{Format: "csv", Match: map[string]string{"site": "sh"}, Row: "first"}, "energy_kwh", 120.5},
+		{"CSV 无表头按序号", []byte("a;1\nb;2\n"), config.OutputParseConfig{Format: "csv", Delimiter: ";", NoHeader: true, Match: map[string]string{"0": "a"}}, "1", 1},
+	}
+	for _, tc := range cases {
+		got, err := ParseOutput(tc.data, tc.parse, tc.field)
+		if err != nil || got != tc.want {
+			t.Fatalf("%s: 期望 %v，实际 %v（错误: %v）", tc.name, tc.want, got, err)
+		}
+	}
+
+	if _, err := ParseOutput(csvData, config.OutputParseConfig{Format: "csv", Match: map[string]string{"site": "gz"}}, "energy_kwh"); err == nil {
+		t.Fatalf("没有匹配行时应当返回错误")
+	}
+	// 解析失败时错误中不应带出文件或命令输出的内容
+	for _, tc := range []struct {
+		data  string
+		parse config.OutputParseConfig
+		field string
+	}{
+		{"secret-token", config.OutputParseConfig{}, ""},
+		{`{"v":"secret-token"}`, config.OutputParseConfig{Format: "json"}, "v"},
+		{"secret-token", config.OutputParseConfig{Format: "json"}, ""},
+		{"v\nsecret-token\n", config.OutputParseConfig{Format: "csv"}, "v"},
+	} {
+		_, err := ParseOutput([]byte(tc.data), tc.parse, tc.field)
+		if err == nil || strings.Contains(err.Error(), "secret") {
+			t.Fatalf("%s 格式解析失败时应当返回不含内容的错误，实际 %v", tc.parse.Format, err)
+		}
+	}
+}
+
+func TestQueryFileStaleness(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "value.txt")
+	if err := os.WriteFile(path, []byte("12\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy := config.FileSourceConfig{AllowedPaths: []string{path}}
+	if value, err := QueryFile(policy, path, config.OutputParseConfig{}, "", time.Hour); err != nil || value != 12 {
+		t.Fatalf("期望 12，实际 %v（错误: %v）", value, err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := QueryFile(policy, path, config.OutputParseConfig{}, "", time.Hour); err == nil {
+		t.Fatalf("文件超过 max_age 时应当返回错误")
+	}
+	if _, err := QueryFile(policy, path, config.OutputParseConfig{}, "", 0); err != nil {
+		t.Fatalf("未配置 max_age 时不应检查修改时间: %v", err)
+	}
+}
+
+func TestQueryFileAllowlist(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	if err := os.MkdirAll(filepath.Join(allowed, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inside := filepath.Join(allowed, "sub", "value.txt")
+	outside := filepath.Join(dir, "secret.txt")
+	for _, path := range []string{inside, outside} {
+		if err := os.WriteFile(path, []byte("7"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(allowed, "link.txt")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	policy := config.FileSourceConfig{AllowedDirs: []string{allowed}}
+
+	if value, err := QueryFile(policy, inside, config.OutputParseConfig{}, "", 0); err != nil || value != 7 {
+		t.Fatalf("白名单目录下的文件应当可读，实际 %v（错误: %v）", value, err)
+	}
+	for _, path := range []string{outside, filepath.Join(allowed, "..", "secret.txt"), link} {
+		if _, err := QueryFile(policy, path, config.OutputParseConfig{}, "", 0); err == nil {
+			t.Fatalf("白名单外的文件 %s 不应被读取", path)
+		}
+	}
+	if _, err := QueryFile(config.FileSourceConfig{}, inside, config.OutputParseConfig{}, "", 0); err == nil {
+		t.Fatal("未配置白名单时不应读取任何文件")
+	}
+}
+
+func TestSyntheticReplayAllowlist(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "load.csv")
+	if err := os.WriteFile(path, []byte("2024-05-01T00:00:00Z,5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	query := "replay?file=" + path
+	client := NewSyntheticClient()
+	if _, err := client.QueryScalar(context.Background(), config.FileSourceConfig{}, "load", query); err == nil {
+		t.Fatal("回放文件不在白名单中时应当失败")
+	}
+	value, err := client.QueryScalar(context.Background(), config.FileSourceConfig{AllowedDirs: []string{dir}}, "load", query)
+	if err != nil || value != 5 {
+		t.Fatalf("白名单内的回放文件应当可读，实际 %v（错误: %v）", value, err)
+	}
+}
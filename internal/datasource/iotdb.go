@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"strconv"
 	"strings"
 
@@ -16,6 +17,7 @@ import (
 // IoTDBClient 负责与 IoTDB 交互获取聚合结果。
 type IoTDBClient struct {
 	session *client.Session
+	tunnel  *SSHTunnel
 }
 
 // NewIoTDBClient 初始化 IoTDB 会话。
@@ -43,14 +45,26 @@ func NewIoTDBClient(cfg config.IoTDBConfig) (*IoTDBClient, error) {
 		}(),
 		TimeZone: cfg.ZoneID,
 	}
+	var tunnel *SSHTunnel
+	if cfg.SSHTunnel != nil {
+		var err error
+		tunnel, err = NewSSHTunnel(*cfg.SSHTunnel, net.JoinHostPort(cfg.Host, strconv.Itoa(port)))
+		if err != nil {
+			return nil, fmt.Errorf("建立 IoTDB SSH 隧道失败: %w", err)
+		}
+		localHost, localPort := tunnel.LocalHostPort()
+		conf.Host = localHost
+		conf.Port = strconv.Itoa(localPort)
+	}
 	sess := client.NewSession(conf)
 	session := &sess
 	// 设置连接超时为 5 秒，避免启动时长时间阻塞
 	timeout := 5000 // 5 秒超时（毫秒）
 	if err := session.Open(cfg.EnableZstd, timeout); err != nil {
+		closeTunnel(tunnel)
 		return nil, fmt.Errorf("打开 IoTDB 会话失败: %w", err)
 	}
-	return &IoTDBClient{session: session}, nil
+	return &IoTDBClient{session: session, tunnel: tunnel}, nil
 }
 
 // TestConnection 测试 IoTDB 连接，使用 show databases 命令。
@@ -134,12 +148,53 @@ func (c *IoTDBClient) QueryScalar(ctx context.Context, sqlStmt, resultField stri
 	return total, nil
 }
 
+// IoTDBRecord 是写入 IoTDB 的一条 DOUBLE 记录，Timestamp 为毫秒时间戳。
+type IoTDBRecord struct {
+	Device      string
+	Measurement string
+	Value       float64
+	Timestamp   int64
+}
+
+// InsertRecords 批量写入记录，供采集结果写回 IoTDB 使用。
+func (c *IoTDBClient) InsertRecords(records []IoTDBRecord) error {
+	if c.session == nil {
+		return errors.New("IoTDB 会话未初始化")
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	devices := make([]string, len(records))
+	measurements := make([][]string, len(records))
+	dataTypes := make([][]client.TSDataType, len(records))
+	values := make([][]interface{}, len(records))
+	timestamps := make([]int64, len(records))
+	for i, r := range records {
+		devices[i] = r.Device
+		measurements[i] = []string{r.Measurement}
+		dataTypes[i] = []client.TSDataType{client.DOUBLE}
+		values[i] = []interface{}{r.Value}
+		timestamps[i] = r.Timestamp
+	}
+	status, err := c.session.InsertRecords(devices, measurements, dataTypes, values, timestamps)
+	if err != nil {
+		return fmt.Errorf("写入 IoTDB 失败: %w", err)
+	}
+	if err := client.VerifySuccess(status); err != nil {
+		return fmt.Errorf("写入 IoTDB 失败: %w", err)
+	}
+	return nil
+}
+
 // Close 关闭会话。
 func (c *IoTDBClient) Close() error {
 	if c.session == nil {
 		return nil
 	}
-	if _, err := c.session.Close(); err != nil {
+	_, err := c.session.Close()
+	closeTunnel(c.tunnel)
+	c.tunnel = nil
+	if err != nil {
 		return err
 	}
 	c.session = nil
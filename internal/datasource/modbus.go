@@ -0,0 +1,376 @@
+package datasource
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// Modbus 功能码。
+const (
+	modbusReadCoils            byte = 0x01
+	modbusReadDiscreteInputs   byte = 0x02
+	modbusReadHoldingRegisters byte = 0x03
+	modbusReadInputRegisters   byte = 0x04
+)
+
+// ModbusException 表示从站返回的异常响应。
+type ModbusException struct {
+	Function byte
+	Code     byte
+}
+
+func (e *ModbusException) Error() string {
+	reasons := map[byte]string{
+		0x01: "非法功能码",
+		0x02: "非法数据地址",
+		0x03: "非法数据值",
+		0x04: "从站设备故障",
+		0x06: "从站设备忙",
+		0x0B: "网关目标设备无响应",
+	}
+	reason, ok := reasons[e.Code]
+	if !ok {
+		reason = "未知异常"
+	}
+	return fmt.Sprintf("Modbus 从站返回异常（功能码 0x%02X，异常码 0x%02X）: %s", e.Function, e.Code, reason)
+}
+
+// ModbusClient 通过 Modbus TCP 读取寄存器与线圈，连接断开后在下次查询时自动重连。
+type ModbusClient struct {
+	addr      string
+	unitID    byte
+	timeout   time.Duration
+	byteOrder string
+	wordOrder string
+
+	mu   sync.Mutex
+	conn net.Conn
+	txID uint16
+}
+
+// modbusQuery 描述一次读取：数据区、起始地址、数据类型与换算参数。
+type modbusQuery struct {
+	function  byte
+	address   uint16
+	dataType  string
+	byteOrder string
+	wordOrder string
+	scale     float64
+	unitID    *byte
+}
+
+// NewModbusClient 基于配置创建 Modbus TCP 客户端并验证连通性。
+func NewModbusClient(cfg config.ModbusConfig) (*ModbusClient, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("Modbus 配置缺少 host")
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 502
+	}
+	timeout := 5 * time.Second
+	if cfg.Timeout != "" {
+		parsed, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("解析 Modbus 超时配置失败: %w", err)
+		}
+		timeout = parsed
+	}
+
+	c := &ModbusClient{
+		addr:      net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		unitID:    byte(cfg.UnitID),
+		timeout:   timeout,
+		byteOrder: orDefault(cfg.ByteOrder, "big"),
+		wordOrder: orDefault(cfg.WordOrder, "big"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := c.Ping(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Ping 建立（或复用）到设备的 TCP 连接。
+func (c *ModbusClient) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.connect(ctx)
+	return err
+}
+
+// QueryScalar 按查询表达式读取并解码一个数值。
+// 查询格式为 <area>:<address>[:<type>][?参数]，例如：
+//   - "holding:100:float32?word_order=little&scale=0.1"
+//   - "input:30:int16"
+//   - "coil:8"
+//
+// area 可选 holding、input、coil、discrete；type 可选 int16、uint16、int32、uint32、float32，默认为 uint16；
+// 参数支持 byte_order、word_order（big/little）、scale 与 unit_id。
+func (c *ModbusClient) QueryScalar(ctx context.Context, query string) (float64, error) {
+	q, err := parseModbusQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	if q.byteOrder == "" {
+		q.byteOrder = c.byteOrder
+	}
+	if q.wordOrder == "" {
+		q.wordOrder = c.wordOrder
+	}
+	unitID := c.unitID
+	if q.unitID != nil {
+		unitID = *q.unitID
+	}
+
+	quantity := uint16(1)
+	if q.dataType == "int32" || q.dataType == "uint32" || q.dataType == "float32" {
+		quantity = 2
+	}
+	data, err := c.read(ctx, unitID, q.function, q.address, quantity)
+	if err != nil {
+		return 0, err
+	}
+
+	var value float64
+	if q.function == modbusReadCoils || q.function == modbusReadDiscreteInputs {
+		value = float64(data[0] & 0x01)
+	} else {
+		value, err = decodeRegisters(data, q)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return value * q.scale, nil
+}
+
+// Close 断开与设备的连接。
+func (c *ModbusClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// read 发送读请求并返回响应中的数据部分，连接异常时断开以便下次重连。
+func (c *ModbusClient) read(ctx context.Context, unitID, function byte, address, quantity uint16) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c.txID++
+	txID := c.txID
+	req := make([]byte, 12)
+	binary.BigEndian.PutUint16(req[0:], txID)
+	binary.BigEndian.PutUint16(req[2:], 0) // 协议标识，Modbus 固定为 0
+	binary.BigEndian.PutUint16(req[4:], 6) // 后续字节数：单元标识 + PDU
+	req[6] = unitID
+	req[7] = function
+	binary.BigEndian.PutUint16(req[8:], address)
+	binary.BigEndian.PutUint16(req[10:], quantity)
+
+	if _, err := conn.Write(req); err != nil {
+		c.dropConn()
+		return nil, fmt.Errorf("发送 Modbus 请求失败: %w", err)
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		c.dropConn()
+		return nil, fmt.Errorf("读取 Modbus 响应失败: %w", err)
+	}
+	length := binary.BigEndian.Uint16(header[4:])
+	// 长度包含单元号，PDU 至少有功能码与一个字节的数据长度或异常码
+	if length < 3 || length > 254 {
+		c.dropConn()
+		return nil, fmt.Errorf("Modbus 响应长度非法: %d", length)
+	}
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		c.dropConn()
+		return nil, fmt.Errorf("读取 Modbus 响应失败: %w", err)
+	}
+	if got := binary.BigEndian.Uint16(header[0:]); got != txID {
+		c.dropConn()
+		return nil, fmt.Errorf("Modbus 响应事务号不匹配: 期望 %d，实际 %d", txID, got)
+	}
+
+	if pdu[0] == function|0x80 {
+		return nil, &ModbusException{Function: function, Code: pdu[1]}
+	}
+	if pdu[0] != function {
+		return nil, fmt.Errorf("Modbus 响应功能码不匹配: 期望 0x%02X，实际 0x%02X", function, pdu[0])
+	}
+	count := int(pdu[1])
+	if len(pdu) < 2+count || count == 0 {
+		return nil, errors.New("Modbus 响应数据不完整")
+	}
+	return pdu[2 : 2+count], nil
+}
+
+func (c *ModbusClient) connect(ctx context.Context) (net.Conn, error) {
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("连接 Modbus 设备 %s 失败: %w", c.addr, err)
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *ModbusClient) dropConn() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
+
+func parseModbusQuery(query string) (modbusQuery, error) {
+	query = strings.TrimSpace(query)
+	spec, rawParams, _ := strings.Cut(query, "?")
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return modbusQuery{}, fmt.Errorf("Modbus 查询格式应为 <area>:<address>[:<type>]，实际为 %q", query)
+	}
+
+	q := modbusQuery{dataType: "uint16", scale: 1}
+	switch strings.ToLower(parts[0]) {
+	case "holding":
+		q.function = modbusReadHoldingRegisters
+	case "input":
+		q.function = modbusReadInputRegisters
+	case "coil":
+		q.function = modbusReadCoils
+	case "discrete":
+		q.function = modbusReadDiscreteInputs
+	default:
+		return modbusQuery{}, fmt.Errorf("不支持的 Modbus 数据区: %s", parts[0])
+	}
+
+	address, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil {
+		return modbusQuery{}, fmt.Errorf("Modbus 地址无效: %s", parts[1])
+	}
+	q.address = uint16(address)
+
+	if len(parts) == 3 {
+		q.dataType = strings.ToLower(parts[2])
+		if q.function == modbusReadCoils || q.function == modbusReadDiscreteInputs {
+			return modbusQuery{}, errors.New("线圈与离散输入不支持指定数据类型")
+		}
+		switch q.dataType {
+		case "int16", "uint16", "int32", "uint32", "float32":
+		default:
+			return modbusQuery{}, fmt.Errorf("不支持的 Modbus 数据类型: %s", parts[2])
+		}
+	}
+
+	params, err := url.ParseQuery(rawParams)
+	if err != nil {
+		return modbusQuery{}, fmt.Errorf("解析 Modbus 查询参数失败: %w", err)
+	}
+	for key := range params {
+		value := params.Get(key)
+		switch key {
+		case "byte_order":
+			if value != "big" && value != "little" {
+				return modbusQuery{}, fmt.Errorf("byte_order 只能为 big 或 little，实际为 %s", value)
+			}
+			q.byteOrder = value
+		case "word_order":
+			if value != "big" && value != "little" {
+				return modbusQuery{}, fmt.Errorf("word_order 只能为 big 或 little，实际为 %s", value)
+			}
+			q.wordOrder = value
+		case "scale":
+			q.scale, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return modbusQuery{}, fmt.Errorf("scale 无效: %s", value)
+			}
+		case "unit_id":
+			id, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				return modbusQuery{}, fmt.Errorf("unit_id 无效: %s", value)
+			}
+			unitID := byte(id)
+			q.unitID = &unitID
+		default:
+			return modbusQuery{}, fmt.Errorf("不支持的 Modbus 查询参数: %s", key)
+		}
+	}
+	return q, nil
+}
+
+// decodeRegisters 按字节序与字序将寄存器内容还原为大端字节后解码。
+func decodeRegisters(data []byte, q modbusQuery) (float64, error) {
+	size := 2
+	if q.dataType == "int32" || q.dataType == "uint32" || q.dataType == "float32" {
+		size = 4
+	}
+	if len(data) < size {
+		return 0, fmt.Errorf("Modbus 寄存器数据不足: 需要 %d 字节，实际 %d 字节", size, len(data))
+	}
+
+	buf := make([]byte, size)
+	copy(buf, data[:size])
+	if q.byteOrder == "little" {
+		for i := 0; i+1 < size; i += 2 {
+			buf[i], buf[i+1] = buf[i+1], buf[i]
+		}
+	}
+	if size == 4 && q.wordOrder == "little" {
+		buf[0], buf[1], buf[2], buf[3] = buf[2], buf[3], buf[0], buf[1]
+	}
+
+	switch q.dataType {
+	case "int16":
+		return float64(int16(binary.BigEndian.Uint16(buf))), nil
+	case "uint16":
+		return float64(binary.BigEndian.Uint16(buf)), nil
+	case "int32":
+		return float64(int32(binary.BigEndian.Uint32(buf))), nil
+	case "uint32":
+		return float64(binary.BigEndian.Uint32(buf)), nil
+	case "float32":
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf))), nil
+	}
+	return 0, fmt.Errorf("不支持的 Modbus 数据类型: %s", q.dataType)
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
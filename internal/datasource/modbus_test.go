@@ -0,0 +1,181 @@
+package datasource
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// testModbusServer 是仅支持读功能码的进程内 Modbus TCP 从站。
+type testModbusServer struct {
+	listener net.Listener
+	holding  map[uint16]uint16
+	input    map[uint16]uint16
+	coils    map[uint16]bool
+	unitID   byte
+	override atomic.Pointer[[]byte] // 非空时原样返回该 PDU，用于模拟异常的从站
+}
+
+func newTestModbusServer(t *testing.T) *testModbusServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	s := &testModbusServer{
+		listener: listener,
+		holding:  make(map[uint16]uint16),
+		input:    make(map[uint16]uint16),
+		coils:    make(map[uint16]bool),
+		unitID:   1,
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testModbusServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		req := make([]byte, 12)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		unit, function := req[6], req[7]
+		address := binary.BigEndian.Uint16(req[8:])
+		quantity := binary.BigEndian.Uint16(req[10:])
+
+		var pdu []byte
+		switch {
+		case unit != s.unitID:
+			pdu = []byte{function | 0x80, 0x0B}
+		case function == modbusReadHoldingRegisters || function == modbusReadInputRegisters:
+			registers := s.holding
+			if function == modbusReadInputRegisters {
+				registers = s.input
+			}
+			pdu = []byte{function, byte(quantity * 2)}
+			for i := uint16(0); i < quantity; i++ {
+				value, ok := registers[address+i]
+				if !ok {
+					pdu = []byte{function | 0x80, 0x02}
+					break
+				}
+				pdu = binary.BigEndian.AppendUint16(pdu, value)
+			}
+		case function == modbusReadCoils:
+			var bits byte
+			if s.coils[address] {
+				bits = 1
+			}
+			pdu = []byte{function, 1, bits}
+		default:
+			pdu = []byte{function | 0x80, 0x01}
+		}
+		if override := s.override.Load(); override != nil {
+			pdu = *override
+		}
+
+		resp := make([]byte, 7, 7+len(pdu))
+		copy(resp[0:2], req[0:2])
+		binary.BigEndian.PutUint16(resp[4:], uint16(len(pdu)+1))
+		resp[6] = unit
+		resp = append(resp, pdu...)
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *testModbusServer) config(t *testing.T) config.ModbusConfig {
+	t.Helper()
+	host, portStr, _ := net.SplitHostPort(s.listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return config.ModbusConfig{Host: host, Port: port, UnitID: int(s.unitID)}
+}
+
+func TestModbusClientDecodesRegisters(t *testing.T) {
+	server := newTestModbusServer(t)
+	bits := math.Float32bits(230.5)
+	server.holding[100] = uint16(bits >> 16)
+	server.holding[101] = uint16(bits)
+	// 低字在前的 int32：-100000
+	raw := uint32(0xFFFE7960)
+	server.holding[200] = uint16(raw)
+	server.holding[201] = uint16(raw >> 16)
+	server.input[30] = 0xFF9C // int16 -100
+	server.input[31] = 0x3412 // 字节交换后为 0x1234
+	server.coils[8] = true
+
+	client, err := NewModbusClient(server.config(t))
+	if err != nil {
+		t.Fatalf("创建 Modbus 客户端失败: %v", err)
+	}
+	defer client.Close()
+
+	cases := []struct {
+		query string
+		want  float64
+	}{
+		{"holding:100:float32", 230.5},
+		{"holding:200:int32?word_order=little", -100000},
+		{"input:30:int16?scale=0.1", -10},
+		{"input:30", 65436},
+		{"input:31:uint16?byte_order=little", 0x1234},
+		{"coil:8", 1},
+	}
+	for _, tc := range cases {
+		got, err := client.QueryScalar(context.Background(), tc.query)
+		if err != nil {
+			t.Fatalf("查询 %s 失败: %v", tc.query, err)
+		}
+		if math.Abs(got-tc.want) > 1e-6 {
+			t.Fatalf("查询 %s 期望 %v，实际 %v", tc.query, tc.want, got)
+		}
+	}
+}
+
+func TestModbusClientErrors(t *testing.T) {
+	server := newTestModbusServer(t)
+	client, err := NewModbusClient(server.config(t))
+	if err != nil {
+		t.Fatalf("创建 Modbus 客户端失败: %v", err)
+	}
+	defer client.Close()
+
+	_, err = client.QueryScalar(context.Background(), "holding:999")
+	var exc *ModbusException
+	if !errors.As(err, &exc) || exc.Code != 0x02 {
+		t.Fatalf("读取不存在的寄存器应返回非法地址异常，实际 %v", err)
+	}
+
+	for _, query := range []string{"holding", "flash:1", "holding:1:float64", "coil:1:int16", "holding:1?foo=bar"} {
+		if _, err := client.QueryScalar(context.Background(), query); err == nil {
+			t.Fatalf("非法查询 %q 应当返回错误", query)
+		}
+	}
+
+	// MBAP 长度为 2 的响应只有功能码，不能越界访问
+	for _, pdu := range [][]byte{{modbusReadHoldingRegisters}, {modbusReadHoldingRegisters | 0x80}} {
+		server.override.Store(&pdu)
+		if _, err := client.QueryScalar(context.Background(), "holding:1"); err == nil {
+			t.Fatalf("过短的响应 % X 应当返回错误", pdu)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// MongoDBClient 封装 MongoDB 只读查询能力：count、find 与聚合管道。
+type MongoDBClient struct {
+	client   *mongo.Client
+	database string
+	maxTime  time.Duration
+}
+
+// mongoQuery 描述 MetricSpec.Query 中以 JSON 给出的一次查询。
+type mongoQuery struct {
+	database   string
+	collection string
+	operation  string // count/find/aggregate
+	filter     bson.D
+	projection bson.D
+	sort       bson.D
+	pipeline   bson.A
+}
+
+// NewMongoDBClient 基于配置创建 MongoDB 客户端并验证连通性。
+func NewMongoDBClient(cfg config.MongoDBConfig) (*MongoDBClient, error) {
+	if cfg.URI == "" {
+		return nil, errors.New("MongoDB 配置缺少 uri")
+	}
+	connectTimeout := 10 * time.Second
+	if cfg.ConnectTimeout != "" {
+		parsed, err := time.ParseDuration(cfg.ConnectTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("解析 MongoDB connect_timeout 失败: %w", err)
+		}
+		connectTimeout = parsed
+	}
+	maxTimeMS := cfg.MaxTimeMS
+	if maxTimeMS == 0 {
+		maxTimeMS = 10000
+	}
+
+	opts := options.Client().
+		ApplyURI(cfg.URI).
+		SetConnectTimeout(connectTimeout).
+		SetServerSelectionTimeout(connectTimeout).
+		SetAppName("sql2metrics")
+	if cfg.Username != "" {
+		opts.SetAuth(options.Credential{
+			Username:   cfg.Username,
+			Password:   cfg.Password,
+			AuthSource: cfg.AuthSource,
+		})
+	}
+	if cfg.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(cfg.ReadPreference)
+		if err != nil {
+			return nil, fmt.Errorf("MongoDB read_preference 无效: %w", err)
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, fmt.Errorf("MongoDB read_preference 无效: %w", err)
+		}
+		opts.SetReadPreference(rp)
+	}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(cfg.TLS, "")
+		if err != nil {
+			return nil, fmt.Errorf("MongoDB TLS 配置无效: %w", err)
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("MongoDB 连接参数无效: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("连接 MongoDB 失败: %w", err)
+	}
+	c := &MongoDBClient{
+		client:   client,
+		database: cfg.Database,
+		maxTime:  time.Duration(maxTimeMS) * time.Millisecond,
+	}
+	if err := c.Ping(ctx); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, err
+	}
+	return c, nil
+}
+
+// QueryScalar 执行查询并返回数值。query 为 JSON（支持 Extended JSON，如 {"$date": ...}），例如：
+//   - {"collection": "devices", "count": {"status": "online"}}
+//   - {"collection": "devices", "find": {"sn": "A1"}, "projection": {"power": 1}, "sort": {"ts": -1}}
+//   - {"collection": "devices", "aggregate": [{"$match": {...}}, {"$group": {"_id": null, "total": {"$sum": "$power"}}}]}
+//
+// find 与 aggregate 取结果中的第一个文档，按 resultField 提取字段；resultField 为空时文档除 _id 外必须只有一个字段。
+func (c *MongoDBClient) QueryScalar(ctx context.Context, query, resultField string) (float64, error) {
+	q, err := parseMongoQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	database := q.database
+	if database == "" {
+		database = c.database
+	}
+	if database == "" {
+		return 0, errors.New("MongoDB 查询未指定 database，且连接未配置默认 database")
+	}
+	coll := c.client.Database(database).Collection(q.collection)
+
+	switch q.operation {
+	case "count":
+		count, err := coll.CountDocuments(ctx, q.filter, options.Count().SetMaxTime(c.maxTime))
+		if err != nil {
+			return 0, fmt.Errorf("执行 MongoDB count 失败: %w", err)
+		}
+		return float64(count), nil
+	case "find":
+		opts := options.FindOne().SetMaxTime(c.maxTime)
+		if q.projection != nil {
+			opts.SetProjection(q.projection)
+		}
+		if q.sort != nil {
+			opts.SetSort(q.sort)
+		}
+		var doc bson.M
+		if err := coll.FindOne(ctx, q.filter, opts).Decode(&doc); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return 0, errors.New("MongoDB find 未匹配到文档")
+			}
+			return 0, fmt.Errorf("执行 MongoDB find 失败: %w", err)
+		}
+		return mongoDocumentValue(doc, resultField)
+	default:
+		cursor, err := coll.Aggregate(ctx, q.pipeline, options.Aggregate().SetMaxTime(c.maxTime))
+		if err != nil {
+			return 0, fmt.Errorf("执行 MongoDB 聚合失败: %w", err)
+		}
+		defer cursor.Close(ctx)
+		if !cursor.Next(ctx) {
+			if err := cursor.Err(); err != nil {
+				return 0, fmt.Errorf("读取 MongoDB 聚合结果失败: %w", err)
+			}
+			return 0, errors.New("MongoDB 聚合结果为空")
+		}
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return 0, fmt.Errorf("解析 MongoDB 聚合结果失败: %w", err)
+		}
+		return mongoDocumentValue(doc, resultField)
+	}
+}
+
+// Ping 验证与服务端的连接。
+func (c *MongoDBClient) Ping(ctx context.Context) error {
+	if err := c.client.Ping(ctx, nil); err != nil {
+		return fmt.Errorf("MongoDB 连接测试失败: %w", err)
+	}
+	return nil
+}
+
+// Close 断开连接。
+func (c *MongoDBClient) Close() error {
+	return c.client.Disconnect(context.Background())
+}
+
+func parseMongoQuery(query string) (mongoQuery, error) {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON([]byte(query), false, &doc); err != nil {
+		return mongoQuery{}, fmt.Errorf("MongoDB 查询必须为 JSON 对象: %w", err)
+	}
+
+	var q mongoQuery
+	for _, elem := range doc {
+		var ok bool
+		switch elem.Key {
+		case "database":
+			q.database, ok = elem.Value.(string)
+		case "collection":
+			q.collection, ok = elem.Value.(string)
+		case "count", "find":
+			if q.operation != "" {
+				return mongoQuery{}, errors.New("count、find、aggregate 只能指定其中一个")
+			}
+			q.operation = elem.Key
+			q.filter, ok = elem.Value.(bson.D)
+		case "aggregate":
+			if q.operation != "" {
+				return mongoQuery{}, errors.New("count、find、aggregate 只能指定其中一个")
+			}
+			q.operation = elem.Key
+			q.pipeline, ok = elem.Value.(bson.A)
+		case "projection":
+			q.projection, ok = elem.Value.(bson.D)
+		case "sort":
+			q.sort, ok = elem.Value.(bson.D)
+		default:
+			return mongoQuery{}, fmt.Errorf("不支持的 MongoDB 查询字段: %s", elem.Key)
+		}
+		if !ok {
+			return mongoQuery{}, fmt.Errorf("MongoDB 查询字段 %s 的类型无效", elem.Key)
+		}
+	}
+
+	if q.collection == "" {
+		return mongoQuery{}, errors.New("MongoDB 查询缺少 collection")
+	}
+	if q.operation == "" {
+		return mongoQuery{}, errors.New("MongoDB 查询需要指定 count、find 或 aggregate")
+	}
+	if (q.projection != nil || q.sort != nil) && q.operation != "find" {
+		return mongoQuery{}, errors.New("projection 与 sort 仅适用于 find")
+	}
+	for i, stage := range q.pipeline {
+		stageDoc, ok := stage.(bson.D)
+		if !ok || len(stageDoc) != 1 {
+			return mongoQuery{}, fmt.Errorf("聚合管道第 %d 个阶段格式无效", i+1)
+		}
+		// 采集只允许只读查询，禁止写入集合的阶段
+		if name := stageDoc[0].Key; name == "$out" || name == "$merge" {
+			return mongoQuery{}, fmt.Errorf("聚合管道不允许使用写入阶段 %s", name)
+		}
+	}
+	if q.filter == nil {
+		q.filter = bson.D{}
+	}
+	return q, nil
+}
+
+// mongoDocumentValue 将 BSON 文档转换为通用结构后按 resultField 提取数值。
+func mongoDocumentValue(doc bson.M, resultField string) (float64, error) {
+	if resultField == "" {
+		delete(doc, "_id")
+		if len(doc) != 1 {
+			return 0, fmt.Errorf("MongoDB 结果文档包含 %d 个字段，请通过 result_field 指定", len(doc))
+		}
+		for _, v := range doc {
+			return toFloat(normalizeBSON(v))
+		}
+	}
+	return extractJSONValue(normalizeBSON(doc), resultField)
+}
+
+// normalizeBSON 将 BSON 值转换为 extractJSONValue 可处理的类型。
+func normalizeBSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = normalizeBSON(item)
+		}
+		return m
+	case bson.D:
+		m := make(map[string]interface{}, len(v))
+		for _, elem := range v {
+			m[elem.Key] = normalizeBSON(elem.Value)
+		}
+		return m
+	case bson.A:
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			arr[i] = normalizeBSON(item)
+		}
+		return arr
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case primitive.DateTime:
+		// 时间按 Unix 秒数输出
+		return float64(v) / 1000
+	case bool:
+		if v {
+			return float64(1)
+		}
+		return float64(0)
+	}
+	return value
+}
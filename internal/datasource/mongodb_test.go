@@ -0,0 +1,81 @@
+package datasource
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseMongoQuery(t *testing.T) {
+	q, err := parseMongoQuery(`{"database": "ems", "collection": "devices", "find": {"updated": {"$gte": {"$date": "2024-01-01T00:00:00Z"}}}, "projection": {"power": 1}, "sort": {"updated": -1}}`)
+	if err != nil {
+		t.Fatalf("解析 find 查询失败: %v", err)
+	}
+	if q.operation != "find" || q.database != "ems" || q.collection != "devices" {
+		t.Fatalf("解析结果不符合预期: %+v", q)
+	}
+	cond, ok := q.filter[0].Value.(bson.D)
+	if !ok {
+		t.Fatalf("嵌套条件应当解析为 bson.D，实际 %T", q.filter[0].Value)
+	}
+	if _, ok := cond[0].Value.(primitive.DateTime); !ok {
+		t.Fatalf("Extended JSON 日期应当解析为 DateTime，实际 %T", cond[0].Value)
+	}
+
+	q, err = parseMongoQuery(`{"collection": "devices", "count": {}}`)
+	if err != nil || q.operation != "count" {
+		t.Fatalf("解析 count 查询失败: %+v, %v", q, err)
+	}
+
+	invalid := []string{
+		`not json`,
+		`{"count": {}}`,
+		`{"collection": "devices"}`,
+		`{"collection": "devices", "count": {}, "find": {}}`,
+		`{"collection": "devices", "aggregate": [{"$match": {}}, {"$out": "copy"}]}`,
+		`{"collection": "devices", "aggregate": [{"$merge": {"into": "copy"}}]}`,
+		`{"collection": "devices", "aggregate": {"$match": {}}}`,
+		`{"collection": "devices", "count": {}, "sort": {"ts": -1}}`,
+		`{"collection": "devices", "find": {}, "limit": 1}`,
+	}
+	for _, query := range invalid {
+		if _, err := parseMongoQuery(query); err == nil {
+			t.Errorf("非法查询 %s 应当返回错误", query)
+		}
+	}
+}
+
+func TestMongoDocumentValue(t *testing.T) {
+	total, _ := primitive.ParseDecimal128("1234.5")
+	doc := bson.M{
+		"_id":     nil,
+		"total":   total,
+		"devices": bson.A{bson.M{"power": int32(7)}, bson.M{"power": int64(9)}},
+		"online":  true,
+		"updated": primitive.DateTime(1700000000000),
+	}
+	cases := []struct {
+		field string
+		want  float64
+	}{
+		{"total", 1234.5},
+		{"devices[1].power", 9},
+		{"online", 1},
+		{"updated", 1700000000},
+	}
+	for _, tc := range cases {
+		got, err := mongoDocumentValue(doc, tc.field)
+		if err != nil || got != tc.want {
+			t.Fatalf("提取 %s 期望 %v，实际 %v（错误: %v）", tc.field, tc.want, got, err)
+		}
+	}
+
+	if _, err := mongoDocumentValue(bson.M{"_id": 1, "a": 1, "b": 2}, ""); err == nil {
+		t.Fatalf("多字段文档未指定 result_field 时应当返回错误")
+	}
+	got, err := mongoDocumentValue(bson.M{"_id": nil, "count": int32(42)}, "")
+	if err != nil || got != 42 {
+		t.Fatalf("单字段文档期望 42，实际 %v（错误: %v）", got, err)
+	}
+}
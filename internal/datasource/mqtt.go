@@ -0,0 +1,305 @@
+package datasource
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// MQTTListener 在收到订阅消息时被调用。
+type MQTTListener func(topic string, payload []byte)
+
+// MQTTClient 订阅 MQTT 主题并按主题过滤器缓存最新的消息。
+// 缓存只为已订阅的过滤器保留一条消息，通配符过滤器匹配再多的主题也不会增长，取消订阅时一并删除。
+type MQTTClient struct {
+	client  mqtt.Client
+	qos     byte
+	timeout time.Duration
+
+	mu       sync.Mutex
+	filters  map[string]struct{}
+	latest   map[string]mqttMessage // 按过滤器索引，值为匹配该过滤器的最近一条消息
+	updated  chan struct{}
+	listener MQTTListener
+}
+
+type mqttMessage struct {
+	payload  []byte
+	received time.Time
+}
+
+// NewMQTTClient 基于配置连接 MQTT Broker。
+func NewMQTTClient(cfg config.MQTTConfig) (*MQTTClient, error) {
+	if cfg.Broker == "" {
+		return nil, errors.New("MQTT 配置缺少 broker")
+	}
+	timeout := 10 * time.Second
+	if cfg.ConnectTimeout != "" {
+		parsed, err := time.ParseDuration(cfg.ConnectTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("解析 MQTT connect_timeout 失败: %w", err)
+		}
+		timeout = parsed
+	}
+	keepAlive := 30 * time.Second
+	if cfg.KeepAlive != "" {
+		parsed, err := time.ParseDuration(cfg.KeepAlive)
+		if err != nil {
+			return nil, fmt.Errorf("解析 MQTT keep_alive 失败: %w", err)
+		}
+		keepAlive = parsed
+	}
+
+	c := &MQTTClient{
+		qos:     byte(cfg.QoS),
+		timeout: timeout,
+		filters: make(map[string]struct{}),
+		latest:  make(map[string]mqttMessage),
+		updated: make(chan struct{}),
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = defaultMQTTClientID()
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetConnectTimeout(timeout).
+		SetKeepAlive(keepAlive).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(c.resubscribe).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT 连接 %s 断开，等待自动重连: %v", cfg.Broker, err)
+		})
+	if cfg.TLS.Enabled || strings.HasPrefix(cfg.Broker, "ssl://") || strings.HasPrefix(cfg.Broker, "tls://") || strings.HasPrefix(cfg.Broker, "wss://") {
+		tlsConfig, err := newTLSConfig(cfg.TLS, "")
+		if err != nil {
+			return nil, fmt.Errorf("MQTT TLS 配置无效: %w", err)
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	c.client = mqtt.NewClient(opts)
+	token := c.client.Connect()
+	if !token.WaitTimeout(timeout) {
+		c.client.Disconnect(0)
+		return nil, fmt.Errorf("连接 MQTT Broker %s 超时", cfg.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("连接 MQTT Broker %s 失败: %w", cfg.Broker, err)
+	}
+	return c, nil
+}
+
+// SetListener 设置收到消息时的回调，用于按消息到达即时更新指标。
+func (c *MQTTClient) SetListener(listener MQTTListener) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listener = listener
+}
+
+// SetSubscriptions 将订阅调整为给定的主题过滤器集合。
+func (c *MQTTClient) SetSubscriptions(filters []string) error {
+	wanted := make(map[string]struct{}, len(filters))
+	for _, f := range filters {
+		wanted[f] = struct{}{}
+	}
+
+	c.mu.Lock()
+	var stale []string
+	for f := range c.filters {
+		if _, ok := wanted[f]; !ok {
+			stale = append(stale, f)
+			delete(c.filters, f)
+			delete(c.latest, f)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(stale) > 0 {
+		token := c.client.Unsubscribe(stale...)
+		if token.WaitTimeout(c.timeout) && token.Error() != nil {
+			log.Printf("取消 MQTT 订阅 %v 失败: %v", stale, token.Error())
+		}
+	}
+	for _, f := range filters {
+		if err := c.subscribe(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryScalar 返回与主题过滤器匹配的最新消息中的数值。
+// 尚未订阅的过滤器会先订阅，并等待首条消息（例如保留消息）到达或 ctx 结束。
+// maxAge 大于 0 时，早于该时长收到的消息视为过期，从缓存中删除并等待新消息。
+func (c *MQTTClient) QueryScalar(ctx context.Context, filter, resultField string, maxAge time.Duration) (float64, error) {
+	if err := c.subscribe(filter); err != nil {
+		return 0, err
+	}
+	for {
+		c.mu.Lock()
+		msg, ok := c.latest[filter]
+		if ok && maxAge > 0 && time.Since(msg.received) > maxAge {
+			delete(c.latest, filter)
+			ok = false
+		}
+		updated := c.updated
+		c.mu.Unlock()
+		if ok {
+			return ParseMQTTPayload(msg.payload, resultField)
+		}
+
+		select {
+		case <-ctx.Done():
+			if maxAge > 0 {
+				return 0, fmt.Errorf("最近 %s 内没有收到主题 %s 的消息: %w", maxAge, filter, ctx.Err())
+			}
+			return 0, fmt.Errorf("尚未收到主题 %s 的消息: %w", filter, ctx.Err())
+		case <-updated:
+		}
+	}
+}
+
+// Ping 检查与 Broker 的连接状态。
+func (c *MQTTClient) Ping(ctx context.Context) error {
+	if !c.client.IsConnectionOpen() {
+		return errors.New("MQTT 连接未建立")
+	}
+	return nil
+}
+
+// Close 断开与 Broker 的连接。
+func (c *MQTTClient) Close() error {
+	c.client.Disconnect(250)
+	return nil
+}
+
+func (c *MQTTClient) subscribe(filter string) error {
+	c.mu.Lock()
+	if _, ok := c.filters[filter]; ok {
+		c.mu.Unlock()
+		return nil
+	}
+	c.filters[filter] = struct{}{}
+	c.mu.Unlock()
+
+	token := c.client.Subscribe(filter, c.qos, c.onMessage)
+	if !token.WaitTimeout(c.timeout) {
+		c.forget(filter)
+		return fmt.Errorf("订阅 MQTT 主题 %s 超时", filter)
+	}
+	if err := token.Error(); err != nil {
+		c.forget(filter)
+		return fmt.Errorf("订阅 MQTT 主题 %s 失败: %w", filter, err)
+	}
+	return nil
+}
+
+func (c *MQTTClient) forget(filter string) {
+	c.mu.Lock()
+	delete(c.filters, filter)
+	delete(c.latest, filter)
+	c.mu.Unlock()
+}
+
+// resubscribe 在（重新）连接后恢复订阅，CleanSession 下 Broker 不会保留订阅。
+func (c *MQTTClient) resubscribe(client mqtt.Client) {
+	c.mu.Lock()
+	filters := make(map[string]byte, len(c.filters))
+	for f := range c.filters {
+		filters[f] = c.qos
+	}
+	c.mu.Unlock()
+	if len(filters) == 0 {
+		return
+	}
+	token := client.SubscribeMultiple(filters, c.onMessage)
+	if token.WaitTimeout(c.timeout) && token.Error() != nil {
+		log.Printf("恢复 MQTT 订阅失败: %v", token.Error())
+	}
+}
+
+func (c *MQTTClient) onMessage(_ mqtt.Client, msg mqtt.Message) {
+	payload := append([]byte(nil), msg.Payload()...)
+	received := mqttMessage{payload: payload, received: time.Now()}
+	c.mu.Lock()
+	for filter := range c.filters {
+		if TopicMatches(filter, msg.Topic()) {
+			c.latest[filter] = received
+		}
+	}
+	close(c.updated)
+	c.updated = make(chan struct{})
+	listener := c.listener
+	c.mu.Unlock()
+
+	if listener != nil {
+		listener(msg.Topic(), payload)
+	}
+}
+
+// ParseMQTTPayload 从消息负载中提取数值：JSON 负载按 result_field 路径提取，纯文本负载直接解析为数字。
+func ParseMQTTPayload(payload []byte, resultField string) (float64, error) {
+	var data interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		if resultField != "" {
+			return 0, fmt.Errorf("MQTT 消息不是合法的 JSON: %w", err)
+		}
+		value, parseErr := strconv.ParseFloat(strings.TrimSpace(string(payload)), 64)
+		if parseErr != nil {
+			return 0, fmt.Errorf("MQTT 消息 %q 无法转换为数字", string(payload))
+		}
+		return value, nil
+	}
+	if b, ok := data.(bool); ok && resultField == "" {
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return extractJSONValue(data, resultField)
+}
+
+// TopicMatches 判断主题是否匹配过滤器，支持 + 单层与 # 多层通配符。
+func TopicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+func defaultMQTTClientID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("sql2metrics-%s-%s", host, hex.EncodeToString(suffix))
+}
@@ -0,0 +1,269 @@
+package datasource
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// testMQTTBroker 是仅支持 MQTT 3.1.1 QoS 0 订阅与发布的进程内 Broker。
+type testMQTTBroker struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	subs     map[net.Conn][]string
+	retained map[string][]byte
+}
+
+func newTestMQTTBroker(t *testing.T) *testMQTTBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	b := &testMQTTBroker{
+		listener: listener,
+		subs:     make(map[net.Conn][]string),
+		retained: make(map[string][]byte),
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.handle(conn)
+		}
+	}()
+	return b
+}
+
+func (b *testMQTTBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// publish 向匹配的订阅者转发消息，retain 为真时同时保存为保留消息。
+func (b *testMQTTBroker) publish(topic string, payload []byte, retain bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if retain {
+		b.retained[topic] = payload
+	}
+	for conn, filters := range b.subs {
+		for _, f := range filters {
+			if TopicMatches(f, topic) {
+				_, _ = conn.Write(encodeMQTTPublish(topic, payload))
+				break
+			}
+		}
+	}
+}
+
+func (b *testMQTTBroker) handle(conn net.Conn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, conn)
+		b.mu.Unlock()
+		_ = conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		b.mu.Lock()
+		switch header >> 4 {
+		case 1: // CONNECT
+			_, _ = conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 8: // SUBSCRIBE
+			packetID := body[:2]
+			ack := []byte{0x90, 0, packetID[0], packetID[1]}
+			var filters []string
+			for rest := body[2:]; len(rest) > 2; {
+				n := int(binary.BigEndian.Uint16(rest))
+				filters = append(filters, string(rest[2:2+n]))
+				rest = rest[3+n:]
+				ack = append(ack, 0x00)
+			}
+			ack[1] = byte(len(ack) - 2)
+			b.subs[conn] = append(b.subs[conn], filters...)
+			_, _ = conn.Write(ack)
+			for topic, payload := range b.retained {
+				for _, f := range filters {
+					if TopicMatches(f, topic) {
+						_, _ = conn.Write(encodeMQTTPublish(topic, payload))
+						break
+					}
+				}
+			}
+		case 10: // UNSUBSCRIBE
+			_, _ = conn.Write([]byte{0xB0, 0x02, body[0], body[1]})
+		case 12: // PINGREQ
+			_, _ = conn.Write([]byte{0xD0, 0x00})
+		case 14: // DISCONNECT
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+	}
+}
+
+func encodeMQTTPublish(topic string, payload []byte) []byte {
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(topic)))
+	body = append(body, topic...)
+	body = append(body, payload...)
+	packet := []byte{0x30}
+	packet = binary.AppendUvarint(packet, uint64(len(body)))
+	return append(packet, body...)
+}
+
+func TestMQTTClientCachesLatestPayload(t *testing.T) {
+	broker := newTestMQTTBroker(t)
+	client, err := NewMQTTClient(config.MQTTConfig{Broker: broker.url(), ClientID: "test"})
+	if err != nil {
+		t.Fatalf("连接 Broker 失败: %v", err)
+	}
+	defer client.Close()
+
+	received := make(chan string, 4)
+	client.SetListener(func(topic string, _ []byte) { received <- topic })
+	if err := client.SetSubscriptions([]string{"site/+/meter"}); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	broker.publish("site/a/meter", []byte(`{"data":{"power":12.5}}`), false)
+	select {
+	case topic := <-received:
+		if topic != "site/a/meter" {
+			t.Fatalf("期望收到 site/a/meter，实际 %s", topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("等待消息超时")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	value, err := client.QueryScalar(ctx, "site/+/meter", "data.power", 0)
+	if err != nil {
+		t.Fatalf("读取缓存消息失败: %v", err)
+	}
+	if value != 12.5 {
+		t.Fatalf("期望 12.5，实际 %v", value)
+	}
+
+	// 未订阅的主题会先订阅并等待保留消息
+	broker.publish("site/b/temperature", []byte("21.5"), true)
+	value, err = client.QueryScalar(ctx, "site/b/#", "", 0)
+	if err != nil {
+		t.Fatalf("读取保留消息失败: %v", err)
+	}
+	if value != 21.5 {
+		t.Fatalf("期望 21.5，实际 %v", value)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelShort()
+	if _, err := client.QueryScalar(short, "site/c/none", "", 0); err == nil {
+		t.Fatalf("没有消息的主题应当在超时后返回错误")
+	}
+}
+
+func TestMQTTClientPrunesCache(t *testing.T) {
+	broker := newTestMQTTBroker(t)
+	client, err := NewMQTTClient(config.MQTTConfig{Broker: broker.url(), ClientID: "test"})
+	if err != nil {
+		t.Fatalf("连接 Broker 失败: %v", err)
+	}
+	defer client.Close()
+
+	received := make(chan string, 16)
+	client.SetListener(func(topic string, _ []byte) { received <- topic })
+	if err := client.SetSubscriptions([]string{"site/#"}); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	for _, topic := range []string{"site/a/meter", "site/b/meter", "site/c/meter"} {
+		broker.publish(topic, []byte("1"), false)
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("等待消息超时")
+		}
+	}
+	client.mu.Lock()
+	cached := len(client.latest)
+	client.mu.Unlock()
+	if cached != 1 {
+		t.Fatalf("通配符过滤器匹配多个主题时缓存应只保留一条，实际 %d 条", cached)
+	}
+
+	// 过期的消息从缓存中删除，查询等待新消息直到超时
+	client.mu.Lock()
+	msg := client.latest["site/#"]
+	msg.received = time.Now().Add(-time.Hour)
+	client.latest["site/#"] = msg
+	client.mu.Unlock()
+	short, cancelShort := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelShort()
+	if _, err := client.QueryScalar(short, "site/#", "", time.Minute); err == nil {
+		t.Fatalf("超过 max_age 的消息应当视为过期")
+	}
+	client.mu.Lock()
+	_, stale := client.latest["site/#"]
+	client.mu.Unlock()
+	if stale {
+		t.Fatalf("过期的消息应当从缓存中删除")
+	}
+
+	broker.publish("site/a/meter", []byte("2"), false)
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("等待消息超时")
+	}
+	if err := client.SetSubscriptions(nil); err != nil {
+		t.Fatalf("取消订阅失败: %v", err)
+	}
+	client.mu.Lock()
+	cached = len(client.latest)
+	client.mu.Unlock()
+	if cached != 0 {
+		t.Fatalf("取消订阅后应当清除对应的缓存，实际 %d 条", cached)
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"site/+/meter", "site/a/meter", true},
+		{"site/+/meter", "site/a/b/meter", false},
+		{"site/#", "site/a/b", true},
+		{"site/#", "site", true},
+		{"site/a", "site/a/b", false},
+		{"+/a", "site/a", true},
+	}
+	for _, tc := range cases {
+		if got := TopicMatches(tc.filter, tc.topic); got != tc.want {
+			t.Errorf("TopicMatches(%q, %q) 期望 %v，实际 %v", tc.filter, tc.topic, tc.want, got)
+		}
+	}
+}
@@ -4,16 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 
 	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/sqlguard"
 )
 
 // MySQLClient 封装 MySQL 查询能力。
 type MySQLClient struct {
-	db *sql.DB
+	db     *sql.DB
+	tunnel *SSHTunnel
+	guard  config.QueryGuardConfig
 }
 
 // NewMySQLClient 基于配置创建连接池。
@@ -22,40 +27,143 @@ func NewMySQLClient(cfg config.MySQLConfig) (*MySQLClient, error) {
 	if err != nil {
 		return nil, err
 	}
-	db, err := sql.Open("mysql", dsn)
+	driverCfg, err := mysql.ParseDSN(dsn)
 	if err != nil {
+		return nil, fmt.Errorf("解析 MySQL DSN 失败: %w", err)
+	}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(cfg.TLS, cfg.Host)
+		if err != nil {
+			return nil, fmt.Errorf("MySQL TLS 配置无效: %w", err)
+		}
+		driverCfg.TLS = tlsConfig
+	}
+	var tunnel *SSHTunnel
+	if cfg.SSHTunnel != nil {
+		tunnel, err = NewSSHTunnel(*cfg.SSHTunnel, driverCfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("建立 MySQL SSH 隧道失败: %w", err)
+		}
+		driverCfg.Addr = tunnel.LocalAddr()
+	}
+	connector, err := mysql.NewConnector(driverCfg)
+	if err != nil {
+		closeTunnel(tunnel)
 		return nil, fmt.Errorf("初始化 MySQL 连接失败: %w", err)
 	}
-	// 采用保守连接池设置，避免穿透数据库。
-	db.SetConnMaxLifetime(30 * time.Minute)
-	db.SetMaxIdleConns(2)
-	db.SetMaxOpenConns(5)
-	
+	db := sql.OpenDB(connector)
+	if err := applyPoolSettings(db, cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime); err != nil {
+		_ = db.Close()
+		closeTunnel(tunnel)
+		return nil, err
+	}
+
 	// 设置连接超时上下文，避免启动时长时间阻塞
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
 	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		closeTunnel(tunnel)
 		return nil, fmt.Errorf("MySQL 连接验证失败: %w", err)
 	}
-	return &MySQLClient{db: db}, nil
+	return &MySQLClient{db: db, tunnel: tunnel, guard: cfg.QueryGuard}, nil
 }
 
 // QueryScalar 执行聚合查询，返回单一数值结果。
+// 查询在只读事务中执行，且必须通过只读语句校验与连接配置的成本限制。
 func (c *MySQLClient) QueryScalar(ctx context.Context, sqlStmt string) (float64, error) {
-	var value sql.NullFloat64
-	if err := c.db.QueryRowContext(ctx, sqlStmt).Scan(&value); err != nil {
-		return 0, fmt.Errorf("执行 MySQL 查询失败: %w", err)
+	if err := sqlguard.CheckReadOnly(sqlStmt); err != nil {
+		return 0, fmt.Errorf("MySQL 查询被拒绝: %w", err)
+	}
+	return queryScalarInTx(ctx, c.db, "MySQL", sqlStmt, &sql.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		return checkQueryCost(ctx, tx, sqlStmt, c.guard)
+	})
+}
+
+// QueryWatermark 执行增量查询：watermark 作为绑定参数，返回首行第一列的增量与第二列的新水位。
+func (c *MySQLClient) QueryWatermark(ctx context.Context, sqlStmt string, watermark Watermark) (float64, Watermark, error) {
+	if err := sqlguard.CheckReadOnly(sqlStmt); err != nil {
+		return 0, Watermark{}, fmt.Errorf("MySQL 查询被拒绝: %w", err)
+	}
+	return queryWatermarkInTx(ctx, c.db, "MySQL", sqlStmt, watermark, &sql.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		return checkQueryCost(ctx, tx, sqlStmt, c.guard, watermark.arg())
+	})
+}
+
+// CheckQueryCost 校验查询是否只读，并通过 EXPLAIN 检查是否超出连接的成本限制，args 为语句的绑定参数。
+func (c *MySQLClient) CheckQueryCost(ctx context.Context, sqlStmt string, args ...any) error {
+	if err := sqlguard.CheckReadOnly(sqlStmt); err != nil {
+		return fmt.Errorf("MySQL 查询被拒绝: %w", err)
 	}
-	if !value.Valid {
-		return 0, fmt.Errorf("MySQL 查询未返回有效结果")
+	if !c.guard.Enabled() {
+		return nil
 	}
-	return value.Float64, nil
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("开启 MySQL 只读事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	return checkQueryCost(ctx, tx, sqlStmt, c.guard, args...)
+}
+
+// checkQueryCost 执行 EXPLAIN 并按扫描方式与估算行数判断是否放行。
+func checkQueryCost(ctx context.Context, tx *sql.Tx, sqlStmt string, guard config.QueryGuardConfig, args ...any) error {
+	if !guard.Enabled() || sqlguard.IsShowStatement(sqlStmt) {
+		return nil
+	}
+	rows, err := tx.QueryContext(ctx, "EXPLAIN "+sqlStmt, args...)
+	if err != nil {
+		return fmt.Errorf("执行 EXPLAIN 失败: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("读取 EXPLAIN 结果失败: %w", err)
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("读取 EXPLAIN 结果失败: %w", err)
+		}
+		plan := make(map[string]string, len(columns))
+		for i, col := range columns {
+			plan[strings.ToLower(col)] = values[i].String
+		}
+
+		table := plan["table"]
+		// 派生表、UNION 结果等临时表的扫描由其来源表决定，这里不重复计算
+		if table == "" || strings.HasPrefix(table, "<") {
+			continue
+		}
+		if guard.DenyFullScan && strings.EqualFold(plan["type"], "ALL") {
+			return fmt.Errorf("查询对表 %s 执行全表扫描，已被连接的 query_guard 拒绝", table)
+		}
+		if guard.MaxEstimatedRows > 0 && plan["rows"] != "" {
+			estimated, err := strconv.ParseInt(plan["rows"], 10, 64)
+			if err == nil && estimated > guard.MaxEstimatedRows {
+				return fmt.Errorf("查询对表 %s 估算扫描 %d 行，超过上限 %d", table, estimated, guard.MaxEstimatedRows)
+			}
+		}
+	}
+	return rows.Err()
 }
 
 // Close 收回底层资源。
 func (c *MySQLClient) Close() error {
-	return c.db.Close()
+	err := c.db.Close()
+	closeTunnel(c.tunnel)
+	return err
+}
+
+// Stats 返回连接池统计信息。
+func (c *MySQLClient) Stats() sql.DBStats {
+	return c.db.Stats()
 }
 
 // Ping 测试数据库连接。
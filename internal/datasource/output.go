@@ -0,0 +1,139 @@
+package datasource
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// ParseOutput 按解析配置从文件内容或命令输出中提取数值。
+// json 格式按 resultField 路径提取；csv 格式中 resultField 为列名（或无表头时从 0 开始的列序号）。
+func ParseOutput(data []byte, parse config.OutputParseConfig, resultField string) (float64, error) {
+	switch parse.Format {
+	case "json":
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			// 错误中不带内容片段，避免文件或命令输出经由错误信息泄露
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return 0, fmt.Errorf("解析 JSON 失败：第 %d 字节处语法错误", syntaxErr.Offset)
+			}
+			return 0, errors.New("解析 JSON 失败")
+		}
+		return extractJSONValue(doc, resultField)
+	case "csv":
+		return parseCSVValue(data, parse, resultField)
+	default:
+		return parseTextValue(data, parse.Regex)
+	}
+}
+
+func parseTextValue(data []byte, pattern string) (float64, error) {
+	text := strings.TrimSpace(string(data))
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return 0, fmt.Errorf("regex 无效: %w", err)
+		}
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			return 0, fmt.Errorf("输出中没有匹配 %s 的内容", pattern)
+		}
+		text = match[len(match)-1]
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, errors.New("内容无法解析为数值")
+	}
+	return value, nil
+}
+
+func parseCSVValue(data []byte, parse config.OutputParseConfig, column string) (float64, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if parse.Delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(parse.Delimiter)
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return 0, fmt.Errorf("解析 CSV 失败：第 %d 行第 %d 列: %w", parseErr.Line, parseErr.Column, parseErr.Err)
+		}
+		return 0, fmt.Errorf("解析 CSV 失败: %w", err)
+	}
+
+	var header []string
+	if !parse.NoHeader {
+		if len(records) == 0 {
+			return 0, errors.New("CSV 缺少表头")
+		}
+		header, records = records[0], records[1:]
+	}
+	if column == "" {
+		return 0, errors.New("csv 格式需要通过 result_field 指定列")
+	}
+	valueIndex, err := csvColumnIndex(header, column)
+	if err != nil {
+		return 0, err
+	}
+	matchIndex := make(map[int]string, len(parse.Match))
+	for name, want := range parse.Match {
+		idx, err := csvColumnIndex(header, name)
+		if err != nil {
+			return 0, err
+		}
+		matchIndex[idx] = want
+	}
+
+	var selected []string
+	for _, record := range records {
+		matched := true
+		for idx, want := range matchIndex {
+			if idx >= len(record) || record[idx] != want {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		selected = record
+		if parse.Row == "first" {
+			break
+		}
+	}
+	if selected == nil {
+		return 0, errors.New("CSV 中没有符合条件的数据行")
+	}
+	if valueIndex >= len(selected) {
+		return 0, fmt.Errorf("CSV 数据行缺少列 %s", column)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(selected[valueIndex]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("CSV 列 %s 的值无法解析为数值", column)
+	}
+	return value, nil
+}
+
+// csvColumnIndex 将列名或从 0 开始的序号解析为列下标。
+func csvColumnIndex(header []string, column string) (int, error) {
+	for i, name := range header {
+		if strings.TrimSpace(name) == column {
+			return i, nil
+		}
+	}
+	if idx, err := strconv.Atoi(column); err == nil && idx >= 0 {
+		return idx, nil
+	}
+	return 0, fmt.Errorf("CSV 表头中不存在列 %s", column)
+}
@@ -0,0 +1,385 @@
+package datasource
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// PluginProtocolVersion 是插件协议版本，Configure 时双方必须一致。
+//
+// 协议为基于标准输入输出的 JSON-RPC 2.0，每行一个 JSON 消息；插件只能向标准输出写协议消息，日志写到标准错误。
+// 方法：
+//   - Configure：params {"protocol_version": 1, "name": "<插件名>", "config": {...}}，result {"protocol_version": 1}
+//   - TestConnection：params {}，result 任意，返回 error 表示连接不可用
+//   - Query：params {"query": "...", "result_field": "...", "connection": "..."}，result {"value": <数值>}
+const PluginProtocolVersion = 1
+
+// PluginClient 启动并监管一个外部插件进程，通过 JSON-RPC 调用其数据源能力。
+// 插件进程退出后按退避策略自动重启，并重新 Configure。
+type PluginClient struct {
+	name    string
+	cfg     config.PluginConfig
+	timeout time.Duration
+	backoff time.Duration
+	limit   time.Duration
+
+	mu     sync.Mutex // 串行化调用，同一时刻只有一个未完成的请求
+	proc   *pluginProcess
+	nextID int64
+
+	restarts atomic.Int64 // 不受 mu 保护，抓取自监控指标时不必等待进行中的调用
+	closed   chan struct{}
+	done     chan struct{}
+}
+
+// pluginProcess 是一次运行中的插件进程。
+type pluginProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	exited chan struct{}
+}
+
+type pluginRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int64       `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type pluginResponse struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newPluginClient(name string, cfg config.PluginConfig) (*PluginClient, error) {
+	timeout, err := config.ParseDuration(cfg.Timeout, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("插件 %s 的 timeout 无效: %w", name, err)
+	}
+	backoff, err := config.ParseDuration(cfg.RestartBackoff, time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("插件 %s 的 restart_backoff 无效: %w", name, err)
+	}
+	limit, err := config.ParseDuration(cfg.MaxRestartBackoff, time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("插件 %s 的 max_restart_backoff 无效: %w", name, err)
+	}
+	return &PluginClient{
+		name:    name,
+		cfg:     cfg,
+		timeout: timeout,
+		backoff: backoff,
+		limit:   limit,
+		closed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// NewPluginClient 启动插件进程并完成 Configure 握手，随后在后台监管进程。启动失败时返回错误，适用于需要立即得到结果的场景（如查询预览）。
+func NewPluginClient(name string, cfg config.PluginConfig) (*PluginClient, error) {
+	c, err := newPluginClient(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	proc, err := c.start()
+	if err != nil {
+		return nil, err
+	}
+	c.proc = proc
+	go c.supervise(proc)
+	return c, nil
+}
+
+// StartPluginClient 启动插件进程并在后台监管。首次启动失败时同样交给监管协程按退避策略重试，
+// 重试成功前的调用返回错误；只有 timeout 等配置无效时返回错误。
+func StartPluginClient(name string, cfg config.PluginConfig) (*PluginClient, error) {
+	c, err := newPluginClient(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	proc, err := c.start()
+	if err != nil {
+		log.Printf("插件 %s 启动失败，%s 后重试: %v", name, c.backoff, err)
+	}
+	c.proc = proc
+	go c.supervise(proc)
+	return c, nil
+}
+
+// QueryScalar 调用插件的 Query 方法返回数值。
+func (c *PluginClient) QueryScalar(ctx context.Context, query, resultField, connection string) (float64, error) {
+	var result struct {
+		Value *float64 `json:"value"`
+	}
+	params := map[string]string{"query": query, "result_field": resultField, "connection": connection}
+	if err := c.call(ctx, "Query", params, &result); err != nil {
+		return 0, err
+	}
+	if result.Value == nil {
+		return 0, fmt.Errorf("插件 %s 的 Query 结果缺少 value", c.name)
+	}
+	return *result.Value, nil
+}
+
+// Ping 调用插件的 TestConnection 方法。
+func (c *PluginClient) Ping(ctx context.Context) error {
+	return c.call(ctx, "TestConnection", struct{}{}, nil)
+}
+
+// Restarts 返回监管协程重新启动插件的次数，包括崩溃后的重启与首次启动失败后的重试成功。
+func (c *PluginClient) Restarts() int64 {
+	return c.restarts.Load()
+}
+
+// Close 停止监管并终止插件进程。
+func (c *PluginClient) Close() error {
+	select {
+	case <-c.closed:
+		return nil
+	default:
+	}
+	close(c.closed)
+	c.mu.Lock()
+	proc := c.proc
+	c.proc = nil
+	c.mu.Unlock()
+	if proc != nil {
+		proc.stop()
+	}
+	<-c.done
+	return nil
+}
+
+// call 发送一次请求并等待响应；超时或协议错误时终止进程，由监管协程重启。
+func (c *PluginClient) call(ctx context.Context, method string, params, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.proc == nil {
+		return fmt.Errorf("插件 %s 未运行，等待重启", c.name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	err := c.roundTrip(ctx, c.proc, method, params, result)
+	var rpcErr *pluginRPCError
+	if err != nil && !errors.As(err, &rpcErr) {
+		// 读写失败或超时后进程状态未知，直接终止
+		c.proc.stop()
+		c.proc = nil
+	}
+	return err
+}
+
+// pluginRPCError 是插件通过 JSON-RPC error 返回的业务错误。
+type pluginRPCError struct {
+	plugin  string
+	method  string
+	code    int
+	message string
+}
+
+func (e *pluginRPCError) Error() string {
+	return fmt.Sprintf("插件 %s 的 %s 调用失败（code=%d）: %s", e.plugin, e.method, e.code, e.message)
+}
+
+func (c *PluginClient) roundTrip(ctx context.Context, proc *pluginProcess, method string, params, result interface{}) error {
+	c.nextID++
+	id := c.nextID
+	line, err := json.Marshal(pluginRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("编码插件请求失败: %w", err)
+	}
+
+	type reply struct {
+		resp pluginResponse
+		err  error
+	}
+	replies := make(chan reply, 1)
+	go func() {
+		if _, err := proc.stdin.Write(append(line, '\n')); err != nil {
+			replies <- reply{err: fmt.Errorf("写入插件 %s 失败: %w", c.name, err)}
+			return
+		}
+		data, err := proc.stdout.ReadBytes('\n')
+		if err != nil {
+			replies <- reply{err: fmt.Errorf("读取插件 %s 响应失败: %w", c.name, err)}
+			return
+		}
+		var resp pluginResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			replies <- reply{err: fmt.Errorf("插件 %s 响应不是合法的 JSON-RPC 消息: %w", c.name, err)}
+			return
+		}
+		replies <- reply{resp: resp}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("调用插件 %s 的 %s 超时: %w", c.name, method, ctx.Err())
+	case <-proc.exited:
+		return fmt.Errorf("插件 %s 进程已退出: %w", c.name, io.ErrUnexpectedEOF)
+	case r := <-replies:
+		if r.err != nil {
+			return r.err
+		}
+		if r.resp.ID != id {
+			return fmt.Errorf("插件 %s 响应 id %d 与请求 id %d 不一致", c.name, r.resp.ID, id)
+		}
+		if r.resp.Error != nil {
+			return &pluginRPCError{plugin: c.name, method: method, code: r.resp.Error.Code, message: r.resp.Error.Message}
+		}
+		if result != nil {
+			if err := json.Unmarshal(r.resp.Result, result); err != nil {
+				return fmt.Errorf("解析插件 %s 的 %s 结果失败: %w", c.name, method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// start 启动插件进程并完成 Configure 握手。
+func (c *PluginClient) start() (*pluginProcess, error) {
+	cmd := exec.Command(c.cfg.Command, c.cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range c.cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建插件 %s 标准输入失败: %w", c.name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建插件 %s 标准输出失败: %w", c.name, err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建插件 %s 标准错误失败: %w", c.name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动插件 %s 失败: %w", c.name, err)
+	}
+
+	proc := &pluginProcess{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), exited: make(chan struct{})}
+	go func() {
+		// 插件日志转发到服务日志
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Printf("插件 %s: %s", c.name, scanner.Text())
+		}
+	}()
+	go func() {
+		_ = cmd.Wait()
+		close(proc.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	var result struct {
+		ProtocolVersion int `json:"protocol_version"`
+	}
+	params := map[string]interface{}{"protocol_version": PluginProtocolVersion, "name": c.name, "config": c.cfg.Config}
+	err = c.roundTrip(ctx, proc, "Configure", params, &result)
+	if err == nil && result.ProtocolVersion != PluginProtocolVersion {
+		err = fmt.Errorf("插件 %s 的协议版本为 %d，需要 %d", c.name, result.ProtocolVersion, PluginProtocolVersion)
+	}
+	if err != nil {
+		proc.stop()
+		return nil, fmt.Errorf("插件 %s 初始化失败: %w", c.name, err)
+	}
+	return proc, nil
+}
+
+// supervise 等待插件进程退出，按指数退避重启，直到 Close。proc 为 nil 表示首次启动失败，直接进入重试。
+func (c *PluginClient) supervise(proc *pluginProcess) {
+	defer close(c.done)
+	wait := c.backoff
+	started := time.Now()
+	for {
+		if proc != nil {
+			select {
+			case <-c.closed:
+				return
+			case <-proc.exited:
+			}
+			// 进程稳定运行过 max_restart_backoff 才重置退避，启动即崩溃时继续按指数增长
+			if time.Since(started) >= c.limit {
+				wait = c.backoff
+			}
+
+			c.mu.Lock()
+			if c.proc == proc {
+				c.proc = nil
+			}
+			c.mu.Unlock()
+			log.Printf("插件 %s 进程已退出（%v），%s 后重启", c.name, proc.cmd.ProcessState, wait)
+		}
+
+		for {
+			select {
+			case <-c.closed:
+				return
+			case <-time.After(wait):
+			}
+			next, err := c.start()
+			wait = c.nextBackoff(wait)
+			if err == nil {
+				proc = next
+				break
+			}
+			log.Printf("重启插件 %s 失败，%s 后重试: %v", c.name, wait, err)
+		}
+
+		c.mu.Lock()
+		select {
+		case <-c.closed:
+			// 重启期间已关闭，丢弃新进程
+			c.mu.Unlock()
+			proc.stop()
+			return
+		default:
+		}
+		c.proc = proc
+		c.restarts.Add(1)
+		c.mu.Unlock()
+		log.Printf("插件 %s 已重启", c.name)
+		started = time.Now()
+	}
+}
+
+// nextBackoff 返回加倍后的重启等待时间，不超过 max_restart_backoff。
+func (c *PluginClient) nextBackoff(wait time.Duration) time.Duration {
+	wait *= 2
+	if wait > c.limit {
+		wait = c.limit
+	}
+	return wait
+}
+
+// stop 关闭标准输入并终止进程。
+func (p *pluginProcess) stop() {
+	_ = p.stdin.Close()
+	select {
+	case <-p.exited:
+		return
+	case <-time.After(time.Second):
+	}
+	_ = p.cmd.Process.Kill()
+	<-p.exited
+}
@@ -0,0 +1,198 @@
+package datasource
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// TestMain 在设置 PLUGIN_HELPER 时把测试二进制当作插件进程运行。
+func TestMain(m *testing.M) {
+	if os.Getenv("PLUGIN_HELPER") == "1" {
+		runHelperPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runHelperPlugin 实现一个最小插件：Query 返回 query 数值乘以 config.factor，"crash" 使进程退出，"fail" 返回错误。
+// 设置了 PLUGIN_HELPER_READY_FILE 时，该文件存在前进程启动后立即退出；设置了 PLUGIN_HELPER_CRASH_AFTER_CONFIGURE 时，Configure 成功后立即退出。
+func runHelperPlugin() {
+	if ready := os.Getenv("PLUGIN_HELPER_READY_FILE"); ready != "" {
+		if _, err := os.Stat(ready); err != nil {
+			os.Exit(1)
+		}
+	}
+	var factor float64 = 1
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     int64           `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "Configure":
+			var params struct {
+				Config struct {
+					Factor float64 `json:"factor"`
+				} `json:"config"`
+			}
+			_ = json.Unmarshal(req.Params, &params)
+			if params.Config.Factor != 0 {
+				factor = params.Config.Factor
+			}
+			version, _ := strconv.Atoi(os.Getenv("PLUGIN_HELPER_VERSION"))
+			resp["result"] = map[string]int{"protocol_version": version}
+			if os.Getenv("PLUGIN_HELPER_CRASH_AFTER_CONFIGURE") == "1" {
+				line, _ := json.Marshal(resp)
+				fmt.Println(string(line))
+				os.Exit(1)
+			}
+		case "TestConnection":
+			resp["result"] = map[string]bool{"ok": true}
+		case "Query":
+			var params struct {
+				Query string `json:"query"`
+			}
+			_ = json.Unmarshal(req.Params, &params)
+			if params.Query == "crash" {
+				os.Exit(1)
+			}
+			value, err := strconv.ParseFloat(params.Query, 64)
+			if err != nil {
+				resp["error"] = map[string]interface{}{"code": -32000, "message": fmt.Sprintf("无效查询 %q", params.Query)}
+			} else {
+				resp["result"] = map[string]float64{"value": value * factor}
+			}
+		}
+		line, _ := json.Marshal(resp)
+		fmt.Println(string(line))
+	}
+}
+
+func helperPluginConfig(t *testing.T, version string) config.PluginConfig {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skipf("无法定位测试二进制: %v", err)
+	}
+	return config.PluginConfig{
+		Command:        exe,
+		Env:            map[string]string{"PLUGIN_HELPER": "1", "PLUGIN_HELPER_VERSION": version},
+		Config:         map[string]interface{}{"factor": 2},
+		Timeout:        "5s",
+		RestartBackoff: "10ms",
+	}
+}
+
+func TestPluginClient(t *testing.T) {
+	client, err := NewPluginClient("helper", helperPluginConfig(t, "1"))
+	if err != nil {
+		t.Fatalf("启动插件失败: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("TestConnection 失败: %v", err)
+	}
+	value, err := client.QueryScalar(ctx, "21", "", "")
+	if err != nil || value != 42 {
+		t.Fatalf("期望按插件配置得到 42，实际 %v（错误: %v）", value, err)
+	}
+	if _, err := client.QueryScalar(ctx, "bad", "", ""); err == nil {
+		t.Fatalf("插件返回 error 时应当失败")
+	}
+	if value, err := client.QueryScalar(ctx, "1", "", ""); err != nil || value != 2 {
+		t.Fatalf("业务错误后插件应继续可用，实际 %v（错误: %v）", value, err)
+	}
+
+	// 插件崩溃后应被监管协程重启并重新 Configure
+	if _, err := client.QueryScalar(ctx, "crash", "", ""); err == nil {
+		t.Fatalf("插件崩溃时查询应当失败")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		value, err = client.QueryScalar(ctx, "5", "", "")
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("插件未在期限内重启: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if value != 10 || client.Restarts() != 1 {
+		t.Fatalf("重启后期望得到 10 且重启次数为 1，实际 %v / %d", value, client.Restarts())
+	}
+}
+
+func TestPluginClientProtocolMismatch(t *testing.T) {
+	if _, err := NewPluginClient("helper", helperPluginConfig(t, "99")); err == nil {
+		t.Fatalf("协议版本不一致时应当启动失败")
+	}
+}
+
+func TestStartPluginClientRetriesFailedStart(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	cfg := helperPluginConfig(t, "1")
+	cfg.Env["PLUGIN_HELPER_READY_FILE"] = ready
+	client, err := StartPluginClient("helper", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.QueryScalar(context.Background(), "1", "", ""); err == nil {
+		t.Fatal("插件启动成功前查询应当失败")
+	}
+
+	// 首次启动失败后由监管协程重试，依赖就绪后自动恢复
+	if err := os.WriteFile(ready, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		value, err := client.QueryScalar(context.Background(), "3", "", "")
+		if err == nil {
+			if value != 6 || client.Restarts() != 1 {
+				t.Fatalf("重试成功后期望得到 6 且重启次数为 1，实际 %v / %d", value, client.Restarts())
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("插件未在期限内重试成功: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestPluginClientCrashLoopBacksOff(t *testing.T) {
+	cfg := helperPluginConfig(t, "1")
+	cfg.Env["PLUGIN_HELPER_CRASH_AFTER_CONFIGURE"] = "1"
+	cfg.RestartBackoff = "20ms"
+	cfg.MaxRestartBackoff = "400ms"
+	client, err := NewPluginClient("helper", cfg)
+	if err != nil {
+		t.Fatalf("启动插件失败: %v", err)
+	}
+	defer client.Close()
+
+	// 进程每次启动后立即崩溃，重启间隔应为 20ms、40ms、80ms…，而不是每次都回到 20ms
+	time.Sleep(700 * time.Millisecond)
+	if restarts := client.Restarts(); restarts < 2 || restarts > 7 {
+		t.Fatalf("崩溃循环时重启间隔应指数增长，700ms 内期望重启 2~7 次，实际 %d", restarts)
+	}
+}
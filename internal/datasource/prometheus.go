@@ -0,0 +1,166 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// Sample 表示多序列结果中的一个序列及其数值。
+type Sample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// PrometheusClient 通过 HTTP API 对远端 Prometheus 执行即时查询，复用 RestAPIClient 的 HTTP 客户端。
+type PrometheusClient struct {
+	api *RestAPIClient
+}
+
+// NewPrometheusClient 基于配置创建 Prometheus 查询客户端。
+func NewPrometheusClient(cfg config.PrometheusSourceConfig) (*PrometheusClient, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("Prometheus 配置缺少 url")
+	}
+	api, err := NewRestAPIClient(cfg.RestAPIConfig())
+	if err != nil {
+		return nil, fmt.Errorf("创建 Prometheus 客户端失败: %w", err)
+	}
+	return &PrometheusClient{api: api}, nil
+}
+
+// QuerySeries 通过 /api/v1/query 执行 PromQL，返回 vector 或 scalar 结果中的全部序列。
+func (c *PrometheusClient) QuerySeries(ctx context.Context, query string) ([]Sample, error) {
+	endpoint := c.api.baseURL + "/api/v1/query?query=" + url.QueryEscape(query)
+	result, err := c.api.doRequest(ctx, "GET", endpoint, "")
+	if err != nil {
+		return nil, fmt.Errorf("执行 PromQL 查询失败: %w", err)
+	}
+	return parsePrometheusResult(result)
+}
+
+// QueryScalar 执行 PromQL 并按 selector 挑选唯一一个序列的值。
+func (c *PrometheusClient) QueryScalar(ctx context.Context, query string, selector map[string]string) (float64, error) {
+	samples, err := c.QuerySeries(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	samples = SelectSamples(samples, selector)
+	switch len(samples) {
+	case 0:
+		return 0, errors.New("PromQL 查询没有匹配的序列")
+	case 1:
+		return samples[0].Value, nil
+	}
+	return 0, fmt.Errorf("PromQL 查询返回 %d 个序列，请通过 series_selector 选择其一或配置 series_labels", len(samples))
+}
+
+// Ping 执行一次常量查询，验证地址与认证信息。
+func (c *PrometheusClient) Ping(ctx context.Context) error {
+	_, err := c.QuerySeries(ctx, "1")
+	return err
+}
+
+// Close 释放资源。
+func (c *PrometheusClient) Close() error {
+	return c.api.Close()
+}
+
+// SelectSamples 返回标签值与 selector 完全匹配的序列。
+func SelectSamples(samples []Sample, selector map[string]string) []Sample {
+	if len(selector) == 0 {
+		return samples
+	}
+	var selected []Sample
+	for _, s := range samples {
+		matched := true
+		for k, v := range selector {
+			if s.Labels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			selected = append(selected, s)
+		}
+	}
+	return selected
+}
+
+func parsePrometheusResult(result interface{}) ([]Sample, error) {
+	body, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("Prometheus 响应格式无效")
+	}
+	if status, _ := body["status"].(string); status != "success" {
+		return nil, fmt.Errorf("Prometheus 查询失败: %v", body["error"])
+	}
+	data, _ := body["data"].(map[string]interface{})
+	resultType, _ := data["resultType"].(string)
+
+	switch resultType {
+	case "scalar":
+		value, err := parsePrometheusValue(data["result"])
+		if err != nil {
+			return nil, err
+		}
+		return []Sample{{Labels: map[string]string{}, Value: value}}, nil
+	case "vector":
+		items, _ := data["result"].([]interface{})
+		samples := make([]Sample, 0, len(items))
+		for _, item := range items {
+			series, _ := item.(map[string]interface{})
+			value, err := parsePrometheusValue(series["value"])
+			if err != nil {
+				return nil, err
+			}
+			labels := make(map[string]string)
+			metric, _ := series["metric"].(map[string]interface{})
+			for k, v := range metric {
+				labels[k] = fmt.Sprint(v)
+			}
+			samples = append(samples, Sample{Labels: labels, Value: value})
+		}
+		sort.Slice(samples, func(i, j int) bool {
+			return seriesKey(samples[i].Labels) < seriesKey(samples[j].Labels)
+		})
+		return samples, nil
+	}
+	return nil, fmt.Errorf("不支持的 PromQL 结果类型: %s，仅支持 vector 与 scalar", resultType)
+}
+
+// parsePrometheusValue 解析 [<时间戳>, "<值>"] 形式的样本值。
+func parsePrometheusValue(raw interface{}) (float64, error) {
+	pair, ok := raw.([]interface{})
+	if !ok || len(pair) != 2 {
+		return 0, errors.New("Prometheus 样本格式无效")
+	}
+	text, ok := pair[1].(string)
+	if !ok {
+		return 0, errors.New("Prometheus 样本值格式无效")
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析 Prometheus 样本值 %q 失败: %w", text, err)
+	}
+	return value, nil
+}
+
+func seriesKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k + "=" + labels[k] + ";")
+	}
+	return sb.String()
+}
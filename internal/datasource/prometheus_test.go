@@ -0,0 +1,96 @@
+package datasource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func newTestPrometheus(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Scope-OrgID") != "tenant-a" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("query") {
+		case `sum by (cluster) (up{job="node"})`:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"cluster":"sh"},"value":[1700000000,"12"]},
+				{"metric":{"cluster":"bj"},"value":[1700000000,"30"]}]}}`))
+		case "1", "scalar(1)":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1700000000,"1"]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPrometheusClientQueries(t *testing.T) {
+	server := newTestPrometheus(t)
+	client, err := NewPrometheusClient(config.PrometheusSourceConfig{
+		URL:         server.URL,
+		BearerToken: "secret",
+		Headers:     map[string]string{"X-Scope-OrgID": "tenant-a"},
+	})
+	if err != nil {
+		t.Fatalf("创建 Prometheus 客户端失败: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("Ping 失败: %v", err)
+	}
+
+	query := `sum by (cluster) (up{job="node"})`
+	samples, err := client.QuerySeries(ctx, query)
+	if err != nil {
+		t.Fatalf("查询序列失败: %v", err)
+	}
+	if len(samples) != 2 || samples[0].Labels["cluster"] != "bj" || samples[0].Value != 30 {
+		t.Fatalf("序列解析或排序不符合预期: %+v", samples)
+	}
+
+	value, err := client.QueryScalar(ctx, query, map[string]string{"cluster": "sh"})
+	if err != nil || value != 12 {
+		t.Fatalf("按标签挑选序列期望 12，实际 %v（错误: %v）", value, err)
+	}
+	if _, err := client.QueryScalar(ctx, query, nil); err == nil {
+		t.Fatalf("多个序列未挑选时应当返回错误")
+	}
+	if _, err := client.QueryScalar(ctx, query, map[string]string{"cluster": "gz"}); err == nil {
+		t.Fatalf("没有匹配序列时应当返回错误")
+	}
+	if value, err := client.QueryScalar(ctx, "scalar(1)", nil); err != nil || value != 1 {
+		t.Fatalf("scalar 结果期望 1，实际 %v（错误: %v）", value, err)
+	}
+	if _, err := client.QueryScalar(ctx, "sum(", nil); err == nil {
+		t.Fatalf("非法 PromQL 应当返回错误")
+	}
+}
+
+func TestPrometheusClientRequiresAuth(t *testing.T) {
+	server := newTestPrometheus(t)
+	client, err := NewPrometheusClient(config.PrometheusSourceConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("创建 Prometheus 客户端失败: %v", err)
+	}
+	err = client.Ping(context.Background())
+	if ErrorClass(err) != "" {
+		t.Fatalf("401 不应被视为可重试错误，实际分类 %q", ErrorClass(err))
+	}
+	if err == nil {
+		t.Fatalf("缺少认证头时应当返回错误")
+	}
+}
@@ -2,9 +2,9 @@ package datasource
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +18,7 @@ import (
 type RedisClient struct {
 	client redis.UniversalClient
 	mode   string
+	tunnel *SSHTunnel
 }
 
 // NewRedisClient 基于配置创建 Redis 客户端。
@@ -34,15 +35,39 @@ func NewRedisClient(cfg config.RedisConfig) (*RedisClient, error) {
 	}
 
 	opt := &redis.Options{
-		Addr:     cfg.Addr,
-		Username: cfg.Username,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	}
-	if cfg.EnableTLS {
-		opt.TLSConfig = &tls.Config{
-			InsecureSkipVerify: cfg.SkipTLSVerify,
+		Addr:         cfg.Addr,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
+	}
+	if cfg.ConnMaxIdleTime != "" {
+		idleTime, err := time.ParseDuration(cfg.ConnMaxIdleTime)
+		if err != nil {
+			return nil, fmt.Errorf("解析 Redis conn_max_idle_time 失败: %w", err)
+		}
+		opt.ConnMaxIdleTime = idleTime
+	}
+	if cfg.EnableTLS || cfg.TLS.Enabled {
+		tlsCfg := cfg.TLS
+		tlsCfg.SkipVerify = tlsCfg.SkipVerify || cfg.SkipTLSVerify
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		tlsConfig, err := newTLSConfig(tlsCfg, host)
+		if err != nil {
+			return nil, fmt.Errorf("Redis TLS 配置无效: %w", err)
+		}
+		opt.TLSConfig = tlsConfig
+	}
+
+	var tunnel *SSHTunnel
+	if cfg.SSHTunnel != nil {
+		var err error
+		tunnel, err = NewSSHTunnel(*cfg.SSHTunnel, cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("建立 Redis SSH 隧道失败: %w", err)
 		}
+		opt.Addr = tunnel.LocalAddr()
 	}
 
 	client := redis.NewClient(opt)
@@ -50,12 +75,14 @@ func NewRedisClient(cfg config.RedisConfig) (*RedisClient, error) {
 	defer cancel()
 	if err := client.Ping(ctx).Err(); err != nil {
 		_ = client.Close()
+		closeTunnel(tunnel)
 		return nil, fmt.Errorf("Redis 连接验证失败: %w", err)
 	}
 
 	return &RedisClient{
 		client: client,
 		mode:   mode,
+		tunnel: tunnel,
 	}, nil
 }
 
@@ -85,6 +112,14 @@ func (c *RedisClient) QueryScalar(ctx context.Context, raw string) (float64, err
 	return redisValueToFloat(result)
 }
 
+// PoolStats 返回连接池统计信息。
+func (c *RedisClient) PoolStats() *redis.PoolStats {
+	if c.client == nil {
+		return &redis.PoolStats{}
+	}
+	return c.client.PoolStats()
+}
+
 // Ping 测试连接。
 func (c *RedisClient) Ping(ctx context.Context) error {
 	if c.client == nil {
@@ -98,7 +133,9 @@ func (c *RedisClient) Close() error {
 	if c.client == nil {
 		return nil
 	}
-	return c.client.Close()
+	err := c.client.Close()
+	closeTunnel(c.tunnel)
+	return err
 }
 
 func parseRedisCommand(raw string) (string, []string, error) {
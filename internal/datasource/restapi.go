@@ -22,7 +22,6 @@ type RestAPIClient struct {
 	client  *http.Client
 	baseURL string
 	headers map[string]string
-	retry   config.RestAPIRetryConfig
 }
 
 // NewRestAPIClient 基于配置创建 REST API 客户端。
@@ -63,8 +62,8 @@ func NewRestAPIClient(cfg config.RestAPIConfig) (*RestAPIClient, error) {
 		tlsConfig.ServerName = u.Hostname()
 	}
 
-	if cfg.TLS.SkipVerify {
-		tlsConfig.InsecureSkipVerify = true
+	if err := applyTLSConfig(tlsConfig, cfg.TLS); err != nil {
+		return nil, fmt.Errorf("RestAPI TLS 配置无效: %w", err)
 	}
 
 	transport := &http.Transport{
@@ -77,6 +76,15 @@ func NewRestAPIClient(cfg config.RestAPIConfig) (*RestAPIClient, error) {
 		ForceAttemptHTTP2: false,
 	}
 
+	// 按连接配置出站代理（http/https/socks5），未配置时直连
+	proxyURL, err := cfg.Proxy.ProxyURL()
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
 	client := &http.Client{
 		Timeout:   timeout,
 		Transport: transport,
@@ -89,7 +97,6 @@ func NewRestAPIClient(cfg config.RestAPIConfig) (*RestAPIClient, error) {
 		client:  client,
 		baseURL: baseURL,
 		headers: cfg.Headers,
-		retry:   cfg.Retry,
 	}, nil
 }
 
@@ -105,37 +112,12 @@ func (c *RestAPIClient) QueryScalar(ctx context.Context, query, resultField stri
 
 	url := c.baseURL + path
 
-	// 执行请求（带重试）
-	maxAttempts := 1
-	if c.retry.MaxAttempts > 0 {
-		maxAttempts = c.retry.MaxAttempts
-	}
-
-	var lastErr error
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		result, err := c.doRequest(ctx, method, url, body)
-		if err == nil {
-			return extractJSONValue(result, resultField)
-		}
-		lastErr = err
-
-		// 最后一次尝试不需要等待
-		if attempt < maxAttempts {
-			backoff := time.Second
-			if c.retry.Backoff != "" {
-				if parsed, parseErr := time.ParseDuration(c.retry.Backoff); parseErr == nil {
-					backoff = parsed
-				}
-			}
-			select {
-			case <-ctx.Done():
-				return 0, ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
+	// 重试由采集服务按连接或指标上的 retry 策略统一处理
+	result, err := c.doRequest(ctx, method, url, body)
+	if err != nil {
+		return 0, err
 	}
-
-	return 0, fmt.Errorf("RestAPI 请求失败（重试 %d 次）: %w", maxAttempts, lastErr)
+	return extractJSONValue(result, resultField)
 }
 
 // QueryRaw 执行 HTTP 请求并返回完整的 JSON 响应，用于预览和字段选择。
@@ -181,7 +163,7 @@ func (c *RestAPIClient) doRequest(ctx context.Context, method, url string, body
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HTTP 请求返回非成功状态码 %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
@@ -275,6 +257,7 @@ func parseQuery(query string) (method, path, body string, err error) {
 // 支持的路径格式：
 //   - "data.count" - 嵌套对象
 //   - "items[0].value" - 数组索引
+//   - "values[95.0]" - 字段名含点号时用方括号访问
 //   - "length" - 特殊关键字，返回数组长度
 func extractJSONValue(data interface{}, path string) (float64, error) {
 	if path == "" {
@@ -314,9 +297,11 @@ func extractJSONValue(data interface{}, path string) (float64, error) {
 			if !ok {
 				return 0, fmt.Errorf("路径 %s: 期望对象类型，实际为 %T", part, current)
 			}
-			val, exists := obj[part]
+			// 非数字的方括号用于访问含点号的字段，例如 values[95.0]
+			key := strings.TrimSuffix(strings.TrimPrefix(part, "["), "]")
+			val, exists := obj[key]
 			if !exists {
-				return 0, fmt.Errorf("路径 %s: 字段 %s 不存在", path, part)
+				return 0, fmt.Errorf("路径 %s: 字段 %s 不存在", path, key)
 			}
 			current = val
 		}
@@ -396,7 +381,8 @@ func toFloat(value interface{}) (float64, error) {
 	case string:
 		parsed, err := strconv.ParseFloat(v, 64)
 		if err != nil {
-			return 0, fmt.Errorf("字符串 %q 无法转换为数字: %w", v, err)
+			// 不在错误中带出原始内容，file 与 command 数据源的错误会出现在日志与预览响应中
+			return 0, fmt.Errorf("字符串无法转换为数字: %w", errors.Unwrap(err))
 		}
 		return parsed, nil
 	case json.Number:
@@ -0,0 +1,80 @@
+package datasource
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// HTTPStatusError 表示 HTTP 请求返回了非 2xx 状态码。
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP 请求返回非成功状态码 %d: %s", e.StatusCode, e.Body)
+}
+
+// ErrorClass 返回错误所属的重试类别，无法归类时返回空字符串。
+func ErrorClass(err error) string {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return ""
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests {
+			return config.RetryOnServer
+		}
+		return ""
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+		return config.RetryOnTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return config.RetryOnTimeout
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		mongo.IsNetworkError(err) {
+		return config.RetryOnConnection
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return config.RetryOnConnection
+	}
+	return ""
+}
+
+// IsRetryable 判断错误是否属于重试策略允许重试的类别。
+func IsRetryable(err error, policy config.RetryConfig) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	class := ErrorClass(err)
+	for _, allowed := range policy.RetryClasses() {
+		if allowed == config.RetryOnAll || (class != "" && allowed == class) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package datasource
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func TestIsRetryable(t *testing.T) {
+	defaults := config.RetryConfig{MaxAttempts: 3}
+	cases := []struct {
+		name   string
+		err    error
+		policy config.RetryConfig
+		want   bool
+	}{
+		{"连接断开", fmt.Errorf("执行 MySQL 查询失败: %w", driver.ErrBadConn), defaults, true},
+		{"查询超时", context.DeadlineExceeded, defaults, true},
+		{"服务端 503", &HTTPStatusError{StatusCode: 503}, defaults, true},
+		{"限流 429", &HTTPStatusError{StatusCode: 429}, defaults, true},
+		{"客户端 404", &HTTPStatusError{StatusCode: 404}, defaults, false},
+		{"SQL 语法错误", errors.New("Error 1064: You have an error in your SQL syntax"), defaults, false},
+		{"仅重试连接错误", &HTTPStatusError{StatusCode: 502}, config.RetryConfig{RetryOn: []string{config.RetryOnConnection}}, false},
+		{"重试所有错误", errors.New("未知错误"), config.RetryConfig{RetryOn: []string{config.RetryOnAll}}, true},
+		{"主动取消", context.Canceled, config.RetryConfig{RetryOn: []string{config.RetryOnAll}}, false},
+	}
+	for _, tc := range cases {
+		if got := IsRetryable(tc.err, tc.policy); got != tc.want {
+			t.Errorf("%s: 期望 %v，实际 %v", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// SNMPClient 通过 SNMP v2c/v3 读取网络设备指标。
+type SNMPClient struct {
+	mu     sync.Mutex
+	client *gosnmp.GoSNMP
+}
+
+// snmpQuery 描述一次 GET 或 WALK 读取。
+type snmpQuery struct {
+	walk      bool
+	oid       string
+	aggregate string
+}
+
+// NewSNMPClient 基于配置创建 SNMP 客户端。
+func NewSNMPClient(cfg config.SNMPConfig) (*SNMPClient, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("SNMP 配置缺少 host")
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 161
+	}
+	timeout := 5 * time.Second
+	if cfg.Timeout != "" {
+		parsed, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("解析 SNMP 超时配置失败: %w", err)
+		}
+		timeout = parsed
+	}
+
+	client := &gosnmp.GoSNMP{
+		Target:             cfg.Host,
+		Port:               uint16(port),
+		Timeout:            timeout,
+		Retries:            cfg.Retries,
+		MaxOids:            gosnmp.MaxOids,
+		MaxRepetitions:     50,
+		ExponentialTimeout: false,
+	}
+
+	switch cfg.Version {
+	case "", "2c":
+		client.Version = gosnmp.Version2c
+		client.Community = cfg.Community
+		if client.Community == "" {
+			client.Community = "public"
+		}
+	case "3":
+		params, flags, err := newUsmParameters(cfg.V3)
+		if err != nil {
+			return nil, err
+		}
+		client.Version = gosnmp.Version3
+		client.SecurityModel = gosnmp.UserSecurityModel
+		client.MsgFlags = flags
+		client.SecurityParameters = params
+		client.ContextName = cfg.V3.ContextName
+	default:
+		return nil, fmt.Errorf("不支持的 SNMP 版本: %s", cfg.Version)
+	}
+
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("连接 SNMP 设备 %s 失败: %w", cfg.Host, err)
+	}
+	return &SNMPClient{client: client}, nil
+}
+
+// QueryScalar 执行查询并返回数值。查询格式：
+//   - "GET <oid>"：读取单个 OID
+//   - "WALK <oid> [sum|avg|min|max|count]"：遍历子树并聚合，默认 sum
+func (c *SNMPClient) QueryScalar(ctx context.Context, query string) (float64, error) {
+	q, err := parseSNMPQuery(query)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.client.Context = ctx
+
+	if !q.walk {
+		packet, err := c.client.Get([]string{q.oid})
+		if err != nil {
+			return 0, fmt.Errorf("SNMP GET %s 失败: %w", q.oid, err)
+		}
+		if packet.Error != gosnmp.NoError {
+			return 0, fmt.Errorf("SNMP GET %s 返回错误: %s", q.oid, packet.Error)
+		}
+		if len(packet.Variables) == 0 {
+			return 0, fmt.Errorf("SNMP GET %s 未返回数据", q.oid)
+		}
+		return snmpValueToFloat(packet.Variables[0])
+	}
+
+	var values []float64
+	walkFn := func(pdu gosnmp.SnmpPDU) error {
+		v, err := snmpValueToFloat(pdu)
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+		return nil
+	}
+	if c.client.Version == gosnmp.Version1 {
+		err = c.client.Walk(q.oid, walkFn)
+	} else {
+		err = c.client.BulkWalk(q.oid, walkFn)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("SNMP WALK %s 失败: %w", q.oid, err)
+	}
+	return aggregateValues(values, q.aggregate)
+}
+
+// Ping 读取 sysUpTime 以验证设备可达与认证信息正确。
+func (c *SNMPClient) Ping(ctx context.Context) error {
+	_, err := c.QueryScalar(ctx, "GET 1.3.6.1.2.1.1.3.0")
+	return err
+}
+
+// Close 关闭底层 UDP 连接。
+func (c *SNMPClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client.Conn == nil {
+		return nil
+	}
+	return c.client.Conn.Close()
+}
+
+func parseSNMPQuery(query string) (snmpQuery, error) {
+	fields := strings.Fields(query)
+	if len(fields) < 2 {
+		return snmpQuery{}, fmt.Errorf("SNMP 查询格式应为 GET <oid> 或 WALK <oid> [聚合方式]，实际为 %q", query)
+	}
+	q := snmpQuery{oid: strings.TrimPrefix(fields[1], ".")}
+	for _, part := range strings.Split(q.oid, ".") {
+		if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+			return snmpQuery{}, fmt.Errorf("OID 格式无效: %s", fields[1])
+		}
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "GET":
+		if len(fields) != 2 {
+			return snmpQuery{}, errors.New("GET 查询只能包含一个 OID")
+		}
+	case "WALK":
+		q.walk = true
+		q.aggregate = "sum"
+		if len(fields) == 3 {
+			q.aggregate = strings.ToLower(fields[2])
+		}
+		if len(fields) > 3 {
+			return snmpQuery{}, errors.New("WALK 查询格式应为 WALK <oid> [聚合方式]")
+		}
+		switch q.aggregate {
+		case "sum", "avg", "min", "max", "count":
+		default:
+			return snmpQuery{}, fmt.Errorf("不支持的聚合方式: %s", q.aggregate)
+		}
+	default:
+		return snmpQuery{}, fmt.Errorf("不支持的 SNMP 操作: %s", fields[0])
+	}
+	return q, nil
+}
+
+func aggregateValues(values []float64, aggregate string) (float64, error) {
+	if aggregate == "count" {
+		return float64(len(values)), nil
+	}
+	if len(values) == 0 {
+		return 0, errors.New("SNMP WALK 未返回任何数据")
+	}
+	result := values[0]
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		switch aggregate {
+		case "min":
+			result = math.Min(result, v)
+		case "max":
+			result = math.Max(result, v)
+		}
+	}
+	switch aggregate {
+	case "sum":
+		return sum, nil
+	case "avg":
+		return sum / float64(len(values)), nil
+	}
+	return result, nil
+}
+
+func snmpValueToFloat(pdu gosnmp.SnmpPDU) (float64, error) {
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return 0, fmt.Errorf("OID %s 不存在或无值", pdu.Name)
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		f, _ := new(big.Float).SetInt(gosnmp.ToBigInt(pdu.Value)).Float64()
+		return f, nil
+	case gosnmp.OpaqueFloat:
+		return float64(pdu.Value.(float32)), nil
+	case gosnmp.OpaqueDouble:
+		return pdu.Value.(float64), nil
+	case gosnmp.OctetString:
+		raw, _ := pdu.Value.([]byte)
+		value, err := strconv.ParseFloat(strings.TrimSpace(string(raw)), 64)
+		if err != nil {
+			return 0, fmt.Errorf("OID %s 的字符串值 %q 无法转换为数字", pdu.Name, string(raw))
+		}
+		return value, nil
+	}
+	return 0, fmt.Errorf("OID %s 的类型 %s 无法转换为数字", pdu.Name, pdu.Type)
+}
+
+func newUsmParameters(cfg config.SNMPv3Config) (*gosnmp.UsmSecurityParameters, gosnmp.SnmpV3MsgFlags, error) {
+	if cfg.Username == "" {
+		return nil, 0, errors.New("SNMPv3 配置缺少 username")
+	}
+	params := &gosnmp.UsmSecurityParameters{
+		UserName:                 cfg.Username,
+		AuthenticationProtocol:   gosnmp.NoAuth,
+		PrivacyProtocol:          gosnmp.NoPriv,
+		AuthenticationPassphrase: cfg.AuthPassphrase,
+		PrivacyPassphrase:        cfg.PrivPassphrase,
+	}
+
+	authProtocols := map[string]gosnmp.SnmpV3AuthProtocol{
+		"MD5": gosnmp.MD5, "SHA": gosnmp.SHA, "SHA224": gosnmp.SHA224,
+		"SHA256": gosnmp.SHA256, "SHA384": gosnmp.SHA384, "SHA512": gosnmp.SHA512,
+	}
+	privProtocols := map[string]gosnmp.SnmpV3PrivProtocol{
+		"DES": gosnmp.DES, "AES": gosnmp.AES, "AES192": gosnmp.AES192,
+		"AES256": gosnmp.AES256, "AES192C": gosnmp.AES192C, "AES256C": gosnmp.AES256C,
+	}
+
+	var flags gosnmp.SnmpV3MsgFlags
+	switch cfg.SecurityLevel {
+	case "", "noAuthNoPriv":
+		flags = gosnmp.NoAuthNoPriv
+	case "authNoPriv", "authPriv":
+		proto, ok := authProtocols[strings.ToUpper(cfg.AuthProtocol)]
+		if !ok {
+			return nil, 0, fmt.Errorf("不支持的 SNMPv3 认证协议: %s", cfg.AuthProtocol)
+		}
+		params.AuthenticationProtocol = proto
+		flags = gosnmp.AuthNoPriv
+		if cfg.SecurityLevel == "authPriv" {
+			priv, ok := privProtocols[strings.ToUpper(cfg.PrivProtocol)]
+			if !ok {
+				return nil, 0, fmt.Errorf("不支持的 SNMPv3 加密协议: %s", cfg.PrivProtocol)
+			}
+			params.PrivacyProtocol = priv
+			flags = gosnmp.AuthPriv
+		}
+	default:
+		return nil, 0, fmt.Errorf("不支持的 SNMPv3 安全级别: %s", cfg.SecurityLevel)
+	}
+	return params, flags, nil
+}
@@ -0,0 +1,168 @@
+package datasource
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// testSNMPAgent 是仅支持 v2c GET/GETNEXT/GETBULK 的进程内 SNMP 代理。
+type testSNMPAgent struct {
+	conn net.PacketConn
+	oids []string
+	vars map[string]gosnmp.SnmpPDU
+}
+
+func newTestSNMPAgent(t *testing.T, vars []gosnmp.SnmpPDU) *testSNMPAgent {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	a := &testSNMPAgent{conn: conn, vars: make(map[string]gosnmp.SnmpPDU)}
+	for _, v := range vars {
+		a.oids = append(a.oids, v.Name)
+		a.vars[v.Name] = v
+	}
+	sort.Slice(a.oids, func(i, j int) bool { return compareOID(a.oids[i], a.oids[j]) < 0 })
+	t.Cleanup(func() { _ = conn.Close() })
+	go a.serve()
+	return a
+}
+
+func (a *testSNMPAgent) serve() {
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public"}
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil || req.Community != "public" {
+			continue
+		}
+
+		var vars []gosnmp.SnmpPDU
+		for _, v := range req.Variables {
+			switch req.PDUType {
+			case gosnmp.GetRequest:
+				pdu, ok := a.vars[v.Name]
+				if !ok {
+					pdu = gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchObject}
+				}
+				vars = append(vars, pdu)
+			case gosnmp.GetNextRequest:
+				vars = append(vars, a.next(v.Name, 1)...)
+			case gosnmp.GetBulkRequest:
+				vars = append(vars, a.next(v.Name, int(req.MaxRepetitions))...)
+			}
+		}
+
+		resp := &gosnmp.SnmpPacket{
+			Version:   gosnmp.Version2c,
+			Community: req.Community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: req.RequestID,
+			Variables: vars,
+		}
+		out, err := resp.MarshalMsg()
+		if err != nil {
+			continue
+		}
+		_, _ = a.conn.WriteTo(out, addr)
+	}
+}
+
+// next 返回字典序位于 oid 之后的至多 limit 个变量，遍历结束时返回 EndOfMibView。
+func (a *testSNMPAgent) next(oid string, limit int) []gosnmp.SnmpPDU {
+	start := sort.Search(len(a.oids), func(i int) bool { return compareOID(a.oids[i], oid) > 0 })
+	var vars []gosnmp.SnmpPDU
+	for i := start; i < len(a.oids) && len(vars) < limit; i++ {
+		vars = append(vars, a.vars[a.oids[i]])
+	}
+	if len(vars) == 0 {
+		vars = append(vars, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView})
+	}
+	return vars
+}
+
+func (a *testSNMPAgent) config() config.SNMPConfig {
+	host, portStr, _ := net.SplitHostPort(a.conn.LocalAddr().String())
+	port, _ := strconv.Atoi(portStr)
+	return config.SNMPConfig{Host: host, Port: port, Timeout: "1s"}
+}
+
+func compareOID(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "."), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "."), ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		x, _ := strconv.Atoi(pa[i])
+		y, _ := strconv.Atoi(pb[i])
+		if x != y {
+			return x - y
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+func TestSNMPClientGetAndWalk(t *testing.T) {
+	agent := newTestSNMPAgent(t, []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(123456)},
+		{Name: ".1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(100)},
+		{Name: ".1.3.6.1.2.1.2.2.1.10.2", Type: gosnmp.Counter32, Value: uint(300)},
+		{Name: ".1.3.6.1.2.1.2.2.1.10.10", Type: gosnmp.Counter32, Value: uint(200)},
+		{Name: ".1.3.6.1.2.1.2.2.1.16.1", Type: gosnmp.Counter32, Value: uint(999)},
+		{Name: ".1.3.6.1.4.1.9.1.0", Type: gosnmp.OctetString, Value: []byte(" 42.5 ")},
+	})
+
+	client, err := NewSNMPClient(agent.config())
+	if err != nil {
+		t.Fatalf("创建 SNMP 客户端失败: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping 失败: %v", err)
+	}
+
+	cases := []struct {
+		query string
+		want  float64
+	}{
+		{"GET 1.3.6.1.2.1.1.3.0", 123456},
+		{"GET .1.3.6.1.4.1.9.1.0", 42.5},
+		{"WALK 1.3.6.1.2.1.2.2.1.10", 600},
+		{"walk 1.3.6.1.2.1.2.2.1.10 count", 3},
+		{"WALK 1.3.6.1.2.1.2.2.1.10 avg", 200},
+		{"WALK 1.3.6.1.2.1.2.2.1.10 max", 300},
+	}
+	for _, tc := range cases {
+		got, err := client.QueryScalar(context.Background(), tc.query)
+		if err != nil {
+			t.Fatalf("查询 %s 失败: %v", tc.query, err)
+		}
+		if got != tc.want {
+			t.Fatalf("查询 %s 期望 %v，实际 %v", tc.query, tc.want, got)
+		}
+	}
+
+	if _, err := client.QueryScalar(context.Background(), "GET 1.3.6.1.2.1.1.5.0"); err == nil {
+		t.Fatalf("读取不存在的 OID 应当返回错误")
+	}
+}
+
+func TestParseSNMPQueryErrors(t *testing.T) {
+	for _, query := range []string{"", "GET", "SET 1.3.6", "GET 1.3.x", "GET 1.3 1.4", "WALK 1.3 median", "WALK 1.3 sum extra"} {
+		if _, err := parseSNMPQuery(query); err == nil {
+			t.Errorf("非法查询 %q 应当返回错误", query)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// 内置的通用 SQL 驱动
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/microsoft/go-mssqldb"
+	_ "modernc.org/sqlite"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/sqlguard"
+)
+
+// SQLDriver 描述 sql 数据源可使用的一种数据库。
+type SQLDriver struct {
+	DriverName string // database/sql 中注册的驱动名
+	// ReadOnlyTx 表示驱动支持只读事务；不支持时查询在普通事务中执行并总是回滚
+	ReadOnlyTx bool
+	// PrepareDSN 可选，在打开连接前调整 DSN，例如为 SQLite 开启 query_only
+	PrepareDSN func(dsn string) string
+}
+
+var (
+	sqlDriversMu sync.RWMutex
+	sqlDrivers   = map[string]SQLDriver{
+		"sqlite":    {DriverName: "sqlite", PrepareDSN: sqliteReadOnlyDSN},
+		"sqlserver": {DriverName: "sqlserver"},
+		"postgres":  {DriverName: "pgx", ReadOnlyTx: true},
+		"tidb":      {DriverName: "mysql", ReadOnlyTx: true},
+	}
+)
+
+// RegisterSQLDriver 注册 sql 数据源可用的数据库，name 对应连接配置中的 driver。
+// 驱动本身需要以空白导入的方式注册到 database/sql。
+func RegisterSQLDriver(name string, driver SQLDriver) {
+	sqlDriversMu.Lock()
+	defer sqlDriversMu.Unlock()
+	sqlDrivers[name] = driver
+}
+
+// SQLDriverNames 返回已注册的数据库名称。
+func SQLDriverNames() []string {
+	sqlDriversMu.RLock()
+	defer sqlDriversMu.RUnlock()
+	names := make([]string, 0, len(sqlDrivers))
+	for name := range sqlDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupSQLDriver(name string) (SQLDriver, bool) {
+	sqlDriversMu.RLock()
+	defer sqlDriversMu.RUnlock()
+	driver, ok := sqlDrivers[name]
+	return driver, ok
+}
+
+// SQLClient 通过 database/sql 驱动执行只读聚合查询。
+type SQLClient struct {
+	db     *sql.DB
+	engine string
+	txOpts *sql.TxOptions
+}
+
+// NewSQLClient 基于配置创建连接池并验证连通性。
+func NewSQLClient(cfg config.SQLConfig) (*SQLClient, error) {
+	driver, ok := lookupSQLDriver(cfg.Driver)
+	if !ok {
+		return nil, fmt.Errorf("不支持的 SQL 驱动: %s，可选 %s", cfg.Driver, strings.Join(SQLDriverNames(), "、"))
+	}
+	if cfg.DSN == "" {
+		return nil, errors.New("SQL 配置缺少 dsn")
+	}
+	dsn := cfg.DSN
+	if driver.PrepareDSN != nil {
+		dsn = driver.PrepareDSN(dsn)
+	}
+	db, err := sql.Open(driver.DriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("初始化 %s 连接失败: %w", cfg.Driver, err)
+	}
+	if err := applyPoolSettings(db, cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s 连接验证失败: %w", cfg.Driver, err)
+	}
+	return &SQLClient{
+		db:     db,
+		engine: cfg.Driver,
+		txOpts: &sql.TxOptions{ReadOnly: driver.ReadOnlyTx},
+	}, nil
+}
+
+// QueryScalar 执行聚合查询，返回首行首列的数值。语句必须通过只读校验，并在事务中执行后回滚。
+func (c *SQLClient) QueryScalar(ctx context.Context, stmt string) (float64, error) {
+	if err := sqlguard.CheckReadOnly(stmt); err != nil {
+		return 0, fmt.Errorf("%s 查询被拒绝: %w", c.engine, err)
+	}
+	return queryScalarInTx(ctx, c.db, c.engine, stmt, c.txOpts, nil)
+}
+
+// QueryWatermark 执行增量查询：watermark 作为绑定参数（占位符写法取决于驱动），返回首行第一列的增量与第二列的新水位。
+func (c *SQLClient) QueryWatermark(ctx context.Context, stmt string, watermark Watermark) (float64, Watermark, error) {
+	if err := sqlguard.CheckReadOnly(stmt); err != nil {
+		return 0, Watermark{}, fmt.Errorf("%s 查询被拒绝: %w", c.engine, err)
+	}
+	return queryWatermarkInTx(ctx, c.db, c.engine, stmt, watermark, c.txOpts, nil)
+}
+
+// Ping 测试数据库连接。
+func (c *SQLClient) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+// Stats 返回连接池统计信息。
+func (c *SQLClient) Stats() sql.DBStats {
+	return c.db.Stats()
+}
+
+// Close 关闭连接池。
+func (c *SQLClient) Close() error {
+	return c.db.Close()
+}
+
+// sqliteReadOnlyDSN 为 SQLite 连接开启 query_only，使任何写入在数据库层面失败。
+func sqliteReadOnlyDSN(dsn string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=query_only(1)"
+}
@@ -0,0 +1,124 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func newTestSQLite(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "devices.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE device (sn TEXT, online INTEGER, power REAL)",
+		"INSERT INTO device VALUES ('A1', 1, 3.5), ('A2', 1, 4.25), ('A3', 0, NULL)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("初始化 SQLite 失败: %v", err)
+		}
+	}
+	return path
+}
+
+func TestSQLClientSQLite(t *testing.T) {
+	client, err := NewSQLClient(config.SQLConfig{Driver: "sqlite", DSN: newTestSQLite(t)})
+	if err != nil {
+		t.Fatalf("创建 SQLite 客户端失败: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	if value, err := client.QueryScalar(ctx, "SELECT COUNT(*) FROM device WHERE online = 1"); err != nil || value != 2 {
+		t.Fatalf("COUNT 期望 2，实际 %v（错误: %v）", value, err)
+	}
+	if value, err := client.QueryScalar(ctx, "SELECT SUM(power) FROM device"); err != nil || value != 7.75 {
+		t.Fatalf("SUM 期望 7.75，实际 %v（错误: %v）", value, err)
+	}
+	if _, err := client.QueryScalar(ctx, "SELECT power FROM device WHERE sn = 'A3'"); err == nil {
+		t.Fatalf("NULL 结果应当返回错误")
+	}
+	if _, err := client.QueryScalar(ctx, "DELETE FROM device"); err == nil {
+		t.Fatalf("写入语句应当被只读校验拒绝")
+	}
+	// 即使绕过只读校验，连接层面的 query_only 也会拒绝写入
+	if _, err := client.db.Exec("UPDATE device SET online = 0"); err == nil {
+		t.Fatalf("SQLite 连接应当开启 query_only")
+	}
+}
+
+func TestSQLClientQueryWatermark(t *testing.T) {
+	client, err := NewSQLClient(config.SQLConfig{Driver: "sqlite", DSN: newTestSQLite(t)})
+	if err != nil {
+		t.Fatalf("创建 SQLite 客户端失败: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+	const stmt = "SELECT COUNT(*), MAX(rowid) FROM device WHERE rowid > ?"
+
+	increment, mark, err := client.QueryWatermark(ctx, stmt, Watermark{Value: "1"})
+	if err != nil || increment != 2 || mark != (Watermark{Value: "3"}) {
+		t.Fatalf("期望增量 2、水位 3，实际 %v、%+v（错误: %v）", increment, mark, err)
+	}
+	// 没有新数据时 MAX 为 NULL，水位为空
+	increment, mark, err = client.QueryWatermark(ctx, stmt, Watermark{Value: "3"})
+	if err != nil || increment != 0 || mark != (Watermark{}) {
+		t.Fatalf("期望增量 0、水位为空，实际 %v、%+v（错误: %v）", increment, mark, err)
+	}
+	if _, _, err := client.QueryWatermark(ctx, "SELECT COUNT(*) FROM device WHERE rowid > ?", Watermark{Value: "0"}); err == nil {
+		t.Fatalf("只返回一列时应当返回错误")
+	}
+}
+
+func TestTimeWatermarkKeepsZone(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	ts := time.Date(2024, 5, 1, 8, 0, 0, 123456789, shanghai)
+	mark, err := formatWatermark(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mark.Time || mark.Value != "2024-05-01T08:00:00.123456789+08:00" {
+		t.Fatalf("时间水位应保留纳秒与时区偏移，实际 %+v", mark)
+	}
+	// 绑定时还原为同一时刻的 time.Time，而不是丢掉偏移后的本地时间文本
+	arg, ok := mark.arg().(time.Time)
+	if !ok || !arg.Equal(ts) {
+		t.Fatalf("时间水位应按 time.Time 绑定且为同一时刻，实际 %v", mark.arg())
+	}
+	if _, offset := arg.Zone(); offset != 8*3600 {
+		t.Fatalf("绑定的时间应保留 +08:00 偏移，实际 %d 秒", offset)
+	}
+	// 非时间水位按原文绑定
+	if arg := (Watermark{Value: "2024-05-01T08:00:00+08:00"}).arg(); arg != "2024-05-01T08:00:00+08:00" {
+		t.Fatalf("文本水位应按字符串绑定，实际 %v", arg)
+	}
+}
+
+func TestSQLDriverRegistry(t *testing.T) {
+	if _, err := NewSQLClient(config.SQLConfig{Driver: "oracle", DSN: "x"}); err == nil {
+		t.Fatalf("未注册的驱动应当返回错误")
+	}
+
+	RegisterSQLDriver("sqlite-plain", SQLDriver{DriverName: "sqlite"})
+	t.Cleanup(func() {
+		sqlDriversMu.Lock()
+		delete(sqlDrivers, "sqlite-plain")
+		sqlDriversMu.Unlock()
+	})
+	client, err := NewSQLClient(config.SQLConfig{Driver: "sqlite-plain", DSN: newTestSQLite(t)})
+	if err != nil {
+		t.Fatalf("注册的驱动应当可以使用: %v", err)
+	}
+	defer client.Close()
+	if value, err := client.QueryScalar(context.Background(), "SELECT MAX(power) FROM device"); err != nil || value != 4.25 {
+		t.Fatalf("MAX 期望 4.25，实际 %v（错误: %v）", value, err)
+	}
+}
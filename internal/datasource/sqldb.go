@@ -0,0 +1,138 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// applyPoolSettings 应用连接池配置，未配置项采用保守默认值，避免穿透数据库。
+func applyPoolSettings(db *sql.DB, maxOpen, maxIdle int, maxLifetime, maxIdleTime string) error {
+	if maxOpen == 0 {
+		maxOpen = 5
+	}
+	if maxIdle == 0 {
+		maxIdle = 2
+	}
+	lifetime := 30 * time.Minute
+	if maxLifetime != "" {
+		parsed, err := time.ParseDuration(maxLifetime)
+		if err != nil {
+			return fmt.Errorf("解析 conn_max_lifetime 失败: %w", err)
+		}
+		lifetime = parsed
+	}
+	var idleTime time.Duration
+	if maxIdleTime != "" {
+		parsed, err := time.ParseDuration(maxIdleTime)
+		if err != nil {
+			return fmt.Errorf("解析 conn_max_idle_time 失败: %w", err)
+		}
+		idleTime = parsed
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+	db.SetConnMaxIdleTime(idleTime)
+	return nil
+}
+
+// queryScalarInTx 在事务中执行已通过只读校验的语句，返回首行首列的数值，结束后总是回滚。
+// check 非空时在执行前调用，用于成本检查等附加校验。
+func queryScalarInTx(ctx context.Context, db *sql.DB, engine, stmt string, opts *sql.TxOptions, check func(*sql.Tx) error) (float64, error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		kind := "事务"
+		if opts != nil && opts.ReadOnly {
+			kind = "只读事务"
+		}
+		return 0, fmt.Errorf("开启 %s %s失败: %w", engine, kind, err)
+	}
+	defer tx.Rollback()
+
+	if check != nil {
+		if err := check(tx); err != nil {
+			return 0, err
+		}
+	}
+
+	var value sql.NullFloat64
+	if err := tx.QueryRowContext(ctx, stmt).Scan(&value); err != nil {
+		return 0, fmt.Errorf("执行 %s 查询失败: %w", engine, err)
+	}
+	if !value.Valid {
+		return 0, fmt.Errorf("%s 查询未返回有效结果", engine)
+	}
+	return value.Float64, nil
+}
+
+// Watermark 是增量查询的水位，以文本保存，下次查询时作为绑定参数。
+// 驱动返回时间类型时 Time 为 true，Value 为保留时区偏移的 RFC3339Nano 文本，绑定时还原为 time.Time，
+// 避免数据库按字符串解析时丢失或误解时区。
+type Watermark struct {
+	Value string
+	Time  bool
+}
+
+// arg 返回绑定参数。
+func (w Watermark) arg() any {
+	if w.Time {
+		if t, err := time.Parse(time.RFC3339Nano, w.Value); err == nil {
+			return t
+		}
+	}
+	return w.Value
+}
+
+// queryWatermarkInTx 与 queryScalarInTx 相同，但以 watermark 为绑定参数执行，返回首行第一列的增量与第二列的新水位。
+// 增量为 NULL 时视为 0，新水位为 NULL（没有新数据）时返回空的 Watermark。
+func queryWatermarkInTx(ctx context.Context, db *sql.DB, engine, stmt string, watermark Watermark, opts *sql.TxOptions, check func(*sql.Tx) error) (float64, Watermark, error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return 0, Watermark{}, fmt.Errorf("开启 %s 事务失败: %w", engine, err)
+	}
+	defer tx.Rollback()
+
+	if check != nil {
+		if err := check(tx); err != nil {
+			return 0, Watermark{}, err
+		}
+	}
+
+	var increment sql.NullFloat64
+	var next any
+	if err := tx.QueryRowContext(ctx, stmt, watermark.arg()).Scan(&increment, &next); err != nil {
+		return 0, Watermark{}, fmt.Errorf("执行 %s 增量查询失败（需返回增量与新水位两列）: %w", engine, err)
+	}
+	if increment.Float64 < 0 {
+		return 0, Watermark{}, fmt.Errorf("%s 增量查询返回了负的增量 %v", engine, increment.Float64)
+	}
+	mark, err := formatWatermark(next)
+	if err != nil {
+		return 0, Watermark{}, fmt.Errorf("%s 增量查询返回的水位无效: %w", engine, err)
+	}
+	return increment.Float64, mark, nil
+}
+
+// formatWatermark 把驱动返回的水位转换为 Watermark，时间保留纳秒精度与时区偏移。
+func formatWatermark(v any) (Watermark, error) {
+	switch v := v.(type) {
+	case nil:
+		return Watermark{}, nil
+	case []byte:
+		return Watermark{Value: string(v)}, nil
+	case string:
+		return Watermark{Value: v}, nil
+	case int64:
+		return Watermark{Value: strconv.FormatInt(v, 10)}, nil
+	case float64:
+		return Watermark{Value: strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case time.Time:
+		return Watermark{Value: v.Format(time.RFC3339Nano), Time: true}, nil
+	default:
+		return Watermark{}, errors.New("不支持的类型")
+	}
+}
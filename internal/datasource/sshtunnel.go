@@ -0,0 +1,265 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// SSHTunnel 在本地回环地址监听，并经 SSH 跳板机将连接转发到远端地址。
+// SSH 会话断开后，下一次连接会自动重建会话。
+type SSHTunnel struct {
+	remoteAddr string
+	jumpAddr   string
+	sshConfig  *ssh.ClientConfig
+	listener   net.Listener
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewSSHTunnel 建立 SSH 会话并开始监听本地端口。
+func NewSSHTunnel(cfg config.SSHTunnelConfig, remoteAddr string) (*SSHTunnel, error) {
+	sshConfig, err := newSSHClientConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &SSHTunnel{
+		remoteAddr: remoteAddr,
+		jumpAddr:   cfg.Addr(),
+		sshConfig:  sshConfig,
+		done:       make(chan struct{}),
+	}
+	// 启动时先验证跳板机可达，避免配置错误延迟到首次查询才暴露
+	if _, err := t.sshClient(); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.resetClient()
+		return nil, fmt.Errorf("SSH 隧道监听本地端口失败: %w", err)
+	}
+	t.listener = listener
+
+	t.wg.Add(1)
+	go t.acceptLoop()
+	return t, nil
+}
+
+// LocalAddr 返回隧道在本地监听的地址，供数据库客户端连接。
+func (t *SSHTunnel) LocalAddr() string {
+	return t.listener.Addr().String()
+}
+
+// LocalHostPort 拆分本地监听地址。
+func (t *SSHTunnel) LocalHostPort() (string, int) {
+	addr := t.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// Close 停止监听并断开 SSH 会话。
+func (t *SSHTunnel) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	t.mu.Unlock()
+
+	err := t.listener.Close()
+	t.resetClient()
+	t.wg.Wait()
+	return err
+}
+
+// closeTunnel 关闭可选的隧道，nil 时忽略。
+func closeTunnel(t *SSHTunnel) {
+	if t == nil {
+		return
+	}
+	if err := t.Close(); err != nil {
+		log.Printf("关闭 SSH 隧道失败: %v", err)
+	}
+}
+
+func (t *SSHTunnel) acceptLoop() {
+	defer t.wg.Done()
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("SSH 隧道接受连接失败: %v", err)
+			continue
+		}
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.forward(local)
+		}()
+	}
+}
+
+func (t *SSHTunnel) forward(local net.Conn) {
+	defer local.Close()
+
+	remote, err := t.dialRemote()
+	if err != nil {
+		log.Printf("SSH 隧道连接 %s 失败: %v", t.remoteAddr, err)
+		return
+	}
+	defer remote.Close()
+
+	copied := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, local)
+		copied <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(local, remote)
+		copied <- struct{}{}
+	}()
+	select {
+	case <-copied:
+	case <-t.done:
+	}
+}
+
+// dialRemote 经 SSH 会话连接远端地址，会话失效时重建一次后重试。
+func (t *SSHTunnel) dialRemote() (net.Conn, error) {
+	client, err := t.sshClient()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.Dial("tcp", t.remoteAddr)
+	if err == nil {
+		return conn, nil
+	}
+
+	log.Printf("SSH 隧道会话异常，准备重建: %v", err)
+	t.dropClient(client)
+	client, err = t.sshClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.Dial("tcp", t.remoteAddr)
+}
+
+func (t *SSHTunnel) sshClient() (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errors.New("SSH 隧道已关闭")
+	}
+	if t.client != nil {
+		return t.client, nil
+	}
+	client, err := ssh.Dial("tcp", t.jumpAddr, t.sshConfig)
+	if err != nil {
+		return nil, fmt.Errorf("连接 SSH 跳板机 %s 失败: %w", t.jumpAddr, err)
+	}
+	t.client = client
+	// 会话断开时清理引用，下次连接自动重建
+	go func() {
+		_ = client.Wait()
+		t.dropClient(client)
+	}()
+	return client, nil
+}
+
+func (t *SSHTunnel) dropClient(client *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == client {
+		t.client = nil
+	}
+	_ = client.Close()
+}
+
+func (t *SSHTunnel) resetClient() {
+	t.mu.Lock()
+	client := t.client
+	t.client = nil
+	t.mu.Unlock()
+	if client != nil {
+		_ = client.Close()
+	}
+}
+
+func newSSHClientConfig(cfg config.SSHTunnelConfig) (*ssh.ClientConfig, error) {
+	if cfg.Host == "" || cfg.User == "" {
+		return nil, errors.New("SSH 隧道配置缺少 host 或 user")
+	}
+
+	var auths []ssh.AuthMethod
+	if cfg.PrivateKeyFile != "" {
+		pem, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 SSH 私钥失败: %w", err)
+		}
+		var signer ssh.Signer
+		if cfg.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(cfg.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 SSH 私钥失败: %w", err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auths = append(auths, ssh.Password(cfg.Password))
+	}
+	if len(auths) == 0 {
+		return nil, errors.New("SSH 隧道需要配置 password 或 private_key_file")
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case cfg.KnownHostsFile != "":
+		cb, err := knownhosts.New(cfg.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载 known_hosts 失败: %w", err)
+		}
+		hostKeyCallback = cb
+	case cfg.InsecureIgnoreHostKey:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, errors.New("SSH 隧道需要配置 known_hosts_file，或显式开启 insecure_ignore_host_key")
+	}
+
+	timeout := 10 * time.Second
+	if cfg.Timeout != "" {
+		parsed, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("解析 SSH 隧道超时配置失败: %w", err)
+		}
+		timeout = parsed
+	}
+
+	return &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}, nil
+}
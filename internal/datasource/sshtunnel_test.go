@@ -0,0 +1,195 @@
+package datasource
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// testSSHServer 是仅支持密码认证与 direct-tcpip 转发的进程内 SSH 服务。
+type testSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu    sync.Mutex
+	conns []*ssh.ServerConn
+}
+
+func newTestSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("生成主机密钥失败: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("创建主机密钥签名失败: %v", err)
+	}
+	serverCfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "tunnel" && string(pass) == "secret" {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	serverCfg.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	s := &testSSHServer{listener: listener, config: serverCfg}
+	go s.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return s
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testSSHServer) handle(conn net.Conn) {
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, serverConn)
+	s.mu.Unlock()
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "direct-tcpip" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		extra := newCh.ExtraData()
+		hostLen := binary.BigEndian.Uint32(extra[:4])
+		host := string(extra[4 : 4+hostLen])
+		port := binary.BigEndian.Uint32(extra[4+hostLen : 8+hostLen])
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+		if err != nil {
+			_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			_ = target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(chReqs)
+		go func() {
+			defer ch.Close()
+			defer target.Close()
+			go func() { _, _ = io.Copy(target, ch) }()
+			_, _ = io.Copy(ch, target)
+		}()
+	}
+}
+
+// dropSessions 断开所有已建立的 SSH 会话，模拟跳板机网络中断。
+func (s *testSSHServer) dropSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		_ = c.Close()
+	}
+	s.conns = nil
+}
+
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func echoThroughTunnel(t *testing.T, addr, msg string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("连接隧道失败: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(msg + "\n")); err != nil {
+		t.Fatalf("写入隧道失败: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("读取隧道失败: %v", err)
+	}
+	if line != msg+"\n" {
+		t.Fatalf("期望回显 %q，实际 %q", msg, line)
+	}
+}
+
+func TestSSHTunnelForwardsAndReconnects(t *testing.T) {
+	server := newTestSSHServer(t)
+	echoAddr := startEchoServer(t)
+
+	host, portStr, _ := net.SplitHostPort(server.listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	tunnel, err := NewSSHTunnel(config.SSHTunnelConfig{
+		Host:                  host,
+		Port:                  port,
+		User:                  "tunnel",
+		Password:              "secret",
+		InsecureIgnoreHostKey: true,
+	}, echoAddr)
+	if err != nil {
+		t.Fatalf("建立隧道失败: %v", err)
+	}
+	defer tunnel.Close()
+
+	echoThroughTunnel(t, tunnel.LocalAddr(), "ping")
+
+	server.dropSessions()
+	echoThroughTunnel(t, tunnel.LocalAddr(), "after-reconnect")
+}
+
+func TestSSHTunnelRejectsBadCredentials(t *testing.T) {
+	server := newTestSSHServer(t)
+	host, portStr, _ := net.SplitHostPort(server.listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	_, err := NewSSHTunnel(config.SSHTunnelConfig{
+		Host:                  host,
+		Port:                  port,
+		User:                  "tunnel",
+		Password:              "wrong",
+		InsecureIgnoreHostKey: true,
+	}, "127.0.0.1:1")
+	if err == nil {
+		t.Fatalf("错误的密码应当导致建立隧道失败")
+	}
+}
@@ -0,0 +1,59 @@
+package datasource
+
+import (
+	"context"
+	"sync"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/synthetic"
+)
+
+// SyntheticClient 为 synthetic 数据源按指标保存生成器，使随机游走、阶跃与回放在多次采集间保持连续。
+type SyntheticClient struct {
+	mu         sync.Mutex
+	generators map[string]*synthetic.Generator
+}
+
+// NewSyntheticClient 创建合成数据客户端。
+func NewSyntheticClient() *SyntheticClient {
+	return &SyntheticClient{generators: make(map[string]*synthetic.Generator)}
+}
+
+// QueryScalar 返回指标 name 的下一个合成数值；查询变化时重建生成器。
+// replay 生成器的回放文件与 file 数据源受同一白名单与大小上限约束。
+func (c *SyntheticClient) QueryScalar(ctx context.Context, files config.FileSourceConfig, name, query string) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.generators[name]
+	if !ok || g.Query() != query {
+		var err error
+		readFile := func(path string) ([]byte, error) {
+			return readAllowedFile(files, path, 0)
+		}
+		if g, err = synthetic.New(query, readFile); err != nil {
+			return 0, err
+		}
+		c.generators[name] = g
+	}
+	return g.Next()
+}
+
+// Retain 丢弃不在 names 中的指标的生成器状态，用于配置热更新后清理。
+func (c *SyntheticClient) Retain(names map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name := range c.generators {
+		if !names[name] {
+			delete(c.generators, name)
+		}
+	}
+}
+
+// Close 释放资源。
+func (c *SyntheticClient) Close() error {
+	return nil
+}
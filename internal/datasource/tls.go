@@ -0,0 +1,60 @@
+package datasource
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// applyTLSConfig 将 CA、客户端证书、SNI 等配置写入 tls.Config。
+func applyTLSConfig(dst *tls.Config, cfg config.TLSConfig) error {
+	if cfg.SkipVerify {
+		dst.InsecureSkipVerify = true
+	}
+	if cfg.ServerName != "" {
+		dst.ServerName = cfg.ServerName
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("读取 CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA 证书 %s 中未找到有效的 PEM 证书", cfg.CAFile)
+		}
+		dst.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return errors.New("cert_file 与 key_file 必须同时配置")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		dst.Certificates = []tls.Certificate{cert}
+	}
+	return nil
+}
+
+// newTLSConfig 基于配置创建 tls.Config，serverName 为默认 SNI。
+func newTLSConfig(cfg config.TLSConfig, serverName string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if err := applyTLSConfig(tlsConfig, cfg); err != nil {
+		return nil, err
+	}
+	return tlsConfig, nil
+}
+
+// NewClientTLSConfig 基于配置创建客户端 tls.Config，供 remote_write 等输出端复用数据源的证书加载逻辑。
+func NewClientTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	return newTLSConfig(cfg, "")
+}
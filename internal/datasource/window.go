@@ -0,0 +1,34 @@
+package datasource
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeWindow 表示一次采集覆盖的时间范围，通常为 [本次采集时间 - 采集周期, 本次采集时间)。
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// NewTimeWindow 返回以 end 结束、长度为 length 的时间窗口。
+func NewTimeWindow(end time.Time, length time.Duration) TimeWindow {
+	return TimeWindow{Start: end.Add(-length), End: end}
+}
+
+// Expand 替换查询中的时间窗口变量：
+//   - {{window_start}} / {{window_end}}：RFC3339 格式（UTC，毫秒精度）
+//   - {{window_start_ms}} / {{window_end_ms}}：Unix 毫秒时间戳
+func (w TimeWindow) Expand(query string) string {
+	if !strings.Contains(query, "{{window_") {
+		return query
+	}
+	const layout = "2006-01-02T15:04:05.000Z07:00"
+	return strings.NewReplacer(
+		"{{window_start}}", w.Start.UTC().Format(layout),
+		"{{window_end}}", w.End.UTC().Format(layout),
+		"{{window_start_ms}}", strconv.FormatInt(w.Start.UnixMilli(), 10),
+		"{{window_end_ms}}", strconv.FormatInt(w.End.UnixMilli(), 10),
+	).Replace(query)
+}
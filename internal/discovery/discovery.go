@@ -0,0 +1,241 @@
+// Package discovery 仿照 Prometheus 的 file_sd/http_sd，从 JSON/YAML 文件或 HTTP 接口发现数据源连接定义。
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// Target 是文件或 HTTP 响应中的一条连接定义，config 的字段与 mysql_connections/sql_connections 中的连接相同。
+type Target struct {
+	Name   string    `yaml:"name"`
+	Secret string    `yaml:"secret"`
+	Config yaml.Node `yaml:"config"`
+}
+
+// Connections 是一次发现得到的全部连接，按数据源与连接名索引。
+type Connections struct {
+	MySQL map[string]config.MySQLConfig
+	SQL   map[string]config.SQLConfig
+}
+
+// Names 返回指定数据源的连接名，按名称排序。
+func (c Connections) Names(source string) []string {
+	var names []string
+	switch source {
+	case "mysql":
+		for name := range c.MySQL {
+			names = append(names, name)
+		}
+	case "sql":
+		for name := range c.SQL {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Metrics 是连接发现的自监控指标。
+type Metrics struct {
+	Connections *prometheus.GaugeVec
+	Failures    *prometheus.CounterVec
+}
+
+// NewMetrics 创建连接发现自监控指标。
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Connections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "collector_discovered_connections",
+			Help: "连接发现得到的连接数",
+		}, []string{"source"}),
+		Failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "collector_discovery_refresh_failures_total",
+			Help: "连接发现刷新失败的次数，失败时沿用该来源上一次的结果",
+		}, []string{"provider"}),
+	}
+}
+
+// Collectors 返回需要注册的采集器。
+func (m *Metrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.Connections, m.Failures}
+}
+
+// connectionNameRegex 限制发现的连接名，连接名会作为 connection label 的值。
+var connectionNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// provider 是一个发现来源。
+type provider struct {
+	name   string // 用于日志与自监控指标，如 file_sd[0]
+	source string
+	fetch  func(ctx context.Context) ([]Target, error)
+}
+
+// Discoverer 按配置从全部来源读取连接定义。
+type Discoverer struct {
+	secrets   map[string]config.DiscoverySecret
+	providers []provider
+	last      map[string][]Target // 各来源上一次成功读取的结果
+	metrics   *Metrics
+}
+
+// New 按配置创建 Discoverer。
+func New(cfg config.ConnectionDiscoveryConfig, metrics *Metrics) (*Discoverer, error) {
+	d := &Discoverer{secrets: cfg.Secrets, last: make(map[string][]Target), metrics: metrics}
+	for i, f := range cfg.FileSD {
+		files := f.Files
+		d.providers = append(d.providers, provider{
+			name:   fmt.Sprintf("file_sd[%d]", i),
+			source: f.Source,
+			fetch:  func(context.Context) ([]Target, error) { return readFiles(files) },
+		})
+	}
+	for i, h := range cfg.HTTPSD {
+		client, err := newHTTPProvider(h)
+		if err != nil {
+			return nil, fmt.Errorf("http_sd[%d] 初始化失败: %w", i, err)
+		}
+		d.providers = append(d.providers, provider{
+			name:   fmt.Sprintf("http_sd[%d]", i),
+			source: h.Source,
+			fetch:  client.fetch,
+		})
+	}
+	return d, nil
+}
+
+// Refresh 读取全部来源并解析为连接配置。某个来源读取失败时沿用它上一次的结果并返回错误，
+// 单条定义无效（如引用了不存在的凭据）时跳过该条并记录日志。同名连接以先出现的为准。
+func (d *Discoverer) Refresh(ctx context.Context) (Connections, error) {
+	conns := Connections{MySQL: make(map[string]config.MySQLConfig), SQL: make(map[string]config.SQLConfig)}
+	var errs []error
+	for _, p := range d.providers {
+		targets, err := p.fetch(ctx)
+		if err != nil {
+			d.metrics.Failures.WithLabelValues(p.name).Inc()
+			errs = append(errs, fmt.Errorf("%s 读取失败: %w", p.name, err))
+			targets = d.last[p.name]
+		} else {
+			d.last[p.name] = targets
+		}
+		for _, target := range targets {
+			if err := d.add(conns, p.source, target); err != nil {
+				log.Printf("连接发现 %s 跳过连接 %q: %v", p.name, target.Name, err)
+			}
+		}
+	}
+	d.metrics.Connections.WithLabelValues("mysql").Set(float64(len(conns.MySQL)))
+	d.metrics.Connections.WithLabelValues("sql").Set(float64(len(conns.SQL)))
+	return conns, errors.Join(errs...)
+}
+
+// add 解析一条连接定义并填入凭据。
+func (d *Discoverer) add(conns Connections, source string, target Target) error {
+	if !connectionNameRegex.MatchString(target.Name) {
+		return errors.New("连接名非法")
+	}
+	if _, ok := conns.MySQL[target.Name]; ok && source == "mysql" {
+		return errors.New("连接名重复")
+	}
+	if _, ok := conns.SQL[target.Name]; ok && source == "sql" {
+		return errors.New("连接名重复")
+	}
+	username, password, err := d.credentials(target.Secret)
+	if err != nil {
+		return err
+	}
+	switch source {
+	case "mysql":
+		var conf config.MySQLConfig
+		if err := target.Config.Decode(&conf); err != nil {
+			return fmt.Errorf("解析连接配置失败: %w", err)
+		}
+		if conf.Host == "" {
+			return errors.New("缺少 host")
+		}
+		if target.Secret != "" {
+			if username != "" {
+				conf.User = username
+			}
+			conf.Password = password
+		}
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+		conns.MySQL[target.Name] = conf
+	case "sql":
+		var conf config.SQLConfig
+		if err := target.Config.Decode(&conf); err != nil {
+			return fmt.Errorf("解析连接配置失败: %w", err)
+		}
+		conf.DSN = strings.NewReplacer("{{username}}", username, "{{password}}", password).Replace(conf.DSN)
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+		conns.SQL[target.Name] = conf
+	}
+	return nil
+}
+
+// credentials 返回凭据的用户名与口令，未引用凭据时均为空。password_file 在每次刷新时重新读取。
+func (d *Discoverer) credentials(name string) (string, string, error) {
+	if name == "" {
+		return "", "", nil
+	}
+	secret, ok := d.secrets[name]
+	if !ok {
+		return "", "", fmt.Errorf("引用的凭据 %s 未在 secrets 中配置", name)
+	}
+	if secret.PasswordFile == "" {
+		return secret.Username, secret.Password, nil
+	}
+	raw, err := os.ReadFile(secret.PasswordFile)
+	if err != nil {
+		return "", "", fmt.Errorf("读取凭据 %s 的 password_file 失败: %w", name, err)
+	}
+	return secret.Username, strings.TrimRight(string(raw), "\r\n"), nil
+}
+
+// readFiles 读取 glob 匹配到的全部文件，任一文件读取或解析失败时整体失败。
+func readFiles(patterns []string) ([]Target, error) {
+	var targets []Target
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := parseTargets(raw)
+			if err != nil {
+				return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+			}
+			targets = append(targets, parsed...)
+		}
+	}
+	return targets, nil
+}
+
+// parseTargets 解析连接定义列表，JSON 是 YAML 的子集，两种格式使用同一解析器。
+func parseTargets(raw []byte) ([]Target, error) {
+	var targets []Target
+	if err := yaml.Unmarshal(raw, &targets); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
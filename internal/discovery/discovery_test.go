@@ -0,0 +1,123 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSD(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	writeFile(t, passwordFile, "s3cret\n")
+	writeFile(t, filepath.Join(dir, "shards.yml"), `
+- name: orders-shard-01
+  secret: orders-reader
+  config:
+    host: shard-01.db.internal
+    port: 3306
+    database: orders
+- name: orders-shard-02
+  secret: missing
+  config:
+    host: shard-02.db.internal
+- name: "bad name"
+  config:
+    host: shard-03.db.internal
+- name: orders-shard-04
+  secret: orders-reader
+  config:
+    host: shard-04.db.internal
+    tls:
+      enabled: true
+      cert_file: /etc/ssl/client.pem
+`)
+	writeFile(t, filepath.Join(dir, "tidb.json"), `[{"name": "tidb-01", "secret": "orders-reader", "config": {"driver": "tidb", "dsn": "{{username}}:{{password}}@tcp(tidb-01:4000)/orders"}}]`)
+
+	metrics := NewMetrics()
+	d, err := New(config.ConnectionDiscoveryConfig{
+		Secrets: map[string]config.DiscoverySecret{"orders-reader": {Username: "reader", PasswordFile: passwordFile}},
+		FileSD: []config.FileSDConfig{
+			{Source: "mysql", Files: []string{filepath.Join(dir, "*.yml")}},
+			{Source: "sql", Files: []string{filepath.Join(dir, "*.json")}},
+		},
+	}, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns, err := d.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 引用了不存在的凭据、连接名非法或未通过连接配置校验（cert_file 缺少 key_file）的定义被跳过
+	if len(conns.MySQL) != 1 {
+		t.Fatalf("应当只发现 1 个 MySQL 连接，实际 %v", conns.Names("mysql"))
+	}
+	shard := conns.MySQL["orders-shard-01"]
+	if shard.Host != "shard-01.db.internal" || shard.Port != 3306 || shard.User != "reader" || shard.Password != "s3cret" {
+		t.Fatalf("MySQL 连接配置或凭据不正确: %+v", shard)
+	}
+	if dsn := conns.SQL["tidb-01"].DSN; dsn != "reader:s3cret@tcp(tidb-01:4000)/orders" {
+		t.Fatalf("SQL 连接的 dsn 占位符替换不正确: %s", dsn)
+	}
+	if v := testutil.ToFloat64(metrics.Connections.WithLabelValues("mysql")); v != 1 {
+		t.Fatalf("collector_discovered_connections{source=mysql} 应为 1，实际 %v", v)
+	}
+}
+
+func TestHTTPSDKeepsLastResultOnFailure(t *testing.T) {
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"name": "edge-01", "config": {"driver": "sqlite", "dsn": "/var/lib/edge/01.db"}}]`))
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics()
+	d, err := New(config.ConnectionDiscoveryConfig{
+		HTTPSD: []config.HTTPSDConfig{{Source: "sql", URL: server.URL, BearerToken: "token"}},
+	}, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns, err := d.Refresh(context.Background())
+	if err != nil || conns.SQL["edge-01"].DSN != "/var/lib/edge/01.db" {
+		t.Fatalf("http_sd 发现结果不正确: %+v %v", conns, err)
+	}
+
+	fail.Store(true)
+	conns, err = d.Refresh(context.Background())
+	if err == nil {
+		t.Fatal("接口失败时应当返回错误")
+	}
+	if _, ok := conns.SQL["edge-01"]; !ok {
+		t.Fatal("接口失败时应当沿用上一次的结果")
+	}
+	if v := testutil.ToFloat64(metrics.Failures.WithLabelValues("http_sd[0]")); v != 1 {
+		t.Fatalf("刷新失败次数应为 1，实际 %v", v)
+	}
+}
@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// maxResponseBytes 限制 http_sd 响应体的大小。
+const maxResponseBytes = 10 << 20
+
+// httpProvider 从 HTTP 接口读取连接定义。
+type httpProvider struct {
+	cfg     config.HTTPSDConfig
+	client  *http.Client
+	timeout time.Duration
+}
+
+func newHTTPProvider(cfg config.HTTPSDConfig) (*httpProvider, error) {
+	tlsConfig, err := datasource.NewClientTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("TLS 配置无效: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := 10 * time.Second
+	if cfg.Timeout != "" {
+		timeout, _ = time.ParseDuration(cfg.Timeout)
+	}
+	return &httpProvider{cfg: cfg, client: &http.Client{Transport: transport}, timeout: timeout}, nil
+}
+
+func (p *httpProvider) fetch(ctx context.Context) ([]Target, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range p.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	switch {
+	case p.cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+p.cfg.BearerToken)
+	case p.cfg.Username != "":
+		req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP 状态码 %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxResponseBytes {
+		return nil, fmt.Errorf("响应体超过 %d 字节", maxResponseBytes)
+	}
+	targets, err := parseTargets(raw)
+	if err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	return targets, nil
+}
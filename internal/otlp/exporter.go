@@ -0,0 +1,251 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// Exporter 在后台导出指标快照。所有指标都是累计值或瞬时值，导出落后时只保留最新快照即可，
+// 因此 Push 不排队：上一次导出未完成时，新的快照覆盖尚未导出的旧快照。
+type Exporter struct {
+	protocol string
+	endpoint string
+	headers  map[string]string
+	gzip     bool
+	timeout  time.Duration
+	resource *resourcepb.Resource
+	start    time.Time
+
+	conn       *grpc.ClientConn
+	grpcClient colmetricspb.MetricsServiceClient
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	pending *snapshot
+	notify  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type snapshot struct {
+	families []*dto.MetricFamily
+	ts       time.Time
+}
+
+// New 创建导出器并启动后台导出协程。
+func New(cfg config.OTLPConfig) (*Exporter, error) {
+	e, err := newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	go e.run()
+	return e, nil
+}
+
+func newExporter(cfg config.OTLPConfig) (*Exporter, error) {
+	e := &Exporter{
+		protocol: cfg.Protocol,
+		endpoint: cfg.Endpoint,
+		headers:  cfg.Headers,
+		gzip:     cfg.Compression == "gzip",
+		timeout:  10 * time.Second,
+		resource: newResource(cfg.ResourceAttributes),
+		start:    time.Now(),
+		notify:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("解析 OTLP timeout 失败: %w", err)
+		}
+		e.timeout = d
+	}
+	tlsConfig, err := datasource.NewClientTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("OTLP TLS 配置无效: %w", err)
+	}
+
+	if e.protocol == "http" {
+		if e.endpoint == "" {
+			e.endpoint = "http://localhost:4318/v1/metrics"
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		e.httpClient = &http.Client{Transport: transport, Timeout: e.timeout}
+		return e, nil
+	}
+
+	e.protocol = "grpc"
+	if e.endpoint == "" {
+		e.endpoint = "localhost:4317"
+	}
+	creds := credentials.NewTLS(tlsConfig)
+	if cfg.Insecure {
+		creds = insecure.NewCredentials()
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if e.gzip {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(grpcgzip.Name)))
+	}
+	// NewClient 不会立即建立连接，Collector 暂不可用时服务仍可启动
+	if e.conn, err = grpc.NewClient(e.endpoint, opts...); err != nil {
+		return nil, fmt.Errorf("创建 OTLP gRPC 客户端失败: %w", err)
+	}
+	e.grpcClient = colmetricspb.NewMetricsServiceClient(e.conn)
+	return e, nil
+}
+
+// Push 提交一次采集后的指标快照，不阻塞采集。
+func (e *Exporter) Push(families []*dto.MetricFamily, ts time.Time) {
+	e.mu.Lock()
+	e.pending = &snapshot{families: families, ts: ts}
+	e.mu.Unlock()
+	select {
+	case e.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Close 导出尚未导出的快照后关闭连接。
+func (e *Exporter) Close() error {
+	select {
+	case <-e.stop:
+		return nil
+	default:
+	}
+	close(e.stop)
+	<-e.done
+	if e.conn != nil {
+		return e.conn.Close()
+	}
+	return nil
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+	for {
+		select {
+		case <-e.stop:
+			e.exportPending()
+			return
+		case <-e.notify:
+			e.exportPending()
+		}
+	}
+}
+
+func (e *Exporter) exportPending() {
+	e.mu.Lock()
+	snap := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if snap == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	if err := e.export(ctx, snap.families, snap.ts); err != nil {
+		log.Printf("OTLP 导出失败: %v", err)
+	}
+}
+
+// export 同步导出一次指标。
+func (e *Exporter) export(ctx context.Context, families []*dto.MetricFamily, ts time.Time) error {
+	req := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{toResourceMetrics(e.resource, families, e.start, ts)},
+	}
+	var rejected int64
+	var message string
+	if e.protocol == "grpc" {
+		if len(e.headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.headers))
+		}
+		resp, err := e.grpcClient.Export(ctx, req)
+		if err != nil {
+			return fmt.Errorf("OTLP gRPC 导出到 %s 失败: %w", e.endpoint, err)
+		}
+		rejected, message = resp.GetPartialSuccess().GetRejectedDataPoints(), resp.GetPartialSuccess().GetErrorMessage()
+	} else {
+		resp, err := e.postHTTP(ctx, req)
+		if err != nil {
+			return err
+		}
+		rejected, message = resp.GetPartialSuccess().GetRejectedDataPoints(), resp.GetPartialSuccess().GetErrorMessage()
+	}
+	if rejected > 0 {
+		return fmt.Errorf("OTLP 接收端拒绝了 %d 个数据点: %s", rejected, message)
+	}
+	return nil
+}
+
+func (e *Exporter) postHTTP(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("编码 OTLP 请求失败: %w", err)
+	}
+	if e.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, fmt.Errorf("压缩 OTLP 请求失败: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("压缩 OTLP 请求失败: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建 OTLP 请求失败: %w", err)
+	}
+	for k, v := range e.headers {
+		httpReq.Header.Set(k, v)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	if e.gzip {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := e.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("OTLP HTTP 导出到 %s 失败: %w", e.endpoint, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取 OTLP 响应失败: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("OTLP 接收端返回 HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data[:min(len(data), 512)]))
+	}
+	var out colmetricspb.ExportMetricsServiceResponse
+	if len(data) > 0 {
+		if err := proto.Unmarshal(data, &out); err != nil {
+			return nil, fmt.Errorf("解析 OTLP 响应失败: %w", err)
+		}
+	}
+	return &out, nil
+}
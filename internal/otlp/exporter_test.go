@@ -0,0 +1,189 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// collector 是 OTLP gRPC 接收端的替身。
+type collector struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	requests chan *colmetricspb.ExportMetricsServiceRequest
+	tokens   chan string
+}
+
+func (c *collector) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.tokens <- firstValue(md.Get("x-token"))
+	c.requests <- req
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func gatherSample(t *testing.T) []*dto.MetricFamily {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "energy_household_total", Help: "户数", ConstLabels: prometheus.Labels{"region": "east"}})
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "collector_errors_total", Help: "失败次数"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "order_latency_seconds", Help: "延迟", Buckets: []float64{1, 5}})
+	registry.MustRegister(gauge, counter, histogram)
+	gauge.Set(42)
+	counter.Add(3)
+	for _, v := range []float64{0.5, 2, 10} {
+		histogram.Observe(v)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return families
+}
+
+func attribute(attrs []*commonpb.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.GetKey() == key {
+			return kv.GetValue().GetStringValue()
+		}
+	}
+	return ""
+}
+
+func findMetric(t *testing.T, rm *metricspb.ResourceMetrics, name string) *metricspb.Metric {
+	t.Helper()
+	for _, m := range rm.GetScopeMetrics()[0].GetMetrics() {
+		if m.GetName() == name {
+			return m
+		}
+	}
+	t.Fatalf("导出结果中缺少指标 %s", name)
+	return nil
+}
+
+func TestExporterGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	recv := &collector{requests: make(chan *colmetricspb.ExportMetricsServiceRequest, 1), tokens: make(chan string, 1)}
+	colmetricspb.RegisterMetricsServiceServer(srv, recv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	exporter, err := New(config.OTLPConfig{
+		Endpoint:           lis.Addr().String(),
+		Insecure:           true,
+		Compression:        "gzip",
+		Headers:            map[string]string{"x-token": "secret"},
+		ResourceAttributes: map[string]string{"deployment.environment": "plant-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+	exporter.Push(gatherSample(t), time.Now())
+
+	var req *colmetricspb.ExportMetricsServiceRequest
+	select {
+	case req = <-recv.requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("未收到 OTLP gRPC 导出请求")
+	}
+	if token := <-recv.tokens; token != "secret" {
+		t.Fatalf("headers 应当作为 gRPC metadata 发送，实际 %q", token)
+	}
+	rm := req.GetResourceMetrics()[0]
+	if attribute(rm.GetResource().GetAttributes(), "service.name") != "sql2metrics" ||
+		attribute(rm.GetResource().GetAttributes(), "deployment.environment") != "plant-1" {
+		t.Fatalf("资源属性不正确: %v", rm.GetResource().GetAttributes())
+	}
+
+	gauge := findMetric(t, rm, "energy_household_total").GetGauge().GetDataPoints()[0]
+	if gauge.GetAsDouble() != 42 || attribute(gauge.GetAttributes(), "region") != "east" {
+		t.Fatalf("gauge 的值或属性不正确: %v", gauge)
+	}
+	sum := findMetric(t, rm, "collector_errors_total").GetSum()
+	if !sum.GetIsMonotonic() || sum.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE ||
+		sum.GetDataPoints()[0].GetAsDouble() != 3 {
+		t.Fatalf("counter 应当导出为单调累计 Sum: %v", sum)
+	}
+	hist := findMetric(t, rm, "order_latency_seconds").GetHistogram().GetDataPoints()[0]
+	if hist.GetCount() != 3 || len(hist.GetExplicitBounds()) != 2 {
+		t.Fatalf("histogram 的计数或边界不正确: %v", hist)
+	}
+	for i, want := range []uint64{1, 1, 1} {
+		if hist.GetBucketCounts()[i] != want {
+			t.Fatalf("histogram 的桶应当为非累计计数，实际 %v", hist.GetBucketCounts())
+		}
+	}
+}
+
+func TestExporterHTTP(t *testing.T) {
+	requests := make(chan *colmetricspb.ExportMetricsServiceRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("Content-Encoding") != "gzip" {
+			http.Error(w, "unexpected headers", http.StatusUnsupportedMediaType)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(zr)
+		var req colmetricspb.ExportMetricsServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- &req
+		resp, _ := proto.Marshal(&colmetricspb.ExportMetricsServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(resp)
+	}))
+	defer srv.Close()
+
+	exporter, err := newExporter(config.OTLPConfig{Protocol: "http", Endpoint: srv.URL + "/v1/metrics", Compression: "gzip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.export(context.Background(), gatherSample(t), time.Now()); err != nil {
+		t.Fatalf("OTLP HTTP 导出失败: %v", err)
+	}
+	req := <-requests
+	if got := len(req.GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()); got != 3 {
+		t.Fatalf("期望导出 3 个指标，实际 %d", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	exporter.endpoint = failing.URL
+	if err := exporter.export(context.Background(), gatherSample(t), time.Now()); err == nil {
+		t.Fatal("接收端返回 5xx 时应当报错")
+	}
+}
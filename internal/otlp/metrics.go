@@ -0,0 +1,155 @@
+// Package otlp 将采集结果以 OpenTelemetry OTLP 协议（gRPC 或 HTTP/protobuf）导出到 OpenTelemetry Collector。
+package otlp
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// scopeName 是导出数据的 instrumentation scope 名称。
+const scopeName = "sql2metrics"
+
+// newResource 构造资源属性，未配置 service.name 时使用 sql2metrics。
+func newResource(attributes map[string]string) *resourcepb.Resource {
+	attrs := make(map[string]string, len(attributes)+1)
+	attrs["service.name"] = "sql2metrics"
+	for k, v := range attributes {
+		attrs[k] = v
+	}
+	return &resourcepb.Resource{Attributes: toAttributes(attrs)}
+}
+
+// toResourceMetrics 将 Gather 得到的指标族转换为 OTLP 指标：gauge 与 untyped 对应 Gauge，counter 对应单调累计 Sum，
+// histogram 对应累计 Histogram，summary 对应 Summary；指标的 label（包括 MetricSpec 的 labels）转换为属性。
+// start 为累计值的起始时间（服务启动时间）。
+func toResourceMetrics(resource *resourcepb.Resource, families []*dto.MetricFamily, start, ts time.Time) *metricspb.ResourceMetrics {
+	startNano := uint64(start.UnixNano())
+	tsNano := uint64(ts.UnixNano())
+	metrics := make([]*metricspb.Metric, 0, len(families))
+
+	for _, mf := range families {
+		metric := &metricspb.Metric{Name: mf.GetName(), Description: mf.GetHelp()}
+		switch mf.GetType() {
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			var points []*metricspb.NumberDataPoint
+			for _, m := range mf.GetMetric() {
+				value := m.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				points = append(points, &metricspb.NumberDataPoint{
+					Attributes:   labelsToAttributes(m.GetLabel()),
+					TimeUnixNano: tsNano,
+					Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+				})
+			}
+			metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: points}}
+		case dto.MetricType_COUNTER:
+			var points []*metricspb.NumberDataPoint
+			for _, m := range mf.GetMetric() {
+				points = append(points, &metricspb.NumberDataPoint{
+					Attributes:        labelsToAttributes(m.GetLabel()),
+					StartTimeUnixNano: startNano,
+					TimeUnixNano:      tsNano,
+					Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: m.GetCounter().GetValue()},
+				})
+			}
+			metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				DataPoints:             points,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}}
+		case dto.MetricType_HISTOGRAM:
+			var points []*metricspb.HistogramDataPoint
+			for _, m := range mf.GetMetric() {
+				h := m.GetHistogram()
+				sum := h.GetSampleSum()
+				point := &metricspb.HistogramDataPoint{
+					Attributes:        labelsToAttributes(m.GetLabel()),
+					StartTimeUnixNano: startNano,
+					TimeUnixNano:      tsNano,
+					Count:             h.GetSampleCount(),
+					Sum:               &sum,
+				}
+				// Prometheus 的桶是累计计数，OTLP 要求每个桶单独计数，并额外包含 +Inf 桶
+				var previous uint64
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						continue
+					}
+					point.ExplicitBounds = append(point.ExplicitBounds, b.GetUpperBound())
+					point.BucketCounts = append(point.BucketCounts, b.GetCumulativeCount()-previous)
+					previous = b.GetCumulativeCount()
+				}
+				point.BucketCounts = append(point.BucketCounts, h.GetSampleCount()-previous)
+				points = append(points, point)
+			}
+			metric.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				DataPoints:             points,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			}}
+		case dto.MetricType_SUMMARY:
+			var points []*metricspb.SummaryDataPoint
+			for _, m := range mf.GetMetric() {
+				sm := m.GetSummary()
+				point := &metricspb.SummaryDataPoint{
+					Attributes:        labelsToAttributes(m.GetLabel()),
+					StartTimeUnixNano: startNano,
+					TimeUnixNano:      tsNano,
+					Count:             sm.GetSampleCount(),
+					Sum:               sm.GetSampleSum(),
+				}
+				for _, q := range sm.GetQuantile() {
+					point.QuantileValues = append(point.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+						Quantile: q.GetQuantile(),
+						Value:    q.GetValue(),
+					})
+				}
+				points = append(points, point)
+			}
+			metric.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: points}}
+		default:
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return &metricspb.ResourceMetrics{
+		Resource: resource,
+		ScopeMetrics: []*metricspb.ScopeMetrics{{
+			Scope:   &commonpb.InstrumentationScope{Name: scopeName},
+			Metrics: metrics,
+		}},
+	}
+}
+
+func labelsToAttributes(labels []*dto.LabelPair) []*commonpb.KeyValue {
+	attrs := make(map[string]string, len(labels))
+	for _, lp := range labels {
+		attrs[lp.GetName()] = lp.GetValue()
+	}
+	return toAttributes(attrs)
+}
+
+// toAttributes 按键排序生成字符串属性，保证输出稳定。
+func toAttributes(values map[string]string) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]*commonpb.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, &commonpb.KeyValue{
+			Key:   k,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: values[k]}},
+		})
+	}
+	return attrs
+}
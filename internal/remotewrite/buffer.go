@@ -0,0 +1,79 @@
+package remotewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const bufferFileExt = ".snappy"
+
+// diskBuffer 把发送失败的请求体（已压缩）逐个写成文件，文件名以写入时间开头，按名称排序即为写入顺序。
+type diskBuffer struct {
+	dir      string
+	maxBytes int64
+	seq      atomic.Uint64
+}
+
+func newDiskBuffer(dir string, maxBytes int64) (*diskBuffer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建 remote_write 缓冲目录失败: %w", err)
+	}
+	return &diskBuffer{dir: dir, maxBytes: maxBytes}, nil
+}
+
+// write 写入一个请求体，先写临时文件再重命名，避免补发时读到半个文件；写入后按容量上限淘汰最旧的文件。
+func (b *diskBuffer) write(payload []byte) error {
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), b.seq.Add(1)%1000000, bufferFileExt)
+	tmp := filepath.Join(b.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, payload, 0o640); err != nil {
+		return fmt.Errorf("写入 remote_write 缓冲失败: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(b.dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入 remote_write 缓冲失败: %w", err)
+	}
+	return b.trim()
+}
+
+// files 返回按写入顺序排列的缓冲文件路径。
+func (b *diskBuffer) files() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取 remote_write 缓冲目录失败: %w", err)
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), bufferFileExt) && !strings.HasPrefix(e.Name(), ".") {
+			paths = append(paths, filepath.Join(b.dir, e.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// trim 在总大小超过上限时从最旧的文件开始删除。
+func (b *diskBuffer) trim() error {
+	paths, err := b.files()
+	if err != nil {
+		return err
+	}
+	sizes := make([]int64, len(paths))
+	var total int64
+	for i, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	for i := 0; total > b.maxBytes && i < len(paths); i++ {
+		if err := os.Remove(paths[i]); err == nil {
+			total -= sizes[i]
+		}
+	}
+	return nil
+}
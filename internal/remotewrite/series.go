@@ -0,0 +1,143 @@
+// Package remotewrite 将采集结果以 Prometheus remote_write 协议（snappy 压缩的 protobuf）推送到远端，
+// 用于中心 Prometheus 无法直接抓取的部署（如位于厂区 NAT 之后的实例）。
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Label 是时间序列的一个 label。
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample 是一个样本，Timestamp 为毫秒时间戳。
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// TimeSeries 是一条时间序列，Labels 包含 __name__。
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// FromMetricFamilies 将 Gather 得到的指标族展开为时间序列，histogram 与 summary 按文本格式拆分为
+// _bucket/_sum/_count 与 quantile 序列，所有样本使用同一时间戳。
+func FromMetricFamilies(families []*dto.MetricFamily, ts time.Time) []TimeSeries {
+	millis := ts.UnixMilli()
+	var series []TimeSeries
+	add := func(name string, labels []*dto.LabelPair, value float64, extra ...Label) {
+		ls := make([]Label, 0, len(labels)+len(extra)+1)
+		ls = append(ls, Label{Name: "__name__", Value: name})
+		for _, lp := range labels {
+			ls = append(ls, Label{Name: lp.GetName(), Value: lp.GetValue()})
+		}
+		ls = append(ls, extra...)
+		series = append(series, TimeSeries{Labels: ls, Samples: []Sample{{Value: value, Timestamp: millis}}})
+	}
+
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetLabel(), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetLabel(), m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetLabel(), m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", m.GetLabel(), float64(b.GetCumulativeCount()), Label{Name: "le", Value: formatFloat(b.GetUpperBound())})
+				}
+				add(name+"_bucket", m.GetLabel(), float64(h.GetSampleCount()), Label{Name: "le", Value: "+Inf"})
+				add(name+"_sum", m.GetLabel(), h.GetSampleSum())
+				add(name+"_count", m.GetLabel(), float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				sm := m.GetSummary()
+				for _, q := range sm.GetQuantile() {
+					add(name, m.GetLabel(), q.GetValue(), Label{Name: "quantile", Value: formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", m.GetLabel(), sm.GetSampleSum())
+				add(name+"_count", m.GetLabel(), float64(sm.GetSampleCount()))
+			}
+		}
+	}
+	return series
+}
+
+// withExternalLabels 追加外部 label（序列中已有的同名 label 优先）并按名称排序，remote_write 要求 label 有序。
+func withExternalLabels(series []TimeSeries, external []Label) []TimeSeries {
+	out := make([]TimeSeries, len(series))
+	for i, ts := range series {
+		labels := append([]Label(nil), ts.Labels...)
+		for _, ext := range external {
+			if !hasLabel(labels, ext.Name) {
+				labels = append(labels, ext)
+			}
+		}
+		sort.Slice(labels, func(a, b int) bool { return labels[a].Name < labels[b].Name })
+		out[i] = TimeSeries{Labels: labels, Samples: ts.Samples}
+	}
+	return out
+}
+
+func hasLabel(labels []Label, name string) bool {
+	for _, l := range labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// encodeWriteRequest 按 prompb.WriteRequest 编码：
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []TimeSeries) []byte {
+	var buf []byte
+	for _, ts := range series {
+		var tsBuf []byte
+		for _, l := range ts.Labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Value)
+			tsBuf = protowire.AppendTag(tsBuf, 1, protowire.BytesType)
+			tsBuf = protowire.AppendBytes(tsBuf, lb)
+		}
+		for _, s := range ts.Samples {
+			var sb []byte
+			sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+			sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+			tsBuf = protowire.AppendTag(tsBuf, 2, protowire.BytesType)
+			tsBuf = protowire.AppendBytes(tsBuf, sb)
+		}
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, tsBuf)
+	}
+	return buf
+}
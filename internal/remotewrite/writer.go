@@ -0,0 +1,314 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// maxPendingBatches 限制内存中等待发送的批次数，超出时丢弃最旧的序列。
+const maxPendingBatches = 100
+
+// Writer 在后台按批次推送时间序列：失败时按指数退避重试，重试耗尽后写入磁盘缓冲，接收端恢复后按顺序补发。
+type Writer struct {
+	url            string
+	client         *http.Client
+	headers        map[string]string
+	bearerToken    string
+	username       string
+	password       string
+	externalLabels []Label
+	batchSize      int
+	maxRetries     int
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	timeout        time.Duration
+	buffer         *diskBuffer
+
+	mu      sync.Mutex
+	pending []TimeSeries
+
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// permanentError 表示接收端拒绝了请求（4xx），重试或补发都不会成功。
+type permanentError struct {
+	status int
+	body   string
+}
+
+func (e *permanentError) Error() string {
+	return fmt.Sprintf("remote_write 接收端拒绝请求（HTTP %d）: %s", e.status, e.body)
+}
+
+// New 创建并启动 Writer。
+func New(cfg config.RemoteWriteConfig) (*Writer, error) {
+	w, err := newWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+func newWriter(cfg config.RemoteWriteConfig) (*Writer, error) {
+	tlsConfig, err := datasource.NewClientTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("remote_write TLS 配置无效: %w", err)
+	}
+	minBackoff, err := config.ParseDuration(cfg.MinBackoff, 500*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("remote_write min_backoff 无效: %w", err)
+	}
+	maxBackoff, err := config.ParseDuration(cfg.MaxBackoff, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("remote_write max_backoff 无效: %w", err)
+	}
+	timeout, err := config.ParseDuration(cfg.Timeout, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("remote_write timeout 无效: %w", err)
+	}
+	w := &Writer{
+		url:         cfg.URL,
+		headers:     cfg.Headers,
+		bearerToken: cfg.BearerToken,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		batchSize:   cfg.BatchSize,
+		maxRetries:  cfg.MaxRetries,
+		minBackoff:  minBackoff,
+		maxBackoff:  maxBackoff,
+		timeout:     timeout,
+		notify:      make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if w.batchSize == 0 {
+		w.batchSize = 500
+	}
+	if w.maxRetries == 0 {
+		w.maxRetries = 3
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	w.client = &http.Client{Transport: transport, Timeout: w.timeout}
+
+	for name, value := range cfg.ExternalLabels {
+		w.externalLabels = append(w.externalLabels, Label{Name: name, Value: value})
+	}
+	sort.Slice(w.externalLabels, func(i, j int) bool { return w.externalLabels[i].Name < w.externalLabels[j].Name })
+
+	if cfg.BufferDir != "" {
+		maxBytes := cfg.BufferMaxBytes
+		if maxBytes == 0 {
+			maxBytes = 64 << 20
+		}
+		if w.buffer, err = newDiskBuffer(cfg.BufferDir, maxBytes); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Append 将一个采集周期的时间序列加入发送队列，不阻塞采集。
+func (w *Writer) Append(series []TimeSeries) {
+	if len(series) == 0 {
+		return
+	}
+	w.mu.Lock()
+	w.pending = append(w.pending, withExternalLabels(series, w.externalLabels)...)
+	if limit := w.batchSize * maxPendingBatches; len(w.pending) > limit {
+		dropped := len(w.pending) - limit
+		w.pending = w.pending[dropped:]
+		log.Printf("remote_write 发送队列已满，丢弃最旧的 %d 条时间序列", dropped)
+	}
+	w.mu.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Close 停止后台发送，并在超时时间内尝试发送队列中剩余的序列（失败时写入磁盘缓冲）。
+func (w *Writer) Close() error {
+	select {
+	case <-w.stop:
+		return nil
+	default:
+	}
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.maxBackoff)
+	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-w.stop
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-w.stop:
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), w.timeout)
+			w.flush(shutdownCtx)
+			shutdownCancel()
+			return
+		case <-w.notify:
+			w.flush(ctx)
+		case <-ticker.C:
+			w.flush(ctx)
+		}
+	}
+}
+
+// flush 先补发磁盘缓冲，再按批次发送队列中的序列。补发失败说明接收端仍不可用，
+// 此时新批次直接写入缓冲，保证恢复后按时间顺序到达。
+func (w *Writer) flush(ctx context.Context) {
+	healthy := w.replay(ctx)
+
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	for start := 0; start < len(pending); start += w.batchSize {
+		end := start + w.batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		payload := snappy.Encode(nil, encodeWriteRequest(pending[start:end]))
+		if !healthy {
+			w.spill(payload, end-start)
+			continue
+		}
+		err := w.send(ctx, payload, w.maxRetries)
+		var permErr *permanentError
+		switch {
+		case err == nil:
+		case errors.As(err, &permErr):
+			log.Printf("remote_write 丢弃 %d 条时间序列: %v", end-start, err)
+		default:
+			log.Printf("remote_write 发送失败: %v", err)
+			healthy = false
+			w.spill(payload, end-start)
+		}
+	}
+}
+
+// replay 按写入顺序补发磁盘缓冲，全部补发完成（或缓冲为空）时返回 true。
+func (w *Writer) replay(ctx context.Context) bool {
+	if w.buffer == nil {
+		return true
+	}
+	paths, err := w.buffer.files()
+	if err != nil {
+		log.Printf("%v", err)
+		return true
+	}
+	for _, path := range paths {
+		payload, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("读取 remote_write 缓冲文件 %s 失败: %v", path, err)
+			continue
+		}
+		// 重放以检查周期为节奏，每个文件只尝试一次
+		err = w.send(ctx, payload, 0)
+		var permErr *permanentError
+		if err != nil && !errors.As(err, &permErr) {
+			return false
+		}
+		if err != nil {
+			log.Printf("remote_write 丢弃缓冲文件 %s: %v", path, err)
+		}
+		os.Remove(path)
+	}
+	if len(paths) > 0 {
+		log.Printf("remote_write 已补发 %d 个缓冲批次", len(paths))
+	}
+	return true
+}
+
+func (w *Writer) spill(payload []byte, count int) {
+	if w.buffer == nil {
+		log.Printf("remote_write 未配置 buffer_dir，丢弃 %d 条时间序列", count)
+		return
+	}
+	if err := w.buffer.write(payload); err != nil {
+		log.Printf("%v，丢弃 %d 条时间序列", err, count)
+	}
+}
+
+// send 发送一个已压缩的请求体，网络错误、5xx 与 429 按指数退避重试 retries 次。
+func (w *Writer) send(ctx context.Context, payload []byte, retries int) error {
+	backoff := w.minBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.post(ctx, payload)
+		var permErr *permanentError
+		if err == nil || errors.As(err, &permErr) || attempt >= retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v（等待重试时取消: %w）", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+func (w *Writer) post(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建 remote_write 请求失败: %w", err)
+	}
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "sql2metrics")
+	if w.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.bearerToken)
+	} else if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote_write 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{status: resp.StatusCode, body: string(bytes.TrimSpace(body))}
+	}
+	return fmt.Errorf("remote_write 接收端返回 HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+}
@@ -0,0 +1,219 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// receiver 是 remote_write 接收端的替身，记录解码后的请求，status 非 0 时返回该状态码。
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests [][]TimeSeries
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.headers = append(r.headers, req.Header.Clone())
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.requests = append(r.requests, decodeWriteRequest(data))
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	r.status = status
+	r.mu.Unlock()
+}
+
+// decodeWriteRequest 解码 prompb.WriteRequest，仅用于测试。
+func decodeWriteRequest(data []byte) []TimeSeries {
+	var series []TimeSeries
+	forEachField(data, func(_ protowire.Number, raw []byte) {
+		var ts TimeSeries
+		forEachField(raw, func(num protowire.Number, raw []byte) {
+			if num == 1 {
+				var l Label
+				forEachField(raw, func(num protowire.Number, raw []byte) {
+					if num == 1 {
+						l.Name = string(raw)
+					} else {
+						l.Value = string(raw)
+					}
+				})
+				ts.Labels = append(ts.Labels, l)
+				return
+			}
+			var s Sample
+			for len(raw) > 0 {
+				num, typ, n := protowire.ConsumeTag(raw)
+				raw = raw[n:]
+				if num == 1 && typ == protowire.Fixed64Type {
+					v, n := protowire.ConsumeFixed64(raw)
+					s.Value = math.Float64frombits(v)
+					raw = raw[n:]
+				} else {
+					v, n := protowire.ConsumeVarint(raw)
+					s.Timestamp = int64(v)
+					raw = raw[n:]
+				}
+			}
+			ts.Samples = append(ts.Samples, s)
+		})
+		series = append(series, ts)
+	})
+	return series
+}
+
+func forEachField(data []byte, fn func(protowire.Number, []byte)) {
+	for len(data) > 0 {
+		num, _, n := protowire.ConsumeTag(data)
+		data = data[n:]
+		raw, n := protowire.ConsumeBytes(data)
+		data = data[n:]
+		fn(num, raw)
+	}
+}
+
+func labelValue(ts TimeSeries, name string) string {
+	for _, l := range ts.Labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+func TestWriterBatchesWithExternalLabels(t *testing.T) {
+	recv := &receiver{}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "energy_total_kwh", Help: "h", ConstLabels: prometheus.Labels{"site": "sh"}})
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "inverter_power_kw", Help: "h"}, []string{"inverter"})
+	registry.MustRegister(gauge, vec)
+	gauge.Set(42)
+	vec.WithLabelValues("a").Set(1)
+	vec.WithLabelValues("b").Set(2)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.UnixMilli(1700000000000)
+
+	w, err := newWriter(config.RemoteWriteConfig{
+		URL:            srv.URL,
+		BearerToken:    "secret",
+		ExternalLabels: map[string]string{"plant": "p1", "site": "ignored"},
+		BatchSize:      2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Append(FromMetricFamilies(families, ts))
+	w.flush(context.Background())
+
+	if len(recv.requests) != 2 || len(recv.requests[0]) != 2 || len(recv.requests[1]) != 1 {
+		t.Fatalf("期望按 batch_size=2 拆成 2 个请求，实际 %v", recv.requests)
+	}
+	h := recv.headers[0]
+	if h.Get("Content-Encoding") != "snappy" || h.Get("Content-Type") != "application/x-protobuf" ||
+		h.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" || h.Get("Authorization") != "Bearer secret" {
+		t.Fatalf("请求头不符合 remote_write 协议: %v", h)
+	}
+	first := recv.requests[0][0]
+	if labelValue(first, "__name__") != "energy_total_kwh" || labelValue(first, "plant") != "p1" || labelValue(first, "site") != "sh" {
+		t.Fatalf("external_labels 应当追加且不覆盖已有 label，实际 %v", first.Labels)
+	}
+	for i := 1; i < len(first.Labels); i++ {
+		if first.Labels[i-1].Name > first.Labels[i].Name {
+			t.Fatalf("label 必须按名称排序: %v", first.Labels)
+		}
+	}
+	if first.Samples[0] != (Sample{Value: 42, Timestamp: ts.UnixMilli()}) {
+		t.Fatalf("样本不正确: %v", first.Samples)
+	}
+}
+
+func TestWriterRetriesAndBuffersOnOutage(t *testing.T) {
+	recv := &receiver{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	w, err := newWriter(config.RemoteWriteConfig{
+		URL:        srv.URL,
+		MaxRetries: 2,
+		MinBackoff: "1ms",
+		BufferDir:  dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := func(v float64) []TimeSeries {
+		return []TimeSeries{{Labels: []Label{{Name: "__name__", Value: "m"}}, Samples: []Sample{{Value: v, Timestamp: int64(v)}}}}
+	}
+
+	w.Append(series(1))
+	w.flush(context.Background())
+	if len(recv.headers) != 3 {
+		t.Fatalf("期望首次发送加 2 次重试共 3 个请求，实际 %d", len(recv.headers))
+	}
+	// 缓冲未补发成功前，新批次直接写入缓冲而不再请求
+	w.Append(series(2))
+	w.flush(context.Background())
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Fatalf("期望缓冲 2 个批次，实际 %d", len(files))
+	}
+
+	recv.setStatus(0)
+	w.Append(series(3))
+	w.flush(context.Background())
+	if len(recv.requests) != 3 {
+		t.Fatalf("恢复后期望补发 2 个缓冲批次并发送新批次，实际 %d 个请求", len(recv.requests))
+	}
+	for i, req := range recv.requests {
+		if req[0].Samples[0].Value != float64(i+1) {
+			t.Fatalf("批次应当按采集顺序到达，第 %d 个为 %v", i, req[0].Samples[0].Value)
+		}
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("补发后缓冲应当清空，剩余 %d 个文件", len(files))
+	}
+
+	// 4xx 表示数据被拒绝，不重试也不缓冲
+	recv.setStatus(http.StatusBadRequest)
+	before := len(recv.headers)
+	w.Append(series(4))
+	w.flush(context.Background())
+	if len(recv.headers)-before != 1 {
+		t.Fatalf("4xx 不应重试")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("4xx 拒绝的批次不应写入缓冲")
+	}
+}
@@ -0,0 +1,159 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// influxDBSink 以行协议写入 InfluxDB，配置 bucket 时使用 v2 接口，否则使用 v1 接口。
+type influxDBSink struct {
+	cfg       config.InfluxDBSinkConfig
+	endpoint  string
+	precision string
+	client    *http.Client
+}
+
+func newInfluxDBSink(cfg config.InfluxDBSinkConfig) (*influxDBSink, error) {
+	tlsConfig, err := datasource.NewClientTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("InfluxDB TLS 配置无效: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	s := &influxDBSink{cfg: cfg, precision: cfg.Precision, client: &http.Client{Transport: transport}}
+	if s.precision == "" {
+		s.precision = "ms"
+	}
+	base := strings.TrimRight(cfg.URL, "/")
+	query := url.Values{}
+	if cfg.Bucket != "" {
+		query.Set("org", cfg.Org)
+		query.Set("bucket", cfg.Bucket)
+		query.Set("precision", s.precision)
+		s.endpoint = base + "/api/v2/write?" + query.Encode()
+	} else {
+		query.Set("db", cfg.Database)
+		// v1 接口的精度写法为 s/ms/u/n
+		query.Set("precision", map[string]string{"s": "s", "ms": "ms", "us": "u", "ns": "n"}[s.precision])
+		s.endpoint = base + "/write?" + query.Encode()
+	}
+	return s, nil
+}
+
+func (s *influxDBSink) Write(ctx context.Context, samples []Sample) error {
+	body := s.encode(samples)
+	if len(body) == 0 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建 InfluxDB 写入请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.cfg.Bucket != "" {
+		if s.cfg.Token != "" {
+			req.Header.Set("Authorization", "Token "+s.cfg.Token)
+		}
+	} else if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("写入 InfluxDB 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("InfluxDB 返回 HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+		// 4xx 表示请求本身有误（行协议格式、库不存在、权限等），重试只会一直失败；429 为限流，可以重试
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			return &permanentError{err: err}
+		}
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// encode 将样本编码为行协议，NaN 与 Inf 无法写入 InfluxDB，直接跳过。
+func (s *influxDBSink) encode(samples []Sample) []byte {
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		tags := make(map[string]string, len(s.cfg.Tags)+len(sample.Labels)+len(sample.Series))
+		for _, m := range []map[string]string{s.cfg.Tags, sample.Labels, sample.Series} {
+			for k, v := range m {
+				tags[k] = v
+			}
+		}
+		names := make([]string, 0, len(tags))
+		for name, value := range tags {
+			if name != "" && value != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		buf.WriteString(escapeLine(sample.Metric, ", "))
+		for _, name := range names {
+			buf.WriteByte(',')
+			buf.WriteString(escapeLine(name, ",= "))
+			buf.WriteByte('=')
+			buf.WriteString(escapeLine(tags[name], ",= "))
+		}
+		buf.WriteString(" value=")
+		buf.WriteString(strconv.FormatFloat(sample.Value, 'g', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(s.timestamp(sample.Time), 10))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func (s *influxDBSink) timestamp(t time.Time) int64 {
+	switch s.precision {
+	case "s":
+		return t.Unix()
+	case "us":
+		return t.UnixMicro()
+	case "ns":
+		return t.UnixNano()
+	default:
+		return t.UnixMilli()
+	}
+}
+
+// escapeLine 按行协议规则转义 chars 中的字符。
+func escapeLine(value, chars string) string {
+	if !strings.ContainsAny(value, chars) {
+		return value
+	}
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *influxDBSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,123 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// iotdbWriter 是 IoTDB 写入会话的最小接口，便于测试替换。
+type iotdbWriter interface {
+	InsertRecords(records []datasource.IoTDBRecord) error
+	Close() error
+}
+
+// iotdbSink 将样本写为 IoTDB 的 DOUBLE 测点。会话在首次写入时建立，写入失败后丢弃并在下次重连。
+type iotdbSink struct {
+	cfg  config.IoTDBSinkConfig
+	conn config.IoTDBConfig
+	dial func(config.IoTDBConfig) (iotdbWriter, error)
+
+	mu     sync.Mutex
+	client iotdbWriter
+}
+
+func newIoTDBSink(cfg config.IoTDBSinkConfig, conn config.IoTDBConfig) *iotdbSink {
+	return &iotdbSink{
+		cfg:  cfg,
+		conn: conn,
+		dial: func(c config.IoTDBConfig) (iotdbWriter, error) {
+			return datasource.NewIoTDBClient(c)
+		},
+	}
+}
+
+func (s *iotdbSink) Write(ctx context.Context, samples []Sample) error {
+	records := make([]datasource.IoTDBRecord, 0, len(samples))
+	for _, sample := range samples {
+		device, measurement := s.target(sample)
+		records = append(records, datasource.IoTDBRecord{
+			Device:      device,
+			Measurement: measurement,
+			Value:       sample.Value,
+			Timestamp:   sample.Time.UnixMilli(),
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.client == nil {
+		client, err := s.dial(s.conn)
+		if err != nil {
+			return fmt.Errorf("连接 IoTDB 失败: %w", err)
+		}
+		s.client = client
+	}
+	if err := s.client.InsertRecords(records); err != nil {
+		s.client.Close()
+		s.client = nil
+		return err
+	}
+	return nil
+}
+
+// target 计算样本对应的设备路径与测点名。
+func (s *iotdbSink) target(sample Sample) (string, string) {
+	device, measurement := s.cfg.Device, sample.Metric
+	if t, ok := s.cfg.Targets[sample.Metric]; ok {
+		if t.Device != "" {
+			device = t.Device
+		}
+		if t.Measurement != "" {
+			measurement = t.Measurement
+		}
+	}
+	if len(sample.Series) > 0 {
+		names := make([]string, 0, len(sample.Series))
+		for name := range sample.Series {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		nodes := []string{device}
+		for _, name := range names {
+			nodes = append(nodes, pathNode(sample.Series[name]))
+		}
+		device = strings.Join(nodes, ".")
+	}
+	return device, measurement
+}
+
+// pathNode 将 label 值转换为合法的 IoTDB 路径节点，非字母数字字符替换为下划线。
+func pathNode(value string) string {
+	if value == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for _, r := range value {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func (s *iotdbSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
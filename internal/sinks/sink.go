@@ -0,0 +1,93 @@
+// Package sinks 将采集成功的样本写回时序数据库（IoTDB、InfluxDB），用于长期保存计算出的业务 KPI。
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// Sample 是一次采集得到的样本。
+type Sample struct {
+	Metric string
+	Labels map[string]string // 指标配置的静态 labels
+	Series map[string]string // series_labels 对应的序列 label，单值指标为空
+	Value  float64
+	Time   time.Time
+}
+
+// Sink 是写回目标，Write 一次写入一批样本。
+type Sink interface {
+	Write(ctx context.Context, samples []Sample) error
+	Close() error
+}
+
+// permanentError 表示写回目标拒绝了这批样本（HTTP 4xx，429 除外），重试不会成功，样本直接丢弃。
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Metrics 是写回的自监控指标，按 sink 名称区分。
+type Metrics struct {
+	Written  *prometheus.CounterVec
+	Failures *prometheus.CounterVec
+	Dropped  *prometheus.CounterVec
+}
+
+// NewMetrics 创建写回自监控指标。
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Written: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "collector_sink_samples_written_total",
+			Help: "成功写回的样本数",
+		}, []string{"sink"}),
+		Failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "collector_sink_write_failures_total",
+			Help: "写回失败的批次数，失败的样本会保留到下次重试",
+		}, []string{"sink"}),
+		Dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "collector_sink_samples_dropped_total",
+			Help: "因待写回样本超过 max_pending 或被写回目标拒绝而丢弃的样本数",
+		}, []string{"sink"}),
+	}
+}
+
+// Collectors 返回需要注册的采集器。
+func (m *Metrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.Written, m.Failures, m.Dropped}
+}
+
+// New 按配置创建写回目标并启动批量写入。iotdb 为顶层 IoTDB 连接配置。
+func New(cfg config.SinkConfig, iotdb config.IoTDBConfig, metrics *Metrics) (*Writer, error) {
+	var sink Sink
+	switch cfg.Type {
+	case "iotdb":
+		sink = newIoTDBSink(*cfg.IoTDB, iotdb)
+	case "influxdb":
+		influx, err := newInfluxDBSink(*cfg.InfluxDB)
+		if err != nil {
+			return nil, err
+		}
+		sink = influx
+	default:
+		return nil, fmt.Errorf("不支持的写回类型: %s", cfg.Type)
+	}
+	w, err := newWriter(cfg, sink, metrics)
+	if err != nil {
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
@@ -0,0 +1,227 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/company/ems-devices/internal/config"
+	"github.com/company/ems-devices/internal/datasource"
+)
+
+// fakeSink 记录写入的批次，fail 为 true 时写入失败，reject 为 true 时拒绝写入。
+type fakeSink struct {
+	mu      sync.Mutex
+	fail    bool
+	reject  bool
+	batches [][]Sample
+}
+
+func (f *fakeSink) Write(ctx context.Context, samples []Sample) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return errors.New("unavailable")
+	}
+	if f.reject {
+		return &permanentError{err: errors.New("bad request")}
+	}
+	f.batches = append(f.batches, append([]Sample(nil), samples...))
+	return nil
+}
+
+func (f *fakeSink) Close() error { return nil }
+
+func TestWriterBatchesAndRetainsFailedSamples(t *testing.T) {
+	metrics := NewMetrics()
+	sink := &fakeSink{fail: true}
+	w, err := newWriter(config.SinkConfig{Name: "kpi", Metrics: []string{"a"}, BatchSize: 2, MaxPending: 3}, sink, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		w.Add(Sample{Metric: "a", Value: float64(i)})
+	}
+	w.Add(Sample{Metric: "b", Value: 9})
+	w.flush(false)
+	if got := testutil.ToFloat64(metrics.Failures.WithLabelValues("kpi")); got != 1 {
+		t.Fatalf("写入失败应当计数，实际 %v", got)
+	}
+	if len(w.pending) != 2 {
+		t.Fatalf("失败的批次应当保留待重试，未列出的指标应被忽略，实际 %d 个待写样本", len(w.pending))
+	}
+
+	w.Add(Sample{Metric: "a", Value: 2})
+	w.Add(Sample{Metric: "a", Value: 3})
+	if got := testutil.ToFloat64(metrics.Dropped.WithLabelValues("kpi")); got != 1 {
+		t.Fatalf("超过 max_pending 时应当丢弃最旧的样本，实际丢弃 %v", got)
+	}
+
+	sink.fail = false
+	w.flush(true)
+	if len(sink.batches) != 2 || len(sink.batches[0]) != 2 || sink.batches[0][0].Value != 1 || len(sink.batches[1]) != 1 {
+		t.Fatalf("恢复后应当按批次写入剩余样本，实际 %+v", sink.batches)
+	}
+	if got := testutil.ToFloat64(metrics.Written.WithLabelValues("kpi")); got != 3 {
+		t.Fatalf("成功写回的样本数应为 3，实际 %v", got)
+	}
+}
+
+func TestWriterDropsRejectedBatches(t *testing.T) {
+	metrics := NewMetrics()
+	sink := &fakeSink{reject: true}
+	w, err := newWriter(config.SinkConfig{Name: "kpi", Metrics: []string{"a"}, BatchSize: 2, MaxPending: 10}, sink, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		w.Add(Sample{Metric: "a", Value: float64(i)})
+	}
+	w.flush(true)
+	if len(w.pending) != 0 {
+		t.Fatalf("被拒绝的批次不应保留重试，实际 %d 个待写样本", len(w.pending))
+	}
+	if got := testutil.ToFloat64(metrics.Dropped.WithLabelValues("kpi")); got != 3 {
+		t.Fatalf("被拒绝的样本应计入丢弃数，实际 %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.Failures.WithLabelValues("kpi")); got != 2 {
+		t.Fatalf("每个被拒绝的批次都应计为失败，实际 %v", got)
+	}
+}
+
+// fakeIoTDB 记录写入 IoTDB 的记录。
+type fakeIoTDB struct {
+	records []datasource.IoTDBRecord
+	err     error
+	closed  bool
+}
+
+func (f *fakeIoTDB) InsertRecords(records []datasource.IoTDBRecord) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.records = append(f.records, records...)
+	return nil
+}
+
+func (f *fakeIoTDB) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestIoTDBSinkMapsSamples(t *testing.T) {
+	fake := &fakeIoTDB{}
+	dials := 0
+	sink := newIoTDBSink(config.IoTDBSinkConfig{
+		Device:  "root.kpi.sql2metrics",
+		Targets: map[string]config.IoTDBSinkTarget{"oee": {Device: "root.kpi.line1", Measurement: "oee_ratio"}},
+	}, config.IoTDBConfig{})
+	sink.dial = func(config.IoTDBConfig) (iotdbWriter, error) {
+		dials++
+		return fake, nil
+	}
+	ts := time.UnixMilli(1700000000123)
+	err := sink.Write(context.Background(), []Sample{
+		{Metric: "oee", Value: 0.85, Time: ts},
+		{Metric: "queue_depth", Series: map[string]string{"queue": "orders-eu", "host": "mq1"}, Value: 7, Time: ts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []datasource.IoTDBRecord{
+		{Device: "root.kpi.line1", Measurement: "oee_ratio", Value: 0.85, Timestamp: 1700000000123},
+		{Device: "root.kpi.sql2metrics.mq1.orders_eu", Measurement: "queue_depth", Value: 7, Timestamp: 1700000000123},
+	}
+	for i, r := range want {
+		if fake.records[i] != r {
+			t.Fatalf("第 %d 条记录应为 %+v，实际 %+v", i, r, fake.records[i])
+		}
+	}
+
+	fake.err = errors.New("broken pipe")
+	if err := sink.Write(context.Background(), []Sample{{Metric: "oee", Time: ts}}); err == nil || !fake.closed {
+		t.Fatalf("写入失败时应当返回错误并关闭会话")
+	}
+	fake.err = nil
+	if err := sink.Write(context.Background(), []Sample{{Metric: "oee", Time: ts}}); err != nil || dials != 2 {
+		t.Fatalf("写入失败后应当重新连接，err=%v dials=%d", err, dials)
+	}
+}
+
+func TestInfluxDBSinkWritesLineProtocol(t *testing.T) {
+	type request struct {
+		path, query, auth, body string
+	}
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), string(body)}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink, err := newInfluxDBSink(config.InfluxDBSinkConfig{
+		URL: srv.URL, Org: "ems", Bucket: "kpi", Token: "secret",
+		Tags: map[string]string{"site": "plant 1", "region": "default"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.UnixMilli(1700000000123)
+	err = sink.Write(context.Background(), []Sample{
+		{Metric: "oee", Labels: map[string]string{"region": "east"}, Series: map[string]string{"line": "a,b"}, Value: 0.85, Time: ts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.path != "/api/v2/write" || !strings.Contains(req.query, "bucket=kpi") || !strings.Contains(req.query, "precision=ms") {
+		t.Fatalf("v2 写入地址不正确: %s?%s", req.path, req.query)
+	}
+	if req.auth != "Token secret" {
+		t.Fatalf("v2 应当使用 Token 认证，实际 %q", req.auth)
+	}
+	if want := "oee,line=a\\,b,region=east,site=plant\\ 1 value=0.85 1700000000123\n"; req.body != want {
+		t.Fatalf("行协议应为 %q，实际 %q", want, req.body)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	v1, err := newInfluxDBSink(config.InfluxDBSinkConfig{URL: failing.URL, Database: "kpi", Precision: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(v1.endpoint, "/write?db=kpi&precision=s") {
+		t.Fatalf("v1 写入地址不正确: %s", v1.endpoint)
+	}
+	var permErr *permanentError
+	if err := v1.Write(context.Background(), []Sample{{Metric: "oee", Value: 1, Time: ts}}); !errors.As(err, &permErr) {
+		t.Fatalf("InfluxDB 返回 4xx 时应当返回不再重试的错误，实际 %v", err)
+	}
+
+	// 限流与服务端错误可以重试
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		retryable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		sink, err := newInfluxDBSink(config.InfluxDBSinkConfig{URL: retryable.URL, Database: "kpi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sink.Write(context.Background(), []Sample{{Metric: "oee", Value: 1, Time: ts}})
+		retryable.Close()
+		if err == nil || errors.As(err, &permErr) {
+			t.Fatalf("InfluxDB 返回 HTTP %d 时应当返回可重试的错误，实际 %v", status, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/company/ems-devices/internal/config"
+)
+
+// Writer 为写回目标攒批：样本数达到 batch_size 时立即写入，否则按 flush_interval 定期写入。
+// 写入失败的批次放回队首，下次继续重试；待写入样本超过 max_pending 时丢弃最旧的样本。
+type Writer struct {
+	name          string
+	sink          Sink
+	metrics       map[string]bool // 为空时写回全部指标
+	batchSize     int
+	maxPending    int
+	flushInterval time.Duration
+	timeout       time.Duration
+
+	written  prometheus.Counter
+	failures prometheus.Counter
+	dropped  prometheus.Counter
+
+	mu      sync.Mutex
+	pending []Sample
+
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newWriter(cfg config.SinkConfig, sink Sink, metrics *Metrics) (*Writer, error) {
+	w := &Writer{
+		name:          cfg.Name,
+		sink:          sink,
+		batchSize:     cfg.BatchSize,
+		maxPending:    cfg.MaxPending,
+		flushInterval: 10 * time.Second,
+		timeout:       10 * time.Second,
+		written:       metrics.Written.WithLabelValues(cfg.Name),
+		failures:      metrics.Failures.WithLabelValues(cfg.Name),
+		dropped:       metrics.Dropped.WithLabelValues(cfg.Name),
+		notify:        make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if w.batchSize == 0 {
+		w.batchSize = 100
+	}
+	if w.maxPending == 0 {
+		w.maxPending = 10000
+	}
+	if w.maxPending < w.batchSize {
+		w.maxPending = w.batchSize
+	}
+	if len(cfg.Metrics) > 0 {
+		w.metrics = make(map[string]bool, len(cfg.Metrics))
+		for _, name := range cfg.Metrics {
+			w.metrics[name] = true
+		}
+	}
+	var err error
+	if cfg.FlushInterval != "" {
+		if w.flushInterval, err = time.ParseDuration(cfg.FlushInterval); err != nil {
+			return nil, fmt.Errorf("解析 flush_interval 失败: %w", err)
+		}
+	}
+	if cfg.Timeout != "" {
+		if w.timeout, err = time.ParseDuration(cfg.Timeout); err != nil {
+			return nil, fmt.Errorf("解析 timeout 失败: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// Name 返回写回目标名称。
+func (w *Writer) Name() string {
+	return w.name
+}
+
+// Add 将样本加入待写入队列，不阻塞采集；未在 metrics 中列出的指标会被忽略。
+func (w *Writer) Add(sample Sample) {
+	if w.metrics != nil && !w.metrics[sample.Metric] {
+		return
+	}
+	w.mu.Lock()
+	w.pending = append(w.pending, sample)
+	w.trimLocked()
+	full := len(w.pending) >= w.batchSize
+	w.mu.Unlock()
+	if full {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close 写入剩余样本后关闭写回目标。
+func (w *Writer) Close() error {
+	select {
+	case <-w.stop:
+		return nil
+	default:
+	}
+	close(w.stop)
+	<-w.done
+	return w.sink.Close()
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			w.flush(true)
+			return
+		case <-w.notify:
+			w.flush(false)
+		case <-ticker.C:
+			w.flush(true)
+		}
+	}
+}
+
+// flush 按批次写入待写样本，all 为 false 时只写满批；写入失败时停止，剩余样本等待下次重试，
+// 被写回目标拒绝的批次直接丢弃并继续写入后续批次。
+func (w *Writer) flush(all bool) {
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		if n > w.batchSize {
+			n = w.batchSize
+		}
+		if n == 0 || (!all && n < w.batchSize) {
+			w.mu.Unlock()
+			return
+		}
+		batch := w.pending[:n:n]
+		w.pending = append([]Sample(nil), w.pending[n:]...)
+		w.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		err := w.sink.Write(ctx, batch)
+		cancel()
+		var permErr *permanentError
+		if errors.As(err, &permErr) {
+			w.failures.Inc()
+			w.dropped.Add(float64(len(batch)))
+			log.Printf("写回 %s 被拒绝，丢弃 %d 个样本: %v", w.name, len(batch), err)
+			continue
+		}
+		if err != nil {
+			w.failures.Inc()
+			log.Printf("写回 %s 失败，%d 个样本将在下次重试: %v", w.name, len(batch), err)
+			w.mu.Lock()
+			w.pending = append(batch, w.pending...)
+			w.trimLocked()
+			w.mu.Unlock()
+			return
+		}
+		w.written.Add(float64(len(batch)))
+	}
+}
+
+// trimLocked 丢弃超过 max_pending 的最旧样本，调用方需持有锁。
+func (w *Writer) trimLocked() {
+	if excess := len(w.pending) - w.maxPending; excess > 0 {
+		w.pending = append([]Sample(nil), w.pending[excess:]...)
+		w.dropped.Add(float64(excess))
+		log.Printf("写回 %s 待写入样本超过上限，丢弃最旧的 %d 个样本", w.name, excess)
+	}
+}
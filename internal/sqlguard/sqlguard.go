@@ -0,0 +1,188 @@
+// Package sqlguard 对指标中的 SQL 做只读校验，避免误执行写入或锁表语句。
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// token 表示去除注释与字面量后的一个关键字及其括号深度。
+type token struct {
+	word  string
+	depth int
+	call  bool // 紧跟左括号，即函数调用
+}
+
+// CheckReadOnly 检查语句是否为单条只读查询（SELECT/SHOW，或以 WITH 开头的 SELECT）。
+func CheckReadOnly(stmt string) error {
+	tokens, err := tokenize(stmt)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return errors.New("SQL 语句不能为空")
+	}
+
+	first := tokens[0].word
+	switch first {
+	case "SELECT", "SHOW":
+	case "WITH":
+		// CTE 之后的第一个顶层关键字才是真正执行的语句
+		if main := topLevelStatement(tokens[1:]); main != "SELECT" {
+			return fmt.Errorf("WITH 语句仅允许用于 SELECT 查询，实际为 %s", main)
+		}
+	default:
+		return fmt.Errorf("仅允许 SELECT/SHOW 只读查询，实际为 %s", first)
+	}
+
+	if first == "SHOW" {
+		return nil
+	}
+	// MySQL 不允许在 SELECT 子查询中嵌入写语句，只需拦截写出结果与加锁读取等用法
+	for i, t := range tokens {
+		switch t.word {
+		case "INTO":
+			return errors.New("不允许使用 SELECT ... INTO 写出结果")
+		case "UPDATE":
+			if i > 0 && tokens[i-1].word == "FOR" {
+				return errors.New("不允许使用 FOR UPDATE 加锁读取")
+			}
+		case "SHARE":
+			if i > 0 && (tokens[i-1].word == "FOR" || tokens[i-1].word == "IN") {
+				return errors.New("不允许使用加锁读取")
+			}
+		case "SLEEP", "BENCHMARK", "GET_LOCK":
+			if t.call {
+				return fmt.Errorf("只读查询中不允许调用 %s", t.word)
+			}
+		}
+	}
+	return nil
+}
+
+// IsShowStatement 判断语句是否为 SHOW 语句，EXPLAIN 对 SHOW 不适用。
+func IsShowStatement(stmt string) bool {
+	tokens, err := tokenize(stmt)
+	return err == nil && len(tokens) > 0 && tokens[0].word == "SHOW"
+}
+
+// topLevelStatement 跳过 CTE 定义，返回括号外的第一个语句关键字。
+func topLevelStatement(tokens []token) string {
+	for _, t := range tokens {
+		if t.depth != 0 {
+			continue
+		}
+		switch t.word {
+		case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE":
+			return t.word
+		}
+	}
+	return "未知语句"
+}
+
+// tokenize 提取语句中的关键字，同时拒绝多语句与可执行注释。
+func tokenize(stmt string) ([]token, error) {
+	var tokens []token
+	depth := 0
+	ended := false
+
+	for i := 0; i < len(stmt); i++ {
+		ch := stmt[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := skipQuoted(stmt, i)
+			if end < 0 {
+				return nil, errors.New("SQL 语句中存在未闭合的引号")
+			}
+			i = end
+		case ch == '-' && isLineComment(stmt[i:]), ch == '#':
+			for i < len(stmt) && stmt[i] != '\n' {
+				i++
+			}
+		case ch == '/' && strings.HasPrefix(stmt[i:], "/*"):
+			if strings.HasPrefix(stmt[i:], "/*!") {
+				return nil, errors.New("不允许使用可执行注释")
+			}
+			end := strings.Index(stmt[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("SQL 语句中存在未闭合的注释")
+			}
+			i += end + 3
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == ';':
+			ended = true
+		case isWordChar(ch):
+			start := i
+			for i < len(stmt) && isWordChar(stmt[i]) {
+				i++
+			}
+			if ended {
+				return nil, errors.New("不允许在一个指标中执行多条 SQL 语句")
+			}
+			next := i
+			for next < len(stmt) && isSpace(stmt[next]) {
+				next++
+			}
+			tokens = append(tokens, token{
+				word:  strings.ToUpper(stmt[start:i]),
+				depth: depth,
+				call:  next < len(stmt) && stmt[next] == '(',
+			})
+			i--
+		default:
+			if ended && !isSpace(ch) {
+				return nil, errors.New("不允许在一个指标中执行多条 SQL 语句")
+			}
+		}
+	}
+
+	// 兼容以括号包裹的查询，例如 (SELECT 1)
+	if len(tokens) > 0 && tokens[0].depth > 0 {
+		base := tokens[0].depth
+		for i := range tokens {
+			tokens[i].depth -= base
+		}
+	}
+	return tokens, nil
+}
+
+// skipQuoted 返回从 start 开始的引号字面量的结束位置，未闭合时返回 -1。
+func skipQuoted(stmt string, start int) int {
+	quote := stmt[start]
+	for i := start + 1; i < len(stmt); i++ {
+		switch stmt[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			// 连续两个引号表示转义
+			if i+1 < len(stmt) && stmt[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// isLineComment 判断是否为 MySQL 行注释，"--" 后必须跟空白字符或位于行尾。
+func isLineComment(s string) bool {
+	if !strings.HasPrefix(s, "--") {
+		return false
+	}
+	return len(s) == 2 || isSpace(s[2])
+}
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || ch == '$' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch >= 0x80
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
@@ -0,0 +1,51 @@
+package sqlguard
+
+import "testing"
+
+func TestCheckReadOnly(t *testing.T) {
+	allowed := []string{
+		"SELECT COUNT(1) FROM station_device",
+		"select count(*) from t where note = 'DELETE FROM t; --'",
+		"  -- 统计设备\nSELECT COUNT(1) FROM t;",
+		"SHOW GLOBAL STATUS LIKE 'Threads_connected'",
+		"WITH recent AS (SELECT id FROM t WHERE ts > NOW() - INTERVAL 1 DAY) SELECT COUNT(*) FROM recent",
+		"(SELECT 1)",
+		"SELECT AVG(`load`) FROM inverter /* 逆变器负载 */",
+		"SELECT 1--1",
+	}
+	for _, stmt := range allowed {
+		if err := CheckReadOnly(stmt); err != nil {
+			t.Errorf("语句应当被允许: %q, err=%v", stmt, err)
+		}
+	}
+
+	rejected := []string{
+		"",
+		"DELETE FROM station_device",
+		"UPDATE t SET a = 1",
+		"INSERT INTO t VALUES (1)",
+		"SELECT 1; DROP TABLE t",
+		"SELECT 1--1; DROP TABLE t",
+		"WITH x AS (SELECT 1) DELETE FROM t",
+		"SELECT * FROM t FOR UPDATE",
+		"SELECT * FROM t LOCK IN SHARE MODE",
+		"SELECT * FROM t INTO OUTFILE '/tmp/x'",
+		"SELECT SLEEP(100)",
+		"SELECT /*! 1; DROP TABLE t */ 1",
+		"SELECT 'unterminated",
+	}
+	for _, stmt := range rejected {
+		if err := CheckReadOnly(stmt); err == nil {
+			t.Errorf("语句应当被拒绝: %q", stmt)
+		}
+	}
+}
+
+func TestIsShowStatement(t *testing.T) {
+	if !IsShowStatement("/* status */ show status") {
+		t.Fatalf("应识别为 SHOW 语句")
+	}
+	if IsShowStatement("SELECT 1") {
+		t.Fatalf("SELECT 不应识别为 SHOW 语句")
+	}
+}
@@ -0,0 +1,359 @@
+// Package synthetic 根据查询描述生成合成数值，用于演示、看板调试与告警联调。
+//
+// 查询格式为 <生成器>?<参数>，例如：
+//   - constant?value=42
+//   - sine?offset=50&amplitude=10&period=1h
+//   - sawtooth?min=0&max=100&period=10m
+//   - step?values=10,80,10&period=5m&loop=false
+//   - random_walk?start=50&step=2&min=0&max=100
+//   - replay?file=/data/load.csv&speed=60
+//
+// 所有生成器都支持 noise（叠加正态噪声的标准差）、dropout（本次采集返回缺失的概率）与 seed（固定随机种子）。
+package synthetic
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDropout 表示按 dropout 概率模拟的数据缺失。
+var ErrDropout = errors.New("模拟数据缺失（dropout）")
+
+// spec 是解析后的生成器描述。
+type spec struct {
+	kind      string
+	value     float64 // constant
+	offset    float64 // sine
+	amplitude float64
+	period    time.Duration // sine/sawtooth/step
+	phase     time.Duration
+	min, max  float64 // sawtooth；random_walk 的可选边界
+	bounded   bool
+	values    []float64 // step
+	loop      bool      // step/replay
+	start     float64   // random_walk
+	step      float64
+	file      string // replay
+	speed     float64
+	noise     float64
+	dropout   float64
+	seed      int64
+}
+
+// point 是回放文件中的一个样本。
+type point struct {
+	offset time.Duration // 相对首个样本的时间
+	value  float64
+}
+
+// Generator 按查询描述生成数值，并保存随机游走当前值、回放起点等状态。非并发安全。
+type Generator struct {
+	query   string
+	spec    spec
+	rng     *rand.Rand
+	started time.Time
+	current float64
+	points  []point
+	now     func() time.Time
+}
+
+// Validate 检查查询语法，不读取回放文件。
+func Validate(query string) error {
+	_, err := parse(query)
+	return err
+}
+
+// ReplayFile 返回 replay 查询引用的回放文件，其他查询或无效查询返回空字符串。
+func ReplayFile(query string) string {
+	s, err := parse(query)
+	if err != nil || s.kind != "replay" {
+		return ""
+	}
+	return s.file
+}
+
+// New 解析查询并创建生成器，replay 生成器会在此时通过 readFile 加载回放文件，
+// 文件访问策略与大小上限由 readFile 负责。
+func New(query string, readFile func(path string) ([]byte, error)) (*Generator, error) {
+	s, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	g := &Generator{query: query, spec: s, current: s.start, now: time.Now}
+	seed := s.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	g.rng = rand.New(rand.NewSource(seed))
+	if s.kind == "replay" {
+		data, err := readFile(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("读取回放文件失败: %w", err)
+		}
+		if g.points, err = loadReplay(data); err != nil {
+			return nil, err
+		}
+	}
+	g.started = g.now()
+	return g, nil
+}
+
+// Query 返回创建生成器的查询。
+func (g *Generator) Query() string {
+	return g.query
+}
+
+// Next 生成下一个数值；按 dropout 概率返回 ErrDropout。
+func (g *Generator) Next() (float64, error) {
+	s := g.spec
+	now := g.now()
+	elapsed := now.Sub(g.started)
+
+	var value float64
+	switch s.kind {
+	case "constant":
+		value = s.value
+	case "sine":
+		// 以墙上时间为基准，多实例或重启后相位一致
+		t := now.Add(s.phase)
+		value = s.offset + s.amplitude*math.Sin(2*math.Pi*cycleFraction(t, s.period))
+	case "sawtooth":
+		value = s.min + (s.max-s.min)*cycleFraction(now, s.period)
+	case "step":
+		idx := int(elapsed / s.period)
+		if s.loop {
+			idx %= len(s.values)
+		} else if idx >= len(s.values) {
+			idx = len(s.values) - 1
+		}
+		value = s.values[idx]
+	case "random_walk":
+		g.current += s.step * (2*g.rng.Float64() - 1)
+		if s.bounded {
+			g.current = math.Max(s.min, math.Min(s.max, g.current))
+		}
+		value = g.current
+	case "replay":
+		value = g.replayValue(time.Duration(float64(elapsed) * s.speed))
+	}
+
+	if s.dropout > 0 && g.rng.Float64() < s.dropout {
+		return 0, ErrDropout
+	}
+	if s.noise > 0 {
+		value += g.rng.NormFloat64() * s.noise
+	}
+	return value, nil
+}
+
+// replayValue 返回回放位置处最近一个不晚于该位置的样本值，loop 时循环回放，否则停留在最后一个样本。
+func (g *Generator) replayValue(position time.Duration) float64 {
+	span := g.points[len(g.points)-1].offset
+	if position > span {
+		if !g.spec.loop || span == 0 {
+			return g.points[len(g.points)-1].value
+		}
+		position %= span
+	}
+	i := sort.Search(len(g.points), func(i int) bool { return g.points[i].offset > position })
+	return g.points[i-1].value
+}
+
+// cycleFraction 返回 t 在周期内所处的位置，取值 [0, 1)。
+func cycleFraction(t time.Time, period time.Duration) float64 {
+	return float64(t.UnixNano()%int64(period)) / float64(period)
+}
+
+func parse(query string) (spec, error) {
+	kind, rawParams, _ := strings.Cut(strings.TrimSpace(query), "?")
+	params, err := url.ParseQuery(rawParams)
+	if err != nil {
+		return spec{}, fmt.Errorf("解析合成数据参数失败: %w", err)
+	}
+
+	s := spec{kind: kind, amplitude: 1, period: time.Hour, max: 100, step: 1, speed: 1, loop: true}
+	allowed := map[string][]string{
+		"constant":    {"value"},
+		"sine":        {"offset", "amplitude", "period", "phase"},
+		"sawtooth":    {"min", "max", "period"},
+		"step":        {"values", "period", "loop"},
+		"random_walk": {"start", "step", "min", "max"},
+		"replay":      {"file", "speed", "loop"},
+	}
+	names, ok := allowed[kind]
+	if !ok {
+		return spec{}, fmt.Errorf("不支持的合成数据生成器: %q，可选 constant、sine、sawtooth、step、random_walk、replay", kind)
+	}
+	names = append(names, "noise", "dropout", "seed")
+
+	for key := range params {
+		if !contains(names, key) {
+			return spec{}, fmt.Errorf("生成器 %s 不支持参数 %s", kind, key)
+		}
+		value := params.Get(key)
+		switch key {
+		case "period", "phase":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return spec{}, fmt.Errorf("参数 %s 无效: %w", key, err)
+			}
+			if key == "period" {
+				if d <= 0 {
+					return spec{}, errors.New("period 必须大于 0")
+				}
+				s.period = d
+			} else {
+				s.phase = d
+			}
+		case "values":
+			for _, item := range strings.Split(value, ",") {
+				v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+				if err != nil {
+					return spec{}, fmt.Errorf("values 中的 %q 不是数值", item)
+				}
+				s.values = append(s.values, v)
+			}
+		case "loop":
+			if s.loop, err = strconv.ParseBool(value); err != nil {
+				return spec{}, fmt.Errorf("loop 只能为 true 或 false，实际为 %s", value)
+			}
+		case "file":
+			s.file = value
+		case "seed":
+			if s.seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return spec{}, fmt.Errorf("seed 必须为整数，实际为 %s", value)
+			}
+		default:
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return spec{}, fmt.Errorf("参数 %s 必须为数值，实际为 %s", key, value)
+			}
+			switch key {
+			case "value":
+				s.value = v
+			case "offset":
+				s.offset = v
+			case "amplitude":
+				s.amplitude = v
+			case "min":
+				s.min = v
+			case "max":
+				s.max = v
+			case "start":
+				s.start = v
+			case "step":
+				s.step = v
+			case "speed":
+				s.speed = v
+			case "noise":
+				s.noise = v
+			case "dropout":
+				s.dropout = v
+			}
+		}
+	}
+
+	switch kind {
+	case "sawtooth":
+		if s.min >= s.max {
+			return spec{}, errors.New("min 必须小于 max")
+		}
+	case "step":
+		if len(s.values) == 0 {
+			return spec{}, errors.New("step 生成器需要 values 参数")
+		}
+	case "random_walk":
+		_, hasMin := params["min"]
+		_, hasMax := params["max"]
+		if hasMin != hasMax {
+			return spec{}, errors.New("random_walk 的 min 与 max 必须同时配置")
+		}
+		s.bounded = hasMin
+		if s.bounded && (s.min > s.max || s.start < s.min || s.start > s.max) {
+			return spec{}, errors.New("random_walk 需满足 min <= start <= max")
+		}
+		if s.step < 0 {
+			return spec{}, errors.New("step 不能为负数")
+		}
+	case "replay":
+		if s.file == "" {
+			return spec{}, errors.New("replay 生成器需要 file 参数")
+		}
+		if s.speed <= 0 {
+			return spec{}, errors.New("speed 必须大于 0")
+		}
+	}
+	if s.noise < 0 {
+		return spec{}, errors.New("noise 不能为负数")
+	}
+	if s.dropout < 0 || s.dropout > 1 {
+		return spec{}, errors.New("dropout 必须位于 0 到 1 之间")
+	}
+	return s, nil
+}
+
+// loadReplay 读取 "时间戳,数值" 两列的 CSV，时间戳可为 RFC3339 或 Unix 秒，首行可为表头。
+func loadReplay(data []byte) ([]point, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析回放文件失败: %w", err)
+	}
+	type sample struct {
+		ts    time.Time
+		value float64
+	}
+	var samples []sample
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("回放文件第 %d 行少于两列", i+1)
+		}
+		ts, tsErr := parseTimestamp(strings.TrimSpace(record[0]))
+		value, valueErr := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if tsErr != nil || valueErr != nil {
+			if i == 0 {
+				continue // 表头
+			}
+			return nil, fmt.Errorf("回放文件第 %d 行格式无效", i+1)
+		}
+		samples = append(samples, sample{ts: ts, value: value})
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("回放文件没有数据")
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].ts.Before(samples[j].ts) })
+
+	points := make([]point, len(samples))
+	for i, s := range samples {
+		points[i] = point{offset: s.ts.Sub(samples[0].ts), value: s.value}
+	}
+	return points, nil
+}
+
+func parseTimestamp(raw string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, raw); err == nil {
+		return ts, nil
+	}
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无法解析时间戳 %q", raw)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+func contains(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
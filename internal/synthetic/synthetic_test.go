@@ -0,0 +1,120 @@
+package synthetic
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newAt 创建生成器并将时钟固定在 start，返回推进时钟的函数。
+func newAt(t *testing.T, query string, start time.Time) (*Generator, func(time.Duration)) {
+	t.Helper()
+	g, err := New(query, os.ReadFile)
+	if err != nil {
+		t.Fatalf("创建生成器 %q 失败: %v", query, err)
+	}
+	now := start
+	g.now = func() time.Time { return now }
+	g.started = start
+	return g, func(d time.Duration) { now = now.Add(d) }
+}
+
+func next(t *testing.T, g *Generator) float64 {
+	t.Helper()
+	v, err := g.Next()
+	if err != nil {
+		t.Fatalf("生成数值失败: %v", err)
+	}
+	return v
+}
+
+func TestGenerators(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	g, _ := newAt(t, "constant?value=42", start)
+	if v := next(t, g); v != 42 {
+		t.Fatalf("constant 期望 42，实际 %v", v)
+	}
+
+	g, advance := newAt(t, "sine?offset=50&amplitude=10&period=4m", start)
+	advance(time.Minute)
+	if v := next(t, g); math.Abs(v-60) > 1e-9 {
+		t.Fatalf("sine 四分之一周期处期望 60，实际 %v", v)
+	}
+
+	g, advance = newAt(t, "sawtooth?min=0&max=100&period=10m", start)
+	advance(15 * time.Minute)
+	if v := next(t, g); v != 50 {
+		t.Fatalf("sawtooth 半个周期处期望 50，实际 %v", v)
+	}
+
+	g, advance = newAt(t, "step?values=10,80&period=5m&loop=false", start)
+	got := []float64{next(t, g)}
+	advance(5 * time.Minute)
+	got = append(got, next(t, g))
+	advance(time.Hour)
+	got = append(got, next(t, g))
+	if got[0] != 10 || got[1] != 80 || got[2] != 80 {
+		t.Fatalf("step 不循环时期望 [10 80 80]，实际 %v", got)
+	}
+
+	g, _ = newAt(t, "random_walk?start=50&step=5&min=45&max=55&seed=1", start)
+	for i := 0; i < 100; i++ {
+		if v := next(t, g); v < 45 || v > 55 {
+			t.Fatalf("random_walk 超出边界: %v", v)
+		}
+	}
+}
+
+func TestReplayAndDropout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "load.csv")
+	data := "timestamp,value\n2024-05-01T00:00:00Z,1\n2024-05-01T00:10:00Z,2\n2024-05-01T00:20:00Z,3\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	g, advance := newAt(t, "replay?speed=60&file="+path, time.Unix(0, 0))
+	var got []float64
+	for i := 0; i < 4; i++ {
+		got = append(got, next(t, g))
+		advance(10 * time.Second) // 60 倍速下相当于 10 分钟
+	}
+	if got[0] != 1 || got[1] != 2 || got[2] != 3 || got[3] != 2 {
+		t.Fatalf("循环回放期望 [1 2 3 2]，实际 %v", got)
+	}
+
+	g, _ = newAt(t, "constant?value=1&dropout=1", time.Unix(0, 0))
+	if _, err := g.Next(); !errors.Is(err, ErrDropout) {
+		t.Fatalf("dropout=1 时应当返回 ErrDropout，实际 %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []string{
+		"square?period=1m",
+		"constant?value=abc",
+		"sine?period=0s",
+		"step?period=1m",
+		"sawtooth?min=10&max=5",
+		"random_walk?min=0",
+		"replay?speed=2",
+		"constant?dropout=2",
+		"constant?amplitude=1",
+	}
+	for _, query := range invalid {
+		if err := Validate(query); err == nil {
+			t.Fatalf("查询 %q 应当校验失败", query)
+		}
+	}
+	if err := Validate("replay?file=/not/exist.csv&loop=false"); err != nil {
+		t.Fatalf("Validate 不应读取回放文件: %v", err)
+	}
+	if got := ReplayFile("replay?file=/data/load.csv&speed=60"); got != "/data/load.csv" {
+		t.Fatalf("ReplayFile 应当返回回放文件路径，实际 %q", got)
+	}
+	if got := ReplayFile("constant?value=1"); got != "" {
+		t.Fatalf("非 replay 查询不应返回文件，实际 %q", got)
+	}
+}
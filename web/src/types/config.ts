@@ -1,5 +1,7 @@
 export interface ScheduleConfig {
   interval: string
+  scrape_min_interval?: string
+  scrape_timeout?: string
 }
 
 export interface PrometheusConfig {
@@ -14,6 +16,54 @@ export interface MySQLConfig {
   password: string
   database: string
   params?: Record<string, string>
+  tls?: TLSConfig
+  ssh_tunnel?: SSHTunnelConfig
+  query_guard?: QueryGuardConfig
+  max_open_conns?: number
+  max_idle_conns?: number
+  conn_max_lifetime?: string
+  conn_max_idle_time?: string
+  retry?: RetryConfig
+}
+
+export interface RetryConfig {
+  max_attempts?: number
+  backoff?: string
+  max_backoff?: string
+  jitter?: number
+  retry_on?: Array<'connection' | 'timeout' | 'server' | 'all'>
+}
+
+export interface QueryGuardConfig {
+  max_estimated_rows?: number
+  deny_full_scan?: boolean
+}
+
+export interface SSHTunnelConfig {
+  host: string
+  port?: number
+  user: string
+  password?: string
+  private_key_file?: string
+  passphrase?: string
+  known_hosts_file?: string
+  insecure_ignore_host_key?: boolean
+  timeout?: string
+}
+
+export interface TLSConfig {
+  enabled?: boolean
+  skip_verify?: boolean
+  ca_file?: string
+  cert_file?: string
+  key_file?: string
+  server_name?: string
+}
+
+export interface ProxyConfig {
+  url?: string
+  username?: string
+  password?: string
 }
 
 export interface IoTDBConfig {
@@ -26,6 +76,8 @@ export interface IoTDBConfig {
   enable_tls: boolean
   enable_zstd: boolean
   session_pool?: number
+  ssh_tunnel?: SSHTunnelConfig
+  retry?: RetryConfig
 }
 
 export interface RedisConfig {
@@ -36,33 +88,175 @@ export interface RedisConfig {
   db?: number
   enable_tls?: boolean
   skip_tls_verify?: boolean
+  tls?: TLSConfig
+  ssh_tunnel?: SSHTunnelConfig
+  pool_size?: number
+  min_idle_conns?: number
+  conn_max_idle_time?: string
+  retry?: RetryConfig
+}
+
+export interface ModbusConfig {
+  host: string
+  port?: number
+  unit_id: number
+  timeout?: string
+  byte_order?: 'big' | 'little'
+  word_order?: 'big' | 'little'
+  retry?: RetryConfig
+}
+
+export interface MQTTConfig {
+  broker: string
+  client_id?: string
+  username?: string
+  password?: string
+  qos?: 0 | 1 | 2
+  keep_alive?: string
+  connect_timeout?: string
+  tls?: TLSConfig
+  retry?: RetryConfig
+}
+
+export interface SNMPv3Config {
+  username: string
+  security_level?: 'noAuthNoPriv' | 'authNoPriv' | 'authPriv'
+  auth_protocol?: 'MD5' | 'SHA' | 'SHA224' | 'SHA256' | 'SHA384' | 'SHA512'
+  auth_passphrase?: string
+  priv_protocol?: 'DES' | 'AES' | 'AES192' | 'AES256' | 'AES192C' | 'AES256C'
+  priv_passphrase?: string
+  context_name?: string
+}
+
+export interface SNMPConfig {
+  host: string
+  port?: number
+  version?: '2c' | '3'
+  community?: string
+  timeout?: string
+  retries?: number
+  v3?: SNMPv3Config
+  retry?: RetryConfig
+}
+
+export interface PrometheusSourceConfig {
+  url: string
+  timeout?: string
+  headers?: Record<string, string>
+  bearer_token?: string
+  username?: string
+  password?: string
+  tls?: TLSConfig
+  proxy?: ProxyConfig
+  retry?: RetryConfig
+}
+
+export interface MongoDBConfig {
+  uri: string
+  database?: string
+  username?: string
+  password?: string
+  auth_source?: string
+  read_preference?: 'primary' | 'primaryPreferred' | 'secondary' | 'secondaryPreferred' | 'nearest'
+  max_time_ms?: number
+  connect_timeout?: string
+  tls?: TLSConfig
+  retry?: RetryConfig
+}
+
+export interface ElasticsearchConfig {
+  url: string
+  username?: string
+  password?: string
+  api_key?: string
+  headers?: Record<string, string>
+  timeout?: string
+  tls?: TLSConfig
+  proxy?: ProxyConfig
+  retry?: RetryConfig
+}
+
+export interface SQLConfig {
+  driver: 'sqlite' | 'sqlserver' | 'postgres' | 'tidb' | string
+  dsn: string
+  max_open_conns?: number
+  max_idle_conns?: number
+  conn_max_lifetime?: string
+  conn_max_idle_time?: string
+  retry?: RetryConfig
+}
+
+export interface OutputParseConfig {
+  format?: 'text' | 'json' | 'csv'
+  regex?: string
+  delimiter?: string
+  no_header?: boolean
+  match?: Record<string, string>
+  row?: 'first' | 'last'
+}
+
+export interface FileSourceConfig {
+  allowed_paths?: string[]
+  allowed_dirs?: string[]
+}
+
+export interface CommandSourceConfig {
+  allowed_commands?: string[]
+  work_dir?: string
+  env?: Record<string, string>
+  inherit_env?: boolean
+  timeout?: string
+  max_timeout?: string
+  max_output_bytes?: number
+}
+
+export interface PluginConfig {
+  command: string
+  args?: string[]
+  env?: Record<string, string>
+  config?: Record<string, unknown>
+  timeout?: string
+  restart_backoff?: string
+  max_restart_backoff?: string
+  retry?: RetryConfig
 }
 
 export interface MetricSpec {
   name: string
   help: string
   type: 'gauge' | 'counter' | 'histogram' | 'summary'
-  source: 'mysql' | 'iotdb' | 'redis' | 'restapi'
+  source: 'mysql' | 'iotdb' | 'redis' | 'restapi' | 'modbus' | 'mqtt' | 'snmp' | 'prometheus' | 'mongodb' | 'elasticsearch' | 'file' | 'command' | 'synthetic' | 'sql' | string // 其余为 plugins 中声明的插件名
   query: string
   labels?: Record<string, string>
   result_field?: string
   connection?: string
+  connections?: string[]
   buckets?: number[]
   objectives?: Record<number, number>
   enabled?: boolean
+  retry?: RetryConfig
+  mode?: 'interval' | 'on_message' | 'on_scrape'
+  groups?: string[]
+  series_selector?: Record<string, string>
+  series_labels?: string[]
+  parse?: OutputParseConfig
+  max_age?: string
+  args?: string[]
+  timeout?: string
+  watermark?: WatermarkConfig
+}
+
+export interface WatermarkConfig {
+  initial?: string
 }
 
 export interface RestAPIConfig {
   base_url: string
   timeout?: string
   headers?: Record<string, string>
-  tls?: {
-    skip_verify?: boolean
-  }
-  retry?: {
-    max_attempts?: number
-    backoff?: string
-  }
+  tls?: TLSConfig
+  retry?: RetryConfig
+  proxy?: ProxyConfig
 }
 
 // 内置通知服务配置
@@ -102,11 +296,148 @@ export interface AlertmanagerConfig {
   url: string
 }
 
+export interface RemoteWriteConfig {
+  enabled: boolean
+  url: string
+  headers?: Record<string, string>
+  bearer_token?: string
+  username?: string
+  password?: string
+  external_labels?: Record<string, string>
+  timeout?: string
+  batch_size?: number
+  max_retries?: number
+  min_backoff?: string
+  max_backoff?: string
+  buffer_dir?: string
+  buffer_max_bytes?: number
+  tls?: TLSConfig
+}
+
+export interface OTLPConfig {
+  enabled: boolean
+  protocol?: 'grpc' | 'http'
+  endpoint?: string
+  insecure?: boolean
+  headers?: Record<string, string>
+  compression?: 'gzip' | 'none'
+  timeout?: string
+  resource_attributes?: Record<string, string>
+  tls?: TLSConfig
+}
+
+export interface PushgatewayConfig {
+  url: string
+  job?: string
+  grouping_key?: Record<string, string>
+  username?: string
+  password?: string
+  timeout?: string
+  tls?: TLSConfig
+}
+
+export interface IoTDBSinkTarget {
+  device?: string
+  measurement?: string
+}
+
+export interface InfluxDBSinkConfig {
+  url: string
+  org?: string
+  bucket?: string
+  token?: string
+  database?: string
+  username?: string
+  password?: string
+  precision?: 's' | 'ms' | 'us' | 'ns'
+  tags?: Record<string, string>
+  tls?: TLSConfig
+}
+
+export interface SinkConfig {
+  name: string
+  type: 'iotdb' | 'influxdb'
+  metrics?: string[]
+  batch_size?: number
+  flush_interval?: string
+  max_pending?: number
+  timeout?: string
+  iotdb?: {
+    device: string
+    targets?: Record<string, IoTDBSinkTarget>
+  }
+  influxdb?: InfluxDBSinkConfig
+}
+
+export interface MetricGroupConfig {
+  bearer_token?: string
+  username?: string
+  password?: string
+  include_self_metrics?: boolean
+  mode?: 'interval' | 'on_scrape'
+}
+
+export interface ProbeMetric {
+  name: string
+  help: string
+  query: string
+  labels?: Record<string, string>
+}
+
+export interface ProbeModule {
+  source: 'mysql' | 'sql'
+  driver?: string
+  timeout?: string
+  metrics: ProbeMetric[]
+}
+
+export interface ProbeConfig {
+  modules?: Record<string, ProbeModule>
+  allowed_targets?: string[]
+}
+
+export interface DiscoverySecret {
+  username?: string
+  password?: string
+  password_file?: string
+}
+
+export interface FileSDConfig {
+  source: 'mysql' | 'sql'
+  files: string[]
+}
+
+export interface HTTPSDConfig {
+  source: 'mysql' | 'sql'
+  url: string
+  timeout?: string
+  headers?: Record<string, string>
+  bearer_token?: string
+  username?: string
+  password?: string
+  tls?: TLSConfig
+}
+
+export interface ConnectionDiscoveryConfig {
+  refresh_interval?: string
+  secrets?: Record<string, DiscoverySecret>
+  file_sd?: FileSDConfig[]
+  http_sd?: HTTPSDConfig[]
+}
+
 export interface Config {
   schedule: ScheduleConfig
   prometheus: PrometheusConfig
   alertmanager: AlertmanagerConfig
   notifier?: NotifierConfig
+  remote_write?: RemoteWriteConfig
+  otlp?: OTLPConfig
+  pushgateway?: PushgatewayConfig
+  sinks?: SinkConfig[]
+  metric_groups?: Record<string, MetricGroupConfig>
+  probe?: ProbeConfig
+  connection_discovery?: ConnectionDiscoveryConfig
+  watermark_state_file?: string
 
   mysql: MySQLConfig
   mysql_connections: Record<string, MySQLConfig>
@@ -115,6 +446,16 @@ export interface Config {
   redis_connections: Record<string, RedisConfig>
 
   restapi_connections: Record<string, RestAPIConfig>
+  modbus_connections?: Record<string, ModbusConfig>
+  mqtt_connections?: Record<string, MQTTConfig>
+  snmp_connections?: Record<string, SNMPConfig>
+  prometheus_connections?: Record<string, PrometheusSourceConfig>
+  mongodb_connections?: Record<string, MongoDBConfig>
+  elasticsearch_connections?: Record<string, ElasticsearchConfig>
+  sql_connections?: Record<string, SQLConfig>
+  file?: FileSourceConfig
+  command?: CommandSourceConfig
+  plugins?: Record<string, PluginConfig>
 
   iotdb: IoTDBConfig
   metrics: MetricSpec[]